@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultAccount is the stdio mode account used when none is named. Its token
+// and state are stored where they were before named accounts existed.
+const defaultAccount = "default"
+
+// accountNamePattern restricts account names to short identifiers, since
+// they appear in flags, tool arguments and database keys.
+var accountNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// validateAccountName rejects account names that are empty or not simple
+// identifiers such as "work" or "personal".
+func validateAccountName(name string) error {
+	if !accountNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid account %q: use letters, digits, '.', '_' or '-'", name)
+	}
+	return nil
+}
+
+// accountStatePrefix marks state keys of named accounts, keeping them apart
+// from HTTP mode user emails.
+const accountStatePrefix = "account:"
+
+// accountStateKey returns the user key stdio mode stores an account's state
+// (recent and default calendars, snoozed and scheduled emails) under. The
+// default account keeps the historical empty key.
+func accountStateKey(account string) string {
+	if account == defaultAccount {
+		return ""
+	}
+	return accountStatePrefix + account
+}
+
+// accountFromStateKey returns the account a key from accountStateKey belongs
+// to. ok is false for any other key, such as an HTTP mode user's email.
+func accountFromStateKey(key string) (account string, ok bool) {
+	if key == "" {
+		return defaultAccount, true
+	}
+	name, ok := strings.CutPrefix(key, accountStatePrefix)
+	if !ok || name == "" {
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestValidateAccountName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"default", "work", "personal-2", "a.b_c"} {
+		if err := validateAccountName(name); err != nil {
+			t.Errorf("validateAccountName(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "-work", "my account", "../x", "a:b"} {
+		if err := validateAccountName(name); err == nil {
+			t.Errorf("validateAccountName(%q) succeeded", name)
+		}
+	}
+}
+
+func TestAccountStateKey(t *testing.T) {
+	t.Parallel()
+
+	if key := accountStateKey(defaultAccount); key != "" {
+		t.Fatalf("accountStateKey(default) = %q, want the historical empty key", key)
+	}
+	for _, account := range []string{defaultAccount, "work"} {
+		if got, ok := accountFromStateKey(accountStateKey(account)); !ok || got != account {
+			t.Errorf("accountFromStateKey(accountStateKey(%q)) = %q, %v", account, got, ok)
+		}
+	}
+	for _, key := range []string{"a@example.com", accountStatePrefix} {
+		if got, ok := accountFromStateKey(key); ok {
+			t.Errorf("accountFromStateKey(%q) = %q, want ok = false", key, got)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
 	"net"
@@ -11,27 +12,97 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sync"
+	"time"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/calendar/v3"
 	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/tasks/v1"
 )
 
 // OAuth scopes
 var oauthScopes = []string{
 	calendar.CalendarScope,
 	gmail.GmailModifyScope,
+	tasks.TasksScope,
 }
 
 var oauthScopesWithEmail = []string{
 	calendar.CalendarScope,
 	gmail.GmailModifyScope,
+	tasks.TasksScope,
 	"https://www.googleapis.com/auth/userinfo.email",
 }
 
-// defaultCredentialsPath returns the default path for OAuth2 credentials.
+// withGmailFullAccess returns scopes with gmail.modify replaced by the full
+// Gmail scope when enabled. Only the full scope allows permanent deletion.
+func withGmailFullAccess(scopes []string, enabled bool) []string {
+	if !enabled {
+		return scopes
+	}
+	result := make([]string, len(scopes))
+	for i, scope := range scopes {
+		if scope == gmail.GmailModifyScope {
+			scope = gmail.MailGoogleComScope
+		}
+		result[i] = scope
+	}
+	return result
+}
+
+// Scope sets selectable with --scopes.
+const (
+	scopeSetFull     = "full"
+	scopeSetReadonly = "readonly"
+)
+
+// readonlyScopes maps each read-write scope to its read-only counterpart.
+var readonlyScopes = map[string]string{
+	calendar.CalendarScope: calendar.CalendarReadonlyScope,
+	gmail.GmailModifyScope: gmail.GmailReadonlyScope,
+	tasks.TasksScope:       tasks.TasksReadonlyScope,
+}
+
+// validateScopeSet checks a --scopes value and that it can be combined with
+// --gmail-full-access.
+func validateScopeSet(scopeSet string, gmailFullAccess bool) error {
+	switch scopeSet {
+	case "", scopeSetFull:
+		return nil
+	case scopeSetReadonly:
+		if gmailFullAccess {
+			return fmt.Errorf("--gmail-full-access cannot be combined with --scopes=readonly")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown scope set %q (use full or readonly)", scopeSet)
+}
+
+// selectScopes returns scopes adjusted for the --scopes set and
+// --gmail-full-access. It does not modify scopes.
+func selectScopes(scopes []string, scopeSet string, gmailFullAccess bool) []string {
+	if scopeSet != scopeSetReadonly {
+		return withGmailFullAccess(scopes, gmailFullAccess)
+	}
+	result := make([]string, len(scopes))
+	for i, scope := range scopes {
+		if readonly, ok := readonlyScopes[scope]; ok {
+			scope = readonly
+		}
+		result[i] = scope
+	}
+	return result
+}
+
+// defaultCredentialsPath returns the OAuth2 credentials path used when
+// --credentials-file is not given: $MCP_GCAL_CREDENTIALS, else
+// credentials.json in the XDG config directory.
 func defaultCredentialsPath() string {
+	if p := os.Getenv("MCP_GCAL_CREDENTIALS"); p != "" {
+		return p
+	}
 	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
 		return filepath.Join(xdg, "mcp-gcal", "credentials.json")
 	}
@@ -39,12 +110,27 @@ func defaultCredentialsPath() string {
 	return filepath.Join(home, ".config", "mcp-gcal", "credentials.json")
 }
 
+// credentialsNotFoundError reports that the OAuth client credentials file does
+// not exist. Unlike a missing token, this cannot be fixed by authenticating.
+type credentialsNotFoundError struct {
+	path string
+}
+
+func (e *credentialsNotFoundError) Error() string {
+	return fmt.Sprintf("OAuth client credentials file not found at %s\n"+
+		"Download an OAuth client JSON (Desktop app) from Google Cloud Console "+
+		"(APIs & Services > Credentials) and save it to that path, or pass --credentials-file.", e.path)
+}
+
 // loadOAuthConfig reads the OAuth2 client credentials JSON file.
 func loadOAuthConfig(credentialsFile string, scopes []string) (*oauth2.Config, error) {
 	if credentialsFile == "" {
 		credentialsFile = defaultCredentialsPath()
 	}
 	b, err := os.ReadFile(credentialsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, &credentialsNotFoundError{path: credentialsFile}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("unable to read credentials file %s: %w\nDownload it from Google Cloud Console and place it at %s",
 			credentialsFile, err, defaultCredentialsPath())
@@ -56,6 +142,70 @@ func loadOAuthConfig(credentialsFile string, scopes []string) (*oauth2.Config, e
 	return config, nil
 }
 
+// loadFallbackOAuthConfig reads the fallback OAuth2 client credentials used
+// during credential rotation. It returns nil when no fallback is configured.
+func loadFallbackOAuthConfig(credentialsFile string, scopes []string) (*oauth2.Config, error) {
+	if credentialsFile == "" {
+		return nil, nil
+	}
+	config, err := loadOAuthConfig(credentialsFile, scopes)
+	if err != nil {
+		return nil, fmt.Errorf("fallback credentials: %w", err)
+	}
+	return config, nil
+}
+
+// isInvalidClient reports whether err is a token endpoint rejection of the
+// OAuth client itself, as happens when a token was issued to a rotated-out client.
+func isInvalidClient(err error) bool {
+	var re *oauth2.RetrieveError
+	if !errors.As(err, &re) {
+		return false
+	}
+	return re.ErrorCode == "invalid_client" || re.ErrorCode == "unauthorized_client"
+}
+
+// refreshingTokenSource returns a TokenSource for tok and a current token from
+// it. Every refresh, not only this first one, tries the primary client and,
+// if it is rejected with invalid_client and a fallback client is configured,
+// the fallback, so tokens issued under the previous client keep refreshing
+// while credentials are rotated, even in long-lived cached services.
+func refreshingTokenSource(config, fallback *oauth2.Config, tok *oauth2.Token) (oauth2.TokenSource, *oauth2.Token, error) {
+	ts := &fallbackTokenSource{config: config, fallback: fallback, tok: tok}
+	newTok, err := ts.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	return ts, newTok, nil
+}
+
+// fallbackTokenSource is the TokenSource of refreshingTokenSource. It is safe
+// for concurrent use.
+type fallbackTokenSource struct {
+	config, fallback *oauth2.Config
+
+	mu  sync.Mutex
+	tok *oauth2.Token
+}
+
+func (s *fallbackTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok.Valid() {
+		return s.tok, nil
+	}
+	// A fresh source per refresh, so it starts from the current token.
+	newTok, err := s.config.TokenSource(context.Background(), s.tok).Token()
+	if err != nil && s.fallback != nil && isInvalidClient(err) {
+		newTok, err = s.fallback.TokenSource(context.Background(), s.tok).Token()
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.tok = newTok
+	return newTok, nil
+}
+
 // runOAuthFlow starts the browser-based OAuth2 consent flow and returns the token.
 func runOAuthFlow(config *oauth2.Config) (*oauth2.Token, error) {
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
@@ -103,6 +253,8 @@ func runOAuthFlow(config *oauth2.Config) (*oauth2.Token, error) {
 	}()
 
 	authURL := config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
+	// A prompt for the user, not a log line: it must show whatever the log
+	// level and format.
 	fmt.Fprintf(os.Stderr, "Opening browser for authentication...\n")
 	fmt.Fprintf(os.Stderr, "If the browser doesn't open, visit this URL:\n%s\n", authURL)
 	openBrowser(authURL)
@@ -138,53 +290,65 @@ func openBrowser(url string) {
 	_ = cmd.Start()
 }
 
-// getTokenSource loads a token from the single-user DB table and returns a refreshing TokenSource.
-func getTokenSource(config *oauth2.Config, database *DB) (oauth2.TokenSource, error) {
-	tok, err := database.LoadToken()
+// getTokenSource loads a stdio mode account's token from the DB and returns a
+// refreshing TokenSource. fallback may be nil; see refreshingTokenSource.
+func getTokenSource(config, fallback *oauth2.Config, store TokenStore, account string) (oauth2.TokenSource, error) {
+	tok, err := store.LoadToken(account)
 	if err != nil {
 		return nil, err
 	}
 
-	ts := config.TokenSource(context.Background(), tok)
-	newTok, err := ts.Token()
+	ts, newTok, err := refreshingTokenSource(config, fallback, tok)
 	if err != nil {
-		return nil, fmt.Errorf("token expired or invalid; run 'mcp-gcal auth' to re-authenticate: %w", err)
+		authCmd := "mcp-gcal auth"
+		if account != defaultAccount {
+			authCmd += " --account=" + account
+		}
+		return nil, fmt.Errorf("token expired or invalid; run '%s' to re-authenticate: %w", authCmd, err)
 	}
 
 	if newTok.AccessToken != tok.AccessToken {
-		_ = database.SaveToken(newTok)
+		_ = store.SaveToken(account, newTok)
 	}
 
 	return ts, nil
 }
 
 // getUserTokenSourceByEmail loads a per-user token by email and returns a refreshing TokenSource.
-func getUserTokenSourceByEmail(config *oauth2.Config, database *DB, email string) (oauth2.TokenSource, error) {
-	user, err := database.GetUserByEmail(email)
+// fallback may be nil; see refreshingTokenSource.
+func getUserTokenSourceByEmail(config, fallback *oauth2.Config, store TokenStore, email string) (oauth2.TokenSource, error) {
+	tok, err := store.GetUserTokenByEmail(email)
 	if err != nil {
 		return nil, err
 	}
-	if user == nil {
-		return nil, fmt.Errorf("user not found: %s", email)
-	}
-
-	var tok oauth2.Token
-	if err := json.Unmarshal([]byte(user.TokenJSON), &tok); err != nil {
-		return nil, fmt.Errorf("unmarshal token: %w", err)
-	}
 
-	ts := config.TokenSource(context.Background(), &tok)
-	newTok, err := ts.Token()
+	ts, newTok, err := refreshingTokenSource(config, fallback, tok)
 	if err != nil {
 		return nil, fmt.Errorf("token expired; user must re-authenticate: %w", err)
 	}
 
 	if newTok.AccessToken != tok.AccessToken {
-		_ = database.UpdateUserToken(email, newTok)
+		_ = store.UpdateUserToken(email, newTok)
 	}
 	return ts, nil
 }
 
+// primaryCalendarEmail returns the account email for a token source. The ID of a
+// user's primary calendar is their email address, so this works with the
+// calendar scope alone (no userinfo.email scope required). The call is bounded
+// by timeout, as other Google API calls are.
+func primaryCalendarEmail(ctx context.Context, ts oauth2.TokenSource, timeout time.Duration) (string, error) {
+	svc, err := calendar.NewService(ctx, googleClientOption(ts, timeout))
+	if err != nil {
+		return "", fmt.Errorf("create calendar service: %w", err)
+	}
+	entry, err := svc.CalendarList.Get("primary").Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("get primary calendar: %w", err)
+	}
+	return entry.Id, nil
+}
+
 // userInfoResponse represents the Google userinfo API response.
 type userInfoResponse struct {
 	Email string `json:"email"`
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/tasks/v1"
+)
+
+func newTestTokenEndpoint(t *testing.T, handler http.HandlerFunc) *oauth2.Config {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return &oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Endpoint:     oauth2.Endpoint{TokenURL: srv.URL, AuthStyle: oauth2.AuthStyleInParams},
+	}
+}
+
+func TestRefreshingTokenSource_FallbackOnInvalidClient(t *testing.T) {
+	t.Parallel()
+
+	rejected := newTestTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"invalid_client"}`))
+	})
+	accepted := newTestTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"new-access","token_type":"Bearer","expires_in":3600}`))
+	})
+	expired := &oauth2.Token{AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)}
+
+	if _, _, err := refreshingTokenSource(rejected, nil, expired); err == nil {
+		t.Fatal("refreshingTokenSource() without fallback error = nil, want invalid_client")
+	}
+
+	_, tok, err := refreshingTokenSource(rejected, accepted, expired)
+	if err != nil {
+		t.Fatalf("refreshingTokenSource() with fallback error = %v", err)
+	}
+	if tok.AccessToken != "new-access" {
+		t.Fatalf("AccessToken = %q, want new-access", tok.AccessToken)
+	}
+}
+
+func TestRefreshingTokenSource_FallbackOnLaterRefresh(t *testing.T) {
+	t.Parallel()
+
+	// The primary client works for the first refresh and is revoked before
+	// the next, as when credentials are rotated under a cached service.
+	var primaryCalls atomic.Int32
+	primary := newTestTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if primaryCalls.Add(1) > 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		// Expires within oauth2's expiry delta, so the next Token call refreshes.
+		w.Write([]byte(`{"access_token":"primary-access","token_type":"Bearer","expires_in":1}`))
+	})
+	var fallbackRefreshToken string
+	fallback := newTestTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		fallbackRefreshToken = r.PostForm.Get("refresh_token")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"fallback-access","token_type":"Bearer","expires_in":3600}`))
+	})
+	expired := &oauth2.Token{AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)}
+
+	ts, tok, err := refreshingTokenSource(primary, fallback, expired)
+	if err != nil || tok.AccessToken != "primary-access" {
+		t.Fatalf("refreshingTokenSource() = %v, %v, want primary-access", tok, err)
+	}
+	tok, err = ts.Token()
+	if err != nil {
+		t.Fatalf("Token() after the primary client was revoked error = %v", err)
+	}
+	if tok.AccessToken != "fallback-access" || fallbackRefreshToken != "refresh" {
+		t.Fatalf("Token() = %q via refresh token %q, want fallback-access via refresh", tok.AccessToken, fallbackRefreshToken)
+	}
+	if tok, err := ts.Token(); err != nil || tok.AccessToken != "fallback-access" || primaryCalls.Load() != 2 {
+		t.Fatalf("Token() with a valid token = %v, %v after %d primary calls, want it reused", tok, err, primaryCalls.Load())
+	}
+}
+
+func TestRefreshingTokenSource_NoFallbackOnOtherErrors(t *testing.T) {
+	t.Parallel()
+
+	fallbackCalled := false
+	revoked := newTestTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+	})
+	fallback := newTestTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		fallbackCalled = true
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"new-access","token_type":"Bearer","expires_in":3600}`))
+	})
+	expired := &oauth2.Token{AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)}
+
+	if _, _, err := refreshingTokenSource(revoked, fallback, expired); err == nil {
+		t.Fatal("refreshingTokenSource() error = nil, want invalid_grant")
+	}
+	if fallbackCalled {
+		t.Fatal("fallback client used for invalid_grant, want only invalid_client")
+	}
+}
+
+// memoryTokenStore is a TokenStore backed by maps, for auth tests. token is
+// the default account's; other accounts are not supported.
+type memoryTokenStore struct {
+	token *oauth2.Token
+	users map[string]*oauth2.Token
+}
+
+func (m *memoryTokenStore) LoadToken(account string) (*oauth2.Token, error) {
+	if m.token == nil || account != defaultAccount {
+		return nil, fmt.Errorf("no token stored")
+	}
+	return m.token, nil
+}
+
+func (m *memoryTokenStore) SaveToken(account string, token *oauth2.Token) error {
+	if account != defaultAccount {
+		return fmt.Errorf("unsupported account %q", account)
+	}
+	m.token = token
+	return nil
+}
+
+func (m *memoryTokenStore) GetUserTokenByEmail(email string) (*oauth2.Token, error) {
+	tok, ok := m.users[email]
+	if !ok {
+		return nil, fmt.Errorf("user not found: %s", email)
+	}
+	return tok, nil
+}
+
+func (m *memoryTokenStore) UpdateUserToken(email string, token *oauth2.Token) error {
+	m.users[email] = token
+	return nil
+}
+
+func TestGetTokenSource_SavesRefreshedToken(t *testing.T) {
+	t.Parallel()
+
+	config := newTestTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"refreshed","token_type":"Bearer","expires_in":3600}`))
+	})
+	store := &memoryTokenStore{
+		token: &oauth2.Token{AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)},
+	}
+
+	if _, err := getTokenSource(config, nil, store, defaultAccount); err != nil {
+		t.Fatalf("getTokenSource() error = %v", err)
+	}
+	if store.token.AccessToken != "refreshed" {
+		t.Fatalf("stored AccessToken = %q, want refreshed", store.token.AccessToken)
+	}
+}
+
+func TestGetUserTokenSourceByEmail(t *testing.T) {
+	t.Parallel()
+
+	config := newTestTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("token endpoint called for a valid token")
+	})
+	valid := &oauth2.Token{AccessToken: "valid", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}
+	store := &memoryTokenStore{users: map[string]*oauth2.Token{"a@example.com": valid}}
+
+	ts, err := getUserTokenSourceByEmail(config, nil, store, "a@example.com")
+	if err != nil {
+		t.Fatalf("getUserTokenSourceByEmail() error = %v", err)
+	}
+	if tok, _ := ts.Token(); tok.AccessToken != "valid" {
+		t.Fatalf("Token().AccessToken = %q, want valid", tok.AccessToken)
+	}
+	if _, err := getUserTokenSourceByEmail(config, nil, store, "b@example.com"); err == nil {
+		t.Fatal("getUserTokenSourceByEmail(unknown) error = nil, want user not found")
+	}
+}
+
+func TestWithGmailFullAccess(t *testing.T) {
+	t.Parallel()
+
+	if got := withGmailFullAccess(oauthScopes, false); !reflect.DeepEqual(got, oauthScopes) {
+		t.Fatalf("withGmailFullAccess(false) = %v, want %v", got, oauthScopes)
+	}
+	got := withGmailFullAccess(oauthScopesWithEmail, true)
+	want := []string{calendar.CalendarScope, gmail.MailGoogleComScope, tasks.TasksScope, "https://www.googleapis.com/auth/userinfo.email"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("withGmailFullAccess(true) = %v, want %v", got, want)
+	}
+	if oauthScopesWithEmail[1] != gmail.GmailModifyScope {
+		t.Fatal("withGmailFullAccess modified its argument")
+	}
+}
+
+func TestSelectScopes(t *testing.T) {
+	t.Parallel()
+
+	if got := selectScopes(oauthScopes, scopeSetFull, false); !reflect.DeepEqual(got, oauthScopes) {
+		t.Fatalf("selectScopes(full) = %v, want %v", got, oauthScopes)
+	}
+	got := selectScopes(oauthScopesWithEmail, scopeSetReadonly, false)
+	want := []string{calendar.CalendarReadonlyScope, gmail.GmailReadonlyScope, tasks.TasksReadonlyScope, "https://www.googleapis.com/auth/userinfo.email"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("selectScopes(readonly) = %v, want %v", got, want)
+	}
+	if oauthScopesWithEmail[0] != calendar.CalendarScope {
+		t.Fatal("selectScopes modified its argument")
+	}
+
+	tests := []struct {
+		scopeSet        string
+		gmailFullAccess bool
+		wantErr         bool
+	}{
+		{"", false, false},
+		{"full", true, false},
+		{"readonly", false, false},
+		{"readonly", true, true},
+		{"write", false, true},
+	}
+	for _, tt := range tests {
+		if err := validateScopeSet(tt.scopeSet, tt.gmailFullAccess); (err != nil) != tt.wantErr {
+			t.Errorf("validateScopeSet(%q, %v) error = %v, wantErr %v", tt.scopeSet, tt.gmailFullAccess, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCacheEntries bounds the number of keys (users) a ttlCache holds at once.
+const maxCacheEntries = 1024
+
+// primaryCalendarIDTTL is how long a user's resolved primary calendar ID is
+// reused. The ID is the account's email address and practically never changes.
+const primaryCalendarIDTTL = time.Hour
+
+// ttlCache holds values per key for a fixed TTL. A nil cache, or one with a
+// zero TTL, caches nothing.
+type ttlCache[V any] struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]ttlEntry[V]),
+	}
+}
+
+// get returns the value cached for key, if it has not expired.
+func (c *ttlCache[V]) get(key string) (V, bool) {
+	var zero V
+	if c == nil || c.ttl <= 0 {
+		return zero, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	if !c.now().Before(e.expires) {
+		delete(c.entries, key)
+		return zero, false
+	}
+	return e.value, true
+}
+
+// put caches value for key. When the cache is full, expired entries are
+// dropped first, then the entry closest to expiry.
+func (c *ttlCache[V]) put(key string, value V) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxCacheEntries {
+		var oldest string
+		found := false
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+				continue
+			}
+			if !found || e.expires.Before(c.entries[oldest].expires) {
+				oldest, found = k, true
+			}
+		}
+		if len(c.entries) >= maxCacheEntries {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = ttlEntry[V]{value: value, expires: now.Add(c.ttl)}
+}
+
+// invalidate drops the value cached for key.
+func (c *ttlCache[V]) invalidate(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// calendarCaches are the per-user caches calendar services share. In HTTP
+// mode a service is built per request, so the caches live on the server.
+type calendarCaches struct {
+	// lists holds recent ListCalendars results. Several tools list calendars
+	// implicitly, and the list rarely changes within a conversation; it is
+	// invalidated when the user creates or deletes a calendar.
+	lists *ttlCache[[]calendarJSON]
+	// primaryIDs holds the real ID behind the "primary" calendar alias.
+	primaryIDs *ttlCache[string]
+}
+
+// newCalendarCaches creates caches that keep calendar lists for listTTL.
+func newCalendarCaches(listTTL time.Duration) calendarCaches {
+	return calendarCaches{
+		lists:      newTTLCache[[]calendarJSON](listTTL),
+		primaryIDs: newTTLCache[string](primaryCalendarIDTTL),
+	}
+}
+
+// invalidate drops everything cached for key, e.g. after the user
+// re-authenticates.
+func (c calendarCaches) invalidate(key string) {
+	c.lists.invalidate(key)
+	c.primaryIDs.invalidate(key)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTTLCache(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	c := newTTLCache[string](time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.put("alice@example.com", "alice@example.com")
+	if got, ok := c.get("alice@example.com"); !ok || got != "alice@example.com" {
+		t.Fatalf("get() = %q, %v, want cached value", got, ok)
+	}
+	c.invalidate("alice@example.com")
+	if _, ok := c.get("alice@example.com"); ok {
+		t.Fatal("get() after invalidate hit, want miss")
+	}
+
+	c.put("bob@example.com", "bob@example.com")
+	now = now.Add(time.Minute)
+	if _, ok := c.get("bob@example.com"); ok {
+		t.Fatal("get() after TTL hit, want miss")
+	}
+
+	var nilCache *ttlCache[string]
+	nilCache.put("k", "v")
+	if _, ok := nilCache.get("k"); ok {
+		t.Fatal("nil cache get() hit, want miss")
+	}
+	disabled := newTTLCache[string](0)
+	disabled.put("k", "v")
+	if _, ok := disabled.get("k"); ok {
+		t.Fatal("zero-TTL cache get() hit, want miss")
+	}
+}
+
+func TestTTLCache_Bounded(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	c := newTTLCache[int](time.Hour)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < maxCacheEntries; i++ {
+		c.put(strconv.Itoa(i), i)
+		now = now.Add(time.Millisecond)
+	}
+	c.put("new", -1)
+	if len(c.entries) != maxCacheEntries {
+		t.Fatalf("cache holds %d entries, want %d", len(c.entries), maxCacheEntries)
+	}
+	if _, ok := c.get("0"); ok {
+		t.Fatal("oldest entry was not evicted")
+	}
+	if _, ok := c.get("new"); !ok {
+		t.Fatal("new entry missing")
+	}
+}
@@ -2,27 +2,61 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
 	"google.golang.org/api/calendar/v3"
-	"google.golang.org/api/option"
 )
 
 // CalendarService wraps the Google Calendar API.
 type CalendarService struct {
-	svc *calendar.Service
+	svc  *calendar.Service
+	opts Options
+
+	// caches hold results shared across services for the same user, under
+	// cacheKey. The zero value caches nothing.
+	caches   calendarCaches
+	cacheKey string
 }
 
 // NewCalendarService creates a Calendar API client from a token source.
-func NewCalendarService(ctx context.Context, ts oauth2.TokenSource) (*CalendarService, error) {
-	svc, err := calendar.NewService(ctx, option.WithTokenSource(ts))
+func NewCalendarService(ctx context.Context, ts oauth2.TokenSource, opts Options) (*CalendarService, error) {
+	svc, err := calendar.NewService(ctx, googleClientOption(ts, opts.RequestTimeout))
 	if err != nil {
 		return nil, fmt.Errorf("create calendar service: %w", err)
 	}
-	return &CalendarService{svc: svc}, nil
+	return &CalendarService{svc: svc, opts: opts}, nil
+}
+
+// Attendee describes an event attendee supplied as tool input.
+type Attendee struct {
+	Email       string `json:"email"`
+	Optional    bool   `json:"optional,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// toEventAttendees converts attendee input to Calendar API attendees, skipping blank emails.
+func toEventAttendees(attendees []Attendee) []*calendar.EventAttendee {
+	var result []*calendar.EventAttendee
+	for _, a := range attendees {
+		email := strings.TrimSpace(a.Email)
+		if email == "" {
+			continue
+		}
+		result = append(result, &calendar.EventAttendee{
+			Email:       email,
+			Optional:    a.Optional,
+			DisplayName: a.DisplayName,
+		})
+	}
+	return result
 }
 
 // JSON output types
@@ -40,6 +74,47 @@ type eventJSON struct {
 	Organizer   *organizerJSON `json:"organizer,omitempty"`
 	Created     string         `json:"created,omitempty"`
 	Updated     string         `json:"updated,omitempty"`
+	ICalUID     string         `json:"iCalUID,omitempty"`
+	// AnyoneCanAddSelf and Locked are omitted when false, their default.
+	// Locked is read-only: Google sets it on locked event copies.
+	AnyoneCanAddSelf bool   `json:"anyoneCanAddSelf,omitempty"`
+	Locked           bool   `json:"locked,omitempty"`
+	Visibility       string `json:"visibility,omitempty"`
+	EventType        string `json:"eventType,omitempty"`
+	// ColorID is an event color from list-colors; empty means the
+	// calendar's color.
+	ColorID string `json:"colorId,omitempty"`
+	// HangoutLink is the Google Meet join URL; Conference details any
+	// conference, including one still being created.
+	HangoutLink string          `json:"hangoutLink,omitempty"`
+	Conference  *conferenceJSON `json:"conference,omitempty"`
+	// Recurrence holds the RRULE/EXRULE/RDATE/EXDATE lines of a recurring
+	// event; RecurringEventID is set on instances of one instead.
+	Recurrence       []string `json:"recurrence,omitempty"`
+	RecurringEventID string   `json:"recurringEventId,omitempty"`
+	// Reminders are the event's notifications; UseDefault means the
+	// calendar's default reminders apply.
+	Reminders *eventRemindersJSON `json:"reminders,omitempty"`
+	// Metadata is the description's metadata footer, set by get-event's
+	// include_metadata. See splitMetadataFooter.
+	Metadata map[string]any `json:"metadata,omitempty"`
+	// Warning reports a part of create-event's request that Google did not
+	// carry out, such as add_conference, although the event was created.
+	Warning string `json:"warning,omitempty"`
+}
+
+type conferenceJSON struct {
+	Solution string `json:"solution,omitempty"`
+	// Status is pending, success or failure while a conference requested
+	// with add_conference is being created.
+	Status      string           `json:"status,omitempty"`
+	EntryPoints []entryPointJSON `json:"entryPoints,omitempty"`
+}
+
+type entryPointJSON struct {
+	Type  string `json:"type"`
+	URI   string `json:"uri"`
+	Label string `json:"label,omitempty"`
 }
 
 type dateTimeJSON struct {
@@ -52,7 +127,10 @@ type attendeeJSON struct {
 	Email          string `json:"email"`
 	DisplayName    string `json:"displayName,omitempty"`
 	ResponseStatus string `json:"responseStatus,omitempty"`
+	Optional       bool   `json:"optional,omitempty"`
 	Self           bool   `json:"self,omitempty"`
+	// Busy is set by get-event's include_availability; nil when unknown.
+	Busy *bool `json:"busy,omitempty"`
 }
 
 type organizerJSON struct {
@@ -67,6 +145,33 @@ type calendarJSON struct {
 	Description string `json:"description,omitempty"`
 	Primary     bool   `json:"primary,omitempty"`
 	TimeZone    string `json:"timeZone,omitempty"`
+	// Colors are hex ("#9fe1e7") as shown in the Calendar UI.
+	BackgroundColor string `json:"backgroundColor,omitempty"`
+	ForegroundColor string `json:"foregroundColor,omitempty"`
+	// Hidden is set on calendars hidden from the user's list, which
+	// list-calendars only returns with show_hidden.
+	Hidden bool `json:"hidden,omitempty"`
+
+	// Detail fields, populated by GetCalendar.
+	AccessRole           string             `json:"accessRole,omitempty"`
+	Selected             bool               `json:"selected,omitempty"`
+	DefaultReminders     []reminderJSON     `json:"defaultReminders,omitempty"`
+	NotificationSettings []notificationJSON `json:"notificationSettings,omitempty"`
+}
+
+type reminderJSON struct {
+	Method  string `json:"method"`
+	Minutes int64  `json:"minutes"`
+}
+
+type eventRemindersJSON struct {
+	UseDefault bool           `json:"useDefault"`
+	Overrides  []reminderJSON `json:"overrides,omitempty"`
+}
+
+type notificationJSON struct {
+	Type   string `json:"type"`
+	Method string `json:"method"`
 }
 
 func convertEvent(e *calendar.Event) eventJSON {
@@ -79,6 +184,28 @@ func convertEvent(e *calendar.Event) eventJSON {
 		HTMLLink:    e.HtmlLink,
 		Created:     e.Created,
 		Updated:     e.Updated,
+		ICalUID:     e.ICalUID,
+
+		AnyoneCanAddSelf: e.AnyoneCanAddSelf,
+		Locked:           e.Locked,
+		Visibility:       e.Visibility,
+		EventType:        e.EventType,
+		ColorID:          e.ColorId,
+		HangoutLink:      e.HangoutLink,
+		Recurrence:       e.Recurrence,
+		RecurringEventID: e.RecurringEventId,
+	}
+	if cd := e.ConferenceData; cd != nil {
+		ev.Conference = &conferenceJSON{}
+		if cd.ConferenceSolution != nil {
+			ev.Conference.Solution = cd.ConferenceSolution.Name
+		}
+		if cd.CreateRequest != nil && cd.CreateRequest.Status != nil {
+			ev.Conference.Status = cd.CreateRequest.Status.StatusCode
+		}
+		for _, ep := range cd.EntryPoints {
+			ev.Conference.EntryPoints = append(ev.Conference.EntryPoints, entryPointJSON{Type: ep.EntryPointType, URI: ep.Uri, Label: ep.Label})
+		}
 	}
 	if e.Start != nil {
 		ev.Start = &dateTimeJSON{
@@ -99,6 +226,7 @@ func convertEvent(e *calendar.Event) eventJSON {
 			Email:          a.Email,
 			DisplayName:    a.DisplayName,
 			ResponseStatus: a.ResponseStatus,
+			Optional:       a.Optional,
 			Self:           a.Self,
 		})
 	}
@@ -109,34 +237,245 @@ func convertEvent(e *calendar.Event) eventJSON {
 			Self:        e.Organizer.Self,
 		}
 	}
+	if e.Reminders != nil {
+		ev.Reminders = &eventRemindersJSON{UseDefault: e.Reminders.UseDefault}
+		for _, r := range e.Reminders.Overrides {
+			ev.Reminders.Overrides = append(ev.Reminders.Overrides, reminderJSON{Method: r.Method, Minutes: r.Minutes})
+		}
+	}
 	return ev
 }
 
-// ListCalendars returns all calendars accessible to the user.
-func (cs *CalendarService) ListCalendars() ([]calendarJSON, error) {
-	list, err := cs.svc.CalendarList.List().Do()
-	if err != nil {
-		return nil, fmt.Errorf("list calendars: %w", err)
+// ListCalendars returns all calendars accessible to the user, including
+// hidden ones if showHidden is set. Results are served from the calendar list
+// cache, if any, while they are fresh.
+func (cs *CalendarService) ListCalendars(ctx context.Context, showHidden bool) ([]calendarJSON, error) {
+	// The cache holds the full list, hidden calendars included, so one
+	// entry serves both kinds of call.
+	all, ok := cs.caches.lists.get(cs.cacheKey)
+	if !ok {
+		list, err := cs.svc.CalendarList.List().ShowHidden(true).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("list calendars: %w", err)
+		}
+		all = make([]calendarJSON, 0, len(list.Items))
+		for _, c := range list.Items {
+			all = append(all, calendarJSON{
+				ID:              c.Id,
+				Summary:         c.Summary,
+				Description:     c.Description,
+				Primary:         c.Primary,
+				TimeZone:        c.TimeZone,
+				BackgroundColor: c.BackgroundColor,
+				ForegroundColor: c.ForegroundColor,
+				Hidden:          c.Hidden,
+			})
+		}
+		cs.caches.lists.put(cs.cacheKey, all)
 	}
-	result := make([]calendarJSON, 0, len(list.Items))
-	for _, c := range list.Items {
-		result = append(result, calendarJSON{
-			ID:          c.Id,
-			Summary:     c.Summary,
-			Description: c.Description,
-			Primary:     c.Primary,
-			TimeZone:    c.TimeZone,
-		})
+	result := make([]calendarJSON, 0, len(all))
+	for _, c := range all {
+		if showHidden || !c.Hidden {
+			result = append(result, c)
+		}
 	}
 	return result, nil
 }
 
-// ListEvents lists events in a calendar within a time range.
-func (cs *CalendarService) ListEvents(calendarID, timeMin, timeMax string, maxResults int64, singleEvents bool, orderBy string) ([]eventJSON, error) {
+// PrimaryCalendarID returns the real ID of the user's primary calendar, for
+// APIs that do not accept the "primary" alias.
+func (cs *CalendarService) PrimaryCalendarID(ctx context.Context) (string, error) {
+	if id, ok := cs.caches.primaryIDs.get(cs.cacheKey); ok {
+		return id, nil
+	}
+	entry, err := cs.svc.CalendarList.Get("primary").Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("get primary calendar: %w", err)
+	}
+	cs.caches.primaryIDs.put(cs.cacheKey, entry.Id)
+	return entry.Id, nil
+}
+
+// calendarTimezone returns the timezone of calendarID (primary if empty).
+func (cs *CalendarService) calendarTimezone(ctx context.Context, calendarID string) (string, error) {
 	if calendarID == "" {
 		calendarID = "primary"
 	}
-	now := time.Now()
+	cal, err := cs.svc.CalendarList.Get(calendarID).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("get calendar timezone: %w", err)
+	}
+	return cal.TimeZone, nil
+}
+
+// GetCalendar returns a calendar from the user's calendar list, including the
+// user's access role, so callers can tell whether the calendar is writable.
+func (cs *CalendarService) GetCalendar(ctx context.Context, calendarID string) (*calendarJSON, error) {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+	c, err := cs.svc.CalendarList.Get(calendarID).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("get calendar: %w", err)
+	}
+	cal := calendarJSON{
+		ID:              c.Id,
+		Summary:         c.Summary,
+		Description:     c.Description,
+		Primary:         c.Primary,
+		TimeZone:        c.TimeZone,
+		BackgroundColor: c.BackgroundColor,
+		ForegroundColor: c.ForegroundColor,
+		Hidden:          c.Hidden,
+		AccessRole:      c.AccessRole,
+		Selected:        c.Selected,
+	}
+	for _, r := range c.DefaultReminders {
+		cal.DefaultReminders = append(cal.DefaultReminders, reminderJSON{Method: r.Method, Minutes: r.Minutes})
+	}
+	if c.NotificationSettings != nil {
+		for _, n := range c.NotificationSettings.Notifications {
+			cal.NotificationSettings = append(cal.NotificationSettings, notificationJSON{Type: n.Type, Method: n.Method})
+		}
+	}
+	return &cal, nil
+}
+
+// CreateCalendar creates a secondary calendar owned by the user. An empty
+// timezone leaves Google to use the account's.
+func (cs *CalendarService) CreateCalendar(ctx context.Context, summary, description, timezone string) (*calendarJSON, error) {
+	if strings.TrimSpace(summary) == "" {
+		return nil, fmt.Errorf("summary is required")
+	}
+	if err := validateTimezone(timezone); err != nil {
+		return nil, err
+	}
+	c, err := cs.svc.Calendars.Insert(&calendar.Calendar{
+		Summary:     summary,
+		Description: description,
+		TimeZone:    timezone,
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("create calendar: %w", err)
+	}
+	cs.caches.lists.invalidate(cs.cacheKey)
+	return &calendarJSON{
+		ID:          c.Id,
+		Summary:     c.Summary,
+		Description: c.Description,
+		TimeZone:    c.TimeZone,
+	}, nil
+}
+
+// DeleteCalendar permanently deletes a secondary calendar and its events.
+// The primary calendar cannot be deleted.
+func (cs *CalendarService) DeleteCalendar(ctx context.Context, calendarID string) error {
+	if calendarID == "" {
+		return fmt.Errorf("calendar_id is required")
+	}
+	if calendarID == "primary" {
+		return fmt.Errorf("the primary calendar cannot be deleted")
+	}
+	// The primary calendar can also be named by its real ID, the account email.
+	primaryID, err := cs.PrimaryCalendarID(ctx)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(calendarID, primaryID) {
+		return fmt.Errorf("the primary calendar cannot be deleted")
+	}
+	if err := cs.svc.Calendars.Delete(calendarID).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("delete calendar: %w", err)
+	}
+	cs.caches.lists.invalidate(cs.cacheKey)
+	return nil
+}
+
+// GetSettings returns the user's Calendar settings (timezone, weekStart,
+// format24HourTime, dateFieldOrder, locale, ...) keyed by setting ID.
+func (cs *CalendarService) GetSettings(ctx context.Context) (map[string]string, error) {
+	result := make(map[string]string)
+	call := cs.svc.Settings.List()
+	for {
+		list, err := call.Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("list settings: %w", err)
+		}
+		for _, s := range list.Items {
+			result[s.Id] = s.Value
+		}
+		if list.NextPageToken == "" {
+			return result, nil
+		}
+		call = cs.svc.Settings.List().PageToken(list.NextPageToken)
+	}
+}
+
+// colorJSON is one entry of the Calendar color palette.
+type colorJSON struct {
+	ID         string `json:"id"`
+	Background string `json:"background"`
+	Foreground string `json:"foreground"`
+}
+
+// ListColors returns the palettes event color_id and calendar colors are
+// chosen from, each ordered by numeric ID.
+func (cs *CalendarService) ListColors(ctx context.Context) (map[string][]colorJSON, error) {
+	colors, err := cs.svc.Colors.Get().Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("get colors: %w", err)
+	}
+	return map[string][]colorJSON{
+		"event":    sortedColors(colors.Event),
+		"calendar": sortedColors(colors.Calendar),
+	}, nil
+}
+
+func sortedColors(defs map[string]calendar.ColorDefinition) []colorJSON {
+	result := make([]colorJSON, 0, len(defs))
+	for id, def := range defs {
+		result = append(result, colorJSON{ID: id, Background: def.Background, Foreground: def.Foreground})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, errA := strconv.Atoi(result[i].ID)
+		b, errB := strconv.Atoi(result[j].ID)
+		if errA != nil || errB != nil {
+			return result[i].ID < result[j].ID
+		}
+		return a < b
+	})
+	return result
+}
+
+// validateColorID checks that id looks like a palette ID from list-colors.
+// Whether the ID exists is left to the API.
+func validateColorID(id string) error {
+	if id == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(id); err != nil || n <= 0 {
+		return fmt.Errorf("invalid color_id %q: must be a numeric ID from list-colors", id)
+	}
+	return nil
+}
+
+// eventPageJSON is one page of ListEvents results. NextPageToken, passed
+// back as pageToken, fetches the next page; it is empty on the last page.
+type eventPageJSON struct {
+	Events        []eventJSON `json:"events"`
+	NextPageToken string      `json:"nextPageToken,omitempty"`
+}
+
+// ListEvents lists events in a calendar within a time range, one page at a
+// time starting from pageToken (empty for the first page).
+func (cs *CalendarService) ListEvents(ctx context.Context, calendarID, timeMin, timeMax string, maxResults int64, singleEvents bool, orderBy, pageToken string) (*eventPageJSON, error) {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+	if err := validateTimeRange(timeMin, timeMax); err != nil {
+		return nil, err
+	}
+	now := time.Now().In(cs.location())
 	if timeMin == "" {
 		timeMin = now.Format(time.RFC3339)
 	}
@@ -146,6 +485,7 @@ func (cs *CalendarService) ListEvents(calendarID, timeMin, timeMax string, maxRe
 	if maxResults <= 0 {
 		maxResults = 50
 	}
+	maxResults, _ = clampMaxResults(maxResults, cs.opts.MaxResultsCeiling)
 
 	call := cs.svc.Events.List(calendarID).
 		TimeMin(timeMin).
@@ -156,38 +496,457 @@ func (cs *CalendarService) ListEvents(calendarID, timeMin, timeMax string, maxRe
 	if orderBy != "" {
 		call = call.OrderBy(orderBy)
 	}
+	if pageToken != "" {
+		call = call.PageToken(pageToken)
+	}
 
-	events, err := call.Do()
+	events, err := call.Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("list events: %w", err)
 	}
 
-	result := make([]eventJSON, 0, len(events.Items))
+	page := &eventPageJSON{
+		Events:        make([]eventJSON, 0, len(events.Items)),
+		NextPageToken: events.NextPageToken,
+	}
 	for _, e := range events.Items {
+		page.Events = append(page.Events, convertEvent(e))
+	}
+	return page, nil
+}
+
+// WatchEvents opens a push notification channel on which Google posts to
+// address, an HTTPS URL, whenever an event in calendarID changes. A positive
+// ttl asks for the channel to expire after it; Google may shorten it.
+func (cs *CalendarService) WatchEvents(ctx context.Context, calendarID, address string, ttl time.Duration) (*WatchChannel, error) {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+	if u, err := url.Parse(address); err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("address must be an https URL")
+	}
+	channelID, err := generateSecureToken(16)
+	if err != nil {
+		return nil, fmt.Errorf("generate channel id: %w", err)
+	}
+	ch := &calendar.Channel{Id: channelID, Type: "web_hook", Address: address}
+	if ttl > 0 {
+		ch.Params = map[string]string{"ttl": strconv.FormatInt(int64(ttl/time.Second), 10)}
+	}
+	opened, err := cs.svc.Events.Watch(calendarID, ch).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("watch events: %w", err)
+	}
+	return &WatchChannel{
+		ChannelID:  opened.Id,
+		ResourceID: opened.ResourceId,
+		CalendarID: calendarID,
+		Expiration: time.UnixMilli(opened.Expiration),
+	}, nil
+}
+
+// StopChannel stops push notifications on a watch channel.
+func (cs *CalendarService) StopChannel(ctx context.Context, channelID, resourceID string) error {
+	if err := cs.svc.Channels.Stop(&calendar.Channel{Id: channelID, ResourceId: resourceID}).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("stop channel: %w", err)
+	}
+	return nil
+}
+
+// FindConflicts returns the events on calendarID that would overlap a new
+// event from start to end (RFC3339 or YYYY-MM-DD, dates in timezone or UTC).
+func (cs *CalendarService) FindConflicts(ctx context.Context, calendarID, start, end, timezone string) ([]eventJSON, error) {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+	timezone = cs.defaultTimezone(timezone)
+	startTime, err := parseEventTime(start, timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start: %w", err)
+	}
+	endTime, err := parseEventTime(end, timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end: %w", err)
+	}
+
+	// All-day events carry no timezone of their own; their dates are days in
+	// the calendar's timezone.
+	var items []*calendar.Event
+	calendarTZ := timezone
+	err = cs.svc.Events.List(calendarID).
+		TimeMin(startTime.Format(time.RFC3339)).
+		TimeMax(endTime.Format(time.RFC3339)).
+		SingleEvents(true).
+		Pages(ctx, func(page *calendar.Events) error {
+			items = append(items, page.Items...)
+			if page.TimeZone != "" {
+				calendarTZ = page.TimeZone
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+
+	result := []eventJSON{}
+	for _, e := range conflictingEvents(items, startTime, endTime, calendarTZ) {
 		result = append(result, convertEvent(e))
 	}
 	return result, nil
 }
 
+// conflictingEvents returns the events that overlap [start, end) and would
+// keep the user busy: cancelled, free ("transparent") and declined events are
+// not conflicts. Events that merely touch the range are not overlaps. All-day
+// events span their dates in timezone (the calendar's), unless they name one.
+func conflictingEvents(events []*calendar.Event, start, end time.Time, timezone string) []*calendar.Event {
+	var result []*calendar.Event
+	for _, e := range events {
+		if e.Status == "cancelled" || e.Transparency == "transparent" || e.Start == nil || e.End == nil {
+			continue
+		}
+		declined := false
+		for _, a := range e.Attendees {
+			if a.Self && a.ResponseStatus == "declined" {
+				declined = true
+			}
+		}
+		if declined {
+			continue
+		}
+		eStart, err1 := parseEventDateTime(e.Start, timezone)
+		eEnd, err2 := parseEventDateTime(e.End, timezone)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if (interval{eStart, eEnd}).Overlaps(interval{start, end}) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// parseEventTime parses an RFC3339 time or a YYYY-MM-DD date, taken as
+// midnight in timezone (UTC if empty or unknown).
+func parseEventTime(s, timezone string) (time.Time, error) {
+	if !isDateOnly(s) {
+		return time.Parse(time.RFC3339, s)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return time.ParseInLocation("2006-01-02", s, loc)
+}
+
+// parseEventDateTime parses an event's start or end. A date-only value is
+// midnight in the value's own timezone, else in timezone.
+func parseEventDateTime(dt *calendar.EventDateTime, timezone string) (time.Time, error) {
+	if dt.TimeZone != "" {
+		timezone = dt.TimeZone
+	}
+	return parseEventTime(dt.DateTime+dt.Date, timezone)
+}
+
+// selfResponseStatus returns the authenticated user's response to an event and
+// whether they are listed as an attendee at all.
+func selfResponseStatus(ev eventJSON) (string, bool) {
+	for _, a := range ev.Attendees {
+		if a.Self {
+			return a.ResponseStatus, true
+		}
+	}
+	return "", false
+}
+
+// filterEvents drops events the user is not taking part in. With myEventsOnly,
+// only events where the user is an attendee who has not declined, or the
+// organizer of an event without a guest list, are kept. With excludeDeclined,
+// only events the user has declined are dropped.
+func filterEvents(events []eventJSON, myEventsOnly, excludeDeclined bool) []eventJSON {
+	if !myEventsOnly && !excludeDeclined {
+		return events
+	}
+	result := make([]eventJSON, 0, len(events))
+	for _, ev := range events {
+		status, attending := selfResponseStatus(ev)
+		if status == "declined" {
+			continue
+		}
+		if myEventsOnly && !attending {
+			ownEvent := len(ev.Attendees) == 0 && ev.Organizer != nil && ev.Organizer.Self
+			if !ownEvent {
+				continue
+			}
+		}
+		result = append(result, ev)
+	}
+	return result
+}
+
 // GetEvent retrieves a single event by ID.
-func (cs *CalendarService) GetEvent(calendarID, eventID string) (*eventJSON, error) {
+// If includeAvailability is true, each attendee is marked busy or free
+// during the event. If includeMetadata is true, the description's metadata
+// footer is moved into Metadata.
+func (cs *CalendarService) GetEvent(ctx context.Context, calendarID, eventID string, includeAvailability, includeMetadata bool) (*eventJSON, error) {
 	if calendarID == "" {
 		calendarID = "primary"
 	}
-	e, err := cs.svc.Events.Get(calendarID, eventID).Do()
+	e, err := cs.svc.Events.Get(calendarID, eventID).Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("get event: %w", err)
 	}
 	ev := convertEvent(e)
+	if includeMetadata {
+		ev.Description, ev.Metadata = splitMetadataFooter(ev.Description)
+	}
+	if includeAvailability {
+		if err := cs.annotateAvailability(ctx, calendarID, &ev); err != nil {
+			return nil, err
+		}
+	}
 	return &ev, nil
 }
 
-// SearchEvents searches events by text query.
-func (cs *CalendarService) SearchEvents(calendarID, query, timeMin, timeMax string, maxResults int64) ([]eventJSON, error) {
+// maxGetEventsIDs caps the number of event IDs a single GetEvents call accepts.
+const maxGetEventsIDs = 50
+
+// getEventsConcurrency bounds the Events.Get calls GetEvents has in flight.
+const getEventsConcurrency = 8
+
+// eventResult is one entry of GetEvents' result: the event, or why it could
+// not be fetched. ID is the requested ID either way.
+type eventResult struct {
+	*eventJSON
+	ID    string `json:"id"`
+	Error string `json:"error,omitempty"`
+}
+
+// GetEvents fetches several events concurrently. Results are in the order of
+// eventIDs; a failure for one ID is reported in its entry rather than failing
+// the whole call.
+func (cs *CalendarService) GetEvents(ctx context.Context, calendarID string, eventIDs []string) ([]eventResult, error) {
+	if len(eventIDs) == 0 {
+		return nil, fmt.Errorf("event_ids is required")
+	}
+	if len(eventIDs) > maxGetEventsIDs {
+		return nil, fmt.Errorf("too many event_ids: %d (max %d)", len(eventIDs), maxGetEventsIDs)
+	}
+	results := make([]eventResult, len(eventIDs))
+	sem := make(chan struct{}, getEventsConcurrency)
+	var wg sync.WaitGroup
+	for i, id := range eventIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			ev, err := cs.GetEvent(ctx, calendarID, id, false, false)
+			results[i] = eventResult{eventJSON: ev, ID: id}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// metadataFence opens the fenced block holding an event's JSON metadata at the
+// end of its description.
+const metadataFence = "```mcp-gcal-metadata"
+
+// appendMetadataFooter appends metadata to description as a fenced JSON block,
+// so apps can round-trip data through events without extended properties.
+func appendMetadataFooter(description string, metadata map[string]any) (string, error) {
+	b, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("encode metadata: %w", err)
+	}
+	footer := metadataFence + "\n" + string(b) + "\n```"
+	if description == "" {
+		return footer, nil
+	}
+	return strings.TrimRight(description, "\n") + "\n\n" + footer, nil
+}
+
+// splitMetadataFooter separates the metadata footer written by
+// appendMetadataFooter from the rest of description. If there is no footer, or
+// it has been mangled (e.g. by editing in the Calendar UI), description is
+// returned unchanged with nil metadata.
+func splitMetadataFooter(description string) (string, map[string]any) {
+	i := strings.LastIndex(description, metadataFence)
+	if i < 0 {
+		return description, nil
+	}
+	block := strings.TrimSpace(description[i+len(metadataFence):])
+	if !strings.HasSuffix(block, "```") {
+		return description, nil
+	}
+	var metadata map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimSuffix(block, "```")), &metadata); err != nil {
+		return description, nil
+	}
+	return strings.TrimRight(description[:i], "\n"), metadata
+}
+
+// annotateAvailability runs a free/busy query for ev's attendees over ev's
+// time window and sets each attendee's Busy field. Attendees whose calendars
+// cannot be queried (e.g. outside the user's organization) are left unknown.
+// An all-day event's dates are days in the timezone of calendarID, the
+// calendar it is on.
+//
+// Free/busy does not say which event makes someone busy, and attendees who
+// have not declined are busy with ev itself. A busy period exactly matching
+// ev's window is therefore taken to be ev; any other overlap is a conflict.
+func (cs *CalendarService) annotateAvailability(ctx context.Context, calendarID string, ev *eventJSON) error {
+	if len(ev.Attendees) == 0 {
+		return nil
+	}
+	timezone := cs.defaultTimezone("")
+	if ev.Start != nil && ev.Start.DateTime == "" && ev.Start.TimeZone == "" {
+		if tz, err := cs.calendarTimezone(ctx, calendarID); err == nil {
+			timezone = tz
+		}
+	}
+	start, err := eventBoundary(ev.Start, timezone)
+	if err != nil {
+		return fmt.Errorf("event start: %w", err)
+	}
+	end, err := eventBoundary(ev.End, timezone)
+	if err != nil {
+		return fmt.Errorf("event end: %w", err)
+	}
+
+	// One query accepts at most maxFreeBusyCalendars calendars.
+	calendars := make(map[string]calendar.FreeBusyCalendar, len(ev.Attendees))
+	for i := 0; i < len(ev.Attendees); i += maxFreeBusyCalendars {
+		req := &calendar.FreeBusyRequest{
+			TimeMin: start.Format(time.RFC3339),
+			TimeMax: end.Format(time.RFC3339),
+		}
+		for _, a := range ev.Attendees[i:min(i+maxFreeBusyCalendars, len(ev.Attendees))] {
+			req.Items = append(req.Items, &calendar.FreeBusyRequestItem{Id: a.Email})
+		}
+		resp, err := cs.svc.Freebusy.Query(req).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("query free/busy: %w", err)
+		}
+		for id, fb := range resp.Calendars {
+			calendars[id] = fb
+		}
+	}
+
+	event := interval{start, end}
+	for i, a := range ev.Attendees {
+		fb, ok := calendars[a.Email]
+		if !ok || len(fb.Errors) > 0 {
+			continue
+		}
+		busy := false
+		for _, p := range fb.Busy {
+			pStart, err1 := time.Parse(time.RFC3339, p.Start)
+			pEnd, err2 := time.Parse(time.RFC3339, p.End)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			period := interval{pStart, pEnd}
+			if period.Equal(event) && a.ResponseStatus != "declined" {
+				continue
+			}
+			if period.Overlaps(event) {
+				busy = true
+				break
+			}
+		}
+		ev.Attendees[i].Busy = &busy
+	}
+	return nil
+}
+
+// eventBoundary returns the instant an event starts or ends. An all-day date
+// is midnight in its own timezone, else in timezone.
+func eventBoundary(dt *dateTimeJSON, timezone string) (time.Time, error) {
+	if dt == nil {
+		return time.Time{}, fmt.Errorf("missing time")
+	}
+	if dt.TimeZone != "" {
+		timezone = dt.TimeZone
+	}
+	return parseEventTime(dt.DateTime+dt.Date, timezone)
+}
+
+// maxFreeBusyCalendars caps the calendars a single FreeBusy call accepts,
+// the limit of one free/busy query.
+const maxFreeBusyCalendars = 50
+
+// busyJSON is a calendar's busy time from a free/busy query. Errors is set
+// instead when the calendar cannot be queried, e.g. because it is not shared
+// with the user.
+type busyJSON struct {
+	Busy   []timePeriodJSON `json:"busy"`
+	Errors []string         `json:"errors,omitempty"`
+}
+
+type timePeriodJSON struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// FreeBusy returns the busy periods between timeMin and timeMax (RFC3339,
+// default now to 7 days from now) of each calendar in calendarIDs, keyed by
+// calendar ID. Attendee emails work as calendar IDs. No IDs means primary.
+func (cs *CalendarService) FreeBusy(ctx context.Context, timeMin, timeMax string, calendarIDs []string) (map[string]busyJSON, error) {
+	if len(calendarIDs) == 0 {
+		calendarIDs = []string{"primary"}
+	}
+	if len(calendarIDs) > maxFreeBusyCalendars {
+		return nil, fmt.Errorf("too many calendar IDs: %d (max %d)", len(calendarIDs), maxFreeBusyCalendars)
+	}
+	if err := validateTimeRange(timeMin, timeMax); err != nil {
+		return nil, err
+	}
+	now := time.Now().In(cs.location())
+	if timeMin == "" {
+		timeMin = now.Format(time.RFC3339)
+	}
+	if timeMax == "" {
+		timeMax = now.AddDate(0, 0, 7).Format(time.RFC3339)
+	}
+
+	req := &calendar.FreeBusyRequest{TimeMin: timeMin, TimeMax: timeMax}
+	for _, id := range calendarIDs {
+		req.Items = append(req.Items, &calendar.FreeBusyRequestItem{Id: id})
+	}
+	resp, err := cs.svc.Freebusy.Query(req).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("query free/busy: %w", err)
+	}
+
+	result := make(map[string]busyJSON, len(calendarIDs))
+	for _, id := range calendarIDs {
+		fb := resp.Calendars[id]
+		busy := busyJSON{Busy: []timePeriodJSON{}}
+		for _, p := range fb.Busy {
+			busy.Busy = append(busy.Busy, timePeriodJSON{Start: p.Start, End: p.End})
+		}
+		for _, e := range fb.Errors {
+			busy.Errors = append(busy.Errors, e.Reason)
+		}
+		result[id] = busy
+	}
+	return result, nil
+}
+
+// SearchEvents searches events by text query. orderBy is startTime (default) or updated.
+func (cs *CalendarService) SearchEvents(ctx context.Context, calendarID, query, timeMin, timeMax string, maxResults int64, orderBy string) ([]eventJSON, error) {
 	if calendarID == "" {
 		calendarID = "primary"
 	}
-	now := time.Now()
+	if err := validateTimeRange(timeMin, timeMax); err != nil {
+		return nil, err
+	}
+	now := time.Now().In(cs.location())
 	if timeMin == "" {
 		timeMin = now.Format(time.RFC3339)
 	}
@@ -197,6 +956,10 @@ func (cs *CalendarService) SearchEvents(calendarID, query, timeMin, timeMax stri
 	if maxResults <= 0 {
 		maxResults = 50
 	}
+	if orderBy == "" {
+		orderBy = "startTime"
+	}
+	maxResults, _ = clampMaxResults(maxResults, cs.opts.MaxResultsCeiling)
 
 	events, err := cs.svc.Events.List(calendarID).
 		Q(query).
@@ -204,7 +967,8 @@ func (cs *CalendarService) SearchEvents(calendarID, query, timeMin, timeMax stri
 		TimeMax(timeMax).
 		MaxResults(maxResults).
 		SingleEvents(true).
-		OrderBy("startTime").
+		OrderBy(orderBy).
+		Context(ctx).
 		Do()
 	if err != nil {
 		return nil, fmt.Errorf("search events: %w", err)
@@ -217,16 +981,274 @@ func (cs *CalendarService) SearchEvents(calendarID, query, timeMin, timeMax stri
 	return result, nil
 }
 
+// validateTimeRange checks that time_min precedes time_max when both are given.
+// Either bound may be empty, in which case the caller's default applies.
+func validateTimeRange(timeMin, timeMax string) error {
+	if timeMin == "" || timeMax == "" {
+		return nil
+	}
+	tMin, err := time.Parse(time.RFC3339, timeMin)
+	if err != nil {
+		return fmt.Errorf("invalid time_min %q: must be RFC3339", timeMin)
+	}
+	tMax, err := time.Parse(time.RFC3339, timeMax)
+	if err != nil {
+		return fmt.Errorf("invalid time_max %q: must be RFC3339", timeMax)
+	}
+	if !tMin.Before(tMax) {
+		return fmt.Errorf("time_min (%s) must be before time_max (%s)", timeMin, timeMax)
+	}
+	return nil
+}
+
+// validateVisibility checks an event visibility value. Empty means unset.
+func validateVisibility(visibility string) error {
+	switch visibility {
+	case "", "default", "public", "private", "confidential":
+		return nil
+	}
+	return fmt.Errorf("invalid visibility %q: must be default, public, private, or confidential", visibility)
+}
+
+// validateSendUpdates checks a send_updates value. Empty leaves the choice to
+// Google, which sends no invitations for API-created events by default.
+func validateSendUpdates(sendUpdates string) error {
+	switch sendUpdates {
+	case "", "all", "externalOnly", "none":
+		return nil
+	}
+	return fmt.Errorf("invalid send_updates %q: must be all, externalOnly, or none", sendUpdates)
+}
+
+// parseRecurrence splits recurrence rules, separated by newlines or commas,
+// into the lines the Calendar API expects. Commas inside a rule
+// ("BYDAY=MO,WE") or a date list ("EXDATE:20250101,20250108") are kept. A bare
+// rule ("FREQ=WEEKLY;BYDAY=MO") is taken as an RRULE. Empty input yields no
+// rules.
+func parseRecurrence(s string) ([]string, error) {
+	var rules []string
+	for _, line := range strings.Split(s, "\n") {
+		for _, part := range splitRecurrenceList(line) {
+			name, _, ok := strings.Cut(part, ":")
+			switch {
+			case ok && isRecurrenceProperty(name):
+			case strings.HasPrefix(strings.ToUpper(part), "FREQ="):
+				part = "RRULE:" + part
+			default:
+				return nil, fmt.Errorf("invalid recurrence %q: expected RRULE, EXRULE, RDATE or EXDATE, e.g. RRULE:FREQ=WEEKLY;BYDAY=MO", part)
+			}
+			rules = append(rules, part)
+		}
+	}
+	return rules, nil
+}
+
+// splitRecurrenceList splits a comma-separated list of recurrence lines,
+// splitting only at commas followed by a new line's property name or rule.
+func splitRecurrenceList(s string) []string {
+	var parts []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, _, ok := strings.Cut(part, ":")
+		startsLine := ok && isRecurrenceProperty(name) || strings.HasPrefix(strings.ToUpper(part), "FREQ=")
+		if n := len(parts); n > 0 && !startsLine {
+			parts[n-1] += "," + part
+			continue
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// isRecurrenceProperty reports whether name (possibly with parameters, as in
+// "EXDATE;TZID=Asia/Tokyo") is an RFC 5545 recurrence property.
+func isRecurrenceProperty(name string) bool {
+	name, _, _ = strings.Cut(name, ";")
+	switch strings.ToUpper(name) {
+	case "RRULE", "EXRULE", "RDATE", "EXDATE":
+		return true
+	}
+	return false
+}
+
+// maxReminderMinutes is the furthest ahead of an event, four weeks, that
+// Google allows a reminder.
+const maxReminderMinutes = 40320
+
+// parseReminders parses comma-separated "method:minutes" reminders, e.g.
+// "popup:10,email:60", into reminder overrides. Empty input means the
+// calendar's default reminders.
+func parseReminders(s string) (*calendar.EventReminders, error) {
+	if strings.TrimSpace(s) == "" {
+		return &calendar.EventReminders{UseDefault: true}, nil
+	}
+	reminders := &calendar.EventReminders{ForceSendFields: []string{"UseDefault"}}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		method, minutes, ok := strings.Cut(part, ":")
+		method = strings.ToLower(strings.TrimSpace(method))
+		if !ok || (method != "popup" && method != "email") {
+			return nil, fmt.Errorf("invalid reminder %q: expected popup:<minutes> or email:<minutes>", part)
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(minutes), 10, 64)
+		if err != nil || n < 0 || n > maxReminderMinutes {
+			return nil, fmt.Errorf("invalid reminder %q: minutes must be between 0 and %d", part, maxReminderMinutes)
+		}
+		reminders.Overrides = append(reminders.Overrides, &calendar.EventReminder{Method: method, Minutes: n, ForceSendFields: []string{"Minutes"}})
+	}
+	if len(reminders.Overrides) > 5 {
+		return nil, fmt.Errorf("too many reminders: at most 5 are allowed")
+	}
+	return reminders, nil
+}
+
+// applyEventType sets a special event type and the properties Google requires
+// for it. focusTime and outOfOffice events auto-decline conflicting invitations;
+// a workingLocation event uses the event location as a custom location label,
+// or the home office when the location is empty or "home". None of these types
+// may have attendees.
+func applyEventType(event *calendar.Event, eventType, declineMessage string) error {
+	if eventType == "" || eventType == "default" {
+		return nil
+	}
+	if len(event.Attendees) > 0 {
+		return fmt.Errorf("attendees cannot be set on %s events", eventType)
+	}
+	if event.AnyoneCanAddSelf {
+		return fmt.Errorf("anyone_can_add_self cannot be set on %s events, which have no guests", eventType)
+	}
+	switch eventType {
+	case "focusTime":
+		event.FocusTimeProperties = &calendar.EventFocusTimeProperties{
+			AutoDeclineMode: "declineOnlyNewConflictingInvitations",
+			ChatStatus:      "doNotDisturb",
+			DeclineMessage:  declineMessage,
+		}
+	case "outOfOffice":
+		event.OutOfOfficeProperties = &calendar.EventOutOfOfficeProperties{
+			AutoDeclineMode: "declineAllConflictingInvitations",
+			DeclineMessage:  declineMessage,
+		}
+	case "workingLocation":
+		props := &calendar.EventWorkingLocationProperties{}
+		if event.Location == "" || strings.EqualFold(event.Location, "home") {
+			props.Type = "homeOffice"
+			props.HomeOffice = map[string]any{}
+		} else {
+			props.Type = "customLocation"
+			props.CustomLocation = &calendar.EventWorkingLocationPropertiesCustomLocation{Label: event.Location}
+		}
+		event.WorkingLocationProperties = props
+	default:
+		return fmt.Errorf("invalid event_type %q: must be default, focusTime, outOfOffice, or workingLocation", eventType)
+	}
+	event.EventType = eventType
+	return nil
+}
+
+// createEventOptions holds optional settings for CreateEvent.
+type createEventOptions struct {
+	// ICalUID sets the event's iCalendar UID.
+	ICalUID string
+	// Import creates the event with Events.Import instead of Events.Insert.
+	// Import preserves ICalUID (required), so re-importing the same UID updates
+	// the existing copy instead of creating a duplicate. Google does not send
+	// invitations for imported events, and the organizer is kept as given rather
+	// than being replaced by the calendar owner.
+	Import bool
+	// AnyoneCanAddSelf lets anyone with the event link add themselves as a
+	// guest. Event types without guests do not allow it.
+	AnyoneCanAddSelf bool
+	// Visibility is default, public, private, or confidential.
+	Visibility string
+	// EventType is default, focusTime, outOfOffice, or workingLocation.
+	// See applyEventType.
+	EventType string
+	// DeclineMessage is sent when a focusTime or outOfOffice event
+	// auto-declines a conflicting invitation.
+	DeclineMessage string
+	// Metadata is appended to the description as a machine-readable footer.
+	Metadata map[string]any
+	// Recurrence holds RFC 5545 recurrence lines making the event repeat.
+	// See parseRecurrence.
+	Recurrence string
+	// Reminders overrides the calendar's default reminders, e.g.
+	// "popup:10,email:60". See parseReminders.
+	Reminders string
+	// ColorID is an event color ID from list-colors.
+	ColorID string
+	// SendUpdates is who Google emails the invitation to: all,
+	// externalOnly or none. See validateSendUpdates.
+	SendUpdates string
+	// AddConference asks Google to create a Meet conference for the event.
+	AddConference bool
+}
+
 // CreateEvent creates a new calendar event.
-func (cs *CalendarService) CreateEvent(calendarID, summary, description, location, start, end, timezone, attendees string) (*eventJSON, error) {
+func (cs *CalendarService) CreateEvent(ctx context.Context, calendarID, summary, description, location, start, end, timezone string, attendees []Attendee, opts createEventOptions) (*eventJSON, error) {
 	if calendarID == "" {
 		calendarID = "primary"
 	}
+	if opts.Import && opts.ICalUID == "" {
+		return nil, fmt.Errorf("ical_uid is required when import is true")
+	}
+	if err := validateSendUpdates(opts.SendUpdates); err != nil {
+		return nil, err
+	}
+	if opts.Import && opts.SendUpdates != "" {
+		return nil, fmt.Errorf("send_updates cannot be used with import: imported events never send invitations")
+	}
+	if opts.Import && opts.AddConference {
+		return nil, fmt.Errorf("add_conference cannot be used with import")
+	}
+	if err := validateVisibility(opts.Visibility); err != nil {
+		return nil, err
+	}
+	if err := validateColorID(opts.ColorID); err != nil {
+		return nil, err
+	}
+	timezone = cs.defaultTimezone(timezone)
+	if err := validateTimezone(timezone); err != nil {
+		return nil, err
+	}
+	recurrence, err := parseRecurrence(opts.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	reminders, err := parseReminders(opts.Reminders)
+	if err != nil {
+		return nil, err
+	}
+	// Google needs a timezone to expand a timed rule across DST changes;
+	// without --timezone, default to the calendar's own.
+	if len(recurrence) > 0 && timezone == "" && !isDateOnly(start) {
+		if timezone, err = cs.calendarTimezone(ctx, calendarID); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Metadata != nil {
+		if description, err = appendMetadataFooter(description, opts.Metadata); err != nil {
+			return nil, err
+		}
+	}
 
 	event := &calendar.Event{
 		Summary:     summary,
 		Description: description,
 		Location:    location,
+		ICalUID:     opts.ICalUID,
+
+		AnyoneCanAddSelf: opts.AnyoneCanAddSelf,
+		Visibility:       opts.Visibility,
+		ColorId:          opts.ColorID,
+		Recurrence:       recurrence,
+		Reminders:        reminders,
 	}
 
 	startIsDate := isDateOnly(start)
@@ -273,30 +1295,92 @@ func (cs *CalendarService) CreateEvent(calendarID, summary, description, locatio
 		event.End.TimeZone = timezone
 	}
 
-	if attendees != "" {
-		for _, email := range strings.Split(attendees, ",") {
-			email = strings.TrimSpace(email)
-			if email != "" {
-				event.Attendees = append(event.Attendees, &calendar.EventAttendee{Email: email})
-			}
+	event.Attendees = toEventAttendees(attendees)
+	if err := applyEventType(event, opts.EventType, opts.DeclineMessage); err != nil {
+		return nil, err
+	}
+
+	if opts.Import {
+		imported, err := cs.svc.Events.Import(calendarID, event).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("import event: %w", err)
 		}
+		ev := convertEvent(imported)
+		return &ev, nil
 	}
 
-	created, err := cs.svc.Events.Insert(calendarID, event).Do()
+	call := cs.svc.Events.Insert(calendarID, event)
+	if opts.SendUpdates != "" {
+		call = call.SendUpdates(opts.SendUpdates)
+	}
+	if opts.AddConference {
+		// The request ID makes a retried insert reuse the same conference.
+		requestID, err := generateSecureToken(16)
+		if err != nil {
+			return nil, fmt.Errorf("generate conference request ID: %w", err)
+		}
+		event.ConferenceData = &calendar.ConferenceData{
+			CreateRequest: &calendar.CreateConferenceRequest{
+				RequestId:             requestID,
+				ConferenceSolutionKey: &calendar.ConferenceSolutionKey{Type: "hangoutsMeet"},
+			},
+		}
+		call = call.ConferenceDataVersion(1)
+	}
+	created, err := call.Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("create event: %w", err)
 	}
 	ev := convertEvent(created)
+	if opts.AddConference {
+		ev.Warning = conferenceWarning(created)
+	}
+	return &ev, nil
+}
+
+// conferenceWarning explains why Google created ev without the conference
+// requested for it, e.g. because the account cannot use Meet. It returns ""
+// if the conference was added or is still being created.
+func conferenceWarning(ev *calendar.Event) string {
+	cd := ev.ConferenceData
+	if cd == nil {
+		return "the event was created without a conference: Google ignored the request; conferencing may be unavailable for this calendar"
+	}
+	if cd.CreateRequest != nil && cd.CreateRequest.Status != nil && cd.CreateRequest.Status.StatusCode == "failure" {
+		return "the event was created but Google failed to add a conference; conferencing may be unavailable for this account"
+	}
+	return ""
+}
+
+// QuickAddEvent creates an event from a natural language description such as
+// "Dinner with Bob tomorrow 7pm", leaving Google to work out the time.
+func (cs *CalendarService) QuickAddEvent(ctx context.Context, calendarID, text string) (*eventJSON, error) {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("text is required")
+	}
+	created, err := cs.svc.Events.QuickAdd(calendarID, text).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("quick add event: %w", err)
+	}
+	ev := convertEvent(created)
 	return &ev, nil
 }
 
 // UpdateEvent updates an existing calendar event with the provided fields.
-func (cs *CalendarService) UpdateEvent(calendarID, eventID string, updates map[string]string) (*eventJSON, error) {
+// A non-nil attendees slice replaces the attendee list (an empty slice clears it).
+// sendUpdates is who Google emails the change to, as in createEventOptions.
+func (cs *CalendarService) UpdateEvent(ctx context.Context, calendarID, eventID string, updates map[string]string, attendees []Attendee, sendUpdates string) (*eventJSON, error) {
 	if calendarID == "" {
 		calendarID = "primary"
 	}
+	if err := validateSendUpdates(sendUpdates); err != nil {
+		return nil, err
+	}
 
-	existing, err := cs.svc.Events.Get(calendarID, eventID).Do()
+	existing, err := cs.svc.Events.Get(calendarID, eventID).Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("get event for update: %w", err)
 	}
@@ -344,6 +1428,7 @@ func (cs *CalendarService) UpdateEvent(calendarID, eventID string, updates map[s
 		if tz == "" && existing.End != nil {
 			tz = existing.End.TimeZone
 		}
+		tz = cs.defaultTimezone(tz)
 		loc := time.UTC
 		if tz != "" {
 			if l, err := time.LoadLocation(tz); err == nil {
@@ -359,17 +1444,57 @@ func (cs *CalendarService) UpdateEvent(calendarID, eventID string, updates map[s
 			existing.End = &calendar.EventDateTime{DateTime: t.Format(time.RFC3339), TimeZone: tz}
 		}
 	}
-	if v, ok := updates["attendees"]; ok {
-		existing.Attendees = nil
-		for _, email := range strings.Split(v, ",") {
-			email = strings.TrimSpace(email)
-			if email != "" {
-				existing.Attendees = append(existing.Attendees, &calendar.EventAttendee{Email: email})
+	if attendees != nil {
+		existing.Attendees = toEventAttendees(attendees)
+	}
+	if v, ok := updates["anyone_can_add_self"]; ok {
+		if v == "true" && existing.EventType != "" && existing.EventType != "default" {
+			return nil, fmt.Errorf("anyone_can_add_self cannot be set on %s events, which have no guests", existing.EventType)
+		}
+		existing.AnyoneCanAddSelf = v == "true"
+	}
+	if v, ok := updates["visibility"]; ok {
+		if err := validateVisibility(v); err != nil {
+			return nil, err
+		}
+		existing.Visibility = v
+	}
+	if v, ok := updates["color_id"]; ok {
+		if err := validateColorID(v); err != nil {
+			return nil, err
+		}
+		existing.ColorId = v
+	}
+	if v, ok := updates["recurrence"]; ok {
+		if existing.RecurringEventId != "" {
+			return nil, fmt.Errorf("event %s is an instance of recurring event %s: change the recurrence on that event instead", eventID, existing.RecurringEventId)
+		}
+		rules, err := parseRecurrence(v)
+		if err != nil {
+			return nil, err
+		}
+		// An empty list must still be sent, or the event stays recurring.
+		existing.Recurrence = rules
+		existing.ForceSendFields = append(existing.ForceSendFields, "Recurrence")
+		// As in CreateEvent, a timed rule needs a timezone to expand across
+		// DST changes; default to the calendar's own.
+		if len(rules) > 0 && existing.Start != nil && existing.Start.DateTime != "" && existing.Start.TimeZone == "" {
+			tz, err := cs.calendarTimezone(ctx, calendarID)
+			if err != nil {
+				return nil, err
+			}
+			existing.Start.TimeZone = tz
+			if existing.End != nil && existing.End.DateTime != "" && existing.End.TimeZone == "" {
+				existing.End.TimeZone = tz
 			}
 		}
 	}
 
-	updated, err := cs.svc.Events.Update(calendarID, eventID, existing).Do()
+	call := cs.svc.Events.Update(calendarID, eventID, existing)
+	if sendUpdates != "" {
+		call = call.SendUpdates(sendUpdates)
+	}
+	updated, err := call.Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("update event: %w", err)
 	}
@@ -378,26 +1503,79 @@ func (cs *CalendarService) UpdateEvent(calendarID, eventID string, updates map[s
 }
 
 // DeleteEvent deletes a calendar event.
-func (cs *CalendarService) DeleteEvent(calendarID, eventID string) error {
+func (cs *CalendarService) DeleteEvent(ctx context.Context, calendarID, eventID string) error {
 	if calendarID == "" {
 		calendarID = "primary"
 	}
-	return cs.svc.Events.Delete(calendarID, eventID).Do()
+	return cs.svc.Events.Delete(calendarID, eventID).Context(ctx).Do()
+}
+
+// MoveEvent moves an event to another calendar, keeping its ID and attendee
+// responses. The user must be an owner or writer of the destination.
+func (cs *CalendarService) MoveEvent(ctx context.Context, sourceCalendarID, eventID, destinationCalendarID string) (*eventJSON, error) {
+	if sourceCalendarID == "" {
+		sourceCalendarID = "primary"
+	}
+	if destinationCalendarID == "" {
+		return nil, fmt.Errorf("destination_calendar_id is required")
+	}
+	dest, err := cs.svc.CalendarList.Get(destinationCalendarID).Context(ctx).Do()
+	if isNotFound(err) {
+		return nil, fmt.Errorf("destination calendar %s is not in your calendar list", destinationCalendarID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get destination calendar: %w", err)
+	}
+	if dest.AccessRole != "owner" && dest.AccessRole != "writer" {
+		return nil, fmt.Errorf("destination calendar %s is not writable (your access role is %s)", destinationCalendarID, dest.AccessRole)
+	}
+	// The destination is a query parameter, where the "primary" alias is
+	// not resolved; pass the real IDs, and compare them to catch a move
+	// within one calendar.
+	source := sourceCalendarID
+	if strings.EqualFold(source, "primary") {
+		if source, err = cs.PrimaryCalendarID(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if source == dest.Id {
+		return nil, fmt.Errorf("event %s is already in calendar %s", eventID, dest.Id)
+	}
+	moved, err := cs.svc.Events.Move(sourceCalendarID, eventID, dest.Id).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("move event: %w", err)
+	}
+	ev := convertEvent(moved)
+	return &ev, nil
 }
 
 // RespondToEvent updates the authenticated user's response to an event invitation.
-func (cs *CalendarService) RespondToEvent(calendarID, eventID, response string) (*eventJSON, error) {
+// The declined_with_proposal response declines the event and attaches a proposed
+// new time (proposedStart/proposedEnd) to the attendee comment, which Google
+// includes in the update sent to the organizer.
+func (cs *CalendarService) RespondToEvent(ctx context.Context, calendarID, eventID, response, proposedStart, proposedEnd, comment string) (*eventJSON, error) {
 	if calendarID == "" {
 		calendarID = "primary"
 	}
 
 	switch response {
 	case "accepted", "declined", "tentative":
+	case "declined_with_proposal":
+		proposal, err := formatTimeProposal(proposedStart, proposedEnd)
+		if err != nil {
+			return nil, err
+		}
+		response = "declined"
+		if comment != "" {
+			comment = proposal + "\n" + comment
+		} else {
+			comment = proposal
+		}
 	default:
-		return nil, fmt.Errorf("invalid response: %s (must be accepted, declined, or tentative)", response)
+		return nil, fmt.Errorf("invalid response: %s (must be accepted, declined, tentative, or declined_with_proposal)", response)
 	}
 
-	event, err := cs.svc.Events.Get(calendarID, eventID).Do()
+	event, err := cs.svc.Events.Get(calendarID, eventID).Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("get event: %w", err)
 	}
@@ -406,6 +1584,9 @@ func (cs *CalendarService) RespondToEvent(calendarID, eventID, response string)
 	for _, a := range event.Attendees {
 		if a.Self {
 			a.ResponseStatus = response
+			if comment != "" {
+				a.Comment = comment
+			}
 			found = true
 			break
 		}
@@ -414,7 +1595,7 @@ func (cs *CalendarService) RespondToEvent(calendarID, eventID, response string)
 		return nil, fmt.Errorf("you are not an attendee of this event")
 	}
 
-	updated, err := cs.svc.Events.Update(calendarID, eventID, event).SendUpdates("all").Do()
+	updated, err := cs.svc.Events.Update(calendarID, eventID, event).SendUpdates("all").Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("update response: %w", err)
 	}
@@ -422,6 +1603,26 @@ func (cs *CalendarService) RespondToEvent(calendarID, eventID, response string)
 	return &ev, nil
 }
 
+// formatTimeProposal validates a proposed start/end pair (RFC3339) and renders
+// it as the attendee comment used for declined_with_proposal responses.
+func formatTimeProposal(start, end string) (string, error) {
+	if start == "" || end == "" {
+		return "", fmt.Errorf("proposed_start and proposed_end are required for declined_with_proposal")
+	}
+	s, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		return "", fmt.Errorf("invalid proposed_start %q: must be RFC3339", start)
+	}
+	e, err := time.Parse(time.RFC3339, end)
+	if err != nil {
+		return "", fmt.Errorf("invalid proposed_end %q: must be RFC3339", end)
+	}
+	if !e.After(s) {
+		return "", fmt.Errorf("proposed_end must be after proposed_start")
+	}
+	return fmt.Sprintf("Proposed new time: %s - %s", start, end), nil
+}
+
 // isDateOnly returns true if s looks like a date-only string (YYYY-MM-DD).
 func isDateOnly(s string) bool {
 	return len(s) == 10 && s[4] == '-' && s[7] == '-'
@@ -0,0 +1,1399 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// newTestCalendarService returns a CalendarService whose API calls are served by handler.
+func newTestCalendarService(t *testing.T, handler http.Handler) *CalendarService {
+	t.Helper()
+	fake := httptest.NewServer(handler)
+	t.Cleanup(fake.Close)
+	svc, err := calendar.NewService(context.Background(),
+		option.WithHTTPClient(fake.Client()),
+		option.WithEndpoint(fake.URL+"/"),
+	)
+	if err != nil {
+		t.Fatalf("calendar.NewService() error = %v", err)
+	}
+	return &CalendarService{svc: svc}
+}
+
+func TestFormatTimeProposal(t *testing.T) {
+	t.Parallel()
+
+	got, err := formatTimeProposal("2025-03-10T15:00:00+09:00", "2025-03-10T16:00:00+09:00")
+	if err != nil {
+		t.Fatalf("formatTimeProposal() error = %v", err)
+	}
+	if !strings.Contains(got, "2025-03-10T15:00:00+09:00") || !strings.Contains(got, "2025-03-10T16:00:00+09:00") {
+		t.Fatalf("formatTimeProposal() = %q, want both times included", got)
+	}
+}
+
+func TestFormatTimeProposal_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		start string
+		end   string
+	}{
+		{"missing start", "", "2025-03-10T16:00:00Z"},
+		{"missing end", "2025-03-10T15:00:00Z", ""},
+		{"date only", "2025-03-10", "2025-03-11"},
+		{"end before start", "2025-03-10T16:00:00Z", "2025-03-10T15:00:00Z"},
+		{"end equals start", "2025-03-10T15:00:00Z", "2025-03-10T15:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := formatTimeProposal(tt.start, tt.end); err == nil {
+				t.Fatalf("formatTimeProposal(%q, %q) expected error", tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestCreateEvent_ImportPreservesICalUID(t *testing.T) {
+	t.Parallel()
+
+	var gotPath string
+	var gotEvent calendar.Event
+	cs := newTestCalendarService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&gotEvent); err != nil {
+			t.Errorf("decode request body: %v", err)
+		}
+		gotEvent.Id = "imported1"
+		_ = json.NewEncoder(w).Encode(gotEvent)
+	}))
+
+	ev, err := cs.CreateEvent(context.Background(), "", "Standup", "", "", "2025-03-10T10:00:00Z", "2025-03-10T10:15:00Z", "", nil,
+		createEventOptions{ICalUID: "abc-123@example.com", Import: true})
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	if gotPath != "/calendars/primary/events/import" {
+		t.Fatalf("request path = %q, want import endpoint", gotPath)
+	}
+	if gotEvent.ICalUID != "abc-123@example.com" {
+		t.Fatalf("request iCalUID = %q, want %q", gotEvent.ICalUID, "abc-123@example.com")
+	}
+	if ev.ICalUID != "abc-123@example.com" {
+		t.Fatalf("result ICalUID = %q, want %q", ev.ICalUID, "abc-123@example.com")
+	}
+}
+
+func TestCreateEvent_ImportRequiresICalUID(t *testing.T) {
+	t.Parallel()
+
+	cs := &CalendarService{}
+	_, err := cs.CreateEvent(context.Background(), "", "Standup", "", "", "2025-03-10T10:00:00Z", "2025-03-10T10:15:00Z", "", nil,
+		createEventOptions{Import: true})
+	if err == nil || !strings.Contains(err.Error(), "ical_uid") {
+		t.Fatalf("CreateEvent() error = %v, want ical_uid required", err)
+	}
+}
+
+func TestCreateEvent_AnyoneCanAddSelf(t *testing.T) {
+	t.Parallel()
+
+	var gotEvent calendar.Event
+	cs := newTestCalendarService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&gotEvent); err != nil {
+			t.Errorf("decode request body: %v", err)
+		}
+		resp := gotEvent
+		resp.Id = "ev1"
+		resp.Locked = true // read-only, set by Google
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+
+	ev, err := cs.CreateEvent(context.Background(), "", "Office hours", "", "", "2025-03-10T10:00:00Z", "2025-03-10T11:00:00Z", "", nil,
+		createEventOptions{AnyoneCanAddSelf: true})
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	if !gotEvent.AnyoneCanAddSelf || gotEvent.Locked {
+		t.Fatalf("request anyoneCanAddSelf, locked = %v, %v, want true, false", gotEvent.AnyoneCanAddSelf, gotEvent.Locked)
+	}
+	if !ev.AnyoneCanAddSelf || !ev.Locked {
+		t.Fatalf("result AnyoneCanAddSelf, Locked = %v, %v, want true, true", ev.AnyoneCanAddSelf, ev.Locked)
+	}
+
+	// Event types without guests reject it.
+	_, err = cs.CreateEvent(context.Background(), "", "Focus", "", "", "2025-03-10T10:00:00Z", "2025-03-10T11:00:00Z", "", nil,
+		createEventOptions{AnyoneCanAddSelf: true, EventType: "focusTime"})
+	if err == nil || !strings.Contains(err.Error(), "anyone_can_add_self") {
+		t.Fatalf("CreateEvent(focusTime) error = %v, want anyone_can_add_self rejected", err)
+	}
+}
+
+func TestUpdateEvent_AnyoneCanAddSelfRequiresGuests(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/calendars/primary/events/ooo", &calendar.Event{Id: "ooo", EventType: "outOfOffice"})
+	fake.respond("PUT", "/calendars/primary/events/ooo", &calendar.Event{Id: "ooo", EventType: "outOfOffice"})
+
+	_, err := fake.calendarService().UpdateEvent(context.Background(), "", "ooo", map[string]string{"anyone_can_add_self": "true"}, nil, "")
+	if err == nil || !strings.Contains(err.Error(), "anyone_can_add_self") {
+		t.Fatalf("UpdateEvent(outOfOffice) error = %v, want anyone_can_add_self rejected", err)
+	}
+	if _, err := fake.calendarService().UpdateEvent(context.Background(), "", "ooo", map[string]string{"anyone_can_add_self": "false"}, nil, ""); err != nil {
+		t.Fatalf("UpdateEvent(anyone_can_add_self=false) error = %v", err)
+	}
+}
+
+func TestValidateTimeRange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		timeMin string
+		timeMax string
+		wantErr string
+	}{
+		{name: "both empty"},
+		{name: "only min", timeMin: "2025-03-10T00:00:00Z"},
+		{name: "ordered", timeMin: "2025-03-10T00:00:00Z", timeMax: "2025-03-11T00:00:00.000Z"},
+		{name: "inverted", timeMin: "2025-03-11T00:00:00Z", timeMax: "2025-03-10T00:00:00Z", wantErr: "must be before"},
+		{name: "equal", timeMin: "2025-03-10T00:00:00Z", timeMax: "2025-03-10T00:00:00Z", wantErr: "must be before"},
+		{name: "bad min", timeMin: "tomorrow", timeMax: "2025-03-10T00:00:00Z", wantErr: "invalid time_min"},
+	}
+	for _, tt := range tests {
+		err := validateTimeRange(tt.timeMin, tt.timeMax)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Fatalf("%s: validateTimeRange() error = %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("%s: validateTimeRange() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestListAndSearchEvents_InvertedRange(t *testing.T) {
+	t.Parallel()
+
+	cs := &CalendarService{}
+	ctx := context.Background()
+	if _, err := cs.ListEvents(ctx, "", "2025-03-11T00:00:00Z", "2025-03-10T00:00:00Z", 0, true, "", ""); err == nil {
+		t.Fatal("ListEvents() error = nil, want inverted range error")
+	}
+	if _, err := cs.SearchEvents(ctx, "", "q", "2025-03-11T00:00:00Z", "2025-03-10T00:00:00Z", 0, ""); err == nil {
+		t.Fatal("SearchEvents() error = nil, want inverted range error")
+	}
+}
+
+func TestSearchEvents_OrderBy(t *testing.T) {
+	t.Parallel()
+
+	var gotOrderBy []string
+	cs := newTestCalendarService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotOrderBy = append(gotOrderBy, r.URL.Query().Get("orderBy"))
+		_ = json.NewEncoder(w).Encode(&calendar.Events{})
+	}))
+
+	for _, orderBy := range []string{"", "updated"} {
+		if _, err := cs.SearchEvents(context.Background(), "", "standup", "", "", 0, orderBy); err != nil {
+			t.Fatalf("SearchEvents(%q) error = %v", orderBy, err)
+		}
+	}
+	if len(gotOrderBy) != 2 || gotOrderBy[0] != "startTime" || gotOrderBy[1] != "updated" {
+		t.Fatalf("orderBy params = %v, want [startTime updated]", gotOrderBy)
+	}
+}
+
+func TestFilterEvents(t *testing.T) {
+	t.Parallel()
+
+	events := []eventJSON{
+		{ID: "accepted", Attendees: []attendeeJSON{{Email: "me@example.com", Self: true, ResponseStatus: "accepted"}}},
+		{ID: "declined", Attendees: []attendeeJSON{{Email: "me@example.com", Self: true, ResponseStatus: "declined"}}},
+		{ID: "pending", Attendees: []attendeeJSON{{Email: "me@example.com", Self: true, ResponseStatus: "needsAction"}}},
+		{ID: "not-invited", Attendees: []attendeeJSON{{Email: "other@example.com", ResponseStatus: "accepted"}}},
+		{ID: "own", Organizer: &organizerJSON{Email: "me@example.com", Self: true}},
+		{ID: "shared-calendar", Organizer: &organizerJSON{Email: "other@example.com"}},
+	}
+
+	ids := func(evs []eventJSON) string {
+		var out []string
+		for _, ev := range evs {
+			out = append(out, ev.ID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		name            string
+		myEventsOnly    bool
+		excludeDeclined bool
+		want            string
+	}{
+		{"no filter", false, false, "accepted,declined,pending,not-invited,own,shared-calendar"},
+		{"exclude declined", false, true, "accepted,pending,not-invited,own,shared-calendar"},
+		{"my events only", true, false, "accepted,pending,own"},
+		{"both", true, true, "accepted,pending,own"},
+	}
+	for _, tt := range tests {
+		got := ids(filterEvents(events, tt.myEventsOnly, tt.excludeDeclined))
+		if got != tt.want {
+			t.Fatalf("%s: filterEvents() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetCalendar(t *testing.T) {
+	t.Parallel()
+
+	var gotPath string
+	cs := newTestCalendarService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_ = json.NewEncoder(w).Encode(&calendar.CalendarListEntry{
+			Id:               "team@example.com",
+			Summary:          "Team",
+			AccessRole:       "reader",
+			BackgroundColor:  "#9fe1e7",
+			Selected:         true,
+			DefaultReminders: []*calendar.EventReminder{{Method: "popup", Minutes: 10}},
+			NotificationSettings: &calendar.CalendarListEntryNotificationSettings{
+				Notifications: []*calendar.CalendarNotification{{Type: "eventCreation", Method: "email"}},
+			},
+		})
+	}))
+
+	cal, err := cs.GetCalendar(context.Background(), "team@example.com")
+	if err != nil {
+		t.Fatalf("GetCalendar() error = %v", err)
+	}
+	if gotPath != "/users/me/calendarList/team@example.com" {
+		t.Fatalf("request path = %q", gotPath)
+	}
+	if cal.AccessRole != "reader" || !cal.Selected || cal.BackgroundColor != "#9fe1e7" {
+		t.Fatalf("GetCalendar() = %+v", cal)
+	}
+	if len(cal.DefaultReminders) != 1 || cal.DefaultReminders[0].Minutes != 10 {
+		t.Fatalf("DefaultReminders = %+v", cal.DefaultReminders)
+	}
+	if len(cal.NotificationSettings) != 1 || cal.NotificationSettings[0].Type != "eventCreation" {
+		t.Fatalf("NotificationSettings = %+v", cal.NotificationSettings)
+	}
+}
+
+func TestCreateEvent_Visibility(t *testing.T) {
+	t.Parallel()
+
+	var gotEvent calendar.Event
+	cs := newTestCalendarService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&gotEvent); err != nil {
+			t.Errorf("decode request body: %v", err)
+		}
+		gotEvent.Id = "ev1"
+		_ = json.NewEncoder(w).Encode(gotEvent)
+	}))
+
+	ev, err := cs.CreateEvent(context.Background(), "", "1:1", "", "", "2025-03-10T10:00:00Z", "2025-03-10T10:30:00Z", "", nil,
+		createEventOptions{Visibility: "private"})
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	if gotEvent.Visibility != "private" || ev.Visibility != "private" {
+		t.Fatalf("visibility request=%q result=%q, want private", gotEvent.Visibility, ev.Visibility)
+	}
+}
+
+func TestCreateEvent_Recurrence(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/users/me/calendarList/primary", &calendar.CalendarListEntry{Id: "primary", TimeZone: "Asia/Tokyo"})
+	fake.handle("POST", "/calendars/primary/events", func(_ *http.Request, body []byte) any {
+		var ev calendar.Event
+		_ = json.Unmarshal(body, &ev)
+		ev.Id = "ev1"
+		return &ev
+	})
+
+	ev, err := fake.calendarService().CreateEvent(context.Background(), "", "Standup", "", "",
+		"2025-03-10T10:00:00", "2025-03-10T10:15:00", "", nil,
+		createEventOptions{Recurrence: "RRULE:FREQ=WEEKLY;BYDAY=MO,WE,EXDATE;TZID=Asia/Tokyo:20250317T100000,20250324T100000"})
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	want := []string{"RRULE:FREQ=WEEKLY;BYDAY=MO,WE", "EXDATE;TZID=Asia/Tokyo:20250317T100000,20250324T100000"}
+	if strings.Join(ev.Recurrence, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("recurrence = %q, want %q", ev.Recurrence, want)
+	}
+	req, _ := fake.request("POST", "/calendars/primary/events")
+	if !strings.Contains(string(req.Body), `"timeZone":"Asia/Tokyo"`) {
+		t.Fatalf("request body = %s, want the calendar's timezone", req.Body)
+	}
+
+	_, err = fake.calendarService().CreateEvent(context.Background(), "", "Standup", "", "",
+		"2025-03-10T10:00:00Z", "2025-03-10T10:15:00Z", "", nil, createEventOptions{Recurrence: "every monday"})
+	if err == nil || !strings.Contains(err.Error(), "invalid recurrence") {
+		t.Fatalf("CreateEvent(invalid rule) error = %v, want invalid recurrence", err)
+	}
+}
+
+func TestCreateEvent_RemindersRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		reminders string
+		want      *eventRemindersJSON
+	}{
+		{"overrides", "popup:10, email:60", &eventRemindersJSON{Overrides: []reminderJSON{{Method: "popup", Minutes: 10}, {Method: "email", Minutes: 60}}}},
+		{"default", "", &eventRemindersJSON{UseDefault: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stored calendar.Event
+			fake := newFakeGoogleAPI(t)
+			fake.handle("POST", "/calendars/primary/events", func(_ *http.Request, body []byte) any {
+				_ = json.Unmarshal(body, &stored)
+				stored.Id = "ev1"
+				return &stored
+			})
+			fake.handle("GET", "/calendars/primary/events/ev1", func(*http.Request, []byte) any { return &stored })
+			cs := fake.calendarService()
+
+			if _, err := cs.CreateEvent(context.Background(), "", "Standup", "", "", "2025-03-10T10:00:00Z", "2025-03-10T10:15:00Z", "", nil,
+				createEventOptions{Reminders: tt.reminders}); err != nil {
+				t.Fatalf("CreateEvent() error = %v", err)
+			}
+			ev, err := cs.GetEvent(context.Background(), "", "ev1", false, false)
+			if err != nil {
+				t.Fatalf("GetEvent() error = %v", err)
+			}
+			got, _ := json.Marshal(ev.Reminders)
+			want, _ := json.Marshal(tt.want)
+			if string(got) != string(want) {
+				t.Fatalf("reminders = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseReminders_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"sms:10", "popup", "popup:soon", "email:-5", "popup:40321", "popup:1,popup:2,popup:3,popup:4,popup:5,popup:6"} {
+		if _, err := parseReminders(s); err == nil {
+			t.Errorf("parseReminders(%q) expected error", s)
+		}
+	}
+}
+
+func TestVisibility_Invalid(t *testing.T) {
+	t.Parallel()
+
+	cs := &CalendarService{}
+	_, err := cs.CreateEvent(context.Background(), "", "1:1", "", "", "2025-03-10T10:00:00Z", "2025-03-10T10:30:00Z", "", nil,
+		createEventOptions{Visibility: "secret"})
+	if err == nil || !strings.Contains(err.Error(), "invalid visibility") {
+		t.Fatalf("CreateEvent() error = %v, want invalid visibility", err)
+	}
+
+	cs = newTestCalendarService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("unexpected %s request after invalid visibility", r.Method)
+		}
+		_ = json.NewEncoder(w).Encode(&calendar.Event{Id: "ev1"})
+	}))
+	_, err = cs.UpdateEvent(context.Background(), "", "ev1", map[string]string{"visibility": "secret"}, nil, "")
+	if err == nil || !strings.Contains(err.Error(), "invalid visibility") {
+		t.Fatalf("UpdateEvent() error = %v, want invalid visibility", err)
+	}
+}
+
+func TestApplyEventType(t *testing.T) {
+	t.Parallel()
+
+	ev := &calendar.Event{}
+	if err := applyEventType(ev, "focusTime", "Heads down"); err != nil {
+		t.Fatalf("applyEventType(focusTime) error = %v", err)
+	}
+	if ev.EventType != "focusTime" || ev.FocusTimeProperties == nil || ev.FocusTimeProperties.DeclineMessage != "Heads down" {
+		t.Fatalf("focusTime event = %+v", ev)
+	}
+
+	ev = &calendar.Event{}
+	if err := applyEventType(ev, "outOfOffice", ""); err != nil {
+		t.Fatalf("applyEventType(outOfOffice) error = %v", err)
+	}
+	if ev.OutOfOfficeProperties == nil || ev.OutOfOfficeProperties.AutoDeclineMode == "" {
+		t.Fatalf("outOfOffice event = %+v", ev)
+	}
+
+	ev = &calendar.Event{Location: "Tokyo office"}
+	if err := applyEventType(ev, "workingLocation", ""); err != nil {
+		t.Fatalf("applyEventType(workingLocation) error = %v", err)
+	}
+	if p := ev.WorkingLocationProperties; p == nil || p.Type != "customLocation" || p.CustomLocation.Label != "Tokyo office" {
+		t.Fatalf("workingLocation properties = %+v", ev.WorkingLocationProperties)
+	}
+
+	ev = &calendar.Event{}
+	if err := applyEventType(ev, "workingLocation", ""); err != nil || ev.WorkingLocationProperties.Type != "homeOffice" {
+		t.Fatalf("applyEventType(workingLocation, no location) = %v, %+v", err, ev.WorkingLocationProperties)
+	}
+
+	ev = &calendar.Event{}
+	if err := applyEventType(ev, "", ""); err != nil || ev.EventType != "" {
+		t.Fatalf("applyEventType(\"\") = %v, EventType %q", err, ev.EventType)
+	}
+}
+
+func TestApplyEventType_Invalid(t *testing.T) {
+	t.Parallel()
+
+	withAttendees := &calendar.Event{Attendees: []*calendar.EventAttendee{{Email: "a@example.com"}}}
+	if err := applyEventType(withAttendees, "outOfOffice", ""); err == nil || !strings.Contains(err.Error(), "attendees") {
+		t.Fatalf("applyEventType() with attendees error = %v, want attendees error", err)
+	}
+	if err := applyEventType(&calendar.Event{}, "birthday", ""); err == nil || !strings.Contains(err.Error(), "invalid event_type") {
+		t.Fatalf("applyEventType(birthday) error = %v, want invalid event_type", err)
+	}
+}
+
+func TestGetEvent_IncludeAvailability(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/calendars/primary/events/e1", &calendar.Event{
+		Id:      "e1",
+		Summary: "Planning",
+		Start:   &calendar.EventDateTime{DateTime: "2025-03-01T10:00:00Z"},
+		End:     &calendar.EventDateTime{DateTime: "2025-03-01T11:00:00Z"},
+		Attendees: []*calendar.EventAttendee{
+			{Email: "free@example.com", ResponseStatus: "accepted"},
+			{Email: "busy@example.com", ResponseStatus: "accepted"},
+			{Email: "declined@example.com", ResponseStatus: "declined"},
+			{Email: "external@other.com"},
+		},
+	})
+	fake.respond("POST", "/freeBusy", &calendar.FreeBusyResponse{
+		Calendars: map[string]calendar.FreeBusyCalendar{
+			// Busy only with the meeting itself.
+			"free@example.com": {Busy: []*calendar.TimePeriod{{Start: "2025-03-01T10:00:00Z", End: "2025-03-01T11:00:00Z"}}},
+			"busy@example.com": {Busy: []*calendar.TimePeriod{{Start: "2025-03-01T09:30:00Z", End: "2025-03-01T11:00:00Z"}}},
+			// Declined, so a period matching the meeting is something else.
+			"declined@example.com": {Busy: []*calendar.TimePeriod{{Start: "2025-03-01T10:00:00Z", End: "2025-03-01T11:00:00Z"}}},
+			"external@other.com":   {Errors: []*calendar.Error{{Reason: "notFound"}}},
+		},
+	})
+	cs := fake.calendarService()
+
+	ev, err := cs.GetEvent(context.Background(), "", "e1", true, false)
+	if err != nil {
+		t.Fatalf("GetEvent() error = %v", err)
+	}
+
+	want := map[string]string{
+		"free@example.com":     "false",
+		"busy@example.com":     "true",
+		"declined@example.com": "true",
+		"external@other.com":   "unknown",
+	}
+	for _, a := range ev.Attendees {
+		got := "unknown"
+		if a.Busy != nil {
+			got = strconv.FormatBool(*a.Busy)
+		}
+		if got != want[a.Email] {
+			t.Errorf("attendee %s busy = %s, want %s", a.Email, got, want[a.Email])
+		}
+	}
+
+	req, _ := fake.request("POST", "/freeBusy")
+	var fbReq calendar.FreeBusyRequest
+	fake.decodeBody(req, &fbReq)
+	if fbReq.TimeMin != "2025-03-01T10:00:00Z" || fbReq.TimeMax != "2025-03-01T11:00:00Z" || len(fbReq.Items) != 4 {
+		t.Fatalf("free/busy request = %+v", fbReq)
+	}
+}
+
+func TestGetEvent_IncludeAvailabilityAllDayManyAttendees(t *testing.T) {
+	t.Parallel()
+
+	var attendees []*calendar.EventAttendee
+	for i := 0; i < maxFreeBusyCalendars+10; i++ {
+		attendees = append(attendees, &calendar.EventAttendee{Email: fmt.Sprintf("a%d@example.com", i), ResponseStatus: "accepted"})
+	}
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/calendars/primary/events/e1", &calendar.Event{
+		Id:        "e1",
+		Start:     &calendar.EventDateTime{Date: "2025-03-01"},
+		End:       &calendar.EventDateTime{Date: "2025-03-02"},
+		Attendees: attendees,
+	})
+	fake.respond("GET", "/users/me/calendarList/primary", &calendar.CalendarListEntry{Id: "me@example.com", TimeZone: "Asia/Tokyo"})
+	var queries []calendar.FreeBusyRequest
+	fake.handle("POST", "/freeBusy", func(_ *http.Request, body []byte) any {
+		var req calendar.FreeBusyRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("decode free/busy request: %v", err)
+		}
+		queries = append(queries, req)
+		calendars := map[string]calendar.FreeBusyCalendar{}
+		for _, item := range req.Items {
+			calendars[item.Id] = calendar.FreeBusyCalendar{}
+		}
+		return &calendar.FreeBusyResponse{Calendars: calendars}
+	})
+
+	ev, err := fake.calendarService().GetEvent(context.Background(), "", "e1", true, false)
+	if err != nil {
+		t.Fatalf("GetEvent() error = %v", err)
+	}
+	if len(queries) != 2 || len(queries[0].Items) != maxFreeBusyCalendars || len(queries[1].Items) != 10 {
+		t.Fatalf("free/busy queries = %d, want the attendees split at %d", len(queries), maxFreeBusyCalendars)
+	}
+	if queries[0].TimeMin != "2025-03-01T00:00:00+09:00" || queries[0].TimeMax != "2025-03-02T00:00:00+09:00" {
+		t.Fatalf("free/busy window = %s..%s, want the day in the calendar's timezone", queries[0].TimeMin, queries[0].TimeMax)
+	}
+	for _, a := range ev.Attendees {
+		if a.Busy == nil || *a.Busy {
+			t.Fatalf("attendee %s busy = %v, want free", a.Email, a.Busy)
+		}
+	}
+}
+
+func TestMetadataFooter_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, description := range []string{"", "Agenda:\n- budget\n"} {
+		withFooter, err := appendMetadataFooter(description, map[string]any{"ticket": "OPS-12", "priority": float64(2)})
+		if err != nil {
+			t.Fatalf("appendMetadataFooter() error = %v", err)
+		}
+		if !strings.HasSuffix(withFooter, "```mcp-gcal-metadata\n{\"priority\":2,\"ticket\":\"OPS-12\"}\n```") {
+			t.Fatalf("appendMetadataFooter() = %q", withFooter)
+		}
+
+		text, metadata := splitMetadataFooter(withFooter)
+		if text != strings.TrimRight(description, "\n") {
+			t.Errorf("description = %q, want %q", text, strings.TrimRight(description, "\n"))
+		}
+		if metadata["ticket"] != "OPS-12" || metadata["priority"] != float64(2) {
+			t.Errorf("metadata = %v", metadata)
+		}
+	}
+}
+
+func TestSplitMetadataFooter_NoOrMangledFooter(t *testing.T) {
+	t.Parallel()
+
+	for _, description := range []string{
+		"Just notes",
+		"Notes\n\n```mcp-gcal-metadata\n{not json}\n```",
+		"Notes\n\n```mcp-gcal-metadata\n{\"a\":1}",
+	} {
+		text, metadata := splitMetadataFooter(description)
+		if text != description || metadata != nil {
+			t.Errorf("splitMetadataFooter(%q) = %q, %v, want unchanged and nil", description, text, metadata)
+		}
+	}
+}
+
+func TestConflictingEvents(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	timed := func(id, s, e string) *calendar.Event {
+		return &calendar.Event{Id: id, Start: &calendar.EventDateTime{DateTime: s}, End: &calendar.EventDateTime{DateTime: e}}
+	}
+
+	overlapping := timed("overlapping", "2025-03-01T10:30:00Z", "2025-03-01T11:30:00Z")
+	containing := timed("containing", "2025-03-01T09:00:00Z", "2025-03-01T12:00:00Z")
+	otherZone := timed("other-zone", "2025-03-01T19:45:00+09:00", "2025-03-01T20:15:00+09:00")
+	allDay := &calendar.Event{Id: "all-day", Start: &calendar.EventDateTime{Date: "2025-03-01"}, End: &calendar.EventDateTime{Date: "2025-03-02"}}
+	before := timed("before", "2025-03-01T09:00:00Z", "2025-03-01T10:00:00Z")
+	after := timed("after", "2025-03-01T11:00:00Z", "2025-03-01T12:00:00Z")
+	free := timed("free", "2025-03-01T10:00:00Z", "2025-03-01T11:00:00Z")
+	free.Transparency = "transparent"
+	cancelled := timed("cancelled", "2025-03-01T10:00:00Z", "2025-03-01T11:00:00Z")
+	cancelled.Status = "cancelled"
+	declined := timed("declined", "2025-03-01T10:00:00Z", "2025-03-01T11:00:00Z")
+	declined.Attendees = []*calendar.EventAttendee{{Email: "me@example.com", Self: true, ResponseStatus: "declined"}}
+
+	got := conflictingEvents([]*calendar.Event{
+		overlapping, containing, otherZone, allDay, before, after, free, cancelled, declined,
+	}, start, end, "")
+
+	var ids []string
+	for _, e := range got {
+		ids = append(ids, e.Id)
+	}
+	want := []string{"overlapping", "containing", "other-zone", "all-day"}
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Fatalf("conflictingEvents() = %v, want %v", ids, want)
+	}
+}
+
+func TestFindConflicts_AllDayInCalendarTimezone(t *testing.T) {
+	t.Parallel()
+
+	// 2025-03-01T16:00Z is already March 2 in Tokyo, where the calendar lives.
+	fake := newFakeGoogleAPI(t)
+	fake.handle("GET", "/calendars/primary/events", func(r *http.Request, _ []byte) any {
+		if r.URL.Query().Get("pageToken") == "" {
+			return &calendar.Events{TimeZone: "Asia/Tokyo", NextPageToken: "p2", Items: []*calendar.Event{
+				{Id: "day1", Start: &calendar.EventDateTime{Date: "2025-03-01"}, End: &calendar.EventDateTime{Date: "2025-03-02"}},
+			}}
+		}
+		return &calendar.Events{TimeZone: "Asia/Tokyo", Items: []*calendar.Event{
+			{Id: "day2", Start: &calendar.EventDateTime{Date: "2025-03-02"}, End: &calendar.EventDateTime{Date: "2025-03-03"}},
+		}}
+	})
+
+	got, err := fake.calendarService().FindConflicts(context.Background(), "", "2025-03-01T16:00:00Z", "2025-03-01T17:00:00Z", "")
+	if err != nil {
+		t.Fatalf("FindConflicts() error = %v", err)
+	}
+	if len(got) != 1 || got[0].ID != "day2" {
+		t.Fatalf("FindConflicts() = %+v, want only the second page's March 2 event", got)
+	}
+}
+
+func TestListCalendars_Cache(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	calls := 0
+	fake.handle("GET", "/users/me/calendarList", func(*http.Request, []byte) any {
+		calls++
+		return map[string]any{"items": []map[string]any{{"id": "cal" + strconv.Itoa(calls)}}}
+	})
+
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	cache := newTTLCache[[]calendarJSON](time.Minute)
+	cache.now = func() time.Time { return now }
+
+	list := func(key string) string {
+		t.Helper()
+		cs := fake.calendarService()
+		cs.caches.lists, cs.cacheKey = cache, key
+		cals, err := cs.ListCalendars(context.Background(), false)
+		if err != nil {
+			t.Fatalf("ListCalendars() error = %v", err)
+		}
+		if len(cals) != 1 {
+			t.Fatalf("ListCalendars() = %+v, want 1 calendar", cals)
+		}
+		return cals[0].ID
+	}
+
+	if got := list("alice@example.com"); got != "cal1" {
+		t.Fatalf("first list = %q, want cal1", got)
+	}
+	now = now.Add(30 * time.Second)
+	if got := list("alice@example.com"); got != "cal1" || calls != 1 {
+		t.Fatalf("list within TTL = %q after %d API calls, want cached cal1", got, calls)
+	}
+	if got := list("bob@example.com"); got != "cal2" {
+		t.Fatalf("other user's list = %q, want cal2", got)
+	}
+	now = now.Add(30 * time.Second)
+	if got := list("alice@example.com"); got != "cal3" {
+		t.Fatalf("list after TTL = %q, want refreshed cal3", got)
+	}
+	cache.invalidate("alice@example.com")
+	if got := list("alice@example.com"); got != "cal4" {
+		t.Fatalf("list after invalidate = %q, want refreshed cal4", got)
+	}
+}
+
+func TestPrimaryCalendarID_Cache(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	calls := 0
+	fake.handle("GET", "/users/me/calendarList/primary", func(*http.Request, []byte) any {
+		calls++
+		return map[string]any{"id": "alice@example.com", "primary": true}
+	})
+	caches := newCalendarCaches(time.Minute)
+
+	for i := 0; i < 2; i++ {
+		cs := fake.calendarService()
+		cs.caches, cs.cacheKey = caches, "alice@example.com"
+		id, err := cs.PrimaryCalendarID(context.Background())
+		if err != nil {
+			t.Fatalf("PrimaryCalendarID() error = %v", err)
+		}
+		if id != "alice@example.com" {
+			t.Fatalf("PrimaryCalendarID() = %q, want alice@example.com", id)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("resolved primary calendar %d times, want 1", calls)
+	}
+
+	caches.invalidate("alice@example.com")
+	cs := fake.calendarService()
+	cs.caches, cs.cacheKey = caches, "alice@example.com"
+	if _, err := cs.PrimaryCalendarID(context.Background()); err != nil {
+		t.Fatalf("PrimaryCalendarID() error = %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("resolved primary calendar %d times after invalidate, want 2", calls)
+	}
+}
+
+func TestUpdateEvent_Recurrence(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		recurrence string
+		wantBody   string
+		want       []string
+	}{
+		{"set", "RRULE:FREQ=WEEKLY;BYDAY=MO\nEXDATE;TZID=Asia/Tokyo:20250317T100000", `"recurrence":["RRULE:FREQ=WEEKLY;BYDAY=MO","EXDATE;TZID=Asia/Tokyo:20250317T100000"]`,
+			[]string{"RRULE:FREQ=WEEKLY;BYDAY=MO", "EXDATE;TZID=Asia/Tokyo:20250317T100000"}},
+		{"bare rule", "FREQ=DAILY;COUNT=5", `"recurrence":["RRULE:FREQ=DAILY;COUNT=5"]`, []string{"RRULE:FREQ=DAILY;COUNT=5"}},
+		{"clear", "", `"recurrence":[]`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fake := newFakeGoogleAPI(t)
+			fake.respond("GET", "/calendars/primary/events/ev1", &calendar.Event{
+				Id:         "ev1",
+				Summary:    "Standup",
+				Recurrence: []string{"RRULE:FREQ=DAILY"},
+			})
+			fake.handle("PUT", "/calendars/primary/events/ev1", func(_ *http.Request, body []byte) any {
+				var ev calendar.Event
+				_ = json.Unmarshal(body, &ev)
+				return &ev
+			})
+
+			ev, err := fake.calendarService().UpdateEvent(context.Background(), "", "ev1",
+				map[string]string{"recurrence": tt.recurrence}, nil, "")
+			if err != nil {
+				t.Fatalf("UpdateEvent() error = %v", err)
+			}
+			req, _ := fake.request("PUT", "/calendars/primary/events/ev1")
+			if !strings.Contains(string(req.Body), tt.wantBody) {
+				t.Fatalf("request body = %s, want %s", req.Body, tt.wantBody)
+			}
+			if strings.Join(ev.Recurrence, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("recurrence = %q, want %q", ev.Recurrence, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateEvent_RecurrenceDefaultsToCalendarTimezone(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/users/me/calendarList/team", &calendar.CalendarListEntry{Id: "team", TimeZone: "Asia/Tokyo"})
+	fake.respond("GET", "/calendars/team/events/ev1", &calendar.Event{
+		Id:      "ev1",
+		Summary: "Standup",
+		Start:   &calendar.EventDateTime{DateTime: "2025-03-10T10:00:00+09:00"},
+		End:     &calendar.EventDateTime{DateTime: "2025-03-10T10:15:00+09:00"},
+	})
+	fake.handle("PUT", "/calendars/team/events/ev1", func(_ *http.Request, body []byte) any {
+		var ev calendar.Event
+		_ = json.Unmarshal(body, &ev)
+		return &ev
+	})
+
+	if _, err := fake.calendarService().UpdateEvent(context.Background(), "team", "ev1",
+		map[string]string{"recurrence": "RRULE:FREQ=WEEKLY;BYDAY=MO"}, nil, ""); err != nil {
+		t.Fatalf("UpdateEvent() error = %v", err)
+	}
+	req, _ := fake.request("PUT", "/calendars/team/events/ev1")
+	var sent calendar.Event
+	fake.decodeBody(req, &sent)
+	if sent.Start.TimeZone != "Asia/Tokyo" || sent.End.TimeZone != "Asia/Tokyo" {
+		t.Fatalf("start, end timezones = %q, %q, want the calendar's Asia/Tokyo", sent.Start.TimeZone, sent.End.TimeZone)
+	}
+}
+
+func TestUpdateEvent_RecurrenceErrors(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/calendars/primary/events/ev1_20250310T010000Z", &calendar.Event{
+		Id:               "ev1_20250310T010000Z",
+		RecurringEventId: "ev1",
+	})
+	fake.respond("GET", "/calendars/primary/events/ev2", &calendar.Event{Id: "ev2"})
+	cs := fake.calendarService()
+
+	_, err := cs.UpdateEvent(context.Background(), "", "ev1_20250310T010000Z",
+		map[string]string{"recurrence": "RRULE:FREQ=WEEKLY"}, nil, "")
+	if err == nil || !strings.Contains(err.Error(), "instance of recurring event ev1") {
+		t.Fatalf("UpdateEvent(instance) error = %v, want instance error", err)
+	}
+
+	_, err = cs.UpdateEvent(context.Background(), "", "ev2",
+		map[string]string{"recurrence": "every monday"}, nil, "")
+	if err == nil || !strings.Contains(err.Error(), "invalid recurrence") {
+		t.Fatalf("UpdateEvent(invalid rule) error = %v, want invalid recurrence", err)
+	}
+}
+
+func TestGetEvents(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/calendars/team@example.com/events/ev1", &calendar.Event{Id: "ev1", Summary: "Standup"})
+	fake.fail("GET", "/calendars/team@example.com/events/missing", http.StatusNotFound, "Not Found")
+	fake.respond("GET", "/calendars/team@example.com/events/ev3", &calendar.Event{Id: "ev3", Summary: "Retro"})
+
+	results, err := fake.calendarService().GetEvents(context.Background(), "team@example.com", []string{"ev1", "missing", "ev3"})
+	if err != nil {
+		t.Fatalf("GetEvents() error = %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("GetEvents() returned %d results, want 3", len(results))
+	}
+	for i, want := range []struct{ id, summary string }{{"ev1", "Standup"}, {"missing", ""}, {"ev3", "Retro"}} {
+		r := results[i]
+		if r.ID != want.id {
+			t.Fatalf("results[%d].ID = %q, want %q", i, r.ID, want.id)
+		}
+		if want.summary == "" {
+			if r.eventJSON != nil || !strings.Contains(r.Error, "404") {
+				t.Fatalf("results[%d] = %+v, want a 404 error", i, r)
+			}
+			continue
+		}
+		if r.Error != "" || r.eventJSON == nil || r.Summary != want.summary {
+			t.Fatalf("results[%d] = %+v, want event %q", i, r, want.summary)
+		}
+	}
+
+	data, err := json.Marshal(results[1])
+	if err != nil {
+		t.Fatalf("marshal result: %v", err)
+	}
+	if !strings.Contains(string(data), `"id":"missing"`) {
+		t.Fatalf("failed entry JSON = %s, want its id", data)
+	}
+}
+
+func TestGetEvents_Limits(t *testing.T) {
+	t.Parallel()
+
+	cs := newFakeGoogleAPI(t).calendarService()
+	if _, err := cs.GetEvents(context.Background(), "", nil); err == nil {
+		t.Fatal("GetEvents(no IDs) error = nil, want error")
+	}
+	ids := make([]string, maxGetEventsIDs+1)
+	for i := range ids {
+		ids[i] = "ev" + strconv.Itoa(i)
+	}
+	if _, err := cs.GetEvents(context.Background(), "", ids); err == nil {
+		t.Fatal("GetEvents(too many IDs) error = nil, want error")
+	}
+}
+
+func TestListCalendars_ColorsAndHidden(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/users/me/calendarList", &calendar.CalendarList{Items: []*calendar.CalendarListEntry{
+		{Id: "primary@example.com", Summary: "Me", Primary: true, BackgroundColor: "#9fe1e7", ForegroundColor: "#000000"},
+		{Id: "old@example.com", Summary: "Old project", Hidden: true, BackgroundColor: "#ac725e", ForegroundColor: "#ffffff"},
+	}})
+	cs := fake.calendarService()
+
+	visible, err := cs.ListCalendars(context.Background(), false)
+	if err != nil {
+		t.Fatalf("ListCalendars() error = %v", err)
+	}
+	if len(visible) != 1 || visible[0].BackgroundColor != "#9fe1e7" || visible[0].ForegroundColor != "#000000" {
+		t.Fatalf("ListCalendars(show_hidden=false) = %+v, want the visible calendar with colors", visible)
+	}
+	req, _ := fake.request("GET", "/users/me/calendarList")
+	if req.Query.Get("showHidden") != "true" {
+		t.Fatalf("showHidden = %q, want true so the cache holds every calendar", req.Query.Get("showHidden"))
+	}
+
+	all, err := cs.ListCalendars(context.Background(), true)
+	if err != nil {
+		t.Fatalf("ListCalendars() error = %v", err)
+	}
+	if len(all) != 2 || !all[1].Hidden || all[1].ForegroundColor != "#ffffff" {
+		t.Fatalf("ListCalendars(show_hidden=true) = %+v, want both calendars", all)
+	}
+}
+
+func TestQuickAddEvent(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("POST", "/calendars/team@example.com/events/quickAdd", &calendar.Event{
+		Id:      "ev1",
+		Summary: "Dinner with Bob",
+		Start:   &calendar.EventDateTime{DateTime: "2025-03-11T19:00:00+09:00"},
+		End:     &calendar.EventDateTime{DateTime: "2025-03-11T20:00:00+09:00"},
+	})
+	cs := fake.calendarService()
+
+	ev, err := cs.QuickAddEvent(context.Background(), "team@example.com", "Dinner with Bob tomorrow 7pm")
+	if err != nil {
+		t.Fatalf("QuickAddEvent() error = %v", err)
+	}
+	if ev.ID != "ev1" || ev.Start.DateTime != "2025-03-11T19:00:00+09:00" {
+		t.Fatalf("QuickAddEvent() = %+v", ev)
+	}
+	req, _ := fake.request("POST", "/calendars/team@example.com/events/quickAdd")
+	if req.Query.Get("text") != "Dinner with Bob tomorrow 7pm" {
+		t.Fatalf("quickAdd query = %v", req.Query)
+	}
+
+	if _, err := cs.QuickAddEvent(context.Background(), "", "  "); err == nil || !strings.Contains(err.Error(), "text is required") {
+		t.Fatalf("QuickAddEvent(blank) error = %v, want text is required", err)
+	}
+}
+
+func TestMoveEvent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		accessRole  string
+		notFound    bool
+		destination string
+		wantErr     string
+	}{
+		{name: "owner", accessRole: "owner"},
+		{name: "writer", accessRole: "writer"},
+		{name: "reader", accessRole: "reader", wantErr: "team is not writable (your access role is reader)"},
+		{name: "not in list", notFound: true, wantErr: "destination calendar team is not in your calendar list"},
+		{name: "same calendar", accessRole: "owner", destination: "primary", wantErr: "ev1 is already in calendar me@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fake := newFakeGoogleAPI(t)
+			fake.respond("GET", "/users/me/calendarList/primary", &calendar.CalendarListEntry{Id: "me@example.com", AccessRole: "owner"})
+			if tt.notFound {
+				fake.fail("GET", "/users/me/calendarList/team", http.StatusNotFound, "Not Found")
+			} else {
+				fake.respond("GET", "/users/me/calendarList/team", &calendar.CalendarListEntry{Id: "team", AccessRole: tt.accessRole})
+			}
+			fake.respond("POST", "/calendars/primary/events/ev1/move", &calendar.Event{Id: "ev1", Summary: "Standup"})
+
+			destination := "team"
+			if tt.destination != "" {
+				destination = tt.destination
+			}
+			ev, err := fake.calendarService().MoveEvent(context.Background(), "", "ev1", destination)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("MoveEvent() error = %v, want containing %q", err, tt.wantErr)
+				}
+				if _, moved := fake.request("POST", "/calendars/primary/events/ev1/move"); moved {
+					t.Fatal("event moved despite error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MoveEvent() error = %v", err)
+			}
+			if ev.ID != "ev1" || ev.Summary != "Standup" {
+				t.Fatalf("MoveEvent() = %+v", ev)
+			}
+			req, _ := fake.request("POST", "/calendars/primary/events/ev1/move")
+			if req.Query.Get("destination") != "team" {
+				t.Fatalf("move query = %v, want destination=team", req.Query)
+			}
+		})
+	}
+}
+
+func TestFreeBusy(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("POST", "/freeBusy", &calendar.FreeBusyResponse{
+		Calendars: map[string]calendar.FreeBusyCalendar{
+			"alice@example.com": {Busy: []*calendar.TimePeriod{{Start: "2025-03-10T10:00:00Z", End: "2025-03-10T11:00:00Z"}}},
+			"bob@example.com":   {},
+			"eve@other.com":     {Errors: []*calendar.Error{{Reason: "notFound"}}},
+		},
+	})
+
+	got, err := fake.calendarService().FreeBusy(context.Background(), "2025-03-10T00:00:00Z", "2025-03-11T00:00:00Z",
+		[]string{"alice@example.com", "bob@example.com", "eve@other.com"})
+	if err != nil {
+		t.Fatalf("FreeBusy() error = %v", err)
+	}
+	b, _ := json.Marshal(got)
+	want := `{"alice@example.com":{"busy":[{"start":"2025-03-10T10:00:00Z","end":"2025-03-10T11:00:00Z"}]},"bob@example.com":{"busy":[]},"eve@other.com":{"busy":[],"errors":["notFound"]}}`
+	if string(b) != want {
+		t.Fatalf("FreeBusy() = %s, want %s", b, want)
+	}
+
+	req, _ := fake.request("POST", "/freeBusy")
+	var fbReq calendar.FreeBusyRequest
+	fake.decodeBody(req, &fbReq)
+	if fbReq.TimeMin != "2025-03-10T00:00:00Z" || fbReq.TimeMax != "2025-03-11T00:00:00Z" || len(fbReq.Items) != 3 {
+		t.Fatalf("free/busy request = %+v", fbReq)
+	}
+}
+
+func TestFreeBusy_DefaultsToPrimary(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("POST", "/freeBusy", &calendar.FreeBusyResponse{})
+
+	got, err := fake.calendarService().FreeBusy(context.Background(), "", "", nil)
+	if err != nil {
+		t.Fatalf("FreeBusy() error = %v", err)
+	}
+	if _, ok := got["primary"]; !ok || len(got) != 1 {
+		t.Fatalf("FreeBusy() = %v, want primary only", got)
+	}
+	req, _ := fake.request("POST", "/freeBusy")
+	var fbReq calendar.FreeBusyRequest
+	fake.decodeBody(req, &fbReq)
+	tMin, err1 := time.Parse(time.RFC3339, fbReq.TimeMin)
+	tMax, err2 := time.Parse(time.RFC3339, fbReq.TimeMax)
+	if err1 != nil || err2 != nil || tMax.Sub(tMin) != 7*24*time.Hour {
+		t.Fatalf("free/busy window = %s to %s, want 7 days", fbReq.TimeMin, fbReq.TimeMax)
+	}
+}
+
+func TestCreateAndDeleteCalendar(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.handle("POST", "/calendars", func(_ *http.Request, body []byte) any {
+		var c calendar.Calendar
+		_ = json.Unmarshal(body, &c)
+		c.Id = "proj@group.calendar.google.com"
+		return &c
+	})
+	fake.respond("DELETE", "/calendars/proj@group.calendar.google.com", nil)
+	fake.respond("GET", "/users/me/calendarList/primary", &calendar.CalendarListEntry{Id: "alice@example.com", Primary: true})
+	calls := 0
+	fake.handle("GET", "/users/me/calendarList", func(*http.Request, []byte) any {
+		calls++
+		return &calendar.CalendarList{}
+	})
+	cs := fake.calendarService()
+	cs.caches, cs.cacheKey = newCalendarCaches(time.Hour), "alice@example.com"
+	ctx := context.Background()
+
+	if _, err := cs.ListCalendars(ctx, false); err != nil {
+		t.Fatalf("ListCalendars() error = %v", err)
+	}
+	cal, err := cs.CreateCalendar(ctx, "Project X", "Launch", "Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("CreateCalendar() error = %v", err)
+	}
+	if cal.ID != "proj@group.calendar.google.com" || cal.Summary != "Project X" || cal.TimeZone != "Asia/Tokyo" {
+		t.Fatalf("CreateCalendar() = %+v", cal)
+	}
+	if err := cs.DeleteCalendar(ctx, cal.ID); err != nil {
+		t.Fatalf("DeleteCalendar() error = %v", err)
+	}
+	if _, ok := fake.request("DELETE", "/calendars/proj@group.calendar.google.com"); !ok {
+		t.Fatal("DeleteCalendar() did not call the API")
+	}
+	if _, err := cs.ListCalendars(ctx, false); err != nil {
+		t.Fatalf("ListCalendars() error = %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("listed calendars %d times, want 2 (cache invalidated)", calls)
+	}
+
+	// The primary calendar's real ID is refused like the alias.
+	if err := cs.DeleteCalendar(ctx, "Alice@example.com"); err == nil || !strings.Contains(err.Error(), "primary calendar cannot be deleted") {
+		t.Fatalf("DeleteCalendar(primary ID) error = %v", err)
+	}
+	if _, ok := fake.request("DELETE", "/calendars/Alice@example.com"); ok {
+		t.Fatal("DeleteCalendar(primary ID) called the API")
+	}
+}
+
+func TestCreateAndDeleteCalendar_Invalid(t *testing.T) {
+	t.Parallel()
+
+	cs := &CalendarService{}
+	ctx := context.Background()
+	if _, err := cs.CreateCalendar(ctx, " ", "", ""); err == nil || !strings.Contains(err.Error(), "summary is required") {
+		t.Fatalf("CreateCalendar(no summary) error = %v", err)
+	}
+	if _, err := cs.CreateCalendar(ctx, "Project X", "", "Mars/Olympus"); err == nil {
+		t.Fatal("CreateCalendar(bad timezone) error = nil")
+	}
+	if err := cs.DeleteCalendar(ctx, "primary"); err == nil || !strings.Contains(err.Error(), "primary calendar cannot be deleted") {
+		t.Fatalf("DeleteCalendar(primary) error = %v", err)
+	}
+}
+
+func TestEventColor(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.handle("POST", "/calendars/primary/events", func(_ *http.Request, body []byte) any {
+		var ev calendar.Event
+		_ = json.Unmarshal(body, &ev)
+		ev.Id = "ev1"
+		return &ev
+	})
+	fake.respond("GET", "/calendars/primary/events/ev1", &calendar.Event{Id: "ev1", Summary: "Review", ColorId: "11"})
+	fake.handle("PUT", "/calendars/primary/events/ev1", func(_ *http.Request, body []byte) any {
+		var ev calendar.Event
+		_ = json.Unmarshal(body, &ev)
+		return &ev
+	})
+	cs := fake.calendarService()
+
+	result, err := dispatchCalendarTool(context.Background(), cs, "create-event", map[string]interface{}{
+		"summary":  "Review",
+		"start":    "2025-03-10T10:00:00Z",
+		"end":      "2025-03-10T11:00:00Z",
+		"color_id": "11",
+	})
+	if err != nil {
+		t.Fatalf("dispatchCalendarTool(create-event) error = %v", err)
+	}
+	if ev := result.(*eventJSON); ev.ColorID != "11" {
+		t.Fatalf("created colorId = %q, want 11", ev.ColorID)
+	}
+
+	result, err = dispatchCalendarTool(context.Background(), cs, "update-event", map[string]interface{}{
+		"event_id": "ev1",
+		"color_id": "",
+	})
+	if err != nil {
+		t.Fatalf("dispatchCalendarTool(update-event) error = %v", err)
+	}
+	if ev := result.(*eventJSON); ev.ColorID != "" {
+		t.Fatalf("updated colorId = %q, want it reset", ev.ColorID)
+	}
+
+	if _, err := cs.CreateEvent(context.Background(), "", "Review", "", "", "2025-03-10T10:00:00Z", "2025-03-10T11:00:00Z", "", nil,
+		createEventOptions{ColorID: "red"}); err == nil {
+		t.Fatal("CreateEvent() with a non-numeric color_id succeeded")
+	}
+}
+
+func TestListColors(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/colors", &calendar.Colors{
+		Event: map[string]calendar.ColorDefinition{
+			"10": {Background: "#51b749", Foreground: "#1d1d1d"},
+			"2":  {Background: "#7ae7bf", Foreground: "#1d1d1d"},
+			"1":  {Background: "#a4bdfc", Foreground: "#1d1d1d"},
+		},
+		Calendar: map[string]calendar.ColorDefinition{
+			"1": {Background: "#ac725e", Foreground: "#1d1d1d"},
+		},
+	})
+
+	colors, err := fake.calendarService().ListColors(context.Background())
+	if err != nil {
+		t.Fatalf("ListColors() error = %v", err)
+	}
+	var ids []string
+	for _, c := range colors["event"] {
+		ids = append(ids, c.ID)
+	}
+	if strings.Join(ids, ",") != "1,2,10" {
+		t.Fatalf("event color IDs = %v, want numeric order 1,2,10", ids)
+	}
+	if colors["event"][0].Background != "#a4bdfc" || len(colors["calendar"]) != 1 {
+		t.Fatalf("colors = %+v", colors)
+	}
+}
+
+func TestEventSendUpdates(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.handle("POST", "/calendars/primary/events", func(_ *http.Request, body []byte) any {
+		var ev calendar.Event
+		_ = json.Unmarshal(body, &ev)
+		ev.Id = "ev1"
+		return &ev
+	})
+	fake.respond("GET", "/calendars/primary/events/ev1", &calendar.Event{Id: "ev1", Summary: "Planning"})
+	fake.handle("PUT", "/calendars/primary/events/ev1", func(_ *http.Request, body []byte) any {
+		var ev calendar.Event
+		_ = json.Unmarshal(body, &ev)
+		return &ev
+	})
+	cs := fake.calendarService()
+
+	result, err := dispatchCalendarTool(context.Background(), cs, "create-event", map[string]interface{}{
+		"summary":      "Planning",
+		"start":        "2025-03-10T10:00:00Z",
+		"end":          "2025-03-10T11:00:00Z",
+		"attendees":    "alice@example.com,bob@example.com:optional",
+		"send_updates": "externalOnly",
+	})
+	if err != nil {
+		t.Fatalf("dispatchCalendarTool(create-event) error = %v", err)
+	}
+	req, _ := fake.request("POST", "/calendars/primary/events")
+	if got := req.Query.Get("sendUpdates"); got != "externalOnly" {
+		t.Fatalf("events.insert sendUpdates = %q, want externalOnly", got)
+	}
+	ev := result.(*eventJSON)
+	if len(ev.Attendees) != 2 || ev.Attendees[0].Optional || !ev.Attendees[1].Optional {
+		t.Fatalf("attendees = %+v, want bob optional", ev.Attendees)
+	}
+
+	if _, err := dispatchCalendarTool(context.Background(), cs, "update-event", map[string]interface{}{
+		"event_id":     "ev1",
+		"summary":      "Planning (moved)",
+		"send_updates": "none",
+	}); err != nil {
+		t.Fatalf("dispatchCalendarTool(update-event) error = %v", err)
+	}
+	req, _ = fake.request("PUT", "/calendars/primary/events/ev1")
+	if got := req.Query.Get("sendUpdates"); got != "none" {
+		t.Fatalf("events.update sendUpdates = %q, want none", got)
+	}
+
+	if _, err := cs.UpdateEvent(context.Background(), "", "ev1", nil, nil, "everyone"); err == nil {
+		t.Fatal("UpdateEvent() with an invalid send_updates succeeded")
+	}
+	if _, err := cs.CreateEvent(context.Background(), "", "Planning", "", "", "2025-03-10T10:00:00Z", "2025-03-10T11:00:00Z", "", nil,
+		createEventOptions{Import: true, ICalUID: "uid@example.com", SendUpdates: "all"}); err == nil {
+		t.Fatal("CreateEvent() with import and send_updates succeeded")
+	}
+}
+
+func TestCreateEvent_AddConference(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.handle("POST", "/calendars/primary/events", func(_ *http.Request, body []byte) any {
+		var ev calendar.Event
+		_ = json.Unmarshal(body, &ev)
+		ev.Id = "ev1"
+		ev.HangoutLink = "https://meet.google.com/abc-defg-hij"
+		ev.ConferenceData.ConferenceSolution = &calendar.ConferenceSolution{Name: "Google Meet"}
+		ev.ConferenceData.CreateRequest.Status = &calendar.ConferenceRequestStatus{StatusCode: "success"}
+		ev.ConferenceData.EntryPoints = []*calendar.EntryPoint{{EntryPointType: "video", Uri: "https://meet.google.com/abc-defg-hij"}}
+		return &ev
+	})
+
+	result, err := dispatchCalendarTool(context.Background(), fake.calendarService(), "create-event", map[string]interface{}{
+		"summary":        "Remote sync",
+		"start":          "2025-03-10T10:00:00Z",
+		"end":            "2025-03-10T10:30:00Z",
+		"add_conference": true,
+	})
+	if err != nil {
+		t.Fatalf("dispatchCalendarTool(create-event) error = %v", err)
+	}
+	req, _ := fake.request("POST", "/calendars/primary/events")
+	if got := req.Query.Get("conferenceDataVersion"); got != "1" {
+		t.Fatalf("events.insert conferenceDataVersion = %q, want 1", got)
+	}
+	var sent calendar.Event
+	fake.decodeBody(req, &sent)
+	if sent.ConferenceData == nil || sent.ConferenceData.CreateRequest.RequestId == "" ||
+		sent.ConferenceData.CreateRequest.ConferenceSolutionKey.Type != "hangoutsMeet" {
+		t.Fatalf("conferenceData = %+v, want a hangoutsMeet create request", sent.ConferenceData)
+	}
+	ev := result.(*eventJSON)
+	if ev.HangoutLink != "https://meet.google.com/abc-defg-hij" || ev.Conference == nil ||
+		ev.Conference.Status != "success" || len(ev.Conference.EntryPoints) != 1 {
+		t.Fatalf("event = %+v, want the Meet link and conference", ev)
+	}
+	if ev.Warning != "" {
+		t.Fatalf("warning = %q, want none", ev.Warning)
+	}
+}
+
+func TestCreateEvent_AddConferenceFailure(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.handle("POST", "/calendars/primary/events", func(_ *http.Request, body []byte) any {
+		var ev calendar.Event
+		_ = json.Unmarshal(body, &ev)
+		ev.Id = "ev1"
+		ev.ConferenceData.CreateRequest.Status = &calendar.ConferenceRequestStatus{StatusCode: "failure"}
+		return &ev
+	})
+
+	// The event exists either way, so it is returned with a warning rather
+	// than as an error the caller might retry.
+	ev, err := fake.calendarService().CreateEvent(context.Background(), "", "Remote sync", "", "", "2025-03-10T10:00:00Z", "2025-03-10T10:30:00Z", "", nil,
+		createEventOptions{AddConference: true})
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	if ev.ID != "ev1" || !strings.Contains(ev.Warning, "Google failed to add a conference") {
+		t.Fatalf("CreateEvent() = %+v, want the event with a conference warning", ev)
+	}
+}
+
+func TestDefaultTimezone(t *testing.T) {
+	t.Parallel()
+
+	if _, err := time.LoadLocation("Asia/Tokyo"); err != nil {
+		t.Skip("timezone database not available")
+	}
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/calendars/primary/events", &calendar.Events{})
+	fake.handle("POST", "/calendars/primary/events", func(_ *http.Request, body []byte) any {
+		var ev calendar.Event
+		_ = json.Unmarshal(body, &ev)
+		ev.Id = "ev1"
+		return &ev
+	})
+	cs := fake.calendarService()
+	cs.opts.Timezone = "Asia/Tokyo"
+
+	if _, err := cs.ListEvents(context.Background(), "", "", "", 0, true, "", ""); err != nil {
+		t.Fatalf("ListEvents() error = %v", err)
+	}
+	req, _ := fake.request("GET", "/calendars/primary/events")
+	for _, key := range []string{"timeMin", "timeMax"} {
+		if got := req.Query.Get(key); !strings.HasSuffix(got, "+09:00") {
+			t.Errorf("default %s = %q, want it in Asia/Tokyo", key, got)
+		}
+	}
+
+	ev, err := cs.CreateEvent(context.Background(), "", "Lunch", "", "", "2025-03-10", "2025-03-10T13:00:00+09:00", "", nil, createEventOptions{})
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	if ev.Start.DateTime != "2025-03-10T00:00:00+09:00" || ev.Start.TimeZone != "Asia/Tokyo" {
+		t.Fatalf("start = %+v, want midnight in Asia/Tokyo", ev.Start)
+	}
+}
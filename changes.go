@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// whatsChangedConcurrency bounds the calendars WhatsChanged syncs at once.
+const whatsChangedConcurrency = 4
+
+// maxChangesLookback is how far back whats-changed reports changes. It
+// bounds the events a since, or an old token falling back to listing by
+// update time, can pull from each calendar in one call.
+const maxChangesLookback = 30 * 24 * time.Hour
+
+// syncTokenPageSize is the page size used when walking a calendar only to
+// obtain a sync token. It is the largest the Calendar API allows.
+const syncTokenPageSize = 2500
+
+// changesToken is the state behind whats-changed's opaque token: the
+// Calendar API sync token of each calendar, and when they were issued.
+type changesToken struct {
+	Issued    time.Time         `json:"issued"`
+	Calendars map[string]string `json:"calendars"`
+}
+
+// encodeChangesToken returns t as an opaque, URL-safe string.
+func encodeChangesToken(t changesToken) (string, error) {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return "", fmt.Errorf("encode token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeChangesToken parses a token returned by encodeChangesToken.
+func decodeChangesToken(s string) (changesToken, error) {
+	var t changesToken
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &t)
+	}
+	if err != nil || t.Issued.IsZero() {
+		return changesToken{}, fmt.Errorf("invalid token: pass the token returned by a previous whats-changed call")
+	}
+	return t, nil
+}
+
+// changedEventJSON is an event reported by whats-changed, with the calendar
+// it belongs to. Deleted events may carry little more than their ID.
+type changedEventJSON struct {
+	CalendarID string `json:"calendarId"`
+	eventJSON
+}
+
+type changesJSON struct {
+	Since    string             `json:"since"`
+	Created  []changedEventJSON `json:"created"`
+	Updated  []changedEventJSON `json:"updated"`
+	Deleted  []changedEventJSON `json:"deleted"`
+	Token    string             `json:"token"`
+	Warnings []string           `json:"warnings,omitempty"`
+}
+
+// calendarChanges is the outcome of syncing one calendar.
+type calendarChanges struct {
+	events    []*calendar.Event
+	syncToken string
+	warning   string
+}
+
+// WhatsChanged reports the events created, updated or deleted across the
+// user's calendars since a time, or since the call that returned token.
+// Exactly one of since and token must be set, and neither may be older than
+// maxChangesLookback. The result's token picks up where this call left off.
+//
+// Calendars are synced with the Calendar API's per-calendar sync tokens,
+// which the token bundles. A calendar without one (a first call, a calendar
+// added since, or an expired sync token) is instead listed by update time.
+func (cs *CalendarService) WhatsChanged(ctx context.Context, since, token string) (*changesJSON, error) {
+	if (since == "") == (token == "") {
+		return nil, fmt.Errorf("exactly one of since or token is required")
+	}
+	issued := time.Now()
+	var prev changesToken
+	if token != "" {
+		var err error
+		if prev, err = decodeChangesToken(token); err != nil {
+			return nil, err
+		}
+	} else {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, fmt.Errorf("invalid since (must be RFC3339): %w", err)
+		}
+		if !t.Before(issued) {
+			return nil, fmt.Errorf("since must be in the past")
+		}
+		if issued.Sub(t) > maxChangesLookback {
+			return nil, fmt.Errorf("since must be within the last %d days; use list-events for older changes", maxChangesLookback/(24*time.Hour))
+		}
+		prev.Issued = t
+	}
+	if token != "" && issued.Sub(prev.Issued) > maxChangesLookback {
+		return nil, fmt.Errorf("token is older than %d days: start again with a recent since", maxChangesLookback/(24*time.Hour))
+	}
+
+	calendars, err := cs.ListCalendars(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]calendarChanges, len(calendars))
+	sem := make(chan struct{}, whatsChangedConcurrency)
+	var wg sync.WaitGroup
+	for i, cal := range calendars {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = cs.syncCalendar(ctx, cal.ID, prev.Issued, prev.Calendars[cal.ID])
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	next := changesToken{Issued: issued, Calendars: make(map[string]string, len(calendars))}
+	changes := &changesJSON{
+		Since:   prev.Issued.Format(time.RFC3339),
+		Created: []changedEventJSON{},
+		Updated: []changedEventJSON{},
+		Deleted: []changedEventJSON{},
+	}
+	for i, cal := range calendars {
+		r := results[i]
+		if r.warning != "" {
+			changes.Warnings = append(changes.Warnings, r.warning)
+		}
+		if r.syncToken != "" {
+			next.Calendars[cal.ID] = r.syncToken
+		}
+		for _, e := range r.events {
+			ev := changedEventJSON{CalendarID: cal.ID, eventJSON: convertEvent(e)}
+			switch {
+			case e.Status == "cancelled":
+				changes.Deleted = append(changes.Deleted, ev)
+			case createdSince(e, prev.Issued):
+				changes.Created = append(changes.Created, ev)
+			default:
+				changes.Updated = append(changes.Updated, ev)
+			}
+		}
+	}
+	if changes.Token, err = encodeChangesToken(next); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// syncCalendar lists the events in a calendar that changed since the given
+// time, using syncToken if set, and returns a sync token for next time.
+// Failures are reported as a warning; the previous sync token is kept so the
+// next call retries.
+func (cs *CalendarService) syncCalendar(ctx context.Context, calendarID string, since time.Time, syncToken string) calendarChanges {
+	if syncToken != "" {
+		events, next, err := cs.listEvents(ctx, cs.svc.Events.List(calendarID).SyncToken(syncToken))
+		if err == nil {
+			return calendarChanges{events: events, syncToken: next}
+		}
+		if !isSyncTokenExpired(err) {
+			return calendarChanges{syncToken: syncToken, warning: fmt.Sprintf("%s: %v", calendarID, err)}
+		}
+	}
+
+	// Take the new sync token first, so changes made while listing are
+	// reported again next time rather than missed.
+	next, err := cs.syncToken(ctx, calendarID)
+	if err != nil {
+		return calendarChanges{syncToken: syncToken, warning: fmt.Sprintf("%s: %v", calendarID, err)}
+	}
+	// Deleted events are always included when listing by update time.
+	events, _, err := cs.listEvents(ctx, cs.svc.Events.List(calendarID).UpdatedMin(since.Format(time.RFC3339)))
+	if err != nil {
+		return calendarChanges{syncToken: syncToken, warning: fmt.Sprintf("%s: %v", calendarID, err)}
+	}
+	return calendarChanges{events: events, syncToken: next}
+}
+
+// syncToken returns a sync token for the current state of a calendar,
+// fetching no event data.
+func (cs *CalendarService) syncToken(ctx context.Context, calendarID string) (string, error) {
+	call := cs.svc.Events.List(calendarID).MaxResults(syncTokenPageSize).Fields("nextPageToken", "nextSyncToken")
+	for {
+		page, err := call.Context(ctx).Do()
+		if err != nil {
+			return "", fmt.Errorf("get sync token: %w", err)
+		}
+		if page.NextPageToken == "" {
+			return page.NextSyncToken, nil
+		}
+		call.PageToken(page.NextPageToken)
+	}
+}
+
+// listEvents runs an Events.List call through all of its pages, returning the
+// events and the final page's sync token.
+func (cs *CalendarService) listEvents(ctx context.Context, call *calendar.EventsListCall) ([]*calendar.Event, string, error) {
+	var events []*calendar.Event
+	for {
+		page, err := call.Context(ctx).Do()
+		if err != nil {
+			return nil, "", fmt.Errorf("list changed events: %w", err)
+		}
+		events = append(events, page.Items...)
+		if page.NextPageToken == "" {
+			return events, page.NextSyncToken, nil
+		}
+		call.PageToken(page.NextPageToken)
+	}
+}
+
+// isSyncTokenExpired reports whether err is the Calendar API's 410 Gone for
+// a sync token that is no longer valid.
+func isSyncTokenExpired(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusGone
+}
+
+// createdSince reports whether e was created at or after t. Events with an
+// unparseable creation time count as updated.
+func createdSince(e *calendar.Event, t time.Time) bool {
+	created, err := time.Parse(time.RFC3339, e.Created)
+	return err == nil && !created.Before(t)
+}
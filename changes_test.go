@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// changedEventIDs returns the IDs of events, as "calendar/event".
+func changedEventIDs(events []changedEventJSON) []string {
+	ids := []string{}
+	for _, e := range events {
+		ids = append(ids, e.CalendarID+"/"+e.ID)
+	}
+	return ids
+}
+
+// handleSyncedEvents serves a calendar's events list for WhatsChanged: a
+// sync token walk (two pages) for fields-only requests, the changed events
+// when listing by update time or by validSyncToken, and 410 Gone for any
+// other sync token.
+func handleSyncedEvents(fake *fakeGoogleAPI, calendarID, validSyncToken string, changed []*calendar.Event) {
+	fake.handle("GET", "/calendars/"+calendarID+"/events", func(r *http.Request, _ []byte) any {
+		q := r.URL.Query()
+		switch {
+		case strings.Contains(q.Get("fields"), "nextSyncToken"):
+			if q.Get("pageToken") == "" {
+				return &calendar.Events{NextPageToken: "p2"}
+			}
+			return &calendar.Events{NextSyncToken: calendarID + "-fresh"}
+		case q.Get("syncToken") != "" && q.Get("syncToken") != validSyncToken:
+			return fakeAPIError{Code: http.StatusGone, Message: "Sync token is no longer valid"}
+		}
+		return &calendar.Events{Items: changed, NextSyncToken: calendarID + "-next"}
+	})
+}
+
+func TestWhatsChanged_Since(t *testing.T) {
+	t.Parallel()
+
+	since := time.Now().UTC().Add(-24 * time.Hour).Truncate(time.Second)
+	at := func(d time.Duration) string { return since.Add(d).Format(time.RFC3339) }
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/users/me/calendarList", &calendar.CalendarList{Items: []*calendar.CalendarListEntry{
+		{Id: "me", Primary: true},
+		{Id: "team"},
+		{Id: "hidden", Hidden: true},
+	}})
+	handleSyncedEvents(fake, "me", "", []*calendar.Event{
+		{Id: "new", Status: "confirmed", Created: at(time.Hour), Updated: at(time.Hour)},
+		{Id: "moved", Status: "confirmed", Created: at(-9 * 24 * time.Hour), Updated: at(2 * time.Hour)},
+	})
+	handleSyncedEvents(fake, "team", "", []*calendar.Event{
+		{Id: "gone", Status: "cancelled"},
+	})
+
+	changes, err := fake.calendarService().WhatsChanged(context.Background(), since.Format(time.RFC3339), "")
+	if err != nil {
+		t.Fatalf("WhatsChanged() error = %v", err)
+	}
+	if got, want := changedEventIDs(changes.Created), []string{"me/new"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("created = %v, want %v", got, want)
+	}
+	if got, want := changedEventIDs(changes.Updated), []string{"me/moved"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("updated = %v, want %v", got, want)
+	}
+	if got, want := changedEventIDs(changes.Deleted), []string{"team/gone"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("deleted = %v, want %v", got, want)
+	}
+	if changes.Since != at(0) || len(changes.Warnings) != 0 {
+		t.Fatalf("since = %q, warnings = %v", changes.Since, changes.Warnings)
+	}
+	req, _ := fake.request("GET", "/calendars/me/events")
+	if req.Query.Get("updatedMin") != at(0) {
+		t.Fatalf("events query = %v, want updatedMin", req.Query)
+	}
+	if _, ok := fake.request("GET", "/calendars/hidden/events"); ok {
+		t.Fatal("hidden calendar was synced")
+	}
+
+	token, err := decodeChangesToken(changes.Token)
+	if err != nil {
+		t.Fatalf("decodeChangesToken() error = %v", err)
+	}
+	if want := map[string]string{"me": "me-fresh", "team": "team-fresh"}; !reflect.DeepEqual(token.Calendars, want) {
+		t.Fatalf("token calendars = %v, want %v", token.Calendars, want)
+	}
+	if !token.Issued.After(since) {
+		t.Fatalf("token issued = %v, want after %v", token.Issued, since)
+	}
+}
+
+func TestWhatsChanged_Token(t *testing.T) {
+	t.Parallel()
+
+	issued := time.Now().UTC().Add(-24 * time.Hour).Truncate(time.Second)
+	token, err := encodeChangesToken(changesToken{
+		Issued: issued,
+		Calendars: map[string]string{
+			"me":      "me-sync",
+			"team":    "team-expired",
+			"removed": "removed-sync",
+		},
+	})
+	if err != nil {
+		t.Fatalf("encodeChangesToken() error = %v", err)
+	}
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/users/me/calendarList", &calendar.CalendarList{Items: []*calendar.CalendarListEntry{
+		{Id: "me"}, {Id: "team"}, {Id: "added"},
+	}})
+	handleSyncedEvents(fake, "me", "me-sync", []*calendar.Event{
+		{Id: "e1", Status: "confirmed", Created: issued.Add(30 * time.Minute).Format(time.RFC3339)},
+	})
+	handleSyncedEvents(fake, "team", "", []*calendar.Event{
+		{Id: "e2", Status: "confirmed", Created: issued.Add(-20 * 24 * time.Hour).Format(time.RFC3339)},
+	})
+	handleSyncedEvents(fake, "added", "", []*calendar.Event{
+		{Id: "e3", Status: "cancelled"},
+	})
+
+	changes, err := fake.calendarService().WhatsChanged(context.Background(), "", token)
+	if err != nil {
+		t.Fatalf("WhatsChanged() error = %v", err)
+	}
+	if got := [][]string{changedEventIDs(changes.Created), changedEventIDs(changes.Updated), changedEventIDs(changes.Deleted)}; !reflect.DeepEqual(got, [][]string{{"me/e1"}, {"team/e2"}, {"added/e3"}}) {
+		t.Fatalf("created, updated, deleted = %v", got)
+	}
+	if req, _ := fake.request("GET", "/calendars/me/events"); req.Query.Get("syncToken") != "me-sync" {
+		t.Fatalf("me events query = %v, want syncToken", req.Query)
+	}
+	// The expired sync token falls back to listing by update time.
+	if req, _ := fake.request("GET", "/calendars/team/events"); req.Query.Get("updatedMin") != issued.Format(time.RFC3339) {
+		t.Fatalf("team events query = %v, want updatedMin", req.Query)
+	}
+
+	next, err := decodeChangesToken(changes.Token)
+	if err != nil {
+		t.Fatalf("decodeChangesToken() error = %v", err)
+	}
+	if want := map[string]string{"me": "me-next", "team": "team-fresh", "added": "added-fresh"}; !reflect.DeepEqual(next.Calendars, want) {
+		t.Fatalf("token calendars = %v, want %v", next.Calendars, want)
+	}
+}
+
+func TestWhatsChanged_CalendarError(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/users/me/calendarList", &calendar.CalendarList{Items: []*calendar.CalendarListEntry{{Id: "me"}, {Id: "broken"}}})
+	handleSyncedEvents(fake, "me", "me-sync", nil)
+	fake.fail("GET", "/calendars/broken/events", http.StatusForbidden, "Forbidden")
+
+	token, _ := encodeChangesToken(changesToken{Issued: time.Now().Add(-time.Hour), Calendars: map[string]string{"me": "me-sync", "broken": "broken-sync"}})
+	changes, err := fake.calendarService().WhatsChanged(context.Background(), "", token)
+	if err != nil {
+		t.Fatalf("WhatsChanged() error = %v", err)
+	}
+	if len(changes.Warnings) != 1 || !strings.HasPrefix(changes.Warnings[0], "broken: ") {
+		t.Fatalf("warnings = %v, want one for broken", changes.Warnings)
+	}
+	next, _ := decodeChangesToken(changes.Token)
+	if next.Calendars["broken"] != "broken-sync" {
+		t.Fatalf("broken sync token = %q, want the previous one kept", next.Calendars["broken"])
+	}
+}
+
+func TestWhatsChanged_InvalidArgs(t *testing.T) {
+	t.Parallel()
+
+	cs := &CalendarService{}
+	oldToken, err := encodeChangesToken(changesToken{Issued: time.Now().Add(-maxChangesLookback - time.Hour)})
+	if err != nil {
+		t.Fatalf("encodeChangesToken() error = %v", err)
+	}
+	tests := []struct {
+		name, since, token, wantErr string
+	}{
+		{"neither", "", "", "exactly one of since or token"},
+		{"both", "2025-03-10T09:00:00Z", "abc", "exactly one of since or token"},
+		{"bad since", "yesterday", "", "invalid since"},
+		{"future since", time.Now().Add(time.Hour).Format(time.RFC3339), "", "must be in the past"},
+		{"bad token", "", "not-a-token", "invalid token"},
+		{"old since", time.Now().Add(-maxChangesLookback - time.Hour).Format(time.RFC3339), "", "within the last 30 days"},
+		{"old token", "", oldToken, "older than 30 days"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := cs.WhatsChanged(context.Background(), tt.since, tt.token)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("WhatsChanged() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
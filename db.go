@@ -7,6 +7,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -19,14 +20,34 @@ import (
 // DB wraps a SQLite database for token and user storage.
 type DB struct {
 	db *sql.DB
+
+	// tokenKey encrypts stored Google tokens; nil stores them in plaintext.
+	tokenKey []byte
+}
+
+// TokenStore persists Google OAuth tokens. The auth layer depends only on this
+// interface, so tokens can be kept somewhere other than SQLite (e.g. Redis or
+// Vault). DB is the default implementation.
+type TokenStore interface {
+	// LoadToken returns the token of a stdio mode account.
+	LoadToken(account string) (*oauth2.Token, error)
+	// SaveToken stores the token of a stdio mode account.
+	SaveToken(account string, token *oauth2.Token) error
+	// GetUserTokenByEmail returns a user's token (HTTP mode).
+	GetUserTokenByEmail(email string) (*oauth2.Token, error)
+	// UpdateUserToken replaces a user's token (HTTP mode).
+	UpdateUserToken(email string, token *oauth2.Token) error
 }
 
+var _ TokenStore = (*DB)(nil)
+
 // User represents an authenticated user.
 type User struct {
 	ID         int64
 	Email      string
 	APIKeyHash string
-	TokenJSON  string
+	// TokenJSON is the token as stored, encrypted if a token key was set.
+	TokenJSON string
 }
 
 // MCPOAuthClient represents a dynamically registered MCP OAuth client.
@@ -54,8 +75,100 @@ type MCPAuthSession struct {
 	Used                bool
 }
 
+// MCPToken describes a valid MCP access token.
+type MCPToken struct {
+	ClientID  string
+	UserEmail string
+	ExpiresAt time.Time
+}
+
+// RecentCalendar is a calendar ID recently used by a mutating calendar tool.
+type RecentCalendar struct {
+	CalendarID string `json:"calendarId"`
+	LastUsed   string `json:"lastUsed"`
+}
+
+// SnoozedEmail is an email hidden from the inbox until WakeAt.
+type SnoozedEmail struct {
+	ID        int64
+	UserEmail string
+	MessageID string
+	LabelID   string
+	WakeAt    time.Time
+}
+
+// ScheduledEmail is an email queued by schedule-email to be sent at SendAt.
+type ScheduledEmail struct {
+	ID        int64  `json:"id"`
+	UserEmail string `json:"-"`
+	To        string `json:"to"`
+	Subject   string `json:"subject"`
+	Raw       []byte `json:"-"`
+	ThreadID  string `json:"threadId,omitempty"`
+	SendAt    string `json:"sendAt"`
+	Status    string `json:"status"`
+	MessageID string `json:"messageId,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// WatchChannel is a Calendar push notification channel opened for a user.
+type WatchChannel struct {
+	ChannelID  string    `json:"channelId"`
+	ResourceID string    `json:"resourceId"`
+	CalendarID string    `json:"calendarId"`
+	Expiration time.Time `json:"expiration"`
+	Expired    bool      `json:"expired"`
+}
+
+// Scheduled email statuses. A background job claims a pending email by
+// moving it to sending, so only one process sends it.
+const (
+	scheduledPending = "pending"
+	scheduledSending = "sending"
+	scheduledSent    = "sent"
+	scheduledFailed  = "failed"
+)
+
+// snoozeClaimLease is how long a background job holds a due snoozed email it
+// claimed. One it fails to wake becomes due again when the lease runs out.
+const snoozeClaimLease = 5 * time.Minute
+
+// stateKeyScope selects the user_email keys one server mode owns: stdio mode
+// keys rows by accountStateKey, http mode by user email. Background jobs of
+// a stdio and an http server sharing a database thus never touch each
+// other's rows.
+type stateKeyScope int
+
+const (
+	stdioStateKeys stateKeyScope = iota
+	httpStateKeys
+)
+
+// userEmailFilter returns a condition on user_email matching s, with its args.
+func (s stateKeyScope) userEmailFilter() (string, []any) {
+	if s == stdioStateKeys {
+		return "(user_email = '' OR substr(user_email, 1, ?) = ?)", []any{len(accountStatePrefix), accountStatePrefix}
+	}
+	return "(user_email <> '' AND substr(user_email, 1, ?) <> ?)", []any{len(accountStatePrefix), accountStatePrefix}
+}
+
+// errUserNotFound and errNoStoredToken mean there are no credentials to act
+// with until the user signs in (again).
+var (
+	errUserNotFound  = errors.New("user not found")
+	errNoStoredToken = errors.New("no token stored")
+)
+
+// maxRecentCalendars is how many recently used calendars are kept per user.
+const maxRecentCalendars = 10
+
 // NewDB opens (or creates) a SQLite database at path and runs migrations.
+// A path of ":memory:" opens a private in-memory database; see NewMemoryDB.
 func NewDB(path string) (*DB, error) {
+	if path == ":memory:" {
+		return NewMemoryDB()
+	}
+
 	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
@@ -66,6 +179,43 @@ func NewDB(path string) (*DB, error) {
 		return nil, fmt.Errorf("set WAL mode: %w", err)
 	}
 
+	return newMigratedDB(db)
+}
+
+// NewMemoryDB returns a DB backed by a private in-memory SQLite database with
+// the same schema as NewDB. Data is lost when the DB is closed. Each SQLite
+// connection to ":memory:" is a separate database, so the pool is limited to
+// one connection that is never closed while idle.
+func NewMemoryDB() (*DB, error) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+
+	return newMigratedDB(db)
+}
+
+// newMigratedDB runs migrations on db and wraps it, closing db on failure.
+func newMigratedDB(db *sql.DB) (*DB, error) {
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	d := &DB{db: db}
+	if err := d.migrateLegacyAPIKeys(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// migrate creates any missing tables.
+func migrate(db *sql.DB) error {
 	// Single-user token table (for stdio mode)
 	if _, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS oauth_tokens (
@@ -74,8 +224,7 @@ func NewDB(path string) (*DB, error) {
 			updated_at TEXT DEFAULT (datetime('now'))
 		)
 	`); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("create oauth_tokens table: %w", err)
+		return fmt.Errorf("create oauth_tokens table: %w", err)
 	}
 
 	// Multi-user table (for HTTP mode)
@@ -89,8 +238,7 @@ func NewDB(path string) (*DB, error) {
 			updated_at TEXT DEFAULT (datetime('now'))
 		)
 	`); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("create users table: %w", err)
+		return fmt.Errorf("create users table: %w", err)
 	}
 
 	// MCP OAuth clients (Dynamic Client Registration)
@@ -104,8 +252,7 @@ func NewDB(path string) (*DB, error) {
 			created_at TEXT DEFAULT (datetime('now'))
 		)
 	`); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("create mcp_oauth_clients table: %w", err)
+		return fmt.Errorf("create mcp_oauth_clients table: %w", err)
 	}
 
 	// MCP OAuth authorization sessions
@@ -125,8 +272,7 @@ func NewDB(path string) (*DB, error) {
 			created_at TEXT DEFAULT (datetime('now'))
 		)
 	`); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("create mcp_oauth_sessions table: %w", err)
+		return fmt.Errorf("create mcp_oauth_sessions table: %w", err)
 	}
 
 	// MCP OAuth access/refresh tokens
@@ -141,49 +287,280 @@ func NewDB(path string) (*DB, error) {
 			created_at TEXT DEFAULT (datetime('now'))
 		)
 	`); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("create mcp_oauth_tokens table: %w", err)
+		return fmt.Errorf("create mcp_oauth_tokens table: %w", err)
 	}
 
-	d := &DB{db: db}
-	if err := d.migrateLegacyAPIKeys(); err != nil {
-		db.Close()
-		return nil, err
+	// Recently used calendars, keyed by user email ("" in stdio mode)
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS recent_calendars (
+			user_email TEXT NOT NULL,
+			calendar_id TEXT NOT NULL,
+			used_at INTEGER NOT NULL,
+			PRIMARY KEY (user_email, calendar_id)
+		)
+	`); err != nil {
+		return fmt.Errorf("create recent_calendars table: %w", err)
 	}
 
-	return d, nil
+	// Snoozed emails, keyed by user email ("" in stdio mode)
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS snoozed_emails (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_email TEXT NOT NULL,
+			message_id TEXT NOT NULL,
+			label_id TEXT NOT NULL,
+			wake_at TEXT NOT NULL,
+			created_at TEXT DEFAULT (datetime('now')),
+			UNIQUE (user_email, message_id)
+		)
+	`); err != nil {
+		return fmt.Errorf("create snoozed_emails table: %w", err)
+	}
+
+	// Preferred calendar per user email ("" in stdio mode)
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS default_calendars (
+			user_email TEXT PRIMARY KEY,
+			calendar_id TEXT NOT NULL,
+			updated_at TEXT DEFAULT (datetime('now'))
+		)
+	`); err != nil {
+		return fmt.Errorf("create default_calendars table: %w", err)
+	}
+
+	// Calendar push notification channels, keyed by user email ("" in stdio mode)
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS watch_channels (
+			channel_id TEXT PRIMARY KEY,
+			user_email TEXT NOT NULL,
+			resource_id TEXT NOT NULL,
+			calendar_id TEXT NOT NULL,
+			expiration TEXT NOT NULL,
+			created_at TEXT DEFAULT (datetime('now'))
+		)
+	`); err != nil {
+		return fmt.Errorf("create watch_channels table: %w", err)
+	}
+
+	// Emails queued for later sending, keyed by user email ("" in stdio mode)
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS scheduled_emails (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_email TEXT NOT NULL,
+			to_addr TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			raw BLOB NOT NULL,
+			thread_id TEXT NOT NULL DEFAULT '',
+			send_at TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			message_id TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT '',
+			created_at TEXT DEFAULT (datetime('now'))
+		)
+	`); err != nil {
+		return fmt.Errorf("create scheduled_emails table: %w", err)
+	}
+
+	// Tokens of named stdio mode accounts. The default account keeps using
+	// the single row of oauth_tokens.
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS account_tokens (
+			account TEXT PRIMARY KEY,
+			token_json TEXT NOT NULL,
+			updated_at TEXT DEFAULT (datetime('now'))
+		)
+	`); err != nil {
+		return fmt.Errorf("create account_tokens table: %w", err)
+	}
+
+	return nil
 }
 
 // --- Single-user methods (stdio mode) ---
 
-// SaveToken stores an OAuth2 token in the single-user table.
-func (d *DB) SaveToken(token *oauth2.Token) error {
+// singleUserTokenAAD binds the encrypted single-user token to its row.
+const singleUserTokenAAD = "oauth_tokens:1"
+
+// accountTokenAAD binds an encrypted account token to its row.
+func accountTokenAAD(account string) string {
+	if account == defaultAccount {
+		return singleUserTokenAAD
+	}
+	return "account_tokens:" + account
+}
+
+// userTokenAAD binds an encrypted user token to the user's row.
+func userTokenAAD(email string) string {
+	return "users:" + email
+}
+
+// SetTokenKey sets the key Google tokens are encrypted with from now on (see
+// loadTokenKey). Rows stored in plaintext stay readable; EncryptTokens
+// encrypts them. A nil key stores new tokens in plaintext.
+func (d *DB) SetTokenKey(key []byte) {
+	d.tokenKey = key
+}
+
+// marshalToken encodes token for the token_json column, encrypting it if a
+// token key is set.
+func (d *DB) marshalToken(token *oauth2.Token, aad string) (string, error) {
 	data, err := json.Marshal(token)
 	if err != nil {
-		return fmt.Errorf("marshal token: %w", err)
+		return "", fmt.Errorf("marshal token: %w", err)
+	}
+	if d.tokenKey == nil {
+		return string(data), nil
+	}
+	return sealToken(d.tokenKey, string(data), aad)
+}
+
+// unmarshalToken decodes a token_json value written by marshalToken.
+func (d *DB) unmarshalToken(stored, aad string) (*oauth2.Token, error) {
+	plaintext, err := openToken(d.tokenKey, stored, aad)
+	if err != nil {
+		return nil, err
+	}
+	var token oauth2.Token
+	if err := json.Unmarshal([]byte(plaintext), &token); err != nil {
+		return nil, fmt.Errorf("unmarshal token: %w", err)
+	}
+	return &token, nil
+}
+
+// EncryptTokens encrypts every token stored in plaintext with the key set by
+// SetTokenKey, returning how many rows it encrypted. It is safe to run
+// repeatedly.
+func (d *DB) EncryptTokens() (int, error) {
+	if d.tokenKey == nil {
+		return 0, fmt.Errorf("no token key set; set MCP_GCAL_DB_KEY or --db-key-file")
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	type row struct {
+		table, where string
+		id           any
+		aad, stored  string
+	}
+	var rows []row
+	var single string
+	switch err := tx.QueryRow("SELECT token_json FROM oauth_tokens WHERE id = 1").Scan(&single); err {
+	case nil:
+		rows = append(rows, row{"oauth_tokens", "id", 1, singleUserTokenAAD, single})
+	case sql.ErrNoRows:
+	default:
+		return 0, fmt.Errorf("read token: %w", err)
+	}
+	users, err := tx.Query("SELECT email, token_json FROM users")
+	if err != nil {
+		return 0, fmt.Errorf("read users: %w", err)
+	}
+	for users.Next() {
+		var email, stored string
+		if err := users.Scan(&email, &stored); err != nil {
+			users.Close()
+			return 0, fmt.Errorf("read users: %w", err)
+		}
+		rows = append(rows, row{"users", "email", email, userTokenAAD(email), stored})
+	}
+	if err := users.Close(); err != nil {
+		return 0, fmt.Errorf("read users: %w", err)
+	}
+	accounts, err := tx.Query("SELECT account, token_json FROM account_tokens")
+	if err != nil {
+		return 0, fmt.Errorf("read account tokens: %w", err)
+	}
+	for accounts.Next() {
+		var account, stored string
+		if err := accounts.Scan(&account, &stored); err != nil {
+			accounts.Close()
+			return 0, fmt.Errorf("read account tokens: %w", err)
+		}
+		rows = append(rows, row{"account_tokens", "account", account, accountTokenAAD(account), stored})
+	}
+	if err := accounts.Close(); err != nil {
+		return 0, fmt.Errorf("read account tokens: %w", err)
+	}
+
+	encrypted := 0
+	for _, r := range rows {
+		if isEncryptedToken(r.stored) {
+			continue
+		}
+		sealed, err := sealToken(d.tokenKey, r.stored, r.aad)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec("UPDATE "+r.table+" SET token_json = ? WHERE "+r.where+" = ?", sealed, r.id); err != nil {
+			return 0, fmt.Errorf("encrypt %s token: %w", r.table, err)
+		}
+		encrypted++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return encrypted, nil
+}
+
+// SaveToken stores the OAuth2 token of a stdio mode account. The default
+// account uses the single-user table, so databases from before named
+// accounts keep working.
+func (d *DB) SaveToken(account string, token *oauth2.Token) error {
+	data, err := d.marshalToken(token, accountTokenAAD(account))
+	if err != nil {
+		return err
+	}
+	if account == defaultAccount {
+		_, err = d.db.Exec(`
+			INSERT OR REPLACE INTO oauth_tokens (id, token_json, updated_at)
+			VALUES (1, ?, datetime('now'))
+		`, data)
+		return err
 	}
 	_, err = d.db.Exec(`
-		INSERT OR REPLACE INTO oauth_tokens (id, token_json, updated_at)
-		VALUES (1, ?, datetime('now'))
-	`, string(data))
+		INSERT OR REPLACE INTO account_tokens (account, token_json, updated_at)
+		VALUES (?, ?, datetime('now'))
+	`, account, data)
 	return err
 }
 
-// LoadToken retrieves the stored single-user OAuth2 token.
-func (d *DB) LoadToken() (*oauth2.Token, error) {
+// LoadToken retrieves the stored OAuth2 token of a stdio mode account.
+func (d *DB) LoadToken(account string) (*oauth2.Token, error) {
 	var tokenJSON string
-	err := d.db.QueryRow("SELECT token_json FROM oauth_tokens WHERE id = 1").Scan(&tokenJSON)
+	var err error
+	if account == defaultAccount {
+		err = d.db.QueryRow("SELECT token_json FROM oauth_tokens WHERE id = 1").Scan(&tokenJSON)
+	} else {
+		err = d.db.QueryRow("SELECT token_json FROM account_tokens WHERE account = ?", account).Scan(&tokenJSON)
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("no token stored; run 'mcp-gcal auth' first")
+			if account == defaultAccount {
+				return nil, fmt.Errorf("%w; run 'mcp-gcal auth' first", errNoStoredToken)
+			}
+			return nil, fmt.Errorf("%w for account %q; run 'mcp-gcal auth --account=%s' first", errNoStoredToken, account, account)
 		}
 		return nil, err
 	}
-	var token oauth2.Token
-	if err := json.Unmarshal([]byte(tokenJSON), &token); err != nil {
-		return nil, fmt.Errorf("unmarshal token: %w", err)
+	return d.unmarshalToken(tokenJSON, accountTokenAAD(account))
+}
+
+// DeleteToken removes the stored OAuth2 token of a stdio mode account,
+// signing it out. Deleting when no token is stored is not an error.
+func (d *DB) DeleteToken(account string) error {
+	var err error
+	if account == defaultAccount {
+		_, err = d.db.Exec("DELETE FROM oauth_tokens WHERE id = 1")
+	} else {
+		_, err = d.db.Exec("DELETE FROM account_tokens WHERE account = ?", account)
 	}
-	return &token, nil
+	if err != nil {
+		return fmt.Errorf("delete token: %w", err)
+	}
+	return nil
 }
 
 // --- Multi-user methods (HTTP mode) ---
@@ -191,9 +568,9 @@ func (d *DB) LoadToken() (*oauth2.Token, error) {
 // CreateOrUpdateUser creates a new user or updates an existing one.
 // Returns the API key for the user.
 func (d *DB) CreateOrUpdateUser(email string, token *oauth2.Token) (string, error) {
-	tokenData, err := json.Marshal(token)
+	tokenData, err := d.marshalToken(token, userTokenAAD(email))
 	if err != nil {
-		return "", fmt.Errorf("marshal token: %w", err)
+		return "", err
 	}
 
 	apiKey, err := generateAPIKey()
@@ -210,7 +587,7 @@ func (d *DB) CreateOrUpdateUser(email string, token *oauth2.Token) (string, erro
 		// User exists: rotate API key and update token.
 		_, err = d.db.Exec(`
 			UPDATE users SET api_key = ?, token_json = ?, updated_at = datetime('now') WHERE id = ?
-		`, apiKeyHash, string(tokenData), userID)
+		`, apiKeyHash, tokenData, userID)
 		if err != nil {
 			return "", fmt.Errorf("update user: %w", err)
 		}
@@ -223,7 +600,7 @@ func (d *DB) CreateOrUpdateUser(email string, token *oauth2.Token) (string, erro
 
 	_, err = d.db.Exec(`
 		INSERT INTO users (email, api_key, token_json) VALUES (?, ?, ?)
-	`, email, apiKeyHash, string(tokenData))
+	`, email, apiKeyHash, tokenData)
 	if err != nil {
 		return "", fmt.Errorf("insert user: %w", err)
 	}
@@ -256,47 +633,149 @@ func (d *DB) GetUserToken(apiKey string) (*oauth2.Token, error) {
 	if u == nil {
 		return nil, fmt.Errorf("user not found")
 	}
-	var token oauth2.Token
-	if err := json.Unmarshal([]byte(u.TokenJSON), &token); err != nil {
-		return nil, fmt.Errorf("unmarshal token: %w", err)
+	return d.unmarshalToken(u.TokenJSON, userTokenAAD(u.Email))
+}
+
+// GetUserTokenByEmail retrieves the OAuth2 token for a user by email.
+func (d *DB) GetUserTokenByEmail(email string) (*oauth2.Token, error) {
+	u, err := d.GetUserByEmail(email)
+	if err != nil {
+		return nil, err
 	}
-	return &token, nil
+	if u == nil {
+		return nil, fmt.Errorf("%w: %s", errUserNotFound, email)
+	}
+	return d.unmarshalToken(u.TokenJSON, userTokenAAD(u.Email))
+}
+
+// RotateAPIKey issues a new API key for the user with the given email,
+// invalidating the previous key immediately. Returns the new plaintext key.
+func (d *DB) RotateAPIKey(email string) (string, error) {
+	apiKey, err := generateAPIKey()
+	if err != nil {
+		return "", fmt.Errorf("generate API key: %w", err)
+	}
+	result, err := d.db.Exec(`
+		UPDATE users SET api_key = ?, updated_at = datetime('now') WHERE email = ?
+	`, hashToken(apiKey), email)
+	if err != nil {
+		return "", fmt.Errorf("rotate api key: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return "", fmt.Errorf("user not found: %s", email)
+	}
+	return apiKey, nil
 }
 
 // UpdateUserToken saves a refreshed token for a user identified by email.
 func (d *DB) UpdateUserToken(email string, token *oauth2.Token) error {
-	data, err := json.Marshal(token)
+	data, err := d.marshalToken(token, userTokenAAD(email))
 	if err != nil {
-		return fmt.Errorf("marshal token: %w", err)
+		return err
 	}
 	_, err = d.db.Exec(`
 		UPDATE users SET token_json = ?, updated_at = datetime('now') WHERE email = ?
-	`, string(data), email)
+	`, data, email)
 	return err
 }
 
+// UserSummary is a user as listed by the users list command.
+type UserSummary struct {
+	Email     string
+	CreatedAt string
+}
+
+// ListUsers returns every user who has authenticated in HTTP mode, oldest
+// first.
+func (d *DB) ListUsers() ([]UserSummary, error) {
+	rows, err := d.db.Query("SELECT email, COALESCE(created_at, '') FROM users ORDER BY created_at, id")
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []UserSummary
+	for rows.Next() {
+		var u UserSummary
+		if err := rows.Scan(&u.Email, &u.CreatedAt); err != nil {
+			return nil, fmt.Errorf("list users: %w", err)
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// DeleteUser removes the user with the given email along with their MCP
+// OAuth tokens and pending authorization sessions, so neither their API key
+// nor any token issued to their clients works afterwards. Their scheduled
+// and snoozed emails, calendar preferences and watch channels go too, so no
+// background job keeps acting for a user who no longer exists.
+func (d *DB) DeleteUser(email string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM users WHERE email = ?", email)
+	if err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("user not found: %s", email)
+	}
+	if _, err := tx.Exec("DELETE FROM mcp_oauth_tokens WHERE user_email = ?", email); err != nil {
+		return fmt.Errorf("delete mcp tokens: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM mcp_oauth_sessions WHERE user_email = ?", email); err != nil {
+		return fmt.Errorf("delete auth sessions: %w", err)
+	}
+	for _, table := range []string{"scheduled_emails", "snoozed_emails", "recent_calendars", "default_calendars", "watch_channels"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE user_email = ?", email); err != nil {
+			return fmt.Errorf("delete %s: %w", table, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
 // --- MCP OAuth client methods ---
 
 // RegisterMCPClient registers a new MCP OAuth client with a generated UUID.
-func (d *DB) RegisterMCPClient(clientName string, redirectURIs []string) (string, error) {
-	clientID, err := generateSecureToken(16)
+// A confidential client is also issued a client secret, of which only the
+// hash is stored; public clients get an empty secret.
+func (d *DB) RegisterMCPClient(clientName string, redirectURIs []string, confidential bool) (clientID, clientSecret string, err error) {
+	clientID, err = generateSecureToken(16)
 	if err != nil {
-		return "", fmt.Errorf("generate client id: %w", err)
+		return "", "", fmt.Errorf("generate client id: %w", err)
+	}
+
+	var secretHash *string
+	if confidential {
+		clientSecret, err = generateSecureToken(32)
+		if err != nil {
+			return "", "", fmt.Errorf("generate client secret: %w", err)
+		}
+		hash := hashToken(clientSecret)
+		secretHash = &hash
 	}
 
 	urisJSON, err := json.Marshal(redirectURIs)
 	if err != nil {
-		return "", fmt.Errorf("marshal redirect_uris: %w", err)
+		return "", "", fmt.Errorf("marshal redirect_uris: %w", err)
 	}
 
 	_, err = d.db.Exec(`
-		INSERT INTO mcp_oauth_clients (client_id, client_name, redirect_uris)
-		VALUES (?, ?, ?)
-	`, clientID, clientName, string(urisJSON))
+		INSERT INTO mcp_oauth_clients (client_id, client_secret_hash, client_name, redirect_uris)
+		VALUES (?, ?, ?, ?)
+	`, clientID, secretHash, clientName, string(urisJSON))
 	if err != nil {
-		return "", fmt.Errorf("insert mcp oauth client: %w", err)
+		return "", "", fmt.Errorf("insert mcp oauth client: %w", err)
 	}
-	return clientID, nil
+	return clientID, clientSecret, nil
 }
 
 // GetMCPClient looks up an MCP OAuth client by client_id.
@@ -440,28 +919,38 @@ func (d *DB) CreateMCPToken(clientID, userEmail string) (accessToken, refreshTok
 
 // ValidateMCPAccessToken checks a bearer token and returns the associated user email.
 func (d *DB) ValidateMCPAccessToken(token string) (string, error) {
+	t, err := d.LookupMCPAccessToken(token)
+	if err != nil {
+		return "", err
+	}
+	return t.UserEmail, nil
+}
+
+// LookupMCPAccessToken returns the client, user and expiry of a valid,
+// unexpired access token.
+func (d *DB) LookupMCPAccessToken(token string) (*MCPToken, error) {
 	h := hashToken(token)
-	var userEmail string
+	var t MCPToken
 	var expiresAt string
 	err := d.db.QueryRow(
-		"SELECT user_email, expires_at FROM mcp_oauth_tokens WHERE access_token_hash = ?", h,
-	).Scan(&userEmail, &expiresAt)
+		"SELECT client_id, user_email, expires_at FROM mcp_oauth_tokens WHERE access_token_hash = ?", h,
+	).Scan(&t.ClientID, &t.UserEmail, &expiresAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return "", fmt.Errorf("invalid access token")
+			return nil, fmt.Errorf("invalid access token")
 		}
-		return "", err
+		return nil, err
 	}
 
-	exp, err := time.Parse(time.RFC3339, expiresAt)
+	t.ExpiresAt, err = time.Parse(time.RFC3339, expiresAt)
 	if err != nil {
-		return "", fmt.Errorf("parse expires_at: %w", err)
+		return nil, fmt.Errorf("parse expires_at: %w", err)
 	}
-	if time.Now().UTC().After(exp) {
-		return "", fmt.Errorf("access token expired")
+	if time.Now().UTC().After(t.ExpiresAt) {
+		return nil, fmt.Errorf("access token expired")
 	}
 
-	return userEmail, nil
+	return &t, nil
 }
 
 // RefreshMCPToken exchanges a refresh token for a new access/refresh token pair.
@@ -492,22 +981,413 @@ func (d *DB) RefreshMCPToken(refreshToken, clientID string) (string, string, err
 	return d.CreateMCPToken(clientID, userEmail)
 }
 
-// CleanupExpiredMCPData removes expired sessions and stale tokens.
+// RevokeMCPToken deletes the token pair issued to clientID whose access or
+// refresh token hashes to tokenHash, so neither token works any more.
+// Unknown hashes, and tokens issued to other clients, are not an error.
+func (d *DB) RevokeMCPToken(tokenHash, clientID string) error {
+	if _, err := d.db.Exec(
+		"DELETE FROM mcp_oauth_tokens WHERE (access_token_hash = ? OR refresh_token_hash = ?) AND client_id = ?",
+		tokenHash, tokenHash, clientID,
+	); err != nil {
+		return fmt.Errorf("revoke mcp token: %w", err)
+	}
+	return nil
+}
+
+// CleanupExpiredMCPData removes expired sessions and stale tokens and
+// returns how many of each were deleted.
 // Sessions are deleted as soon as they expire.
 // Tokens are kept for 7 days past access token expiry so that refresh tokens
 // remain usable even after the access token has expired.
-func (d *DB) CleanupExpiredMCPData() error {
+func (d *DB) CleanupExpiredMCPData() (sessions, tokens int64, err error) {
 	now := time.Now().UTC().Format(time.RFC3339)
-	if _, err := d.db.Exec("DELETE FROM mcp_oauth_sessions WHERE expires_at < ?", now); err != nil {
-		return fmt.Errorf("cleanup sessions: %w", err)
+	res, err := d.db.Exec("DELETE FROM mcp_oauth_sessions WHERE expires_at < ?", now)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cleanup sessions: %w", err)
+	}
+	if sessions, err = res.RowsAffected(); err != nil {
+		return 0, 0, fmt.Errorf("cleanup sessions: %w", err)
 	}
 	stale := time.Now().UTC().Add(-7 * 24 * time.Hour).Format(time.RFC3339)
-	if _, err := d.db.Exec("DELETE FROM mcp_oauth_tokens WHERE expires_at < ?", stale); err != nil {
-		return fmt.Errorf("cleanup tokens: %w", err)
+	res, err = d.db.Exec("DELETE FROM mcp_oauth_tokens WHERE expires_at < ?", stale)
+	if err != nil {
+		return sessions, 0, fmt.Errorf("cleanup tokens: %w", err)
+	}
+	if tokens, err = res.RowsAffected(); err != nil {
+		return sessions, 0, fmt.Errorf("cleanup tokens: %w", err)
+	}
+	return sessions, tokens, nil
+}
+
+// --- Recently used calendars ---
+
+// TouchRecentCalendar records that calendarID was just used by userEmail and
+// prunes entries beyond the most recent maxRecentCalendars.
+func (d *DB) TouchRecentCalendar(userEmail, calendarID string) error {
+	if _, err := d.db.Exec(`
+		INSERT INTO recent_calendars (user_email, calendar_id, used_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_email, calendar_id) DO UPDATE SET used_at = excluded.used_at
+	`, userEmail, calendarID, time.Now().UnixNano()); err != nil {
+		return fmt.Errorf("record recent calendar: %w", err)
+	}
+	if _, err := d.db.Exec(`
+		DELETE FROM recent_calendars WHERE user_email = ? AND calendar_id NOT IN (
+			SELECT calendar_id FROM recent_calendars WHERE user_email = ? ORDER BY used_at DESC LIMIT ?
+		)
+	`, userEmail, userEmail, maxRecentCalendars); err != nil {
+		return fmt.Errorf("prune recent calendars: %w", err)
+	}
+	return nil
+}
+
+// ListRecentCalendars returns userEmail's recently used calendars, most recent first.
+func (d *DB) ListRecentCalendars(userEmail string) ([]RecentCalendar, error) {
+	rows, err := d.db.Query(`
+		SELECT calendar_id, used_at FROM recent_calendars WHERE user_email = ? ORDER BY used_at DESC
+	`, userEmail)
+	if err != nil {
+		return nil, fmt.Errorf("list recent calendars: %w", err)
+	}
+	defer rows.Close()
+
+	result := []RecentCalendar{}
+	for rows.Next() {
+		var rc RecentCalendar
+		var usedAt int64
+		if err := rows.Scan(&rc.CalendarID, &usedAt); err != nil {
+			return nil, fmt.Errorf("scan recent calendar: %w", err)
+		}
+		rc.LastUsed = time.Unix(0, usedAt).UTC().Format(time.RFC3339)
+		result = append(result, rc)
+	}
+	return result, rows.Err()
+}
+
+// --- Default calendar ---
+
+// SetDefaultCalendar stores the calendar userEmail's tools use when no
+// calendar_id is given. Setting "primary" (or "") clears the preference.
+func (d *DB) SetDefaultCalendar(userEmail, calendarID string) error {
+	if calendarID == "" || calendarID == "primary" {
+		if _, err := d.db.Exec("DELETE FROM default_calendars WHERE user_email = ?", userEmail); err != nil {
+			return fmt.Errorf("clear default calendar: %w", err)
+		}
+		return nil
+	}
+	_, err := d.db.Exec(`
+		INSERT INTO default_calendars (user_email, calendar_id) VALUES (?, ?)
+		ON CONFLICT (user_email) DO UPDATE SET calendar_id = excluded.calendar_id, updated_at = datetime('now')
+	`, userEmail, calendarID)
+	if err != nil {
+		return fmt.Errorf("save default calendar: %w", err)
+	}
+	return nil
+}
+
+// GetDefaultCalendar returns userEmail's preferred calendar ID, or "" if none is set.
+func (d *DB) GetDefaultCalendar(userEmail string) (string, error) {
+	var calendarID string
+	err := d.db.QueryRow("SELECT calendar_id FROM default_calendars WHERE user_email = ?", userEmail).Scan(&calendarID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get default calendar: %w", err)
+	}
+	return calendarID, nil
+}
+
+// ForgetCalendar removes a deleted calendar from userEmail's default and
+// recently used calendars, so later calls do not fall back to it.
+func (d *DB) ForgetCalendar(userEmail, calendarID string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"default_calendars", "recent_calendars"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE user_email = ? AND calendar_id = ?", userEmail, calendarID); err != nil {
+			return fmt.Errorf("forget calendar in %s: %w", table, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// --- Watch channels ---
+
+// SaveWatchChannel records a watch channel opened for userEmail.
+func (d *DB) SaveWatchChannel(userEmail string, wc WatchChannel) error {
+	_, err := d.db.Exec(`
+		INSERT INTO watch_channels (channel_id, user_email, resource_id, calendar_id, expiration) VALUES (?, ?, ?, ?, ?)
+	`, wc.ChannelID, userEmail, wc.ResourceID, wc.CalendarID, wc.Expiration.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("save watch channel: %w", err)
+	}
+	return nil
+}
+
+// ListWatchChannels returns userEmail's watch channels, soonest to expire
+// first. Channels past their expiration are flagged rather than hidden, since
+// Google stops delivering on them but the record may still need cleaning up.
+func (d *DB) ListWatchChannels(userEmail string) ([]WatchChannel, error) {
+	rows, err := d.db.Query(`
+		SELECT channel_id, resource_id, calendar_id, expiration FROM watch_channels
+		WHERE user_email = ? ORDER BY expiration
+	`, userEmail)
+	if err != nil {
+		return nil, fmt.Errorf("list watch channels: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	result := []WatchChannel{}
+	for rows.Next() {
+		var wc WatchChannel
+		var expiration string
+		if err := rows.Scan(&wc.ChannelID, &wc.ResourceID, &wc.CalendarID, &expiration); err != nil {
+			return nil, fmt.Errorf("scan watch channel: %w", err)
+		}
+		wc.Expiration, _ = time.Parse(time.RFC3339, expiration)
+		wc.Expired = !wc.Expiration.After(now)
+		result = append(result, wc)
+	}
+	return result, rows.Err()
+}
+
+// GetWatchChannel returns one of userEmail's watch channels.
+func (d *DB) GetWatchChannel(userEmail, channelID string) (*WatchChannel, error) {
+	var wc WatchChannel
+	var expiration string
+	err := d.db.QueryRow(`
+		SELECT channel_id, resource_id, calendar_id, expiration FROM watch_channels
+		WHERE user_email = ? AND channel_id = ?
+	`, userEmail, channelID).Scan(&wc.ChannelID, &wc.ResourceID, &wc.CalendarID, &expiration)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("watch channel not found: %s", channelID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get watch channel: %w", err)
+	}
+	wc.Expiration, _ = time.Parse(time.RFC3339, expiration)
+	wc.Expired = !wc.Expiration.After(time.Now())
+	return &wc, nil
+}
+
+// DeleteWatchChannel removes a watch channel record.
+func (d *DB) DeleteWatchChannel(userEmail, channelID string) error {
+	if _, err := d.db.Exec("DELETE FROM watch_channels WHERE user_email = ? AND channel_id = ?", userEmail, channelID); err != nil {
+		return fmt.Errorf("delete watch channel: %w", err)
 	}
 	return nil
 }
 
+// --- Snoozed emails ---
+
+// SnoozeEmail records that messageID should return to userEmail's inbox at
+// wakeAt. Snoozing an already snoozed message replaces its wake time.
+func (d *DB) SnoozeEmail(userEmail, messageID, labelID string, wakeAt time.Time) error {
+	_, err := d.db.Exec(`
+		INSERT INTO snoozed_emails (user_email, message_id, label_id, wake_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_email, message_id) DO UPDATE SET label_id = excluded.label_id, wake_at = excluded.wake_at
+	`, userEmail, messageID, labelID, wakeAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("save snoozed email: %w", err)
+	}
+	return nil
+}
+
+// DueSnoozedEmails returns scope's snoozed emails whose wake time is at or
+// before now.
+func (d *DB) DueSnoozedEmails(now time.Time, scope stateKeyScope) ([]SnoozedEmail, error) {
+	filter, args := scope.userEmailFilter()
+	rows, err := d.db.Query(`
+		SELECT id, user_email, message_id, label_id, wake_at FROM snoozed_emails
+		WHERE wake_at <= ? AND `+filter+` ORDER BY wake_at
+	`, append([]any{now.UTC().Format(time.RFC3339)}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("list due snoozed emails: %w", err)
+	}
+	defer rows.Close()
+
+	var result []SnoozedEmail
+	for rows.Next() {
+		var se SnoozedEmail
+		var wakeAt string
+		if err := rows.Scan(&se.ID, &se.UserEmail, &se.MessageID, &se.LabelID, &wakeAt); err != nil {
+			return nil, fmt.Errorf("scan snoozed email: %w", err)
+		}
+		se.WakeAt, _ = time.Parse(time.RFC3339, wakeAt)
+		result = append(result, se)
+	}
+	return result, rows.Err()
+}
+
+// ClaimSnoozedEmail claims a due snoozed email for waking by moving its wake
+// time snoozeClaimLease past now. It reports false if another process
+// claimed it first, or it was snoozed again, since it was listed.
+func (d *DB) ClaimSnoozedEmail(se SnoozedEmail, now time.Time) (bool, error) {
+	res, err := d.db.Exec("UPDATE snoozed_emails SET wake_at = ? WHERE id = ? AND wake_at = ?",
+		now.Add(snoozeClaimLease).UTC().Format(time.RFC3339), se.ID, se.WakeAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return false, fmt.Errorf("claim snoozed email: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("claim snoozed email: %w", err)
+	}
+	return n == 1, nil
+}
+
+// DeleteSnoozedEmail removes a snooze record once the email has been woken.
+func (d *DB) DeleteSnoozedEmail(id int64) error {
+	if _, err := d.db.Exec("DELETE FROM snoozed_emails WHERE id = ?", id); err != nil {
+		return fmt.Errorf("delete snoozed email: %w", err)
+	}
+	return nil
+}
+
+// --- Scheduled emails ---
+
+// ScheduleEmail queues se for sending at sendAt and returns its ID.
+func (d *DB) ScheduleEmail(se ScheduledEmail, sendAt time.Time) (int64, error) {
+	res, err := d.db.Exec(`
+		INSERT INTO scheduled_emails (user_email, to_addr, subject, raw, thread_id, send_at) VALUES (?, ?, ?, ?, ?, ?)
+	`, se.UserEmail, se.To, se.Subject, se.Raw, se.ThreadID, sendAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("save scheduled email: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// ListScheduledEmails returns userEmail's scheduled emails, soonest first.
+func (d *DB) ListScheduledEmails(userEmail string) ([]ScheduledEmail, error) {
+	rows, err := d.db.Query(`
+		SELECT id, user_email, to_addr, subject, thread_id, send_at, status, message_id, error
+		FROM scheduled_emails WHERE user_email = ? ORDER BY send_at, id
+	`, userEmail)
+	if err != nil {
+		return nil, fmt.Errorf("list scheduled emails: %w", err)
+	}
+	defer rows.Close()
+
+	result := []ScheduledEmail{}
+	for rows.Next() {
+		var se ScheduledEmail
+		if err := rows.Scan(&se.ID, &se.UserEmail, &se.To, &se.Subject, &se.ThreadID, &se.SendAt, &se.Status, &se.MessageID, &se.Error); err != nil {
+			return nil, fmt.Errorf("scan scheduled email: %w", err)
+		}
+		result = append(result, se)
+	}
+	return result, rows.Err()
+}
+
+// DueScheduledEmails returns scope's pending scheduled emails whose send time
+// is at or before now.
+func (d *DB) DueScheduledEmails(now time.Time, scope stateKeyScope) ([]ScheduledEmail, error) {
+	filter, args := scope.userEmailFilter()
+	rows, err := d.db.Query(`
+		SELECT id, user_email, to_addr, subject, raw, thread_id, send_at, status
+		FROM scheduled_emails WHERE status = ? AND send_at <= ? AND `+filter+` ORDER BY send_at, id
+	`, append([]any{scheduledPending, now.UTC().Format(time.RFC3339)}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("list due scheduled emails: %w", err)
+	}
+	defer rows.Close()
+
+	var result []ScheduledEmail
+	for rows.Next() {
+		var se ScheduledEmail
+		if err := rows.Scan(&se.ID, &se.UserEmail, &se.To, &se.Subject, &se.Raw, &se.ThreadID, &se.SendAt, &se.Status); err != nil {
+			return nil, fmt.Errorf("scan scheduled email: %w", err)
+		}
+		result = append(result, se)
+	}
+	return result, rows.Err()
+}
+
+// ClaimScheduledEmail claims a pending scheduled email for sending. It
+// reports false if another process claimed it first or it was cancelled. An
+// email left sending by a crash is not retried, as it may have gone out.
+func (d *DB) ClaimScheduledEmail(id int64) (bool, error) {
+	res, err := d.db.Exec("UPDATE scheduled_emails SET status = ? WHERE id = ? AND status = ?", scheduledSending, id, scheduledPending)
+	if err != nil {
+		return false, fmt.Errorf("claim scheduled email: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("claim scheduled email: %w", err)
+	}
+	return n == 1, nil
+}
+
+// ReleaseScheduledEmail returns a claimed scheduled email to pending, so a
+// send that failed transiently is retried.
+func (d *DB) ReleaseScheduledEmail(id int64) error {
+	if _, err := d.db.Exec("UPDATE scheduled_emails SET status = ? WHERE id = ? AND status = ?", scheduledPending, id, scheduledSending); err != nil {
+		return fmt.Errorf("release scheduled email: %w", err)
+	}
+	return nil
+}
+
+// MarkScheduledEmailSent records that a scheduled email was sent as messageID.
+func (d *DB) MarkScheduledEmailSent(id int64, messageID string) error {
+	if _, err := d.db.Exec("UPDATE scheduled_emails SET status = ?, message_id = ? WHERE id = ?", scheduledSent, messageID, id); err != nil {
+		return fmt.Errorf("mark scheduled email sent: %w", err)
+	}
+	return nil
+}
+
+// MarkScheduledEmailFailed records that a scheduled email will not be sent.
+func (d *DB) MarkScheduledEmailFailed(id int64, reason string) error {
+	if _, err := d.db.Exec("UPDATE scheduled_emails SET status = ?, error = ? WHERE id = ?", scheduledFailed, reason, id); err != nil {
+		return fmt.Errorf("mark scheduled email failed: %w", err)
+	}
+	return nil
+}
+
+// CancelScheduledEmail deletes one of userEmail's pending scheduled emails.
+func (d *DB) CancelScheduledEmail(userEmail string, id int64) error {
+	res, err := d.db.Exec("DELETE FROM scheduled_emails WHERE id = ? AND user_email = ? AND status = ?", id, userEmail, scheduledPending)
+	if err != nil {
+		return fmt.Errorf("cancel scheduled email: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cancel scheduled email: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no pending scheduled email with id %d", id)
+	}
+	return nil
+}
+
+// Vacuum rebuilds the database file to reclaim free pages and returns the
+// database size in bytes before and after.
+func (d *DB) Vacuum() (before, after int64, err error) {
+	if before, err = d.size(); err != nil {
+		return 0, 0, err
+	}
+	if _, err := d.db.Exec("VACUUM"); err != nil {
+		return 0, 0, fmt.Errorf("vacuum: %w", err)
+	}
+	if after, err = d.size(); err != nil {
+		return 0, 0, err
+	}
+	return before, after, nil
+}
+
+// size returns the database size in bytes as page_count * page_size.
+func (d *DB) size() (int64, error) {
+	var pages, pageSize int64
+	if err := d.db.QueryRow("PRAGMA page_count").Scan(&pages); err != nil {
+		return 0, fmt.Errorf("page count: %w", err)
+	}
+	if err := d.db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, fmt.Errorf("page size: %w", err)
+	}
+	return pages * pageSize, nil
+}
+
 // --- User lookup by email ---
 
 // GetUserByEmail looks up a user by their email address.
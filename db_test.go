@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -136,3 +137,489 @@ func TestMigrateLegacyAPIKeys(t *testing.T) {
 		t.Fatalf("legacy key should resolve after migration")
 	}
 }
+
+func TestRotateAPIKey(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	oldKey, err := d.CreateOrUpdateUser("user@example.com", &oauth2.Token{AccessToken: "a", TokenType: "Bearer"})
+	if err != nil {
+		t.Fatalf("CreateOrUpdateUser() error = %v", err)
+	}
+
+	newKey, err := d.RotateAPIKey("user@example.com")
+	if err != nil {
+		t.Fatalf("RotateAPIKey() error = %v", err)
+	}
+	if newKey == oldKey || !strings.HasPrefix(newKey, "gcal_") {
+		t.Fatalf("RotateAPIKey() = %q, want a new gcal_ key", newKey)
+	}
+
+	if u, err := d.GetUserByAPIKey(oldKey); err != nil || u != nil {
+		t.Fatalf("old key should be invalid after rotation (user=%v, err=%v)", u, err)
+	}
+	if u, err := d.GetUserByAPIKey(newKey); err != nil || u == nil {
+		t.Fatalf("new key should resolve to a user (user=%v, err=%v)", u, err)
+	}
+
+	if _, err := d.RotateAPIKey("nobody@example.com"); err == nil {
+		t.Fatal("RotateAPIKey(unknown user) expected error")
+	}
+}
+
+func TestVacuum(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewDB(filepath.Join(t.TempDir(), "vacuum.db"))
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	for i := 0; i < 200; i++ {
+		tok := &oauth2.Token{AccessToken: strings.Repeat("x", 1024), TokenType: "Bearer"}
+		if _, err := d.CreateOrUpdateUser(fmt.Sprintf("user%d@example.com", i), tok); err != nil {
+			t.Fatalf("CreateOrUpdateUser() error = %v", err)
+		}
+	}
+	if _, err := d.db.Exec("DELETE FROM users"); err != nil {
+		t.Fatalf("delete users: %v", err)
+	}
+
+	before, after, err := d.Vacuum()
+	if err != nil {
+		t.Fatalf("Vacuum() error = %v", err)
+	}
+	if after >= before {
+		t.Fatalf("Vacuum() size %d -> %d, want it to shrink", before, after)
+	}
+}
+
+func TestCleanupExpiredMCPData(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	now := time.Now()
+	if err := d.CreateAuthSession("expired", "client", "http://localhost/cb", "", "", "", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("CreateAuthSession() error = %v", err)
+	}
+	if err := d.CreateAuthSession("live", "client", "http://localhost/cb", "", "", "", now.Add(time.Hour)); err != nil {
+		t.Fatalf("CreateAuthSession() error = %v", err)
+	}
+	staleAccess, _, err := d.CreateMCPToken("client", "stale@example.com")
+	if err != nil {
+		t.Fatalf("CreateMCPToken() error = %v", err)
+	}
+	recentAccess, _, err := d.CreateMCPToken("client", "recent@example.com")
+	if err != nil {
+		t.Fatalf("CreateMCPToken() error = %v", err)
+	}
+	for access, expiresAt := range map[string]time.Time{
+		staleAccess:  now.Add(-8 * 24 * time.Hour),
+		recentAccess: now.Add(-time.Hour),
+	} {
+		if _, err := d.db.Exec("UPDATE mcp_oauth_tokens SET expires_at = ? WHERE access_token_hash = ?",
+			expiresAt.UTC().Format(time.RFC3339), hashToken(access)); err != nil {
+			t.Fatalf("backdate token: %v", err)
+		}
+	}
+
+	sessions, tokens, err := d.CleanupExpiredMCPData()
+	if err != nil {
+		t.Fatalf("CleanupExpiredMCPData() error = %v", err)
+	}
+	if sessions != 1 || tokens != 1 {
+		t.Fatalf("CleanupExpiredMCPData() = %d sessions, %d tokens, want 1, 1", sessions, tokens)
+	}
+	if s, err := d.GetAuthSessionByState("live"); err != nil || s == nil {
+		t.Fatalf("GetAuthSessionByState(live) = %v, %v, want the live session kept", s, err)
+	}
+	var remaining []string
+	rows, err := d.db.Query("SELECT user_email FROM mcp_oauth_tokens")
+	if err != nil {
+		t.Fatalf("list tokens: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			t.Fatalf("scan token: %v", err)
+		}
+		remaining = append(remaining, email)
+	}
+	if len(remaining) != 1 || remaining[0] != "recent@example.com" {
+		t.Fatalf("remaining tokens = %v, want [recent@example.com]", remaining)
+	}
+}
+
+func TestRecentCalendars(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	for i := 0; i < maxRecentCalendars+2; i++ {
+		if err := d.TouchRecentCalendar("a@example.com", fmt.Sprintf("cal%d", i)); err != nil {
+			t.Fatalf("TouchRecentCalendar() error = %v", err)
+		}
+	}
+	if err := d.TouchRecentCalendar("a@example.com", "cal5"); err != nil {
+		t.Fatalf("TouchRecentCalendar() error = %v", err)
+	}
+	if err := d.TouchRecentCalendar("b@example.com", "other"); err != nil {
+		t.Fatalf("TouchRecentCalendar() error = %v", err)
+	}
+
+	recent, err := d.ListRecentCalendars("a@example.com")
+	if err != nil {
+		t.Fatalf("ListRecentCalendars() error = %v", err)
+	}
+	if len(recent) != maxRecentCalendars {
+		t.Fatalf("len(recent) = %d, want %d", len(recent), maxRecentCalendars)
+	}
+	if recent[0].CalendarID != "cal5" || recent[1].CalendarID != fmt.Sprintf("cal%d", maxRecentCalendars+1) {
+		t.Fatalf("recent order = %v, want cal5 first", recent)
+	}
+	for _, rc := range recent {
+		if rc.CalendarID == "cal0" || rc.CalendarID == "cal1" || rc.CalendarID == "other" {
+			t.Fatalf("recent contains pruned or foreign calendar %q", rc.CalendarID)
+		}
+	}
+}
+
+func TestDefaultCalendar(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	steps := []struct {
+		set  string
+		want string
+	}{
+		{"", ""},
+		{"team@group.calendar.google.com", "team@group.calendar.google.com"},
+		{"other@group.calendar.google.com", "other@group.calendar.google.com"},
+		{"primary", ""},
+	}
+	for _, step := range steps {
+		if step.set != "" {
+			if err := d.SetDefaultCalendar("a@example.com", step.set); err != nil {
+				t.Fatalf("SetDefaultCalendar(%q) error = %v", step.set, err)
+			}
+		}
+		got, err := d.GetDefaultCalendar("a@example.com")
+		if err != nil {
+			t.Fatalf("GetDefaultCalendar() error = %v", err)
+		}
+		if got != step.want {
+			t.Fatalf("after setting %q, GetDefaultCalendar() = %q, want %q", step.set, got, step.want)
+		}
+	}
+	if got, _ := d.GetDefaultCalendar("b@example.com"); got != "" {
+		t.Fatalf("other user's default calendar = %q, want none", got)
+	}
+}
+
+func TestNewMemoryDB(t *testing.T) {
+	t.Parallel()
+
+	d1, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d1.Close()
+	})
+	d2, err := NewDB(":memory:")
+	if err != nil {
+		t.Fatalf("NewDB(:memory:) error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d2.Close()
+	})
+
+	tok := &oauth2.Token{AccessToken: "access", TokenType: "Bearer"}
+	if err := d1.SaveToken(defaultAccount, tok); err != nil {
+		t.Fatalf("SaveToken() error = %v", err)
+	}
+	got, err := d1.LoadToken(defaultAccount)
+	if err != nil {
+		t.Fatalf("LoadToken() error = %v", err)
+	}
+	if got.AccessToken != "access" {
+		t.Fatalf("LoadToken().AccessToken = %q, want access", got.AccessToken)
+	}
+
+	if _, err := d2.LoadToken(defaultAccount); err == nil {
+		t.Fatal("second in-memory DB sees first DB's token, want isolated databases")
+	}
+}
+
+func TestDeleteToken(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	if err := d.SaveToken(defaultAccount, &oauth2.Token{AccessToken: "access", TokenType: "Bearer"}); err != nil {
+		t.Fatalf("SaveToken() error = %v", err)
+	}
+	if err := d.DeleteToken(defaultAccount); err != nil {
+		t.Fatalf("DeleteToken() error = %v", err)
+	}
+	if _, err := d.LoadToken(defaultAccount); err == nil || !strings.Contains(err.Error(), "mcp-gcal auth") {
+		t.Fatalf("LoadToken() after DeleteToken error = %v, want no token stored", err)
+	}
+	if err := d.DeleteToken(defaultAccount); err != nil {
+		t.Fatalf("DeleteToken() without a token error = %v", err)
+	}
+}
+
+func TestTokenEncryption(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	// Tokens written before a key is configured stay plaintext.
+	if err := d.SaveToken(defaultAccount, &oauth2.Token{AccessToken: "single", RefreshToken: "single-refresh"}); err != nil {
+		t.Fatalf("SaveToken() error = %v", err)
+	}
+	if _, err := d.CreateOrUpdateUser("old@example.com", &oauth2.Token{AccessToken: "old"}); err != nil {
+		t.Fatalf("CreateOrUpdateUser() error = %v", err)
+	}
+	if _, err := d.EncryptTokens(); err == nil {
+		t.Fatal("EncryptTokens() without a key succeeded")
+	}
+
+	key, _ := loadTokenKey(testTokenKey, "")
+	d.SetTokenKey(key)
+	if _, err := d.CreateOrUpdateUser("new@example.com", &oauth2.Token{AccessToken: "new"}); err != nil {
+		t.Fatalf("CreateOrUpdateUser() error = %v", err)
+	}
+	storedToken := func(query string, args ...any) string {
+		t.Helper()
+		var stored string
+		if err := d.db.QueryRow(query, args...).Scan(&stored); err != nil {
+			t.Fatalf("query token_json: %v", err)
+		}
+		return stored
+	}
+	if stored := storedToken("SELECT token_json FROM users WHERE email = ?", "new@example.com"); !isEncryptedToken(stored) {
+		t.Fatalf("new user token_json = %q, want encrypted", stored)
+	}
+	if tok, err := d.GetUserTokenByEmail("old@example.com"); err != nil || tok.AccessToken != "old" {
+		t.Fatalf("GetUserTokenByEmail(plaintext row) = %v, %v, want old token", tok, err)
+	}
+
+	n, err := d.EncryptTokens()
+	if err != nil || n != 2 {
+		t.Fatalf("EncryptTokens() = %d, %v, want 2 rows", n, err)
+	}
+	if n, err := d.EncryptTokens(); err != nil || n != 0 {
+		t.Fatalf("EncryptTokens() again = %d, %v, want 0 rows", n, err)
+	}
+	if stored := storedToken("SELECT token_json FROM oauth_tokens WHERE id = 1"); !isEncryptedToken(stored) {
+		t.Fatalf("single-user token_json = %q, want encrypted", stored)
+	}
+	if tok, err := d.LoadToken(defaultAccount); err != nil || tok.RefreshToken != "single-refresh" {
+		t.Fatalf("LoadToken() = %v, %v, want decrypted token", tok, err)
+	}
+	if tok, err := d.GetUserTokenByEmail("old@example.com"); err != nil || tok.AccessToken != "old" {
+		t.Fatalf("GetUserTokenByEmail() = %v, %v, want decrypted token", tok, err)
+	}
+
+	// A ciphertext moved to another user's row does not decrypt.
+	if _, err := d.db.Exec("UPDATE users SET token_json = ? WHERE email = ?",
+		storedToken("SELECT token_json FROM users WHERE email = ?", "new@example.com"), "old@example.com"); err != nil {
+		t.Fatalf("swap token_json: %v", err)
+	}
+	if _, err := d.GetUserTokenByEmail("old@example.com"); err == nil {
+		t.Fatal("GetUserTokenByEmail() decrypted another user's token")
+	}
+
+	d.SetTokenKey(nil)
+	if _, err := d.LoadToken(defaultAccount); err == nil || !strings.Contains(err.Error(), "MCP_GCAL_DB_KEY") {
+		t.Fatalf("LoadToken() without key error = %v, want key hint", err)
+	}
+}
+
+func TestListAndDeleteUsers(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := d.CreateOrUpdateUser(email, &oauth2.Token{AccessToken: email}); err != nil {
+			t.Fatalf("CreateOrUpdateUser(%s) error = %v", email, err)
+		}
+	}
+	accessA, _, err := d.CreateMCPToken("client", "a@example.com")
+	if err != nil {
+		t.Fatalf("CreateMCPToken() error = %v", err)
+	}
+	accessB, _, err := d.CreateMCPToken("client", "b@example.com")
+	if err != nil {
+		t.Fatalf("CreateMCPToken() error = %v", err)
+	}
+
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := d.ScheduleEmail(ScheduledEmail{UserEmail: email, To: "c@example.com", Raw: []byte("x")}, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("ScheduleEmail() error = %v", err)
+		}
+		if err := d.SnoozeEmail(email, "m1", "Label_1", time.Now().Add(-time.Minute)); err != nil {
+			t.Fatalf("SnoozeEmail() error = %v", err)
+		}
+		if err := d.TouchRecentCalendar(email, "team"); err != nil {
+			t.Fatalf("TouchRecentCalendar() error = %v", err)
+		}
+		if err := d.SetDefaultCalendar(email, "team"); err != nil {
+			t.Fatalf("SetDefaultCalendar() error = %v", err)
+		}
+		if err := d.SaveWatchChannel(email, WatchChannel{ChannelID: "ch-" + email, ResourceID: "r1", CalendarID: "team", Expiration: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatalf("SaveWatchChannel() error = %v", err)
+		}
+	}
+
+	users, err := d.ListUsers()
+	if err != nil {
+		t.Fatalf("ListUsers() error = %v", err)
+	}
+	if len(users) != 2 || users[0].Email != "a@example.com" || users[1].Email != "b@example.com" || users[0].CreatedAt == "" {
+		t.Fatalf("ListUsers() = %+v, want a and b with created_at", users)
+	}
+
+	if err := d.DeleteUser("a@example.com"); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if u, err := d.GetUserByEmail("a@example.com"); err != nil || u != nil {
+		t.Fatalf("GetUserByEmail(deleted) = %+v, %v, want nil", u, err)
+	}
+	if email, _ := d.ValidateMCPAccessToken(accessA); email != "" {
+		t.Fatalf("deleted user's MCP token still valid for %q", email)
+	}
+	if email, err := d.ValidateMCPAccessToken(accessB); err != nil || email != "b@example.com" {
+		t.Fatalf("other user's MCP token = %q, %v, want still valid", email, err)
+	}
+	if users, _ := d.ListUsers(); len(users) != 1 {
+		t.Fatalf("ListUsers() after delete = %+v, want only b", users)
+	}
+
+	// The deleted user's state is gone; the other user's is kept.
+	for _, tt := range []struct {
+		email string
+		want  int
+	}{
+		{"a@example.com", 0},
+		{"b@example.com", 1},
+	} {
+		scheduled, _ := d.ListScheduledEmails(tt.email)
+		recent, _ := d.ListRecentCalendars(tt.email)
+		channels, _ := d.ListWatchChannels(tt.email)
+		if len(scheduled) != tt.want || len(recent) != tt.want || len(channels) != tt.want {
+			t.Fatalf("%s state = %d scheduled, %d recent, %d channels, want %d each", tt.email, len(scheduled), len(recent), len(channels), tt.want)
+		}
+		def, _ := d.GetDefaultCalendar(tt.email)
+		if (def != "") != (tt.want == 1) {
+			t.Fatalf("%s default calendar = %q", tt.email, def)
+		}
+	}
+	if due, _ := d.DueSnoozedEmails(time.Now(), httpStateKeys); len(due) != 1 || due[0].UserEmail != "b@example.com" {
+		t.Fatalf("DueSnoozedEmails() = %+v, want only b's", due)
+	}
+	if err := d.DeleteUser("a@example.com"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("DeleteUser(missing) error = %v, want not found", err)
+	}
+}
+
+func TestAccountTokens(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	if err := d.SaveToken(defaultAccount, &oauth2.Token{AccessToken: "personal"}); err != nil {
+		t.Fatalf("SaveToken(default) error = %v", err)
+	}
+	if _, err := d.LoadToken("work"); err == nil || !strings.Contains(err.Error(), "--account=work") {
+		t.Fatalf("LoadToken(work) before auth error = %v, want auth --account hint", err)
+	}
+	key, _ := loadTokenKey(testTokenKey, "")
+	d.SetTokenKey(key)
+	if err := d.SaveToken("work", &oauth2.Token{AccessToken: "work"}); err != nil {
+		t.Fatalf("SaveToken(work) error = %v", err)
+	}
+
+	for account, want := range map[string]string{defaultAccount: "personal", "work": "work"} {
+		tok, err := d.LoadToken(account)
+		if err != nil || tok.AccessToken != want {
+			t.Fatalf("LoadToken(%s) = %v, %v, want %s", account, tok, err, want)
+		}
+	}
+	// The default account still lives in the original single-user row.
+	var n int
+	if err := d.db.QueryRow("SELECT COUNT(*) FROM oauth_tokens").Scan(&n); err != nil || n != 1 {
+		t.Fatalf("oauth_tokens rows = %d, %v, want 1", n, err)
+	}
+	if n, err := d.EncryptTokens(); err != nil || n != 1 {
+		t.Fatalf("EncryptTokens() = %d, %v, want only the plaintext default token", n, err)
+	}
+
+	if err := d.DeleteToken("work"); err != nil {
+		t.Fatalf("DeleteToken(work) error = %v", err)
+	}
+	if _, err := d.LoadToken("work"); err == nil {
+		t.Fatal("LoadToken(work) after DeleteToken succeeded")
+	}
+	if _, err := d.LoadToken(defaultAccount); err != nil {
+		t.Fatalf("LoadToken(default) after deleting work error = %v", err)
+	}
+}
@@ -1,16 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"html"
+	"io"
 	"mime"
+	"net/http"
+	"net/mail"
+	"sort"
 	"strings"
 
 	"golang.org/x/oauth2"
 	"google.golang.org/api/gmail/v1"
-	"google.golang.org/api/option"
+	"google.golang.org/api/googleapi"
 )
 
 // Attachment represents a file attachment for sending emails.
@@ -22,16 +29,17 @@ type Attachment struct {
 
 // GmailService wraps the Google Gmail API.
 type GmailService struct {
-	svc *gmail.Service
+	svc  *gmail.Service
+	opts Options
 }
 
 // NewGmailService creates a Gmail API client from a token source.
-func NewGmailService(ctx context.Context, ts oauth2.TokenSource) (*GmailService, error) {
-	svc, err := gmail.NewService(ctx, option.WithTokenSource(ts))
+func NewGmailService(ctx context.Context, ts oauth2.TokenSource, opts Options) (*GmailService, error) {
+	svc, err := gmail.NewService(ctx, googleClientOption(ts, opts.RequestTimeout))
 	if err != nil {
 		return nil, fmt.Errorf("create gmail service: %w", err)
 	}
-	return &GmailService{svc: svc}, nil
+	return &GmailService{svc: svc, opts: opts}, nil
 }
 
 // JSON output types
@@ -43,11 +51,14 @@ type emailJSON struct {
 	From        string           `json:"from"`
 	To          string           `json:"to"`
 	Cc          string           `json:"cc,omitempty"`
+	Bcc         string           `json:"bcc,omitempty"`
 	Date        string           `json:"date"`
 	Snippet     string           `json:"snippet,omitempty"`
 	Body        string           `json:"body,omitempty"`
 	Labels      []string         `json:"labels,omitempty"`
 	Attachments []attachmentJSON `json:"attachments,omitempty"`
+	Raw         string           `json:"raw,omitempty"`
+	Warnings    []string         `json:"warnings,omitempty"`
 }
 
 type attachmentJSON struct {
@@ -57,12 +68,35 @@ type attachmentJSON struct {
 	Size     int64  `json:"size"`
 }
 
+// attachmentDataJSON is an attachment's content, as returned by GetAttachment.
+type attachmentDataJSON struct {
+	MessageID    string `json:"messageId"`
+	AttachmentID string `json:"attachmentId"`
+	Size         int64  `json:"size"`
+	// Data is the content, base64url-encoded as Gmail returns it.
+	Data string `json:"data"`
+}
+
+// draftJSON summarizes a draft, as listed by ListDrafts.
+type draftJSON struct {
+	ID        string `json:"id"`
+	MessageID string `json:"messageId"`
+	ThreadID  string `json:"threadId,omitempty"`
+	Subject   string `json:"subject"`
+	To        string `json:"to"`
+	Cc        string `json:"cc,omitempty"`
+	Snippet   string `json:"snippet,omitempty"`
+}
+
 type labelJSON struct {
 	ID             string `json:"id"`
 	Name           string `json:"name"`
 	Type           string `json:"type,omitempty"`
 	MessagesTotal  int64  `json:"messagesTotal,omitempty"`
 	MessagesUnread int64  `json:"messagesUnread,omitempty"`
+
+	LabelListVisibility   string `json:"labelListVisibility,omitempty"`
+	MessageListVisibility string `json:"messageListVisibility,omitempty"`
 }
 
 // Helper functions
@@ -127,13 +161,19 @@ func extractAttachments(part *gmail.MessagePart) []attachmentJSON {
 	return attachments
 }
 
-func convertMessage(msg *gmail.Message) emailJSON {
-	email := emailJSON{
+// emailSummary returns the fields of msg every format carries. Gmail
+// HTML-escapes snippets, so the snippet is unescaped.
+func emailSummary(msg *gmail.Message) emailJSON {
+	return emailJSON{
 		ID:       msg.Id,
 		ThreadID: msg.ThreadId,
-		Snippet:  msg.Snippet,
+		Snippet:  html.UnescapeString(msg.Snippet),
 		Labels:   msg.LabelIds,
 	}
+}
+
+func convertMessage(msg *gmail.Message) emailJSON {
+	email := emailSummary(msg)
 	if msg.Payload != nil {
 		email.Subject = getHeader(msg.Payload.Headers, "Subject")
 		email.From = getHeader(msg.Payload.Headers, "From")
@@ -153,6 +193,39 @@ func convertMessage(msg *gmail.Message) emailJSON {
 	return email
 }
 
+// decodeBase64URL decodes Gmail's base64url data, which may or may not be padded.
+func decodeBase64URL(data string) ([]byte, error) {
+	if strings.HasSuffix(data, "=") {
+		return base64.URLEncoding.DecodeString(data)
+	}
+	return base64.RawURLEncoding.DecodeString(data)
+}
+
+// convertRawMessage converts a message fetched with format=raw, decoding the
+// RFC822 source into Raw and filling the summary headers from it.
+func convertRawMessage(msg *gmail.Message) (emailJSON, error) {
+	email := emailSummary(msg)
+	decoded, err := decodeBase64URL(msg.Raw)
+	if err != nil {
+		return email, fmt.Errorf("decode raw message: %w", err)
+	}
+	email.Raw = string(decoded)
+
+	if m, err := mail.ReadMessage(bytes.NewReader(decoded)); err == nil {
+		dec := new(mime.WordDecoder)
+		subject := m.Header.Get("Subject")
+		if ds, err := dec.DecodeHeader(subject); err == nil {
+			subject = ds
+		}
+		email.Subject = subject
+		email.From = m.Header.Get("From")
+		email.To = m.Header.Get("To")
+		email.Cc = m.Header.Get("Cc")
+		email.Date = m.Header.Get("Date")
+	}
+	return email, nil
+}
+
 // validateAttachments checks that attachment fields are valid for MIME construction.
 func validateAttachments(attachments []Attachment) error {
 	for i, att := range attachments {
@@ -175,17 +248,155 @@ func validateAttachments(attachments []Attachment) error {
 	return nil
 }
 
-func buildRawEmail(to, subject, body, cc, bcc, inReplyTo string, attachments []Attachment) string {
+// maxAttachmentsTotalBytes is Gmail's limit on the total size of attachments.
+const maxAttachmentsTotalBytes = 25 << 20
+
+// validateAttachmentLimits checks the attachment count against maxCount (0 =
+// no limit) and their total decoded size against Gmail's limit, so an
+// oversized email fails before anything is sent.
+func validateAttachmentLimits(attachments []Attachment, maxCount int) error {
+	if maxCount > 0 && len(attachments) > maxCount {
+		return fmt.Errorf("too many attachments: %d (maximum %d)", len(attachments), maxCount)
+	}
+	var total int
+	for _, att := range attachments {
+		total += base64.StdEncoding.DecodedLen(len(att.Data))
+	}
+	if total > maxAttachmentsTotalBytes {
+		return fmt.Errorf("attachments total %d bytes, over Gmail's %d byte limit", total, maxAttachmentsTotalBytes)
+	}
+	return nil
+}
+
+// checkAttachments validates attachments for an outgoing email.
+func (gs *GmailService) checkAttachments(attachments []Attachment) error {
+	if err := validateAttachments(attachments); err != nil {
+		return err
+	}
+	return validateAttachmentLimits(attachments, gs.opts.MaxAttachments)
+}
+
+// validateReplyTo checks that a non-empty Reply-To value is a single valid address.
+func validateReplyTo(replyTo string) error {
+	if replyTo == "" {
+		return nil
+	}
+	if _, err := mail.ParseAddress(replyTo); err != nil {
+		return fmt.Errorf("invalid reply_to address %q: %w", replyTo, err)
+	}
+	return nil
+}
+
+// validateRecipients checks that To, Cc and Bcc are well-formed address lists,
+// such as "a@example.com, Alice <alice@example.com>", so a malformed entry is
+// reported clearly instead of as an opaque Gmail error.
+func validateRecipients(to, cc, bcc string) error {
+	if strings.TrimSpace(to+cc+bcc) == "" {
+		return fmt.Errorf("at least one recipient is required in to, cc or bcc")
+	}
+	for _, f := range []struct{ name, list string }{{"to", to}, {"cc", cc}, {"bcc", bcc}} {
+		if strings.TrimSpace(f.list) == "" {
+			continue
+		}
+		if _, err := mail.ParseAddressList(f.list); err != nil {
+			return fmt.Errorf("invalid %s address %q: %w", f.name, badAddressEntry(f.list), err)
+		}
+	}
+	return nil
+}
+
+// badAddressEntry returns the first comma-separated entry of list that does not
+// parse as an address, or list itself if none can be singled out (e.g. when a
+// quoted display name contains a comma).
+func badAddressEntry(list string) string {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if _, err := mail.ParseAddress(entry); err != nil {
+			return entry
+		}
+	}
+	return list
+}
+
+// dedupRecipients removes addresses repeated within or across To, Cc and Bcc,
+// keeping each in the highest-priority field (To, then Cc, then Bcc). A field
+// is only rewritten when something was removed from it; fields that cannot be
+// parsed are left as they are. It returns a warning per removed address.
+func dedupRecipients(to, cc, bcc string) (string, string, string, []string) {
+	fields := []struct {
+		name string
+		list *string
+	}{{"To", &to}, {"Cc", &cc}, {"Bcc", &bcc}}
+
+	seenIn := map[string]string{}
+	var warnings []string
+	for _, f := range fields {
+		if strings.TrimSpace(*f.list) == "" {
+			continue
+		}
+		addrs, err := mail.ParseAddressList(*f.list)
+		if err != nil {
+			continue
+		}
+		kept := make([]string, 0, len(addrs))
+		for _, a := range addrs {
+			key := strings.ToLower(a.Address)
+			if first, ok := seenIn[key]; ok {
+				warnings = append(warnings, fmt.Sprintf("removed duplicate recipient %s from %s (already in %s)", a.Address, f.name, first))
+				continue
+			}
+			seenIn[key] = f.name
+			kept = append(kept, a.String())
+		}
+		if len(kept) < len(addrs) {
+			*f.list = strings.Join(kept, ", ")
+		}
+	}
+	return to, cc, bcc, warnings
+}
+
+// selfSendWarning returns a warning if from is among the recipients.
+func selfSendWarning(from string, recipientLists ...string) string {
+	self, err := mail.ParseAddress(from)
+	if err != nil {
+		return ""
+	}
+	for _, list := range recipientLists {
+		addrs, err := mail.ParseAddressList(list)
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if strings.EqualFold(a.Address, self.Address) {
+				return fmt.Sprintf("this email was also sent to yourself (%s)", self.Address)
+			}
+		}
+	}
+	return ""
+}
+
+// buildRawEmail returns the RFC822 message base64url-encoded for the raw field.
+func buildRawEmail(to, subject, body, cc, bcc, replyTo, inReplyTo string, attachments []Attachment) string {
+	return base64.RawURLEncoding.EncodeToString(buildMIMEMessage(to, subject, body, cc, bcc, replyTo, inReplyTo, attachments))
+}
+
+// buildMIMEMessage assembles the RFC822 message bytes.
+func buildMIMEMessage(to, subject, body, cc, bcc, replyTo, inReplyTo string, attachments []Attachment) []byte {
 	var buf strings.Builder
 
-	// Common headers
-	buf.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	// Common headers. To is omitted, not left blank, for Bcc-only messages.
+	if to != "" {
+		buf.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	}
 	if cc != "" {
 		buf.WriteString(fmt.Sprintf("Cc: %s\r\n", cc))
 	}
 	if bcc != "" {
 		buf.WriteString(fmt.Sprintf("Bcc: %s\r\n", bcc))
 	}
+	if replyTo != "" {
+		buf.WriteString(fmt.Sprintf("Reply-To: %s\r\n", replyTo))
+	}
 	buf.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject)))
 	if inReplyTo != "" {
 		buf.WriteString(fmt.Sprintf("In-Reply-To: %s\r\n", inReplyTo))
@@ -197,7 +408,7 @@ func buildRawEmail(to, subject, body, cc, bcc, inReplyTo string, attachments []A
 		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
 		buf.WriteString("\r\n")
 		buf.WriteString(body)
-		return base64.RawURLEncoding.EncodeToString([]byte(buf.String()))
+		return []byte(buf.String())
 	}
 
 	// MIME multipart email with attachments
@@ -227,7 +438,26 @@ func buildRawEmail(to, subject, body, cc, bcc, inReplyTo string, attachments []A
 	// Closing boundary
 	buf.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
 
-	return base64.RawURLEncoding.EncodeToString([]byte(buf.String()))
+	return []byte(buf.String())
+}
+
+// mediaUploadThreshold is the message size above which messages are uploaded
+// through Gmail's media endpoint instead of inlined as base64 in the JSON body.
+// Inlining grows the request by a third and hits the metadata request limit
+// well below Gmail's 25MB attachment limit.
+const mediaUploadThreshold = 5 << 20
+
+// newOutgoingMessage prepares raw for sending. Small messages are returned with
+// Raw set; for large ones media is non-nil and should be passed to the call's
+// Media method. The client library switches to a resumable upload, retried with
+// backoff, once media exceeds its chunk size.
+func newOutgoingMessage(raw []byte, threadID string) (msg *gmail.Message, media io.Reader) {
+	msg = &gmail.Message{ThreadId: threadID}
+	if len(raw) > mediaUploadThreshold {
+		return msg, bytes.NewReader(raw)
+	}
+	msg.Raw = base64.RawURLEncoding.EncodeToString(raw)
+	return msg, nil
 }
 
 func generateBoundary() string {
@@ -262,12 +492,13 @@ func wrapBase64Lines(data string) string {
 // Service methods
 
 // SearchEmails searches emails using Gmail query syntax and returns metadata.
-func (gs *GmailService) SearchEmails(query string, maxResults int64) ([]emailJSON, error) {
+func (gs *GmailService) SearchEmails(ctx context.Context, query string, maxResults int64) ([]emailJSON, error) {
 	if maxResults <= 0 {
 		maxResults = 20
 	}
+	maxResults, _ = clampMaxResults(maxResults, gs.opts.MaxResultsCeiling)
 
-	list, err := gs.svc.Users.Messages.List("me").Q(query).MaxResults(maxResults).Do()
+	list, err := gs.svc.Users.Messages.List("me").Q(query).MaxResults(maxResults).Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("search emails: %w", err)
 	}
@@ -275,20 +506,16 @@ func (gs *GmailService) SearchEmails(query string, maxResults int64) ([]emailJSO
 	results := make([]emailJSON, 0, len(list.Messages))
 	for _, m := range list.Messages {
 		msg, err := gs.svc.Users.Messages.Get("me", m.Id).Format("metadata").
-			MetadataHeaders("Subject", "From", "To", "Date").Do()
+			MetadataHeaders("Subject", "From", "To", "Cc", "Date").Context(ctx).Do()
 		if err != nil {
 			continue
 		}
-		email := emailJSON{
-			ID:       msg.Id,
-			ThreadID: msg.ThreadId,
-			Snippet:  msg.Snippet,
-			Labels:   msg.LabelIds,
-		}
+		email := emailSummary(msg)
 		if msg.Payload != nil {
 			email.Subject = getHeader(msg.Payload.Headers, "Subject")
 			email.From = getHeader(msg.Payload.Headers, "From")
 			email.To = getHeader(msg.Payload.Headers, "To")
+			email.Cc = getHeader(msg.Payload.Headers, "Cc")
 			email.Date = getHeader(msg.Payload.Headers, "Date")
 		}
 		results = append(results, email)
@@ -296,34 +523,216 @@ func (gs *GmailService) SearchEmails(query string, maxResults int64) ([]emailJSO
 	return results, nil
 }
 
-// ReadEmail retrieves the full content of an email.
-func (gs *GmailService) ReadEmail(messageID string) (*emailJSON, error) {
-	msg, err := gs.svc.Users.Messages.Get("me", messageID).Format("full").Do()
+// ReadEmail retrieves an email. format is full (default), metadata (headers only),
+// or raw (the complete RFC822 source in the raw field). If markRead is set, an
+// unread email is then marked read and its updated labels returned.
+func (gs *GmailService) ReadEmail(ctx context.Context, messageID, format string, markRead bool) (*emailJSON, error) {
+	switch format {
+	case "":
+		format = "full"
+	case "full", "metadata", "raw":
+	default:
+		return nil, fmt.Errorf("invalid format: %s (must be full, metadata, or raw)", format)
+	}
+
+	msg, err := gs.svc.Users.Messages.Get("me", messageID).Format(format).Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("read email: %w", err)
 	}
-	email := convertMessage(msg)
+	var email emailJSON
+	if format == "raw" {
+		email, err = convertRawMessage(msg)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		email = convertMessage(msg)
+	}
+	if markRead && hasLabel(email.Labels, "UNREAD") {
+		modified, err := gs.ModifyEmail(ctx, messageID, "", "UNREAD")
+		if err != nil {
+			return nil, err
+		}
+		email.Labels = modified.Labels
+	}
 	return &email, nil
 }
 
+// ReadThread retrieves every message in a thread, oldest first, as Gmail
+// orders them. If markRead is set and any message is unread, the whole
+// thread is then marked read and the messages' updated labels returned.
+func (gs *GmailService) ReadThread(ctx context.Context, threadID string, markRead bool) ([]emailJSON, error) {
+	if threadID == "" {
+		return nil, fmt.Errorf("thread_id is required")
+	}
+	thread, err := gs.svc.Users.Threads.Get("me", threadID).Format("full").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("read thread: %w", err)
+	}
+	emails := make([]emailJSON, 0, len(thread.Messages))
+	unread := false
+	for _, msg := range thread.Messages {
+		email := convertMessage(msg)
+		unread = unread || hasLabel(email.Labels, "UNREAD")
+		emails = append(emails, email)
+	}
+	if markRead && unread {
+		modified, err := gs.svc.Users.Threads.Modify("me", threadID, &gmail.ModifyThreadRequest{
+			RemoveLabelIds: []string{"UNREAD"},
+		}).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("mark thread read: %w", err)
+		}
+		labels := make(map[string][]string, len(modified.Messages))
+		for _, msg := range modified.Messages {
+			labels[msg.Id] = msg.LabelIds
+		}
+		for i := range emails {
+			if l, ok := labels[emails[i].ID]; ok {
+				emails[i].Labels = l
+			}
+		}
+	}
+	return emails, nil
+}
+
+// GetAttachment downloads an attachment of an email. attachmentID is an ID
+// listed in the email's attachments by ReadEmail, which also gives its size.
+func (gs *GmailService) GetAttachment(ctx context.Context, messageID, attachmentID string) (*attachmentDataJSON, error) {
+	if messageID == "" || attachmentID == "" {
+		return nil, fmt.Errorf("message_id and attachment_id are required")
+	}
+	body, err := gs.svc.Users.Messages.Attachments.Get("me", messageID, attachmentID).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("get attachment: %w", err)
+	}
+	return &attachmentDataJSON{
+		MessageID:    messageID,
+		AttachmentID: attachmentID,
+		Size:         body.Size,
+		Data:         body.Data,
+	}, nil
+}
+
+// hasLabel reports whether labels contains the label ID.
+func hasLabel(labels []string, id string) bool {
+	for _, l := range labels {
+		if l == id {
+			return true
+		}
+	}
+	return false
+}
+
 // SendEmail sends an email and returns the sent message metadata.
-func (gs *GmailService) SendEmail(to, subject, body, cc, bcc, threadID, inReplyTo string, attachments []Attachment) (*emailJSON, error) {
-	raw := buildRawEmail(to, subject, body, cc, bcc, inReplyTo, attachments)
-	msg := &gmail.Message{Raw: raw}
-	if threadID != "" {
-		msg.ThreadId = threadID
+func (gs *GmailService) SendEmail(ctx context.Context, to, subject, body, cc, bcc, replyTo, threadID, inReplyTo string, attachments []Attachment) (*emailJSON, error) {
+	if err := gs.checkAttachments(attachments); err != nil {
+		return nil, err
 	}
+	to, cc, bcc, warnings, err := prepareRecipients(to, cc, bcc, replyTo)
+	if err != nil {
+		return nil, err
+	}
+	// Recipients' clients thread by In-Reply-To/References, not Gmail's
+	// thread ID, so reply to the thread's latest message unless told otherwise.
+	if threadID != "" && inReplyTo == "" {
+		latest, err := gs.latestMessageID(ctx, threadID)
+		if err != nil {
+			return nil, err
+		}
+		inReplyTo = latest
+	}
+	raw := buildMIMEMessage(to, subject, body, cc, bcc, replyTo, inReplyTo, attachments)
+	email, err := gs.SendRawEmail(ctx, raw, threadID)
+	if err != nil {
+		return nil, err
+	}
+	// The sender's address is only known from the sent message's From header.
+	if w := selfSendWarning(email.From, to, cc, bcc); w != "" {
+		warnings = append(warnings, w)
+	}
+	email.Warnings = warnings
+	return email, nil
+}
+
+// prepareRecipients validates an outgoing email's recipients and Reply-To,
+// and drops duplicate recipients, returning a warning for each one dropped.
+func prepareRecipients(to, cc, bcc, replyTo string) (string, string, string, []string, error) {
+	if err := validateRecipients(to, cc, bcc); err != nil {
+		return "", "", "", nil, err
+	}
+	if err := validateReplyTo(replyTo); err != nil {
+		return "", "", "", nil, err
+	}
+	to, cc, bcc, warnings := dedupRecipients(to, cc, bcc)
+	return to, cc, bcc, warnings, nil
+}
 
-	sent, err := gs.svc.Users.Messages.Send("me", msg).Do()
+// SendScheduledEmail sends a message assembled by schedule-email. A reply
+// scheduled without in_reply_to is threaded to the thread's latest message
+// as of sending, as SendEmail does.
+func (gs *GmailService) SendScheduledEmail(ctx context.Context, raw []byte, threadID string) (*emailJSON, error) {
+	if threadID != "" && !hasInReplyTo(raw) {
+		latest, err := gs.latestMessageID(ctx, threadID)
+		if err != nil {
+			return nil, err
+		}
+		if latest != "" {
+			headers := fmt.Sprintf("In-Reply-To: %s\r\nReferences: %s\r\n", latest, latest)
+			raw = append([]byte(headers), raw...)
+		}
+	}
+	return gs.SendRawEmail(ctx, raw, threadID)
+}
+
+// hasInReplyTo reports whether the raw message sets In-Reply-To.
+func hasInReplyTo(raw []byte) bool {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	return err == nil && msg.Header.Get("In-Reply-To") != ""
+}
+
+// latestMessageID returns the Message-ID header of the last message in a
+// thread, or "" if it has none.
+func (gs *GmailService) latestMessageID(ctx context.Context, threadID string) (string, error) {
+	thread, err := gs.svc.Users.Threads.Get("me", threadID).Format("metadata").
+		MetadataHeaders("Message-ID").Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("get thread: %w", err)
+	}
+	if len(thread.Messages) == 0 {
+		return "", nil
+	}
+	last := thread.Messages[len(thread.Messages)-1]
+	if last.Payload == nil {
+		return "", nil
+	}
+	return getHeader(last.Payload.Headers, "Message-ID"), nil
+}
+
+// SendRawEmail sends an already assembled RFC 2822 message and returns the
+// sent message's metadata.
+func (gs *GmailService) SendRawEmail(ctx context.Context, raw []byte, threadID string) (*emailJSON, error) {
+	msg, media := newOutgoingMessage(raw, threadID)
+	call := gs.svc.Users.Messages.Send("me", msg)
+	if media != nil {
+		call = call.Media(media, googleapi.ContentType("message/rfc822"))
+	}
+
+	sent, err := call.Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("send email: %w", err)
 	}
+	return gs.sentEmail(ctx, sent), nil
+}
 
-	// Fetch metadata of the sent message
+// sentEmail returns the metadata of a just sent message, falling back to its
+// IDs if the metadata cannot be fetched.
+func (gs *GmailService) sentEmail(ctx context.Context, sent *gmail.Message) *emailJSON {
+	// The sender's copy keeps the Bcc header.
 	result, err := gs.svc.Users.Messages.Get("me", sent.Id).Format("metadata").
-		MetadataHeaders("Subject", "From", "To", "Date").Do()
+		MetadataHeaders("Subject", "From", "To", "Cc", "Bcc", "Date").Context(ctx).Do()
 	if err != nil {
-		return &emailJSON{ID: sent.Id, ThreadID: sent.ThreadId}, nil
+		return &emailJSON{ID: sent.Id, ThreadID: sent.ThreadId}
 	}
 	email := emailJSON{
 		ID:       result.Id,
@@ -334,19 +743,32 @@ func (gs *GmailService) SendEmail(to, subject, body, cc, bcc, threadID, inReplyT
 		email.Subject = getHeader(result.Payload.Headers, "Subject")
 		email.From = getHeader(result.Payload.Headers, "From")
 		email.To = getHeader(result.Payload.Headers, "To")
+		email.Cc = getHeader(result.Payload.Headers, "Cc")
+		email.Bcc = getHeader(result.Payload.Headers, "Bcc")
 		email.Date = getHeader(result.Payload.Headers, "Date")
 	}
-	return &email, nil
+	return &email
 }
 
 // DraftEmail creates a draft email without sending it.
-func (gs *GmailService) DraftEmail(to, subject, body, cc, bcc string, attachments []Attachment) (any, error) {
-	raw := buildRawEmail(to, subject, body, cc, bcc, "", attachments)
-	draft := &gmail.Draft{
-		Message: &gmail.Message{Raw: raw},
+func (gs *GmailService) DraftEmail(ctx context.Context, to, subject, body, cc, bcc, replyTo string, attachments []Attachment) (any, error) {
+	if err := gs.checkAttachments(attachments); err != nil {
+		return nil, err
+	}
+	if err := validateRecipients(to, cc, bcc); err != nil {
+		return nil, err
+	}
+	if err := validateReplyTo(replyTo); err != nil {
+		return nil, err
+	}
+	raw := buildMIMEMessage(to, subject, body, cc, bcc, replyTo, "", attachments)
+	msg, media := newOutgoingMessage(raw, "")
+	call := gs.svc.Users.Drafts.Create("me", &gmail.Draft{Message: msg})
+	if media != nil {
+		call = call.Media(media, googleapi.ContentType("message/rfc822"))
 	}
 
-	created, err := gs.svc.Users.Drafts.Create("me", draft).Do()
+	created, err := call.Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("create draft: %w", err)
 	}
@@ -357,27 +779,267 @@ func (gs *GmailService) DraftEmail(to, subject, body, cc, bcc string, attachment
 	}, nil
 }
 
-// ModifyEmail adds or removes labels on an email.
-func (gs *GmailService) ModifyEmail(messageID, addLabels, removeLabels string) (*emailJSON, error) {
-	req := &gmail.ModifyMessageRequest{}
-	if addLabels != "" {
-		for _, l := range strings.Split(addLabels, ",") {
-			l = strings.TrimSpace(l)
-			if l != "" {
-				req.AddLabelIds = append(req.AddLabelIds, l)
+// ListDrafts returns the user's drafts, newest first, optionally filtered by
+// a Gmail search query.
+func (gs *GmailService) ListDrafts(ctx context.Context, query string, maxResults int64) ([]draftJSON, error) {
+	if maxResults <= 0 {
+		maxResults = 20
+	}
+	maxResults, _ = clampMaxResults(maxResults, gs.opts.MaxResultsCeiling)
+
+	call := gs.svc.Users.Drafts.List("me").MaxResults(maxResults)
+	if query != "" {
+		call = call.Q(query)
+	}
+	list, err := call.Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("list drafts: %w", err)
+	}
+
+	results := make([]draftJSON, 0, len(list.Drafts))
+	for _, d := range list.Drafts {
+		draft, err := gs.svc.Users.Drafts.Get("me", d.Id).Format("metadata").Context(ctx).Do()
+		if err != nil {
+			continue
+		}
+		result := draftJSON{ID: draft.Id}
+		if msg := draft.Message; msg != nil {
+			result.MessageID = msg.Id
+			result.ThreadID = msg.ThreadId
+			result.Snippet = html.UnescapeString(msg.Snippet)
+			if msg.Payload != nil {
+				result.Subject = getHeader(msg.Payload.Headers, "Subject")
+				result.To = getHeader(msg.Payload.Headers, "To")
+				result.Cc = getHeader(msg.Payload.Headers, "Cc")
 			}
 		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// SendDraft sends an existing draft, which Gmail then deletes, and returns
+// the sent message's metadata.
+func (gs *GmailService) SendDraft(ctx context.Context, draftID string) (*emailJSON, error) {
+	if draftID == "" {
+		return nil, fmt.Errorf("draft_id is required")
+	}
+	sent, err := gs.svc.Users.Drafts.Send("me", &gmail.Draft{Id: draftID}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("send draft: %w", err)
+	}
+	return gs.sentEmail(ctx, sent), nil
+}
+
+// ReplyEmail replies to a message in its thread. The recipients come from the
+// original's Reply-To or From and, with replyAll, its To and Cc minus the
+// user's own address.
+func (gs *GmailService) ReplyEmail(ctx context.Context, messageID, body string, replyAll bool) (*emailJSON, error) {
+	if messageID == "" {
+		return nil, fmt.Errorf("message_id is required")
+	}
+	original, err := gs.svc.Users.Messages.Get("me", messageID).Format("metadata").
+		MetadataHeaders("From", "Reply-To", "To", "Cc", "Subject", "Message-ID").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("get email: %w", err)
+	}
+	var headers []*gmail.MessagePartHeader
+	if original.Payload != nil {
+		headers = original.Payload.Headers
+	}
+	profile, err := gs.svc.Users.GetProfile("me").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("get profile: %w", err)
+	}
+
+	to, cc := replyRecipients(profile.EmailAddress,
+		getHeader(headers, "From"), getHeader(headers, "Reply-To"),
+		getHeader(headers, "To"), getHeader(headers, "Cc"), replyAll)
+	subject := getHeader(headers, "Subject")
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+	return gs.SendEmail(ctx, to, subject, body, cc, "", "", original.ThreadId, getHeader(headers, "Message-ID"), nil)
+}
+
+// replyRecipients derives the To and Cc of a reply from the original headers.
+// A reply to the user's own message goes to its original recipients, as in
+// Gmail. self is never included unless it is the only recipient.
+func replyRecipients(self, from, replyTo, origTo, origCc string, replyAll bool) (to, cc string) {
+	isSelf := func(addr string) bool {
+		a, err := mail.ParseAddress(addr)
+		return err == nil && strings.EqualFold(a.Address, self)
+	}
+	if isSelf(from) {
+		if !replyAll {
+			return origTo, ""
+		}
+		return origTo, excludeAddresses([]string{origCc}, self, origTo)
 	}
-	if removeLabels != "" {
-		for _, l := range strings.Split(removeLabels, ",") {
-			l = strings.TrimSpace(l)
-			if l != "" {
-				req.RemoveLabelIds = append(req.RemoveLabelIds, l)
+	to = from
+	if replyTo != "" {
+		to = replyTo
+	}
+	if !replyAll {
+		return to, ""
+	}
+	return to, excludeAddresses([]string{origTo, origCc}, self, to)
+}
+
+// excludeAddresses returns the addresses in lists, deduplicated and without
+// those in exclude, as a comma-separated list. Entries that do not parse are
+// dropped.
+func excludeAddresses(lists []string, exclude ...string) string {
+	skip := make(map[string]bool)
+	for _, e := range exclude {
+		for _, a := range parseAddresses(e) {
+			skip[strings.ToLower(a.Address)] = true
+		}
+	}
+	var kept []string
+	for _, list := range lists {
+		for _, a := range parseAddresses(list) {
+			key := strings.ToLower(a.Address)
+			if skip[key] {
+				continue
 			}
+			skip[key] = true
+			kept = append(kept, a.String())
 		}
 	}
+	return strings.Join(kept, ", ")
+}
 
-	msg, err := gs.svc.Users.Messages.Modify("me", messageID, req).Do()
+// parseAddresses parses an address list, falling back to its comma-separated
+// entries one by one so a single malformed entry does not lose the rest.
+func parseAddresses(list string) []*mail.Address {
+	if strings.TrimSpace(list) == "" {
+		return nil
+	}
+	if addrs, err := mail.ParseAddressList(list); err == nil {
+		return addrs
+	}
+	var addrs []*mail.Address
+	for _, part := range strings.Split(list, ",") {
+		if a, err := mail.ParseAddress(part); err == nil {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// forwardedHeaders are the original headers quoted under the forwarded
+// message banner, in display order.
+var forwardedHeaders = []string{"From", "Date", "Subject", "To", "Cc"}
+
+// ForwardEmail forwards a message to new recipients with an optional note.
+// The original headers are quoted in the text part and the original MIME
+// entity, attachments included, follows it unchanged.
+func (gs *GmailService) ForwardEmail(ctx context.Context, messageID, to, body string) (*emailJSON, error) {
+	if messageID == "" {
+		return nil, fmt.Errorf("message_id is required")
+	}
+	if err := validateRecipients(to, "", ""); err != nil {
+		return nil, err
+	}
+	original, err := gs.svc.Users.Messages.Get("me", messageID).Format("raw").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("get email: %w", err)
+	}
+	decoded, err := decodeBase64URL(original.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("decode raw message: %w", err)
+	}
+	raw, err := buildForwardMessage(to, body, decoded)
+	if err != nil {
+		return nil, err
+	}
+	return gs.SendRawEmail(ctx, raw, "")
+}
+
+// buildForwardMessage assembles a message forwarding original, an RFC822
+// message, to the given recipients.
+func buildForwardMessage(to, body string, original []byte) ([]byte, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(original))
+	if err != nil {
+		return nil, fmt.Errorf("parse original message: %w", err)
+	}
+	content, err := io.ReadAll(m.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read original message: %w", err)
+	}
+
+	dec := new(mime.WordDecoder)
+	decodeHeader := func(name string) string {
+		v := m.Header.Get(name)
+		if d, err := dec.DecodeHeader(v); err == nil {
+			return d
+		}
+		return v
+	}
+	subject := decodeHeader("Subject")
+	if !strings.HasPrefix(strings.ToLower(subject), "fwd:") {
+		subject = "Fwd: " + subject
+	}
+
+	var buf strings.Builder
+	boundary := generateBoundary()
+	buf.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	buf.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject)))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=%q\r\n", boundary))
+	buf.WriteString("\r\n")
+
+	// Note and quoted headers
+	buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	if body != "" {
+		buf.WriteString(body)
+		buf.WriteString("\r\n\r\n")
+	}
+	buf.WriteString("---------- Forwarded message ----------\r\n")
+	for _, name := range forwardedHeaders {
+		if v := decodeHeader(name); v != "" {
+			buf.WriteString(fmt.Sprintf("%s: %s\r\n", name, v))
+		}
+	}
+	buf.WriteString("\r\n")
+
+	// Original content, with its own Content-* headers
+	buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+	names := make([]string, 0, len(m.Header))
+	for name := range m.Header {
+		if strings.HasPrefix(name, "Content-") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range m.Header[name] {
+			buf.WriteString(fmt.Sprintf("%s: %s\r\n", name, v))
+		}
+	}
+	buf.WriteString("\r\n")
+	buf.Write(content)
+	if !bytes.HasSuffix(content, []byte("\n")) {
+		buf.WriteString("\r\n")
+	}
+
+	// Closing boundary
+	buf.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
+
+	return []byte(buf.String()), nil
+}
+
+// ModifyEmail adds or removes labels on an email.
+func (gs *GmailService) ModifyEmail(ctx context.Context, messageID, addLabels, removeLabels string) (*emailJSON, error) {
+	req := &gmail.ModifyMessageRequest{
+		AddLabelIds:    splitLabelIDs(addLabels),
+		RemoveLabelIds: splitLabelIDs(removeLabels),
+	}
+
+	msg, err := gs.svc.Users.Messages.Modify("me", messageID, req).Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("modify email: %w", err)
 	}
@@ -388,30 +1050,344 @@ func (gs *GmailService) ModifyEmail(messageID, addLabels, removeLabels string) (
 	}, nil
 }
 
+// splitLabelIDs splits a comma-separated list of label IDs.
+func splitLabelIDs(list string) []string {
+	var ids []string
+	for _, l := range strings.Split(list, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			ids = append(ids, l)
+		}
+	}
+	return ids
+}
+
+// PermanentlyDelete deletes an email immediately, bypassing trash. It cannot
+// be undone and needs a token granted the full Gmail scope.
+func (gs *GmailService) PermanentlyDelete(ctx context.Context, messageID string) error {
+	if messageID == "" {
+		return fmt.Errorf("message_id is required")
+	}
+	if err := gs.svc.Users.Messages.Delete("me", messageID).Context(ctx).Do(); err != nil {
+		return gmailFullAccessHint(fmt.Errorf("delete email: %w", err))
+	}
+	return nil
+}
+
+// gmailFullAccessHint adds a hint to errors caused by a token granted only
+// the gmail.modify scope.
+func gmailFullAccessHint(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden && strings.Contains(strings.ToLower(apiErr.Message), "insufficient") {
+		return fmt.Errorf("%w\nPermanent deletion needs full Gmail access; restart with --gmail-full-access and re-authenticate.", err)
+	}
+	return err
+}
+
+// ArchiveEmail removes an email from the inbox.
+func (gs *GmailService) ArchiveEmail(ctx context.Context, messageID string) (*emailJSON, error) {
+	if messageID == "" {
+		return nil, fmt.Errorf("message_id is required")
+	}
+	return gs.ModifyEmail(ctx, messageID, "", "INBOX")
+}
+
+// MarkRead marks an email as read, or as unread if read is false.
+func (gs *GmailService) MarkRead(ctx context.Context, messageID string, read bool) (*emailJSON, error) {
+	if messageID == "" {
+		return nil, fmt.Errorf("message_id is required")
+	}
+	if read {
+		return gs.ModifyEmail(ctx, messageID, "", "UNREAD")
+	}
+	return gs.ModifyEmail(ctx, messageID, "UNREAD", "")
+}
+
 // DeleteEmail moves an email to trash.
-func (gs *GmailService) DeleteEmail(messageID string) error {
-	_, err := gs.svc.Users.Messages.Trash("me", messageID).Do()
+func (gs *GmailService) DeleteEmail(ctx context.Context, messageID string) error {
+	_, err := gs.TrashEmail(ctx, messageID)
+	return err
+}
+
+// TrashEmail moves an email to trash and returns its resulting labels.
+func (gs *GmailService) TrashEmail(ctx context.Context, messageID string) (*emailJSON, error) {
+	if messageID == "" {
+		return nil, fmt.Errorf("message_id is required")
+	}
+	msg, err := gs.svc.Users.Messages.Trash("me", messageID).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("trash email: %w", err)
+	}
+	return &emailJSON{ID: msg.Id, ThreadID: msg.ThreadId, Labels: msg.LabelIds}, nil
+}
+
+// UntrashEmail restores an email from trash.
+func (gs *GmailService) UntrashEmail(ctx context.Context, messageID string) (*emailJSON, error) {
+	if messageID == "" {
+		return nil, fmt.Errorf("message_id is required")
+	}
+	msg, err := gs.svc.Users.Messages.Untrash("me", messageID).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("untrash email: %w", err)
+	}
+	return &emailJSON{ID: msg.Id, ThreadID: msg.ThreadId, Labels: msg.LabelIds}, nil
+}
+
+// batchTrashMaxMessages is the hard upper bound on messages trashed by a single
+// batch-delete-emails call, regardless of the requested max_messages.
+const batchTrashMaxMessages = 500
+
+// listMessageIDs returns up to limit message IDs matching a Gmail query.
+// The second return value reports whether more messages matched than were returned.
+func (gs *GmailService) listMessageIDs(ctx context.Context, query string, limit int64) ([]string, bool, error) {
+	var ids []string
+	pageToken := ""
+	for {
+		call := gs.svc.Users.Messages.List("me").Q(query).MaxResults(min(limit-int64(len(ids)), 500))
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		list, err := call.Context(ctx).Do()
+		if err != nil {
+			return nil, false, fmt.Errorf("list messages: %w", err)
+		}
+		for _, m := range list.Messages {
+			ids = append(ids, m.Id)
+		}
+		if list.NextPageToken == "" {
+			return ids, false, nil
+		}
+		if int64(len(ids)) >= limit {
+			return ids, true, nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+// BatchTrashEmails moves every message matching query (up to maxMessages) to trash.
+func (gs *GmailService) BatchTrashEmails(ctx context.Context, query string, maxMessages int64) (map[string]any, error) {
+	if query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	if maxMessages <= 0 || maxMessages > batchTrashMaxMessages {
+		maxMessages = batchTrashMaxMessages
+	}
+
+	ids, truncated, err := gs.listMessageIDs(ctx, query, maxMessages)
 	if err != nil {
-		return fmt.Errorf("trash email: %w", err)
+		return nil, err
+	}
+	if len(ids) > 0 {
+		req := &gmail.BatchModifyMessagesRequest{
+			Ids:            ids,
+			AddLabelIds:    []string{"TRASH"},
+			RemoveLabelIds: []string{"INBOX"},
+		}
+		if err := gs.svc.Users.Messages.BatchModify("me", req).Context(ctx).Do(); err != nil {
+			return nil, fmt.Errorf("batch trash emails: %w", err)
+		}
+	}
+	return map[string]any{
+		"status":    "trashed",
+		"count":     len(ids),
+		"truncated": truncated,
+	}, nil
+}
+
+// batchModifyConfirmThreshold is how many messages batch-modify-emails may
+// relabel without confirm=true.
+const batchModifyConfirmThreshold = 50
+
+// batchModifyMaxMessages caps one batch-modify-emails call. It is also the
+// most IDs Gmail's batchModify accepts at once.
+const batchModifyMaxMessages = 1000
+
+// BatchModify adds and removes labels on every message matching query. Unless
+// confirm is set it refuses when more than batchModifyConfirmThreshold
+// messages match, so a loose query cannot relabel the whole mailbox.
+func (gs *GmailService) BatchModify(ctx context.Context, query, addLabels, removeLabels string, confirm bool) (map[string]any, error) {
+	if query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	add, remove := splitLabelIDs(addLabels), splitLabelIDs(removeLabels)
+	if len(add) == 0 && len(remove) == 0 {
+		return nil, fmt.Errorf("at least one of add_labels or remove_labels is required")
+	}
+
+	limit := int64(batchModifyMaxMessages)
+	if !confirm {
+		limit = batchModifyConfirmThreshold
+	}
+	ids, truncated, err := gs.listMessageIDs(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	if truncated && !confirm {
+		return nil, fmt.Errorf("more than %d emails match %q; check the query with search-emails, then retry with confirm=true", batchModifyConfirmThreshold, query)
+	}
+	if len(ids) > 0 {
+		req := &gmail.BatchModifyMessagesRequest{
+			Ids:            ids,
+			AddLabelIds:    add,
+			RemoveLabelIds: remove,
+		}
+		if err := gs.svc.Users.Messages.BatchModify("me", req).Context(ctx).Do(); err != nil {
+			return nil, fmt.Errorf("batch modify emails: %w", err)
+		}
+	}
+	return map[string]any{
+		"status":    "modified",
+		"count":     len(ids),
+		"truncated": truncated,
+	}, nil
+}
+
+// snoozeLabelName is the user label applied to emails snoozed by snooze-email.
+const snoozeLabelName = "mcp-gcal/Snoozed"
+
+// snoozeLabelID returns the ID of the managed snooze label, creating it if absent.
+func (gs *GmailService) snoozeLabelID(ctx context.Context) (string, error) {
+	list, err := gs.svc.Users.Labels.List("me").Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("list labels: %w", err)
+	}
+	for _, l := range list.Labels {
+		if l.Name == snoozeLabelName {
+			return l.Id, nil
+		}
+	}
+	created, err := gs.svc.Users.Labels.Create("me", &gmail.Label{
+		Name:                  snoozeLabelName,
+		LabelListVisibility:   "labelShow",
+		MessageListVisibility: "show",
+	}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("create snooze label: %w", err)
+	}
+	return created.Id, nil
+}
+
+// SnoozeEmail moves an email out of the inbox and under the snooze label.
+// It returns the snooze label ID, needed to wake the email later.
+func (gs *GmailService) SnoozeEmail(ctx context.Context, messageID string) (string, error) {
+	labelID, err := gs.snoozeLabelID(ctx)
+	if err != nil {
+		return "", err
+	}
+	_, err = gs.svc.Users.Messages.Modify("me", messageID, &gmail.ModifyMessageRequest{
+		AddLabelIds:    []string{labelID},
+		RemoveLabelIds: []string{"INBOX"},
+	}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("snooze email: %w", err)
+	}
+	return labelID, nil
+}
+
+// UnsnoozeEmail returns a snoozed email to the inbox and removes the snooze label.
+func (gs *GmailService) UnsnoozeEmail(ctx context.Context, messageID, labelID string) error {
+	_, err := gs.svc.Users.Messages.Modify("me", messageID, &gmail.ModifyMessageRequest{
+		AddLabelIds:    []string{"INBOX"},
+		RemoveLabelIds: []string{labelID},
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("unsnooze email: %w", err)
 	}
 	return nil
 }
 
 // ListLabels returns all Gmail labels.
-func (gs *GmailService) ListLabels() ([]labelJSON, error) {
-	list, err := gs.svc.Users.Labels.List("me").Do()
+func (gs *GmailService) ListLabels(ctx context.Context) ([]labelJSON, error) {
+	list, err := gs.svc.Users.Labels.List("me").Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("list labels: %w", err)
 	}
 	result := make([]labelJSON, 0, len(list.Labels))
 	for _, l := range list.Labels {
-		result = append(result, labelJSON{
-			ID:             l.Id,
-			Name:           l.Name,
-			Type:           l.Type,
-			MessagesTotal:  l.MessagesTotal,
-			MessagesUnread: l.MessagesUnread,
-		})
+		result = append(result, convertLabel(l))
 	}
 	return result, nil
 }
+
+func convertLabel(l *gmail.Label) labelJSON {
+	return labelJSON{
+		ID:                    l.Id,
+		Name:                  l.Name,
+		Type:                  l.Type,
+		MessagesTotal:         l.MessagesTotal,
+		MessagesUnread:        l.MessagesUnread,
+		LabelListVisibility:   l.LabelListVisibility,
+		MessageListVisibility: l.MessageListVisibility,
+	}
+}
+
+// validateLabelVisibility checks the visibility values Gmail accepts. Empty
+// values are left for Gmail to default or keep.
+func validateLabelVisibility(labelListVisibility, messageListVisibility string) error {
+	switch labelListVisibility {
+	case "", "labelShow", "labelShowIfUnread", "labelHide":
+	default:
+		return fmt.Errorf("label_list_visibility must be labelShow, labelShowIfUnread or labelHide, got %q", labelListVisibility)
+	}
+	switch messageListVisibility {
+	case "", "show", "hide":
+	default:
+		return fmt.Errorf("message_list_visibility must be show or hide, got %q", messageListVisibility)
+	}
+	return nil
+}
+
+// CreateLabel creates a user label.
+func (gs *GmailService) CreateLabel(ctx context.Context, name, labelListVisibility, messageListVisibility string) (*labelJSON, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if err := validateLabelVisibility(labelListVisibility, messageListVisibility); err != nil {
+		return nil, err
+	}
+	created, err := gs.svc.Users.Labels.Create("me", &gmail.Label{
+		Name:                  name,
+		LabelListVisibility:   labelListVisibility,
+		MessageListVisibility: messageListVisibility,
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("create label: %w", err)
+	}
+	label := convertLabel(created)
+	return &label, nil
+}
+
+// UpdateLabel renames a user label or changes its visibility. Empty values
+// leave the corresponding setting unchanged.
+func (gs *GmailService) UpdateLabel(ctx context.Context, labelID, name, labelListVisibility, messageListVisibility string) (*labelJSON, error) {
+	if labelID == "" {
+		return nil, fmt.Errorf("label_id is required")
+	}
+	if name == "" && labelListVisibility == "" && messageListVisibility == "" {
+		return nil, fmt.Errorf("at least one of name, label_list_visibility or message_list_visibility is required")
+	}
+	if err := validateLabelVisibility(labelListVisibility, messageListVisibility); err != nil {
+		return nil, err
+	}
+	updated, err := gs.svc.Users.Labels.Patch("me", labelID, &gmail.Label{
+		Name:                  name,
+		LabelListVisibility:   labelListVisibility,
+		MessageListVisibility: messageListVisibility,
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("update label: %w", err)
+	}
+	label := convertLabel(updated)
+	return &label, nil
+}
+
+// DeleteLabel permanently deletes a user label, removing it from the messages
+// it is applied to.
+func (gs *GmailService) DeleteLabel(ctx context.Context, labelID string) error {
+	if labelID == "" {
+		return fmt.Errorf("label_id is required")
+	}
+	if err := gs.svc.Users.Labels.Delete("me", labelID).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("delete label: %w", err)
+	}
+	return nil
+}
@@ -1,13 +1,40 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"reflect"
 	"strings"
 	"testing"
 
 	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
 )
 
+// newTestGmailService returns a GmailService whose API calls are served by handler.
+func newTestGmailService(t *testing.T, handler http.Handler) *GmailService {
+	t.Helper()
+	fake := httptest.NewServer(handler)
+	t.Cleanup(fake.Close)
+	svc, err := gmail.NewService(context.Background(),
+		option.WithHTTPClient(fake.Client()),
+		option.WithEndpoint(fake.URL+"/"),
+	)
+	if err != nil {
+		t.Fatalf("gmail.NewService() error = %v", err)
+	}
+	return &GmailService{svc: svc}
+}
+
 func TestGetHeader(t *testing.T) {
 	t.Parallel()
 
@@ -344,6 +371,48 @@ func TestConvertMessage_HTMLFallback(t *testing.T) {
 	}
 }
 
+func TestConvertRawMessage(t *testing.T) {
+	t.Parallel()
+
+	source := "From: alice@example.com\r\nTo: bob@example.com\r\nCc: carol@example.com\r\n" +
+		"Subject: =?utf-8?q?Caf=C3=A9?=\r\nDate: Mon, 1 Jan 2024 10:00:00 +0000\r\n\r\nHello"
+	msg := &gmail.Message{
+		Id:       "msg1",
+		ThreadId: "thread1",
+		Raw:      base64.URLEncoding.EncodeToString([]byte(source)),
+	}
+
+	email, err := convertRawMessage(msg)
+	if err != nil {
+		t.Fatalf("convertRawMessage() error = %v", err)
+	}
+	if email.Raw != source {
+		t.Fatalf("Raw = %q, want %q", email.Raw, source)
+	}
+	if email.Subject != "Café" {
+		t.Fatalf("Subject = %q, want %q", email.Subject, "Café")
+	}
+	if email.From != "alice@example.com" || email.To != "bob@example.com" || email.Cc != "carol@example.com" {
+		t.Fatalf("unexpected headers: from=%q to=%q cc=%q", email.From, email.To, email.Cc)
+	}
+	if email.Body != "" {
+		t.Fatalf("Body = %q, want empty for raw format", email.Body)
+	}
+}
+
+func TestConvertRawMessage_Unpadded(t *testing.T) {
+	t.Parallel()
+
+	msg := &gmail.Message{Id: "msg1", Raw: b64("Subject: x\r\n\r\nbody")}
+	email, err := convertRawMessage(msg)
+	if err != nil {
+		t.Fatalf("convertRawMessage() error = %v", err)
+	}
+	if email.Subject != "x" {
+		t.Fatalf("Subject = %q, want %q", email.Subject, "x")
+	}
+}
+
 func TestValidateAttachments_Valid(t *testing.T) {
 	t.Parallel()
 	err := validateAttachments([]Attachment{
@@ -444,7 +513,7 @@ func TestValidateAttachments_SecondItemInvalid(t *testing.T) {
 func TestBuildRawEmail(t *testing.T) {
 	t.Parallel()
 
-	raw := buildRawEmail("to@example.com", "Test Subject", "Hello body", "", "", "", nil)
+	raw := buildRawEmail("to@example.com", "Test Subject", "Hello body", "", "", "", "", nil)
 	decoded, err := base64.RawURLEncoding.DecodeString(raw)
 	if err != nil {
 		t.Fatalf("decode raw email: %v", err)
@@ -476,7 +545,7 @@ func TestBuildRawEmail(t *testing.T) {
 func TestBuildRawEmail_WithCcBcc(t *testing.T) {
 	t.Parallel()
 
-	raw := buildRawEmail("to@example.com", "Subject", "Body", "cc@example.com", "bcc@example.com", "", nil)
+	raw := buildRawEmail("to@example.com", "Subject", "Body", "cc@example.com", "bcc@example.com", "", "", nil)
 	decoded, err := base64.RawURLEncoding.DecodeString(raw)
 	if err != nil {
 		t.Fatalf("decode raw email: %v", err)
@@ -491,10 +560,46 @@ func TestBuildRawEmail_WithCcBcc(t *testing.T) {
 	}
 }
 
+func TestBuildRawEmail_WithReplyTo(t *testing.T) {
+	t.Parallel()
+
+	raw := buildRawEmail("to@example.com", "Subject", "Body", "", "", "team@example.com", "", nil)
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		t.Fatalf("decode raw email: %v", err)
+	}
+	s := string(decoded)
+
+	if !contains(s, "Reply-To: team@example.com\r\n") {
+		t.Fatalf("missing Reply-To header in: %s", s)
+	}
+}
+
+func TestValidateReplyTo(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		replyTo string
+		wantErr bool
+	}{
+		{"", false},
+		{"team@example.com", false},
+		{"Support Team <team@example.com>", false},
+		{"not-an-address", true},
+		{"a@example.com, b@example.com", true},
+	}
+	for _, tt := range tests {
+		err := validateReplyTo(tt.replyTo)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("validateReplyTo(%q) error = %v, wantErr %v", tt.replyTo, err, tt.wantErr)
+		}
+	}
+}
+
 func TestBuildRawEmail_WithInReplyTo(t *testing.T) {
 	t.Parallel()
 
-	raw := buildRawEmail("to@example.com", "Re: Subject", "Reply body", "", "", "<msg-id@example.com>", nil)
+	raw := buildRawEmail("to@example.com", "Re: Subject", "Reply body", "", "", "", "<msg-id@example.com>", nil)
 	decoded, err := base64.RawURLEncoding.DecodeString(raw)
 	if err != nil {
 		t.Fatalf("decode raw email: %v", err)
@@ -512,7 +617,7 @@ func TestBuildRawEmail_WithInReplyTo(t *testing.T) {
 func TestBuildRawEmail_UTF8Subject(t *testing.T) {
 	t.Parallel()
 
-	raw := buildRawEmail("to@example.com", "日本語の件名", "本文", "", "", "", nil)
+	raw := buildRawEmail("to@example.com", "日本語の件名", "本文", "", "", "", "", nil)
 	decoded, err := base64.RawURLEncoding.DecodeString(raw)
 	if err != nil {
 		t.Fatalf("decode raw email: %v", err)
@@ -540,7 +645,7 @@ func TestBuildRawEmail_WithAttachments(t *testing.T) {
 		},
 	}
 
-	raw := buildRawEmail("to@example.com", "With Attachment", "See attached.", "", "", "", attachments)
+	raw := buildRawEmail("to@example.com", "With Attachment", "See attached.", "", "", "", "", attachments)
 	decoded, err := base64.RawURLEncoding.DecodeString(raw)
 	if err != nil {
 		t.Fatalf("decode raw email: %v", err)
@@ -589,7 +694,7 @@ func TestBuildRawEmail_MultipleAttachments(t *testing.T) {
 		},
 	}
 
-	raw := buildRawEmail("to@example.com", "Multi", "Body", "", "", "", attachments)
+	raw := buildRawEmail("to@example.com", "Multi", "Body", "", "", "", "", attachments)
 	decoded, err := base64.RawURLEncoding.DecodeString(raw)
 	if err != nil {
 		t.Fatalf("decode raw email: %v", err)
@@ -608,7 +713,7 @@ func TestBuildRawEmail_EmptyAttachments(t *testing.T) {
 	t.Parallel()
 
 	// Empty slice should produce simple email (no MIME multipart)
-	raw := buildRawEmail("to@example.com", "Simple", "Body", "", "", "", []Attachment{})
+	raw := buildRawEmail("to@example.com", "Simple", "Body", "", "", "", "", []Attachment{})
 	decoded, err := base64.RawURLEncoding.DecodeString(raw)
 	if err != nil {
 		t.Fatalf("decode raw email: %v", err)
@@ -675,3 +780,939 @@ func searchString(s, substr string) bool {
 	}
 	return false
 }
+
+func TestSendEmail_ReturnsCcAndBcc(t *testing.T) {
+	t.Parallel()
+
+	var requestedHeaders []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gmail/v1/users/me/messages/send", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&gmail.Message{Id: "m1", ThreadId: "t1"})
+	})
+	mux.HandleFunc("/gmail/v1/users/me/messages/m1", func(w http.ResponseWriter, r *http.Request) {
+		requestedHeaders = r.URL.Query()["metadataHeaders"]
+		json.NewEncoder(w).Encode(&gmail.Message{
+			Id:       "m1",
+			ThreadId: "t1",
+			Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+				{Name: "To", Value: "bob@example.com"},
+				{Name: "Cc", Value: "carol@example.com"},
+				{Name: "Bcc", Value: "dave@example.com"},
+			}},
+		})
+	})
+	gs := newTestGmailService(t, mux)
+
+	email, err := gs.SendEmail(context.Background(), "bob@example.com", "Hi", "body",
+		"carol@example.com", "dave@example.com", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("SendEmail() error = %v", err)
+	}
+	if email.Cc != "carol@example.com" || email.Bcc != "dave@example.com" {
+		t.Fatalf("Cc, Bcc = %q, %q, want carol@example.com, dave@example.com", email.Cc, email.Bcc)
+	}
+	if !contains(strings.Join(requestedHeaders, ","), "Cc") {
+		t.Fatalf("metadataHeaders = %v, want Cc included", requestedHeaders)
+	}
+}
+
+func TestValidateRecipients(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		to, cc, bcc string
+		wantErr     string
+	}{
+		{name: "plain", to: "a@example.com, b@example.com"},
+		{name: "display name", to: "Alice <a@example.com>", cc: `"Doe, John" <j@example.com>`},
+		{name: "malformed to", to: "a@example.com, not-an-address", wantErr: `invalid to address "not-an-address"`},
+		{name: "malformed bcc", to: "a@example.com", bcc: "Bob <bob@example", wantErr: `invalid bcc address "Bob <bob@example"`},
+		{name: "bcc only", bcc: "list@example.com"},
+		{name: "no recipients", to: " ", wantErr: "at least one recipient is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateRecipients(tt.to, tt.cc, tt.bcc)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateRecipients() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateRecipients() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDedupRecipients(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                    string
+		to, cc, bcc             string
+		wantTo, wantCc, wantBcc string
+		wantWarnings            int
+	}{
+		{
+			name: "no duplicates",
+			to:   "a@example.com", cc: "b@example.com", bcc: "c@example.com",
+			wantTo: "a@example.com", wantCc: "b@example.com", wantBcc: "c@example.com",
+		},
+		{
+			name:   "duplicate within To",
+			to:     "a@example.com, A@Example.com",
+			wantTo: "<a@example.com>", wantWarnings: 1,
+		},
+		{
+			name: "To wins over Cc and Bcc",
+			to:   "a@example.com", cc: "Alice <a@example.com>, b@example.com", bcc: "a@example.com",
+			wantTo: "a@example.com", wantCc: "<b@example.com>", wantBcc: "", wantWarnings: 2,
+		},
+		{
+			name: "Cc wins over Bcc",
+			to:   "a@example.com", cc: "b@example.com", bcc: "b@example.com, c@example.com",
+			wantTo: "a@example.com", wantCc: "b@example.com", wantBcc: "<c@example.com>", wantWarnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			to, cc, bcc, warnings := dedupRecipients(tt.to, tt.cc, tt.bcc)
+			if to != tt.wantTo || cc != tt.wantCc || bcc != tt.wantBcc {
+				t.Fatalf("dedupRecipients() = %q, %q, %q, want %q, %q, %q", to, cc, bcc, tt.wantTo, tt.wantCc, tt.wantBcc)
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Fatalf("warnings = %v, want %d", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestSendEmail_WarnsOnSelfSend(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("POST", "/gmail/v1/users/me/messages/send", &gmail.Message{Id: "m1", ThreadId: "t1"})
+	fake.respond("GET", "/gmail/v1/users/me/messages/m1", &gmail.Message{
+		Id: "m1",
+		Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+			{Name: "From", Value: "Me <me@example.com>"},
+		}},
+	})
+	gs := fake.gmailService()
+
+	email, err := gs.SendEmail(context.Background(), "bob@example.com", "Hi", "body",
+		"bob@example.com", "me@example.com", "", "", "", nil)
+	if err != nil {
+		t.Fatalf("SendEmail() error = %v", err)
+	}
+	if len(email.Warnings) != 2 || !contains(email.Warnings[1], "yourself") {
+		t.Fatalf("warnings = %v, want duplicate and self-send warnings", email.Warnings)
+	}
+
+	req, _ := fake.request("POST", "/gmail/v1/users/me/messages/send")
+	var msg gmail.Message
+	fake.decodeBody(req, &msg)
+	raw, _ := base64.RawURLEncoding.DecodeString(msg.Raw)
+	if contains(string(raw), "Cc:") {
+		t.Fatalf("raw message still has Cc header:\n%s", raw)
+	}
+}
+
+func TestDraftEmail_InvalidAttachmentFailsEarly(t *testing.T) {
+	t.Parallel()
+
+	// Any request reaching the fake API fails the test.
+	gs := newFakeGoogleAPI(t).gmailService()
+	gs.opts.MaxAttachments = 2
+
+	att := Attachment{Filename: "a.txt", MimeType: "text/plain", Data: "aGk="}
+	tests := []struct {
+		name        string
+		attachments []Attachment
+		wantErr     string
+	}{
+		{"missing filename", []Attachment{{MimeType: "text/plain", Data: "aGk="}}, "filename is required"},
+		{"too many", []Attachment{att, att, att}, "too many attachments: 3 (maximum 2)"},
+		{"too large", []Attachment{{Filename: "big.bin", MimeType: "application/octet-stream", Data: strings.Repeat("A", maxAttachmentsTotalBytes/3*4+8)}}, "over Gmail's"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := gs.DraftEmail(context.Background(), "a@example.com", "Hi", "body", "", "", "", tt.attachments)
+			if err == nil || !contains(err.Error(), tt.wantErr) {
+				t.Fatalf("DraftEmail() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSearchEmails_UnescapesSnippet(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/gmail/v1/users/me/messages", &gmail.ListMessagesResponse{
+		Messages: []*gmail.Message{{Id: "m1"}},
+	})
+	fake.respond("GET", "/gmail/v1/users/me/messages/m1", &gmail.Message{
+		Id:      "m1",
+		Snippet: "Q&amp;A &quot;today&quot; &lt;3pm&gt; &#39;ok&#39;",
+	})
+
+	emails, err := fake.gmailService().SearchEmails(context.Background(), "", 10)
+	if err != nil {
+		t.Fatalf("SearchEmails() error = %v", err)
+	}
+	if len(emails) != 1 {
+		t.Fatalf("SearchEmails() returned %d emails, want 1", len(emails))
+	}
+	if want := `Q&A "today" <3pm> 'ok'`; emails[0].Snippet != want {
+		t.Fatalf("snippet = %q, want %q", emails[0].Snippet, want)
+	}
+	if got := convertMessage(&gmail.Message{Snippet: "Tom &amp; Jerry"}).Snippet; got != "Tom & Jerry" {
+		t.Fatalf("convertMessage snippet = %q, want %q", got, "Tom & Jerry")
+	}
+}
+
+func TestReadEmail_MarkRead(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		labels     []string
+		markRead   bool
+		wantModify bool
+		wantLabels []string
+	}{
+		{"unread, mark read", []string{"INBOX", "UNREAD"}, true, true, []string{"INBOX"}},
+		{"unread, leave", []string{"INBOX", "UNREAD"}, false, false, []string{"INBOX", "UNREAD"}},
+		{"already read", []string{"INBOX"}, true, false, []string{"INBOX"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fake := newFakeGoogleAPI(t)
+			fake.respond("GET", "/gmail/v1/users/me/messages/m1", &gmail.Message{Id: "m1", ThreadId: "t1", LabelIds: tt.labels})
+			fake.respond("POST", "/gmail/v1/users/me/messages/m1/modify", &gmail.Message{Id: "m1", ThreadId: "t1", LabelIds: []string{"INBOX"}})
+
+			email, err := fake.gmailService().ReadEmail(context.Background(), "m1", "", tt.markRead)
+			if err != nil {
+				t.Fatalf("ReadEmail() error = %v", err)
+			}
+			if !reflect.DeepEqual(email.Labels, tt.wantLabels) {
+				t.Fatalf("labels = %v, want %v", email.Labels, tt.wantLabels)
+			}
+			req, modified := fake.request("POST", "/gmail/v1/users/me/messages/m1/modify")
+			if modified != tt.wantModify {
+				t.Fatalf("modify called = %v, want %v", modified, tt.wantModify)
+			}
+			if modified {
+				var modify gmail.ModifyMessageRequest
+				fake.decodeBody(req, &modify)
+				if len(modify.AddLabelIds) != 0 || !reflect.DeepEqual(modify.RemoveLabelIds, []string{"UNREAD"}) {
+					t.Fatalf("modify request = %+v, want UNREAD removed", modify)
+				}
+			}
+		})
+	}
+}
+
+func TestNewOutgoingMessage(t *testing.T) {
+	t.Parallel()
+
+	small := []byte("To: a@example.com\r\n\r\nhi")
+	msg, media := newOutgoingMessage(small, "t1")
+	if media != nil || msg.Raw == "" || msg.ThreadId != "t1" {
+		t.Fatalf("small message: Raw=%q media=%v, want inline raw", msg.Raw, media)
+	}
+
+	large := make([]byte, mediaUploadThreshold+1)
+	msg, media = newOutgoingMessage(large, "")
+	if media == nil || msg.Raw != "" {
+		t.Fatalf("large message: Raw len=%d media=%v, want media upload", len(msg.Raw), media)
+	}
+}
+
+func TestSendEmail_LargeMessageUsesMediaUpload(t *testing.T) {
+	t.Parallel()
+
+	var sendPath, uploadType string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/messages/send") {
+			sendPath = r.URL.Path
+			uploadType = r.URL.Query().Get("uploadType")
+			json.NewEncoder(w).Encode(&gmail.Message{Id: "m1", ThreadId: "t1"})
+			return
+		}
+		json.NewEncoder(w).Encode(&gmail.Message{Id: "m1", ThreadId: "t1"})
+	})
+	gs := newTestGmailService(t, mux)
+
+	body := strings.Repeat("x", mediaUploadThreshold+1)
+	if _, err := gs.SendEmail(context.Background(), "bob@example.com", "Big", body, "", "", "", "", "", nil); err != nil {
+		t.Fatalf("SendEmail() error = %v", err)
+	}
+	if sendPath != "/upload/gmail/v1/users/me/messages/send" {
+		t.Fatalf("send path = %q, want media upload endpoint", sendPath)
+	}
+	if uploadType == "" {
+		t.Fatal("uploadType query parameter not set")
+	}
+}
+
+func TestSendEmail_ThreadRepliesToLatestMessage(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/gmail/v1/users/me/threads/t1", &gmail.Thread{
+		Id: "t1",
+		Messages: []*gmail.Message{
+			{Id: "m0", Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+				{Name: "Message-ID", Value: "<first@example.com>"},
+			}}},
+			{Id: "m1", Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+				{Name: "Message-Id", Value: "<latest@example.com>"},
+			}}},
+		},
+	})
+	fake.respond("POST", "/gmail/v1/users/me/messages/send", &gmail.Message{Id: "m2", ThreadId: "t1"})
+	fake.respond("GET", "/gmail/v1/users/me/messages/m2", &gmail.Message{Id: "m2", ThreadId: "t1"})
+	gs := fake.gmailService()
+
+	if _, err := gs.SendEmail(context.Background(), "bob@example.com", "Re: Hi", "body", "", "", "", "t1", "", nil); err != nil {
+		t.Fatalf("SendEmail() error = %v", err)
+	}
+
+	req, _ := fake.request("POST", "/gmail/v1/users/me/messages/send")
+	var msg gmail.Message
+	fake.decodeBody(req, &msg)
+	if msg.ThreadId != "t1" {
+		t.Fatalf("threadId = %q, want t1", msg.ThreadId)
+	}
+	raw, _ := base64.RawURLEncoding.DecodeString(msg.Raw)
+	for _, want := range []string{"In-Reply-To: <latest@example.com>\r\n", "References: <latest@example.com>\r\n"} {
+		if !contains(string(raw), want) {
+			t.Fatalf("raw message missing %q:\n%s", want, raw)
+		}
+	}
+}
+
+func TestSendScheduledEmail_ThreadsAtSendTime(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/gmail/v1/users/me/threads/t1", &gmail.Thread{
+		Id: "t1",
+		Messages: []*gmail.Message{
+			{Id: "m1", Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+				{Name: "Message-ID", Value: "<latest@example.com>"},
+			}}},
+		},
+	})
+	fake.respond("POST", "/gmail/v1/users/me/messages/send", &gmail.Message{Id: "m2", ThreadId: "t1"})
+	fake.respond("GET", "/gmail/v1/users/me/messages/m2", &gmail.Message{Id: "m2", ThreadId: "t1"})
+	gs := fake.gmailService()
+
+	raw := buildMIMEMessage("bob@example.com", "Re: Hi", "body", "", "", "", "", nil)
+	if _, err := gs.SendScheduledEmail(context.Background(), raw, "t1"); err != nil {
+		t.Fatalf("SendScheduledEmail() error = %v", err)
+	}
+
+	req, _ := fake.request("POST", "/gmail/v1/users/me/messages/send")
+	var msg gmail.Message
+	fake.decodeBody(req, &msg)
+	sent, _ := base64.RawURLEncoding.DecodeString(msg.Raw)
+	for _, want := range []string{"In-Reply-To: <latest@example.com>\r\n", "References: <latest@example.com>\r\n"} {
+		if !contains(string(sent), want) {
+			t.Fatalf("raw message missing %q:\n%s", want, sent)
+		}
+	}
+
+	// An explicit in_reply_to given at schedule time is kept as is.
+	explicit := newFakeGoogleAPI(t)
+	explicit.respond("POST", "/gmail/v1/users/me/messages/send", &gmail.Message{Id: "m2", ThreadId: "t1"})
+	explicit.respond("GET", "/gmail/v1/users/me/messages/m2", &gmail.Message{Id: "m2", ThreadId: "t1"})
+	raw = buildMIMEMessage("bob@example.com", "Re: Hi", "body", "", "", "", "<chosen@example.com>", nil)
+	if _, err := explicit.gmailService().SendScheduledEmail(context.Background(), raw, "t1"); err != nil {
+		t.Fatalf("SendScheduledEmail(in_reply_to) error = %v", err)
+	}
+	if _, ok := explicit.request("GET", "/gmail/v1/users/me/threads/t1"); ok {
+		t.Fatalf("SendScheduledEmail(in_reply_to) looked up the thread")
+	}
+}
+
+func TestBuildMIMEMessage_BccOnly(t *testing.T) {
+	t.Parallel()
+
+	raw := string(buildMIMEMessage("", "Announcement", "body", "", "list@example.com", "", "", nil))
+	if strings.HasPrefix(raw, "To:") || contains(raw, "\r\nTo:") {
+		t.Fatalf("Bcc-only message has a To header:\n%s", raw)
+	}
+	if !strings.HasPrefix(raw, "Bcc: list@example.com\r\n") {
+		t.Fatalf("Bcc-only message missing Bcc header:\n%s", raw)
+	}
+}
+
+func TestGetAttachment(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/gmail/v1/users/me/messages/m1/attachments/a1", &gmail.MessagePartBody{
+		AttachmentId: "a1",
+		Size:         11,
+		Data:         base64.RawURLEncoding.EncodeToString([]byte("id,name\n1,a")),
+	})
+	gs := fake.gmailService()
+
+	att, err := gs.GetAttachment(context.Background(), "m1", "a1")
+	if err != nil {
+		t.Fatalf("GetAttachment() error = %v", err)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(att.Data)
+	if err != nil || string(data) != "id,name\n1,a" || att.Size != 11 || att.MessageID != "m1" || att.AttachmentID != "a1" {
+		t.Fatalf("GetAttachment() = %+v (data %q, err %v)", att, data, err)
+	}
+
+	if _, err := gs.GetAttachment(context.Background(), "m1", ""); err == nil {
+		t.Fatal("GetAttachment(no attachment_id) error = nil")
+	}
+}
+
+func TestReadThread(t *testing.T) {
+	t.Parallel()
+
+	message := func(id, subject, body string) *gmail.Message {
+		return &gmail.Message{Id: id, ThreadId: "t1", Payload: &gmail.MessagePart{
+			MimeType: "text/plain",
+			Headers:  []*gmail.MessagePartHeader{{Name: "Subject", Value: subject}},
+			Body:     &gmail.MessagePartBody{Data: base64.RawURLEncoding.EncodeToString([]byte(body))},
+		}}
+	}
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/gmail/v1/users/me/threads/t1", &gmail.Thread{Id: "t1", Messages: []*gmail.Message{
+		message("m1", "Lunch?", "Noon?"),
+		message("m2", "Re: Lunch?", "Sure"),
+	}})
+
+	emails, err := fake.gmailService().ReadThread(context.Background(), "t1", true)
+	if err != nil {
+		t.Fatalf("ReadThread() error = %v", err)
+	}
+	if len(emails) != 2 || emails[0].ID != "m1" || emails[1].Subject != "Re: Lunch?" || emails[1].Body != "Sure" {
+		t.Fatalf("ReadThread() = %+v", emails)
+	}
+	req, _ := fake.request("GET", "/gmail/v1/users/me/threads/t1")
+	if req.Query.Get("format") != "full" {
+		t.Fatalf("threads.get query = %v, want format=full", req.Query)
+	}
+	// Nothing was unread, so mark_read leaves the thread alone.
+	if _, ok := fake.request("POST", "/gmail/v1/users/me/threads/t1/modify"); ok {
+		t.Fatalf("read thread was modified")
+	}
+}
+
+func TestReadThread_MarkRead(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/gmail/v1/users/me/threads/t1", &gmail.Thread{Id: "t1", Messages: []*gmail.Message{
+		{Id: "m1", ThreadId: "t1", LabelIds: []string{"INBOX"}},
+		{Id: "m2", ThreadId: "t1", LabelIds: []string{"INBOX", "UNREAD"}},
+	}})
+	fake.respond("POST", "/gmail/v1/users/me/threads/t1/modify", &gmail.Thread{Id: "t1", Messages: []*gmail.Message{
+		{Id: "m1", LabelIds: []string{"INBOX"}},
+		{Id: "m2", LabelIds: []string{"INBOX"}},
+	}})
+
+	emails, err := fake.gmailService().ReadThread(context.Background(), "t1", true)
+	if err != nil {
+		t.Fatalf("ReadThread() error = %v", err)
+	}
+	req, ok := fake.request("POST", "/gmail/v1/users/me/threads/t1/modify")
+	if !ok {
+		t.Fatalf("unread thread was not marked read")
+	}
+	var modify gmail.ModifyThreadRequest
+	fake.decodeBody(req, &modify)
+	if len(modify.AddLabelIds) != 0 || !reflect.DeepEqual(modify.RemoveLabelIds, []string{"UNREAD"}) {
+		t.Fatalf("modify request = %+v, want UNREAD removed", modify)
+	}
+	if len(emails) != 2 || !reflect.DeepEqual(emails[1].Labels, []string{"INBOX"}) {
+		t.Fatalf("ReadThread() = %+v, want updated labels", emails)
+	}
+}
+
+func TestListDraftsAndSendDraft(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/gmail/v1/users/me/drafts", &gmail.ListDraftsResponse{Drafts: []*gmail.Draft{
+		{Id: "d1", Message: &gmail.Message{Id: "m1"}},
+	}})
+	fake.respond("GET", "/gmail/v1/users/me/drafts/d1", &gmail.Draft{Id: "d1", Message: &gmail.Message{
+		Id: "m1", ThreadId: "t1", Snippet: "See you &amp; the team",
+		Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+			{Name: "Subject", Value: "Offsite"},
+			{Name: "To", Value: "team@example.com"},
+		}},
+	}})
+	fake.respond("POST", "/gmail/v1/users/me/drafts/send", &gmail.Message{Id: "m1", ThreadId: "t1"})
+	fake.respond("GET", "/gmail/v1/users/me/messages/m1", &gmail.Message{Id: "m1", ThreadId: "t1", LabelIds: []string{"SENT"},
+		Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{{Name: "Subject", Value: "Offsite"}}},
+	})
+
+	result, err := dispatchGmailTool(context.Background(), fake.gmailService(), "list-drafts", map[string]interface{}{
+		"query":       "subject:offsite",
+		"max_results": float64(5),
+	})
+	if err != nil {
+		t.Fatalf("dispatchGmailTool(list-drafts) error = %v", err)
+	}
+	drafts, ok := result.([]draftJSON)
+	if !ok || len(drafts) != 1 {
+		t.Fatalf("list-drafts result = %#v, want one draft", result)
+	}
+	want := draftJSON{ID: "d1", MessageID: "m1", ThreadID: "t1", Subject: "Offsite", To: "team@example.com", Snippet: "See you & the team"}
+	if drafts[0] != want {
+		t.Fatalf("draft = %+v, want %+v", drafts[0], want)
+	}
+	req, _ := fake.request("GET", "/gmail/v1/users/me/drafts")
+	if req.Query.Get("q") != "subject:offsite" || req.Query.Get("maxResults") != "5" {
+		t.Fatalf("drafts.list query = %v", req.Query)
+	}
+
+	result, err = dispatchGmailTool(context.Background(), fake.gmailService(), "send-draft", map[string]interface{}{"draft_id": "d1"})
+	if err != nil {
+		t.Fatalf("dispatchGmailTool(send-draft) error = %v", err)
+	}
+	if email, ok := result.(*emailJSON); !ok || email.ID != "m1" || email.Subject != "Offsite" {
+		t.Fatalf("send-draft result = %#v, want the sent message", result)
+	}
+	var sent gmail.Draft
+	req, _ = fake.request("POST", "/gmail/v1/users/me/drafts/send")
+	fake.decodeBody(req, &sent)
+	if sent.Id != "d1" {
+		t.Fatalf("drafts.send body id = %q, want d1", sent.Id)
+	}
+
+	if _, err := fake.gmailService().SendDraft(context.Background(), ""); err == nil {
+		t.Fatal("SendDraft(\"\") succeeded")
+	}
+}
+
+func TestForwardEmail(t *testing.T) {
+	t.Parallel()
+
+	original := "From: Alice <alice@example.com>\r\n" +
+		"To: me@example.com\r\n" +
+		"Date: Mon, 5 Oct 2026 09:00:00 +0900\r\n" +
+		"Subject: =?utf-8?q?Quarterly_report?=\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"orig\"\r\n" +
+		"\r\n" +
+		"--orig\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		"Numbers attached.\r\n" +
+		"--orig\r\n" +
+		"Content-Type: text/csv; name=\"q3.csv\"\r\n" +
+		"Content-Disposition: attachment; filename=\"q3.csv\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"YSxiCjEsMgo=\r\n" +
+		"--orig--\r\n"
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/gmail/v1/users/me/messages/orig1", &gmail.Message{
+		Id: "orig1", Raw: base64.URLEncoding.EncodeToString([]byte(original)),
+	})
+	fake.respond("POST", "/gmail/v1/users/me/messages/send", &gmail.Message{Id: "fwd1", ThreadId: "t2"})
+	fake.respond("GET", "/gmail/v1/users/me/messages/fwd1", &gmail.Message{Id: "fwd1", ThreadId: "t2",
+		Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{{Name: "Subject", Value: "Fwd: Quarterly report"}}},
+	})
+
+	result, err := dispatchGmailTool(context.Background(), fake.gmailService(), "forward-email", map[string]interface{}{
+		"message_id": "orig1",
+		"to":         "bob@example.com",
+		"body":       "FYI",
+	})
+	if err != nil {
+		t.Fatalf("dispatchGmailTool(forward-email) error = %v", err)
+	}
+	if email, ok := result.(*emailJSON); !ok || email.ID != "fwd1" {
+		t.Fatalf("forward-email result = %#v, want the sent message", result)
+	}
+	req, _ := fake.request("GET", "/gmail/v1/users/me/messages/orig1")
+	if req.Query.Get("format") != "raw" {
+		t.Fatalf("messages.get format = %q, want raw", req.Query.Get("format"))
+	}
+
+	var sent gmail.Message
+	req, _ = fake.request("POST", "/gmail/v1/users/me/messages/send")
+	fake.decodeBody(req, &sent)
+	raw, err := decodeBase64URL(sent.Raw)
+	if err != nil {
+		t.Fatalf("decode sent raw: %v", err)
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parse forwarded message: %v", err)
+	}
+	if got := m.Header.Get("To"); got != "bob@example.com" {
+		t.Errorf("To = %q, want bob@example.com", got)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject")); subject != "Fwd: Quarterly report" {
+		t.Errorf("Subject = %q, want Fwd: Quarterly report", subject)
+	}
+
+	_, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("parse Content-Type: %v", err)
+	}
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	note, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("read note part: %v", err)
+	}
+	noteText, _ := io.ReadAll(note)
+	for _, want := range []string{"FYI", "---------- Forwarded message ----------", "From: Alice <alice@example.com>", "Subject: Quarterly report", "Date: Mon, 5 Oct 2026 09:00:00 +0900"} {
+		if !strings.Contains(string(noteText), want) {
+			t.Errorf("note part = %q, want it to contain %q", noteText, want)
+		}
+	}
+
+	inner, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("read original part: %v", err)
+	}
+	_, innerParams, err := mime.ParseMediaType(inner.Header.Get("Content-Type"))
+	if err != nil || innerParams["boundary"] != "orig" {
+		t.Fatalf("original part Content-Type = %q, want the original multipart", inner.Header.Get("Content-Type"))
+	}
+	ir := multipart.NewReader(inner, "orig")
+	var filenames []string
+	for {
+		p, err := ir.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read original sub-part: %v", err)
+		}
+		if name := p.FileName(); name != "" {
+			filenames = append(filenames, name)
+		}
+	}
+	if len(filenames) != 1 || filenames[0] != "q3.csv" {
+		t.Errorf("forwarded attachments = %v, want [q3.csv]", filenames)
+	}
+
+	if _, err := fake.gmailService().ForwardEmail(context.Background(), "orig1", "not an address", ""); err == nil {
+		t.Fatal("ForwardEmail() with an invalid recipient succeeded")
+	}
+}
+
+func TestReplyRecipients(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                          string
+		from, replyTo, origTo, origCc string
+		replyAll                      bool
+		wantTo, wantCc                string
+	}{
+		{
+			name: "reply to sender", from: "Alice <alice@example.com>", origTo: "me@example.com, bob@example.com",
+			wantTo: "Alice <alice@example.com>",
+		},
+		{
+			name: "reply-to wins", from: "alice@example.com", replyTo: "list@example.com", origTo: "me@example.com",
+			wantTo: "list@example.com",
+		},
+		{
+			name: "reply all drops self and sender", from: "alice@example.com", origTo: "Me <ME@example.com>, bob@example.com",
+			origCc: "carol@example.com, alice@example.com", replyAll: true,
+			wantTo: "alice@example.com", wantCc: "<bob@example.com>, <carol@example.com>",
+		},
+		{
+			name: "own message goes to its recipients", from: "me@example.com", origTo: "bob@example.com",
+			origCc: "carol@example.com, me@example.com", replyAll: true,
+			wantTo: "bob@example.com", wantCc: "<carol@example.com>",
+		},
+	}
+	for _, tt := range tests {
+		to, cc := replyRecipients("me@example.com", tt.from, tt.replyTo, tt.origTo, tt.origCc, tt.replyAll)
+		if to != tt.wantTo || cc != tt.wantCc {
+			t.Errorf("%s: replyRecipients() = (%q, %q), want (%q, %q)", tt.name, to, cc, tt.wantTo, tt.wantCc)
+		}
+	}
+}
+
+func TestReplyEmail(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/gmail/v1/users/me/messages/orig1", &gmail.Message{Id: "orig1", ThreadId: "t1",
+		Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+			{Name: "From", Value: "alice@example.com"},
+			{Name: "To", Value: "me@example.com, bob@example.com"},
+			{Name: "Subject", Value: "Lunch"},
+			{Name: "Message-ID", Value: "<orig1@example.com>"},
+		}},
+	})
+	fake.respond("GET", "/gmail/v1/users/me/profile", &gmail.Profile{EmailAddress: "me@example.com"})
+	fake.respond("POST", "/gmail/v1/users/me/messages/send", &gmail.Message{Id: "r1", ThreadId: "t1"})
+	fake.respond("GET", "/gmail/v1/users/me/messages/r1", &gmail.Message{Id: "r1", ThreadId: "t1"})
+
+	result, err := dispatchGmailTool(context.Background(), fake.gmailService(), "reply-email", map[string]interface{}{
+		"message_id": "orig1",
+		"body":       "Sounds good",
+		"reply_all":  true,
+	})
+	if err != nil {
+		t.Fatalf("dispatchGmailTool(reply-email) error = %v", err)
+	}
+	if email, ok := result.(*emailJSON); !ok || email.ID != "r1" {
+		t.Fatalf("reply-email result = %#v, want the sent message", result)
+	}
+
+	var sent gmail.Message
+	req, _ := fake.request("POST", "/gmail/v1/users/me/messages/send")
+	fake.decodeBody(req, &sent)
+	if sent.ThreadId != "t1" {
+		t.Errorf("sent thread ID = %q, want t1", sent.ThreadId)
+	}
+	raw, err := decodeBase64URL(sent.Raw)
+	if err != nil {
+		t.Fatalf("decode sent raw: %v", err)
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parse reply: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	for header, want := range map[string]string{
+		"To":          "alice@example.com",
+		"Cc":          "<bob@example.com>",
+		"Subject":     "Re: Lunch",
+		"In-Reply-To": "<orig1@example.com>",
+		"References":  "<orig1@example.com>",
+	} {
+		got := m.Header.Get(header)
+		if header == "Subject" {
+			got = subject
+		}
+		if got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestLabelManagement(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("POST", "/gmail/v1/users/me/labels", &gmail.Label{Id: "Label_1", Name: "Projects/Alpha", Type: "user", LabelListVisibility: "labelShowIfUnread"})
+	fake.respond("PATCH", "/gmail/v1/users/me/labels/Label_1", &gmail.Label{Id: "Label_1", Name: "Projects/Beta", Type: "user", MessageListVisibility: "hide"})
+	fake.respond("DELETE", "/gmail/v1/users/me/labels/Label_1", nil)
+	gs := fake.gmailService()
+	ctx := context.Background()
+
+	result, err := dispatchGmailTool(ctx, gs, "create-label", map[string]interface{}{
+		"name":                  "Projects/Alpha",
+		"label_list_visibility": "labelShowIfUnread",
+	})
+	if err != nil {
+		t.Fatalf("dispatchGmailTool(create-label) error = %v", err)
+	}
+	if label, ok := result.(*labelJSON); !ok || label.ID != "Label_1" || label.LabelListVisibility != "labelShowIfUnread" {
+		t.Fatalf("create-label result = %#v", result)
+	}
+	var created gmail.Label
+	req, _ := fake.request("POST", "/gmail/v1/users/me/labels")
+	fake.decodeBody(req, &created)
+	if created.Name != "Projects/Alpha" || created.LabelListVisibility != "labelShowIfUnread" || created.MessageListVisibility != "" {
+		t.Fatalf("labels.create body = %+v", created)
+	}
+
+	result, err = dispatchGmailTool(ctx, gs, "update-label", map[string]interface{}{
+		"label_id":                "Label_1",
+		"name":                    "Projects/Beta",
+		"message_list_visibility": "hide",
+	})
+	if err != nil {
+		t.Fatalf("dispatchGmailTool(update-label) error = %v", err)
+	}
+	if label, ok := result.(*labelJSON); !ok || label.Name != "Projects/Beta" {
+		t.Fatalf("update-label result = %#v", result)
+	}
+	var patch map[string]any
+	req, _ = fake.request("PATCH", "/gmail/v1/users/me/labels/Label_1")
+	fake.decodeBody(req, &patch)
+	if _, ok := patch["labelListVisibility"]; ok || patch["name"] != "Projects/Beta" || patch["messageListVisibility"] != "hide" {
+		t.Fatalf("labels.patch body = %v, want only the changed fields", patch)
+	}
+
+	if _, err := dispatchGmailTool(ctx, gs, "delete-label", map[string]interface{}{"label_id": "Label_1"}); err != nil {
+		t.Fatalf("dispatchGmailTool(delete-label) error = %v", err)
+	}
+	if _, ok := fake.request("DELETE", "/gmail/v1/users/me/labels/Label_1"); !ok {
+		t.Fatal("labels.delete was not called")
+	}
+
+	if _, err := gs.CreateLabel(ctx, "X", "sometimes", ""); err == nil {
+		t.Fatal("CreateLabel() with an invalid visibility succeeded")
+	}
+	if _, err := gs.UpdateLabel(ctx, "Label_1", "", "", ""); err == nil {
+		t.Fatal("UpdateLabel() with nothing to change succeeded")
+	}
+}
+
+func TestBatchModify(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/gmail/v1/users/me/messages", &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "m1"}, {Id: "m2"}}})
+	fake.respond("POST", "/gmail/v1/users/me/messages/batchModify", nil)
+
+	result, err := dispatchGmailTool(context.Background(), fake.gmailService(), "batch-modify-emails", map[string]interface{}{
+		"query":         "from:newsletter@example.com",
+		"remove_labels": "UNREAD, INBOX",
+	})
+	if err != nil {
+		t.Fatalf("dispatchGmailTool(batch-modify-emails) error = %v", err)
+	}
+	if got := result.(map[string]any); got["count"] != 2 || got["truncated"] != false {
+		t.Fatalf("batch-modify-emails result = %v, want count 2", got)
+	}
+	var req gmail.BatchModifyMessagesRequest
+	r, _ := fake.request("POST", "/gmail/v1/users/me/messages/batchModify")
+	fake.decodeBody(r, &req)
+	if !reflect.DeepEqual(req.Ids, []string{"m1", "m2"}) || !reflect.DeepEqual(req.RemoveLabelIds, []string{"UNREAD", "INBOX"}) || len(req.AddLabelIds) != 0 {
+		t.Fatalf("batchModify body = %+v", req)
+	}
+	r, _ = fake.request("GET", "/gmail/v1/users/me/messages")
+	if r.Query.Get("maxResults") != "50" {
+		t.Fatalf("messages.list maxResults = %q, want the confirm threshold", r.Query.Get("maxResults"))
+	}
+
+	if _, err := fake.gmailService().BatchModify(context.Background(), "in:inbox", "", "", false); err == nil {
+		t.Fatal("BatchModify() with no labels succeeded")
+	}
+}
+
+func TestBatchModify_RequiresConfirm(t *testing.T) {
+	t.Parallel()
+
+	many := make([]*gmail.Message, batchModifyConfirmThreshold)
+	for i := range many {
+		many[i] = &gmail.Message{Id: fmt.Sprintf("m%d", i)}
+	}
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/gmail/v1/users/me/messages", &gmail.ListMessagesResponse{Messages: many, NextPageToken: "more"})
+
+	_, err := fake.gmailService().BatchModify(context.Background(), "in:inbox", "", "UNREAD", false)
+	if err == nil || !strings.Contains(err.Error(), "confirm=true") {
+		t.Fatalf("BatchModify() without confirm error = %v, want a confirm error", err)
+	}
+	if _, ok := fake.request("POST", "/gmail/v1/users/me/messages/batchModify"); ok {
+		t.Fatal("batchModify was called without confirm")
+	}
+}
+
+func TestConvenienceLabelTools(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		tool                string
+		wantAdd, wantRemove []string
+	}{
+		{tool: "archive-email", wantRemove: []string{"INBOX"}},
+		{tool: "mark-read", wantRemove: []string{"UNREAD"}},
+		{tool: "mark-unread", wantAdd: []string{"UNREAD"}},
+	}
+	for _, tt := range tests {
+		fake := newFakeGoogleAPI(t)
+		fake.respond("POST", "/gmail/v1/users/me/messages/m1/modify", &gmail.Message{Id: "m1", LabelIds: []string{"IMPORTANT"}})
+
+		result, err := dispatchGmailTool(context.Background(), fake.gmailService(), tt.tool, map[string]interface{}{"message_id": "m1"})
+		if err != nil {
+			t.Fatalf("dispatchGmailTool(%s) error = %v", tt.tool, err)
+		}
+		if email, ok := result.(*emailJSON); !ok || email.ID != "m1" {
+			t.Fatalf("%s result = %#v", tt.tool, result)
+		}
+		var req gmail.ModifyMessageRequest
+		r, _ := fake.request("POST", "/gmail/v1/users/me/messages/m1/modify")
+		fake.decodeBody(r, &req)
+		if !reflect.DeepEqual(req.AddLabelIds, tt.wantAdd) || !reflect.DeepEqual(req.RemoveLabelIds, tt.wantRemove) {
+			t.Errorf("%s modify body = %+v, want add %v remove %v", tt.tool, req, tt.wantAdd, tt.wantRemove)
+		}
+		if _, err := dispatchGmailTool(context.Background(), fake.gmailService(), tt.tool, map[string]interface{}{}); err == nil {
+			t.Errorf("dispatchGmailTool(%s) without message_id succeeded", tt.tool)
+		}
+	}
+}
+
+func TestTrashAndUntrashEmail(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("POST", "/gmail/v1/users/me/messages/m1/trash", &gmail.Message{Id: "m1", LabelIds: []string{"TRASH"}})
+	fake.respond("POST", "/gmail/v1/users/me/messages/m1/untrash", &gmail.Message{Id: "m1", LabelIds: []string{"INBOX"}})
+
+	result, err := dispatchGmailTool(context.Background(), fake.gmailService(), "trash-email", map[string]interface{}{"message_id": "m1"})
+	if err != nil {
+		t.Fatalf("dispatchGmailTool(trash-email) error = %v", err)
+	}
+	if email := result.(*emailJSON); !reflect.DeepEqual(email.Labels, []string{"TRASH"}) {
+		t.Fatalf("trash-email labels = %v, want [TRASH]", email.Labels)
+	}
+	result, err = dispatchGmailTool(context.Background(), fake.gmailService(), "untrash-email", map[string]interface{}{"message_id": "m1"})
+	if err != nil {
+		t.Fatalf("dispatchGmailTool(untrash-email) error = %v", err)
+	}
+	if email := result.(*emailJSON); !reflect.DeepEqual(email.Labels, []string{"INBOX"}) {
+		t.Fatalf("untrash-email labels = %v, want [INBOX]", email.Labels)
+	}
+}
+
+func TestPermanentlyDeleteEmail(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("DELETE", "/gmail/v1/users/me/messages/m1", nil)
+	fake.fail("DELETE", "/gmail/v1/users/me/messages/m2", http.StatusForbidden, "Request had insufficient authentication scopes.")
+	gs := fake.gmailService()
+
+	if _, err := dispatchGmailTool(context.Background(), gs, "permanently-delete-email", map[string]interface{}{"message_id": "m1"}); err == nil {
+		t.Fatal("permanently-delete-email without confirm succeeded")
+	}
+	if _, ok := fake.request("DELETE", "/gmail/v1/users/me/messages/m1"); ok {
+		t.Fatal("messages.delete was called without confirm")
+	}
+
+	result, err := dispatchGmailTool(context.Background(), gs, "permanently-delete-email", map[string]interface{}{"message_id": "m1", "confirm": true})
+	if err != nil {
+		t.Fatalf("dispatchGmailTool(permanently-delete-email) error = %v", err)
+	}
+	if got := result.(map[string]string); got["status"] != "deleted" {
+		t.Fatalf("permanently-delete-email result = %v", got)
+	}
+	if _, ok := fake.request("DELETE", "/gmail/v1/users/me/messages/m1"); !ok {
+		t.Fatal("messages.delete was not called")
+	}
+
+	err = gs.PermanentlyDelete(context.Background(), "m2")
+	if err == nil || !strings.Contains(err.Error(), "--gmail-full-access") {
+		t.Fatalf("PermanentlyDelete() error = %v, want full access hint", err)
+	}
+}
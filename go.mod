@@ -4,6 +4,7 @@ go 1.23.0
 
 require (
 	golang.org/x/oauth2 v0.25.0
+	golang.org/x/text v0.21.0
 	google.golang.org/api v0.214.0
 	modernc.org/sqlite v1.34.5
 )
@@ -30,7 +31,6 @@ require (
 	golang.org/x/crypto v0.31.0 // indirect
 	golang.org/x/net v0.33.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
-	golang.org/x/text v0.21.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20241209162323-e6fa225c2576 // indirect
 	google.golang.org/grpc v1.67.1 // indirect
 	google.golang.org/protobuf v1.35.2 // indirect
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// fakeGoogleAPI is an http.Handler standing in for the Calendar, Gmail and
+// Tasks REST APIs. Tests register canned responses per method and path, and
+// inspect the requests the services made.
+type fakeGoogleAPI struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	routes   map[string]func(r *http.Request, body []byte) any
+	requests []fakeRequest
+}
+
+// fakeRequest is a request received by fakeGoogleAPI.
+type fakeRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Body   []byte
+}
+
+func newFakeGoogleAPI(t *testing.T) *fakeGoogleAPI {
+	t.Helper()
+	return &fakeGoogleAPI{
+		t:      t,
+		routes: make(map[string]func(r *http.Request, body []byte) any),
+	}
+}
+
+// handle registers fn for method and path (e.g. "GET", "/calendars/primary/events").
+// fn's return value is written as the JSON response; nil means 204 No Content.
+func (f *fakeGoogleAPI) handle(method, path string, fn func(r *http.Request, body []byte) any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.routes[method+" "+path] = fn
+}
+
+// respond registers a fixed JSON response for method and path.
+func (f *fakeGoogleAPI) respond(method, path string, resp any) {
+	f.handle(method, path, func(*http.Request, []byte) any { return resp })
+}
+
+// fakeAPIError is a handler result that is written as a Google API error.
+type fakeAPIError struct {
+	Code    int
+	Message string
+}
+
+// fail registers a Google API error response for method and path.
+func (f *fakeGoogleAPI) fail(method, path string, code int, message string) {
+	f.respond(method, path, fakeAPIError{Code: code, Message: message})
+}
+
+func (f *fakeGoogleAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, fakeRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Body: body})
+	fn, ok := f.routes[r.Method+" "+r.URL.Path]
+	f.mu.Unlock()
+
+	if !ok {
+		f.t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"code":404,"message":"not found"}}`))
+		return
+	}
+
+	resp := fn(r, body)
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if apiErr, ok := resp.(fakeAPIError); ok {
+		w.WriteHeader(apiErr.Code)
+		resp = map[string]any{"error": map[string]any{"code": apiErr.Code, "message": apiErr.Message}}
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// request returns the last request received for method and path.
+func (f *fakeGoogleAPI) request(method, path string) (fakeRequest, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.requests) - 1; i >= 0; i-- {
+		if req := f.requests[i]; req.Method == method && req.Path == path {
+			return req, true
+		}
+	}
+	return fakeRequest{}, false
+}
+
+// decodeBody decodes a request body into v, failing the test on error.
+func (f *fakeGoogleAPI) decodeBody(req fakeRequest, v any) {
+	f.t.Helper()
+	if err := json.Unmarshal(req.Body, v); err != nil {
+		f.t.Fatalf("decode %s %s body: %v", req.Method, req.Path, err)
+	}
+}
+
+func (f *fakeGoogleAPI) calendarService() *CalendarService {
+	return newTestCalendarService(f.t, f)
+}
+
+func (f *fakeGoogleAPI) gmailService() *GmailService {
+	return newTestGmailService(f.t, f)
+}
+
+func (f *fakeGoogleAPI) tasksService() *TasksService {
+	return newTestTasksService(f.t, f)
+}
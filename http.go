@@ -5,14 +5,17 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
+	"io"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/oauth2"
 )
@@ -20,19 +23,33 @@ import (
 // HTTPServer serves MCP over HTTP with per-user Google OAuth authentication.
 type HTTPServer struct {
 	database        *DB
+	opts            Options
 	credentialsFile string
 	addr            string
 	baseURL         string
 	oauthConfig     *oauth2.Config
+	// fallbackOAuthConfig refreshes tokens issued to a rotated-out client; may be nil.
+	fallbackOAuthConfig *oauth2.Config
 
 	// Pending OAuth states (state -> true)
 	pendingStates sync.Map
+
+	// In-flight tools/call requests, keyed by user email and JSON-RPC ID
+	inflight inflightRequests
+
+	// calendarCaches are shared by calendar services, keyed by user email.
+	calendarCaches calendarCaches
+
+	// Open GET /mcp event streams, keyed by user email
+	streams eventStreams
+	// shuttingDown is closed when Run begins a graceful shutdown.
+	shuttingDown chan struct{}
 }
 
 // NewHTTPServer creates a new multi-user HTTP MCP server.
-func NewHTTPServer(database *DB, credentialsFile, addr, baseURL string) (*HTTPServer, error) {
+func NewHTTPServer(database *DB, credentialsFile, addr, baseURL string, opts Options) (*HTTPServer, error) {
 	// Load OAuth config with email scope for user identification
-	config, err := loadOAuthConfig(credentialsFile, oauthScopesWithEmail)
+	config, err := loadOAuthConfig(credentialsFile, selectScopes(oauthScopesWithEmail, opts.Scopes, opts.GmailFullAccess))
 	if err != nil {
 		return nil, err
 	}
@@ -43,22 +60,74 @@ func NewHTTPServer(database *DB, credentialsFile, addr, baseURL string) (*HTTPSe
 	}
 	config.RedirectURL = resolvedBaseURL + "/auth/callback"
 
+	fallback, err := loadFallbackOAuthConfig(opts.FallbackCredentialsFile, selectScopes(oauthScopesWithEmail, opts.Scopes, opts.GmailFullAccess))
+	if err != nil {
+		return nil, err
+	}
+
 	return &HTTPServer{
 		database:        database,
+		opts:            opts,
 		credentialsFile: credentialsFile,
 		addr:            addr,
 		baseURL:         resolvedBaseURL,
 		oauthConfig:     config,
+
+		fallbackOAuthConfig: fallback,
+		calendarCaches:      newCalendarCaches(opts.CalendarListTTL),
 	}, nil
 }
 
 // Run starts the HTTP server.
 func (h *HTTPServer) Run(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              h.addr,
+		Handler:           logRequests(slog.Default(), h.routes()),
+		ReadHeaderTimeout: readHeaderTimeout(h.opts.ReadTimeout),
+		ReadTimeout:       h.opts.ReadTimeout,
+		WriteTimeout:      h.opts.WriteTimeout,
+		IdleTimeout:       h.opts.IdleTimeout,
+	}
+
+	// Shutdown does not cancel request contexts, so event streams, which
+	// would otherwise hold it up for the whole grace period, end here.
+	h.shuttingDown = make(chan struct{})
+	server.RegisterOnShutdown(func() { close(h.shuttingDown) })
+
+	ln, err := net.Listen("tcp", h.addr)
+	if err != nil {
+		return err
+	}
+
+	go runPeriodic(ctx, schedulerInterval, func(ctx context.Context) {
+		runBackgroundJobs(ctx, h.database, httpStateKeys, h.gmailServiceFor, h.notifyUser)
+	})
+	if h.opts.CleanupInterval > 0 {
+		cleanupExpiredMCPData(h.database)
+		go runPeriodic(ctx, h.opts.CleanupInterval, func(context.Context) {
+			cleanupExpiredMCPData(h.database)
+		})
+	}
+
+	slog.Info("HTTP server listening",
+		"addr", h.addr,
+		"base_url", h.baseURL,
+		"login_url", h.baseURL+"/auth/login",
+		"mcp_endpoint", h.baseURL+"/mcp")
+
+	return serveUntilDone(ctx, server, ln, shutdownGrace)
+}
+
+// routes returns the handler for every HTTP endpoint.
+func (h *HTTPServer) routes() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// Auth endpoints
 	mux.HandleFunc("GET /auth/login", h.handleAuthLogin)
 	mux.HandleFunc("GET /auth/callback", h.handleAuthCallback)
+	mux.HandleFunc("POST /auth/rotate-key", h.handleRotateAPIKey)
+	// DELETE /auth/apikey is the same operation, for invalidating a leaked key.
+	mux.HandleFunc("DELETE /auth/apikey", h.handleRotateAPIKey)
 
 	// OAuth discovery (RFC 8414 + RFC 9728)
 	mux.HandleFunc("GET /.well-known/oauth-authorization-server", h.handleOAuthMetadata)
@@ -69,34 +138,34 @@ func (h *HTTPServer) Run(ctx context.Context) error {
 	mux.HandleFunc("POST /oauth/register", h.handleOAuthRegister)
 	mux.HandleFunc("GET /oauth/authorize", h.handleOAuthAuthorize)
 	mux.HandleFunc("POST /oauth/token", h.handleOAuthToken)
+	mux.HandleFunc("POST /oauth/revoke", h.handleOAuthRevoke)
+	mux.HandleFunc("POST /oauth/introspect", h.handleOAuthIntrospect)
 
 	// Health check
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 	})
 
+	// Tool catalog
+	mux.HandleFunc("GET /tools", h.handleToolCatalog)
+
 	// MCP endpoint (requires Bearer token)
 	mux.HandleFunc("POST /mcp", h.handleMCP)
+	mux.HandleFunc("GET /mcp", h.handleMCPStream)
+	return mux
+}
 
-	server := &http.Server{
-		Addr:    h.addr,
-		Handler: mux,
-	}
-
-	go func() {
-		<-ctx.Done()
-		server.Close()
-	}()
-
-	fmt.Fprintf(os.Stderr, "HTTP server listening on %s\n", h.addr)
-	fmt.Fprintf(os.Stderr, "Base URL: %s\n", h.baseURL)
-	fmt.Fprintf(os.Stderr, "Login URL: %s/auth/login\n", h.baseURL)
-	fmt.Fprintf(os.Stderr, "MCP endpoint: %s/mcp\n", h.baseURL)
+// maxReadHeaderTimeout bounds how long a client may take to send request
+// headers, the window slowloris attacks exploit.
+const maxReadHeaderTimeout = 10 * time.Second
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		return err
+// readHeaderTimeout returns the header timeout for a server whose whole-request
+// read timeout is readTimeout (zero meaning none).
+func readHeaderTimeout(readTimeout time.Duration) time.Duration {
+	if readTimeout > 0 && readTimeout < maxReadHeaderTimeout {
+		return readTimeout
 	}
-	return nil
+	return maxReadHeaderTimeout
 }
 
 // handleAuthLogin redirects the user to Google OAuth consent screen.
@@ -119,7 +188,7 @@ func (h *HTTPServer) handleAuthCallback(w http.ResponseWriter, r *http.Request)
 	// Check if this is an MCP OAuth flow
 	session, err := h.database.GetAuthSessionByState(state)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] GetAuthSessionByState: %v\n", err)
+		slog.Error("get auth session by state failed", "error", err)
 	}
 	if session != nil {
 		h.handleMCPAuthCallback(w, r, session)
@@ -149,7 +218,7 @@ func (h *HTTPServer) handleAuthCallback(w http.ResponseWriter, r *http.Request)
 	// Exchange code for token
 	tok, err := h.oauthConfig.Exchange(context.Background(), code)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] OAuth exchange failed: %v\n", err)
+		slog.Error("OAuth exchange failed", "error", err)
 		http.Error(w, "token exchange failed", http.StatusInternalServerError)
 		return
 	}
@@ -157,7 +226,7 @@ func (h *HTTPServer) handleAuthCallback(w http.ResponseWriter, r *http.Request)
 	// Fetch user email from Google
 	email, err := fetchUserEmail(tok)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] Fetch email failed: %v\n", err)
+		slog.Error("fetch user email failed", "error", err)
 		http.Error(w, "failed to get user email", http.StatusInternalServerError)
 		return
 	}
@@ -165,18 +234,53 @@ func (h *HTTPServer) handleAuthCallback(w http.ResponseWriter, r *http.Request)
 	// Create or update user in DB
 	apiKey, err := h.database.CreateOrUpdateUser(email, tok)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] Create user failed: %v\n", err)
+		slog.Error("create user failed", "user", email, "error", err)
 		http.Error(w, "failed to create user", http.StatusInternalServerError)
 		return
 	}
 
-	fmt.Fprintf(os.Stderr, "[INFO] User authenticated: %s\n", email)
+	h.calendarCaches.invalidate(email)
+	slog.Info("user authenticated", "user", email, "flow", "api_key")
 
 	// Show API key to user
+	writeAPIKeyPage(w, "Authentication Successful", email, apiKey)
+}
+
+// handleRotateAPIKey issues a new API key for the user identified by the current
+// Bearer token and invalidates the old one. The key is shown once, as HTML when
+// the client accepts it and as JSON otherwise.
+func (h *HTTPServer) handleRotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	email, status, err := h.authenticateRequest(r)
+	if err != nil {
+		if status == http.StatusUnauthorized {
+			setWWWAuthenticate(w, h.baseURL)
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+
+	apiKey, err := h.database.RotateAPIKey(email)
+	if err != nil {
+		slog.Error("rotate API key failed", "user", email, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to rotate API key"})
+		return
+	}
+
+	slog.Info("API key rotated", "user", email)
+
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		writeAPIKeyPage(w, "API Key Rotated", email, apiKey)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"email": email, "api_key": apiKey})
+}
+
+// writeAPIKeyPage renders the page that shows a freshly issued API key.
+func writeAPIKeyPage(w http.ResponseWriter, title, email, apiKey string) {
 	w.Header().Set("Content-Type", "text/html")
 	fmt.Fprintf(w, `<!DOCTYPE html>
 <html>
-<head><title>Authentication Successful</title>
+<head><title>%s</title>
 <style>
 body { font-family: -apple-system, BlinkMacSystemFont, sans-serif; max-width: 600px; margin: 40px auto; padding: 20px; }
 .key-box { background: #f5f5f5; border: 1px solid #ddd; border-radius: 8px; padding: 16px; margin: 16px 0; word-break: break-all; font-family: monospace; font-size: 14px; }
@@ -185,7 +289,7 @@ body { font-family: -apple-system, BlinkMacSystemFont, sans-serif; max-width: 60
 </style>
 </head>
 <body>
-<h2>Authentication Successful!</h2>
+<h2>%s!</h2>
 <p>Welcome, <strong>%s</strong></p>
 <p>Your API key for MCP requests:</p>
 <div class="key-box" id="api-key">%s</div>
@@ -195,82 +299,132 @@ body { font-family: -apple-system, BlinkMacSystemFont, sans-serif; max-width: 60
 <pre style="background:#f5f5f5;padding:12px;border-radius:6px;overflow-x:auto">Authorization: Bearer %s</pre>
 <p>You can close this tab now.</p>
 </body>
-</html>`, html.EscapeString(email), html.EscapeString(apiKey), html.EscapeString(apiKey))
+</html>`, html.EscapeString(title), html.EscapeString(title), html.EscapeString(email), html.EscapeString(apiKey), html.EscapeString(apiKey))
 }
 
 // handleMCP handles MCP JSON-RPC requests with per-user authentication.
 // Supports both MCP OAuth tokens and legacy API key Bearer tokens.
 func (h *HTTPServer) handleMCP(w http.ResponseWriter, r *http.Request) {
+	userEmail, status, err := h.authenticateRequest(r)
+	if err != nil {
+		if status == http.StatusUnauthorized {
+			setWWWAuthenticate(w, h.baseURL)
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	h.handleMCPRequest(w, r, userEmail)
+}
+
+// authenticateRequest resolves the request's Bearer token to a user email.
+// MCP OAuth access tokens are tried first, then legacy API keys. On failure it
+// returns the HTTP status to respond with.
+func (h *HTTPServer) authenticateRequest(r *http.Request) (string, int, error) {
 	token := extractBearerToken(r)
 	if token == "" {
-		setWWWAuthenticate(w, h.baseURL)
-		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing Authorization header"})
-		return
+		return "", http.StatusUnauthorized, fmt.Errorf("missing Authorization header")
 	}
 
 	// 1. Try MCP OAuth token
 	userEmail, err := h.database.ValidateMCPAccessToken(token)
 	if err == nil && userEmail != "" {
-		h.handleMCPRequest(w, r, userEmail)
-		return
+		return userEmail, http.StatusOK, nil
 	}
 
 	// 2. Fallback to legacy API key
 	user, err := h.database.GetUserByAPIKey(token)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "database error"})
-		return
+		return "", http.StatusInternalServerError, fmt.Errorf("database error")
 	}
 	if user == nil {
-		setWWWAuthenticate(w, h.baseURL)
-		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid credentials"})
-		return
+		return "", http.StatusUnauthorized, fmt.Errorf("invalid credentials")
 	}
-	h.handleMCPRequest(w, r, user.Email)
+	return user.Email, http.StatusOK, nil
 }
 
-// handleMCPRequest processes a JSON-RPC request for an authenticated user identified by email.
+// handleMCPRequest processes a JSON-RPC message, a single request or a batch,
+// for an authenticated user identified by email. The response is a JSON body,
+// or a one-message SSE stream if the client accepts text/event-stream.
 func (h *HTTPServer) handleMCPRequest(w http.ResponseWriter, r *http.Request, userEmail string) {
-	// Parse JSON-RPC request
-	var req jsonrpcRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	noteRequestUser(r.Context(), userEmail)
+	h.limitRequestBody(w, r)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		if isRequestTooLarge(err) {
+			writeJSONRPC(w, errorResponse(nil, codeInvalidRequest, "Invalid Request",
+				fmt.Sprintf("request body exceeds %d bytes", h.opts.MaxRequestBytes)))
+			return
+		}
 		writeJSONRPC(w, errorResponse(nil, codeParseError, "Parse error", err.Error()))
 		return
 	}
 
-	if req.JSONRPC != "2.0" {
-		writeJSONRPC(w, errorResponse(req.ID, codeInvalidRequest, "Invalid Request", "jsonrpc must be 2.0"))
+	handle := func(data []byte) *jsonrpcResponse {
+		return h.handleMCPMessage(r.Context(), data, userEmail)
+	}
+	var out any
+	if isBatch(body) {
+		out = handleBatch(body, handle)
+	} else if resp := handle(body); resp != nil {
+		out = resp
+	}
+
+	// Notifications, alone or in a batch, get no body
+	if out == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if acceptsEventStream(r) {
+		writeJSONRPCEventStream(w, out)
 		return
 	}
+	writeJSONRPC(w, out)
+}
+
+// handleMCPMessage processes one JSON-RPC message. It returns nil for
+// notifications, which get no response.
+func (h *HTTPServer) handleMCPMessage(ctx context.Context, data []byte, userEmail string) *jsonrpcResponse {
+	var req jsonrpcRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errorResponse(nil, codeParseError, "Parse error", err.Error())
+	}
+
+	if req.JSONRPC != "2.0" {
+		return errorResponse(req.ID, codeInvalidRequest, "Invalid Request", "jsonrpc must be 2.0")
+	}
+	noteRequestRPC(ctx, req.Method, "")
 
 	// Handle notifications
 	if req.ID == nil || string(req.ID) == "null" {
-		w.WriteHeader(http.StatusOK)
-		return
+		if req.Method == "notifications/cancelled" {
+			var params cancelledParams
+			if err := json.Unmarshal(req.Params, &params); err == nil && params.RequestID != nil {
+				h.inflight.cancel(userEmail + " " + requestKey(params.RequestID))
+			}
+		}
+		return nil
 	}
 
-	var resp *jsonrpcResponse
 	switch req.Method {
 	case "initialize":
-		resp = h.handleInitialize(req.ID)
+		return h.handleInitialize(req.ID, userEmail)
 	case "tools/list":
-		resp = h.handleToolsList(req.ID)
+		return h.handleToolsList(req.ID)
 	case "tools/call":
-		resp = h.handleToolsCall(r.Context(), req.ID, req.Params, userEmail)
+		return h.handleToolsCall(ctx, req.ID, req.Params, userEmail)
 	case "resources/list":
-		resp = h.handleResourcesList(req.ID)
+		return h.handleResourcesList(req.ID)
 	case "resources/read":
-		resp = h.handleResourcesRead(req.ID, req.Params)
+		return h.handleResourcesRead(req.ID, req.Params)
 	case "ping":
-		resp = successResponse(req.ID, struct{}{})
+		return successResponse(req.ID, struct{}{})
 	default:
-		resp = errorResponse(req.ID, codeMethodNotFound, "Method not found", req.Method)
+		return errorResponse(req.ID, codeMethodNotFound, "Method not found", req.Method)
 	}
-
-	writeJSONRPC(w, resp)
 }
 
-func (h *HTTPServer) handleInitialize(id json.RawMessage) *jsonrpcResponse {
+func (h *HTTPServer) handleInitialize(id json.RawMessage, userEmail string) *jsonrpcResponse {
 	result := &initializeResult{
 		ProtocolVersion: protocolVersion,
 		Capabilities: serverCapabilities{
@@ -281,25 +435,51 @@ func (h *HTTPServer) handleInitialize(id json.RawMessage) *jsonrpcResponse {
 			Name:    serverName,
 			Version: serverVersion,
 		},
+		Meta: h.sessionMeta(userEmail),
 	}
 	return successResponse(id, result)
 }
 
+// sessionMeta reports whether userEmail's stored Google token still works.
+func (h *HTTPServer) sessionMeta(userEmail string) *initializeMeta {
+	if _, err := getUserTokenSourceByEmail(h.oauthConfig, h.fallbackOAuthConfig, h.database, userEmail); err != nil {
+		return &initializeMeta{Authenticated: false}
+	}
+	return &initializeMeta{Authenticated: true, Email: userEmail}
+}
+
 func (h *HTTPServer) handleToolsList(id json.RawMessage) *jsonrpcResponse {
-	all := allTools()
 	var tools []mcpTool
-	for _, t := range all {
-		// In HTTP mode, don't expose the "authenticate" tool (auth is done via /auth/login)
-		if t.Name == "authenticate" {
-			continue
+	for _, t := range httpModeTools() {
+		if t.isVisibleToModel() {
+			tools = append(tools, t)
 		}
-		if !t.isVisibleToModel() {
+	}
+	return successResponse(id, &listToolsResult{Tools: tools})
+}
+
+// httpModeTools returns the tools served in HTTP mode, with _meta populated.
+// The "authenticate" tool is omitted since auth is done via /auth/login.
+func httpModeTools() []mcpTool {
+	var tools []mcpTool
+	for _, t := range allTools() {
+		if t.Name == "authenticate" {
 			continue
 		}
 		t.Meta = buildToolMeta(t)
 		tools = append(tools, t)
 	}
-	return successResponse(id, &listToolsResult{Tools: tools})
+	return tools
+}
+
+// handleToolCatalog serves GET /tools: every tool this server exposes in HTTP
+// mode, including app-only tools, with schemas and _meta. It is unauthenticated
+// so deployment checks and client config generators can read it.
+func (h *HTTPServer) handleToolCatalog(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"server": serverInfo{Name: serverName, Version: serverVersion},
+		"tools":  httpModeTools(),
+	})
 }
 
 func (h *HTTPServer) handleToolsCall(ctx context.Context, id json.RawMessage, rawParams json.RawMessage, userEmail string) *jsonrpcResponse {
@@ -307,25 +487,21 @@ func (h *HTTPServer) handleToolsCall(ctx context.Context, id json.RawMessage, ra
 	if err := json.Unmarshal(rawParams, &params); err != nil {
 		return errorResponse(id, codeInvalidParams, "Invalid params", err.Error())
 	}
+	noteRequestRPC(ctx, "", params.Name)
 
-	// Build service for this user
-	ts, err := getUserTokenSourceByEmail(h.oauthConfig, h.database, userEmail)
-	if err != nil {
-		return successResponse(id, &callToolResult{
-			Content: []content{{Type: "text", Text: fmt.Sprintf("authentication error: %v", err)}},
-			IsError: true,
-		})
-	}
+	ctx, done := h.inflight.begin(ctx, userEmail+" "+requestKey(id))
+	defer done()
 
-	result, err := dispatchHTTPTool(ctx, ts, params.Name, params.Arguments)
+	start := time.Now()
+	result, err := h.callTool(ctx, userEmail, params.Name, params.Arguments)
+	logToolCall(userEmail, params.Name, start, err)
 	if err != nil {
-		return successResponse(id, &callToolResult{
-			Content: []content{{Type: "text", Text: err.Error()}},
-			IsError: true,
-		})
+		return successResponse(id, toolErrorResult(err))
 	}
 
-	jsonBytes, err := json.Marshal(result)
+	// Marshal result to JSON text
+	result, capMeta := splitCapIndicator(result)
+	jsonBytes, err := marshalToolResult(params.Name, params.Arguments, result, h.opts.CompactOutput)
 	if err != nil {
 		return successResponse(id, &callToolResult{
 			Content: []content{{Type: "text", Text: fmt.Sprintf("marshal error: %v", err)}},
@@ -337,11 +513,73 @@ func (h *HTTPServer) handleToolsCall(ctx context.Context, id json.RawMessage, ra
 		Content: []content{{Type: "text", Text: string(jsonBytes)}},
 	}
 	if tool := findTool(params.Name); tool != nil && tool.hasUI() {
-		res.Meta = buildResultMeta(*tool, string(jsonBytes))
+		res.Meta = buildResultMeta(*tool, string(jsonBytes), callLocale(params.Arguments, h.opts.Locale))
 	}
+	res.Meta = mergeMeta(res.Meta, capMeta)
 	return successResponse(id, res)
 }
 
+// callTool runs a tool on behalf of userEmail.
+func (h *HTTPServer) callTool(ctx context.Context, userEmail, name string, args map[string]interface{}) (any, error) {
+	if isLocalTool(name) {
+		return dispatchLocalTool(h.opts, name, args)
+	}
+	if isStatefulTool(name) {
+		return dispatchStatefulTool(ctx, h.database, h.opts, userEmail, name, args, statefulServices{
+			gmail: func() (*GmailService, error) {
+				return h.gmailServiceFor(ctx, userEmail)
+			},
+			calendar: func() (*CalendarService, error) {
+				return h.calendarServiceFor(ctx, userEmail)
+			},
+		})
+	}
+
+	// Build service for this user
+	ts, err := getUserTokenSourceByEmail(h.oauthConfig, h.fallbackOAuthConfig, h.database, userEmail)
+	if err != nil {
+		return nil, fmt.Errorf("authentication error: %v", err)
+	}
+
+	if !isGmailTool(name) && !isTasksTool(name) {
+		args = withDefaultCalendar(h.database, userEmail, name, args)
+	}
+	result, err := dispatchHTTPTool(ctx, ts, h.opts, h.calendarCaches, userEmail, name, args)
+	if err != nil {
+		return nil, err
+	}
+	recordRecentCalendar(h.database, userEmail, name, args)
+	forgetDeletedCalendar(h.database, userEmail, name, args)
+	return result, nil
+}
+
+// gmailServiceFor builds a Gmail service acting for userEmail.
+func (h *HTTPServer) gmailServiceFor(ctx context.Context, userEmail string) (*GmailService, error) {
+	ts, err := getUserTokenSourceByEmail(h.oauthConfig, h.fallbackOAuthConfig, h.database, userEmail)
+	if err != nil {
+		return nil, fmt.Errorf("authentication error: %w", err)
+	}
+	svc, err := NewGmailService(ctx, ts, h.opts)
+	if err != nil {
+		return nil, fmt.Errorf("gmail service error: %w", err)
+	}
+	return svc, nil
+}
+
+// calendarServiceFor builds a Calendar service acting for userEmail.
+func (h *HTTPServer) calendarServiceFor(ctx context.Context, userEmail string) (*CalendarService, error) {
+	ts, err := getUserTokenSourceByEmail(h.oauthConfig, h.fallbackOAuthConfig, h.database, userEmail)
+	if err != nil {
+		return nil, fmt.Errorf("authentication error: %v", err)
+	}
+	svc, err := NewCalendarService(ctx, ts, h.opts)
+	if err != nil {
+		return nil, fmt.Errorf("calendar service error: %w", err)
+	}
+	svc.caches, svc.cacheKey = h.calendarCaches, userEmail
+	return svc, nil
+}
+
 func (h *HTTPServer) handleResourcesList(id json.RawMessage) *jsonrpcResponse {
 	resources := []resource{}
 	for _, t := range allTools() {
@@ -368,7 +606,7 @@ func (h *HTTPServer) handleResourcesRead(id json.RawMessage, rawParams json.RawM
 		return errorResponse(id, codeInvalidParams, "Invalid resource URI", err.Error())
 	}
 
-	htmlContent, err := generateUIHTML(*tool, encodedData, "")
+	htmlContent, err := generateUIHTML(*tool, encodedData, "", uiResourceLocale(params.URI, h.opts.Locale))
 	if err != nil {
 		return errorResponse(id, codeInternalError, "Failed to generate UI", err.Error())
 	}
@@ -404,13 +642,28 @@ func generateState() (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
+// limitRequestBody caps how much of r's body handlers may read, so a client
+// cannot exhaust memory with an oversized request.
+func (h *HTTPServer) limitRequestBody(w http.ResponseWriter, r *http.Request) {
+	if h.opts.MaxRequestBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.opts.MaxRequestBytes)
+	}
+}
+
+// isRequestTooLarge reports whether err came from reading past the body limit.
+func isRequestTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
 func writeJSON(w http.ResponseWriter, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
 }
 
-func writeJSONRPC(w http.ResponseWriter, resp *jsonrpcResponse) {
+// writeJSONRPC writes resp, a response or a batch of them, as a JSON body.
+func writeJSONRPC(w http.ResponseWriter, resp any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(resp)
@@ -1,8 +1,16 @@
 package main
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
 )
 
 func TestResolveBaseURL(t *testing.T) {
@@ -131,3 +139,564 @@ func TestExtractBearerToken(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleRotateAPIKey(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewDB(filepath.Join(t.TempDir(), "http.db"))
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	oldKey, err := d.CreateOrUpdateUser("user@example.com", &oauth2.Token{AccessToken: "a", TokenType: "Bearer"})
+	if err != nil {
+		t.Fatalf("CreateOrUpdateUser() error = %v", err)
+	}
+	h := &HTTPServer{database: d, baseURL: "http://localhost:8080"}
+
+	// Unauthenticated
+	rec := httptest.NewRecorder()
+	h.handleRotateAPIKey(rec, httptest.NewRequest(http.MethodPost, "/auth/rotate-key", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status without token = %d, want 401", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/rotate-key", nil)
+	req.Header.Set("Authorization", "Bearer "+oldKey)
+	rec = httptest.NewRecorder()
+	h.handleRotateAPIKey(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body["email"] != "user@example.com" || body["api_key"] == "" || body["api_key"] == oldKey {
+		t.Fatalf("unexpected response: %v", body)
+	}
+
+	// The old key no longer works.
+	req = httptest.NewRequest(http.MethodPost, "/auth/rotate-key", nil)
+	req.Header.Set("Authorization", "Bearer "+oldKey)
+	rec = httptest.NewRecorder()
+	h.handleRotateAPIKey(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status with old key = %d, want 401", rec.Code)
+	}
+}
+
+func TestDeleteAPIKey(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	oldKey, err := d.CreateOrUpdateUser("user@example.com", &oauth2.Token{AccessToken: "a", TokenType: "Bearer"})
+	if err != nil {
+		t.Fatalf("CreateOrUpdateUser() error = %v", err)
+	}
+	mux := (&HTTPServer{database: d, baseURL: "http://localhost:8080"}).routes()
+	deleteKey := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/auth/apikey", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := deleteKey(oldKey)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("DELETE /auth/apikey = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	newKey := body["api_key"]
+	if body["email"] != "user@example.com" || newKey == "" || newKey == oldKey {
+		t.Fatalf("DELETE /auth/apikey response = %v, want a new key", body)
+	}
+
+	if rec := deleteKey(oldKey); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("DELETE /auth/apikey with the old key = %d, want 401", rec.Code)
+	}
+	if user, err := d.GetUserByAPIKey(oldKey); err != nil || user != nil {
+		t.Fatalf("GetUserByAPIKey(old key) = %+v, %v, want no user", user, err)
+	}
+	if user, err := d.GetUserByAPIKey(newKey); err != nil || user.Email != "user@example.com" {
+		t.Fatalf("GetUserByAPIKey(new key) = %+v, %v, want the user", user, err)
+	}
+}
+
+func TestHandleOAuthRevoke(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	h := &HTTPServer{database: d, baseURL: "http://localhost:8080"}
+	revoke := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/oauth/revoke", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.handleOAuthRevoke(rec, req)
+		return rec
+	}
+
+	access1, _, err := d.CreateMCPToken("client1", "user@example.com")
+	if err != nil {
+		t.Fatalf("CreateMCPToken() error = %v", err)
+	}
+	access2, refresh2, err := d.CreateMCPToken("client1", "user@example.com")
+	if err != nil {
+		t.Fatalf("CreateMCPToken() error = %v", err)
+	}
+
+	// Another client cannot revoke client1's tokens, nor learn that it failed.
+	if rec := revoke(url.Values{"token": {access1}, "client_id": {"client2"}}); rec.Code != http.StatusOK {
+		t.Fatalf("revoke another client's token = %d, want 200", rec.Code)
+	}
+	if _, err := d.ValidateMCPAccessToken(access1); err != nil {
+		t.Fatalf("access token revoked by another client: %v", err)
+	}
+	if rec := revoke(url.Values{"token": {access1}}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("revoke without client_id = %d, want 400", rec.Code)
+	}
+
+	// Revoking an access token also revokes its refresh token.
+	if rec := revoke(url.Values{"token": {access1}, "token_type_hint": {"access_token"}, "client_id": {"client1"}}); rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Fatalf("revoke access token = %d %q, want 200 with empty body", rec.Code, rec.Body.String())
+	}
+	if _, err := d.ValidateMCPAccessToken(access1); err == nil {
+		t.Fatal("revoked access token still valid")
+	}
+	if _, err := d.ValidateMCPAccessToken(access2); err != nil {
+		t.Fatalf("other access token invalid after revoke: %v", err)
+	}
+
+	// A refresh token revokes its access token, whatever the hint says.
+	if rec := revoke(url.Values{"token": {refresh2}, "token_type_hint": {"access_token"}, "client_id": {"client1"}}); rec.Code != http.StatusOK {
+		t.Fatalf("revoke refresh token = %d, want 200", rec.Code)
+	}
+	if _, err := d.ValidateMCPAccessToken(access2); err == nil {
+		t.Fatal("access token still valid after revoking its refresh token")
+	}
+	if _, _, err := d.RefreshMCPToken(refresh2, "client1"); err == nil {
+		t.Fatal("revoked refresh token still usable")
+	}
+
+	if rec := revoke(url.Values{"token": {"unknown"}, "client_id": {"client1"}}); rec.Code != http.StatusOK {
+		t.Fatalf("revoke unknown token = %d, want 200", rec.Code)
+	}
+	if rec := revoke(url.Values{"client_id": {"client1"}}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("revoke without token = %d, want 400", rec.Code)
+	}
+}
+
+func TestOAuthConfidentialClient(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	h := &HTTPServer{database: d, baseURL: "http://localhost:8080"}
+	register := func(authMethod string) (*httptest.ResponseRecorder, map[string]any) {
+		body := `{"client_name":"test","redirect_uris":["http://localhost/cb"],"token_endpoint_auth_method":"` + authMethod + `"}`
+		rec := httptest.NewRecorder()
+		h.handleOAuthRegister(rec, httptest.NewRequest(http.MethodPost, "/oauth/register", strings.NewReader(body)))
+		var resp map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+	refresh := func(form url.Values, basicID, basicSecret string) *httptest.ResponseRecorder {
+		form.Set("grant_type", "refresh_token")
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if basicID != "" {
+			req.SetBasicAuth(url.QueryEscape(basicID), url.QueryEscape(basicSecret))
+		}
+		rec := httptest.NewRecorder()
+		h.handleOAuthToken(rec, req)
+		return rec
+	}
+
+	rec, resp := register("client_secret_basic")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("register confidential client = %d %s", rec.Code, rec.Body.String())
+	}
+	clientID, _ := resp["client_id"].(string)
+	secret, _ := resp["client_secret"].(string)
+	if secret == "" || resp["token_endpoint_auth_method"] != "client_secret_basic" || resp["client_secret_expires_at"] != float64(0) {
+		t.Fatalf("register confidential client response = %v", resp)
+	}
+	client, err := d.GetMCPClient(clientID)
+	if err != nil || client == nil || client.ClientSecretHash == nil || *client.ClientSecretHash == secret {
+		t.Fatalf("stored client = %+v, %v, want only a secret hash", client, err)
+	}
+
+	_, refreshToken, err := d.CreateMCPToken(clientID, "user@example.com")
+	if err != nil {
+		t.Fatalf("CreateMCPToken() error = %v", err)
+	}
+	if rec := refresh(url.Values{"refresh_token": {refreshToken}, "client_id": {clientID}}, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh without secret = %d, want 401", rec.Code)
+	}
+	rec = refresh(url.Values{"refresh_token": {refreshToken}}, clientID, "wrong")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("refresh with wrong Basic secret = %d %v, want 401 with a challenge", rec.Code, rec.Header())
+	}
+	rec = refresh(url.Values{"refresh_token": {refreshToken}}, clientID, secret)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh with Basic secret = %d %s, want 200", rec.Code, rec.Body.String())
+	}
+	var tokens map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &tokens)
+	refreshToken, _ = tokens["refresh_token"].(string)
+	if rec := refresh(url.Values{"refresh_token": {refreshToken}, "client_id": {clientID}, "client_secret": {secret}}, "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("refresh with form secret = %d %s, want 200", rec.Code, rec.Body.String())
+	}
+
+	// Revocation authenticates the client like the token endpoint does.
+	_ = json.Unmarshal(rec.Body.Bytes(), &tokens)
+	revoke := func(form url.Values, basicSecret string) int {
+		req := httptest.NewRequest(http.MethodPost, "/oauth/revoke", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(basicSecret))
+		rec := httptest.NewRecorder()
+		h.handleOAuthRevoke(rec, req)
+		return rec.Code
+	}
+	refreshToken, _ = tokens["refresh_token"].(string)
+	if code := revoke(url.Values{"token": {refreshToken}}, "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("revoke with wrong Basic secret = %d, want 401", code)
+	}
+	if code := revoke(url.Values{"token": {refreshToken}}, secret); code != http.StatusOK {
+		t.Fatalf("revoke with Basic secret = %d, want 200", code)
+	}
+	if rec := refresh(url.Values{"refresh_token": {refreshToken}}, clientID, secret); rec.Code == http.StatusOK {
+		t.Fatal("revoked refresh token still usable")
+	}
+
+	// Public clients are unchanged: no secret is issued or required.
+	rec, resp = register("")
+	if rec.Code != http.StatusCreated || resp["token_endpoint_auth_method"] != "none" {
+		t.Fatalf("register public client = %d %v", rec.Code, resp)
+	}
+	if _, ok := resp["client_secret"]; ok {
+		t.Fatalf("public client was issued a secret: %v", resp)
+	}
+	publicID, _ := resp["client_id"].(string)
+	_, refreshToken, err = d.CreateMCPToken(publicID, "user@example.com")
+	if err != nil {
+		t.Fatalf("CreateMCPToken() error = %v", err)
+	}
+	if rec := refresh(url.Values{"refresh_token": {refreshToken}, "client_id": {publicID}}, "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("refresh public client = %d %s, want 200", rec.Code, rec.Body.String())
+	}
+
+	if rec, _ := register("private_key_jwt"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("register with unsupported auth method = %d, want 400", rec.Code)
+	}
+}
+
+func TestRedirectURIMatches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		registered, actual string
+		want               bool
+	}{
+		{"http://127.0.0.1:0/callback", "http://127.0.0.1:54231/callback", true},
+		{"http://127.0.0.1/callback", "http://127.0.0.1:54231/callback", true},
+		{"http://[::1]:8000/callback", "http://[::1]:54231/callback", true},
+		{"http://localhost:3000/callback", "http://localhost:54231/callback", true},
+		{"https://example.com/callback", "https://example.com/callback", true},
+		{"http://127.0.0.1:0/callback", "http://127.0.0.1:54231/other", false},
+		{"http://127.0.0.1:0/callback", "http://localhost:54231/callback", false},
+		{"http://127.0.0.1:0/callback", "https://127.0.0.1:54231/callback", false},
+		{"http://127.0.0.1:0/callback", "http://127.0.0.1:54231/callback?x=1", false},
+		{"http://127.0.0.1:0/callback", "http://evil.example:54231/callback", false},
+		{"https://example.com:443/callback", "https://example.com:8443/callback", false},
+		{"http://example.com/callback", "http://example.com:8080/callback", false},
+	}
+	for _, tt := range tests {
+		if got := redirectURIMatches(tt.registered, tt.actual); got != tt.want {
+			t.Errorf("redirectURIMatches(%q, %q) = %v, want %v", tt.registered, tt.actual, got, tt.want)
+		}
+	}
+}
+
+func TestHandleOAuthAuthorize_LoopbackRedirect(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	clientID, _, err := d.RegisterMCPClient("native", []string{"http://127.0.0.1:0/callback"}, false)
+	if err != nil {
+		t.Fatalf("RegisterMCPClient() error = %v", err)
+	}
+	h := &HTTPServer{database: d, oauthConfig: &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://accounts.example.com/auth"}}}
+	authorize := func(redirectURI string) *httptest.ResponseRecorder {
+		q := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {clientID},
+			"redirect_uri":          {redirectURI},
+			"code_challenge":        {"challenge"},
+			"code_challenge_method": {"S256"},
+		}
+		rec := httptest.NewRecorder()
+		h.handleOAuthAuthorize(rec, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+q.Encode(), nil))
+		return rec
+	}
+
+	if rec := authorize("http://127.0.0.1:54231/callback"); rec.Code != http.StatusFound {
+		t.Fatalf("authorize with loopback port 54231 = %d %s, want 302", rec.Code, rec.Body.String())
+	}
+	if rec := authorize("http://127.0.0.1:54231/elsewhere"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("authorize with another path = %d, want 400", rec.Code)
+	}
+}
+
+func TestHandleOAuthIntrospect(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	access, refresh, err := d.CreateMCPToken("client1", "user@example.com")
+	if err != nil {
+		t.Fatalf("CreateMCPToken() error = %v", err)
+	}
+	h := &HTTPServer{database: d, baseURL: "http://localhost:8080", opts: Options{IntrospectionSecret: "gateway-secret"}}
+	introspect := func(h *HTTPServer, bearer, token string) (*httptest.ResponseRecorder, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, "/oauth/introspect", strings.NewReader(url.Values{"token": {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		h.handleOAuthIntrospect(rec, req)
+		var body map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec, body
+	}
+
+	rec, body := introspect(h, "gateway-secret", access)
+	if rec.Code != http.StatusOK || body["active"] != true || body["sub"] != "user@example.com" || body["client_id"] != "client1" {
+		t.Fatalf("introspect access token = %d %v", rec.Code, body)
+	}
+	if exp, _ := body["exp"].(float64); time.Unix(int64(exp), 0).Before(time.Now()) {
+		t.Fatalf("exp = %v, want in the future", body["exp"])
+	}
+	for _, token := range []string{refresh, "unknown"} {
+		if rec, body := introspect(h, "gateway-secret", token); rec.Code != http.StatusOK || len(body) != 1 || body["active"] != false {
+			t.Fatalf("introspect %q = %d %v, want only active false", token, rec.Code, body)
+		}
+	}
+
+	for _, bearer := range []string{"", "wrong", access} {
+		if rec, _ := introspect(h, bearer, access); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("introspect with bearer %q = %d, want 401", bearer, rec.Code)
+		}
+	}
+
+	disabled := &HTTPServer{database: d, baseURL: "http://localhost:8080"}
+	if rec, _ := introspect(disabled, "", access); rec.Code != http.StatusNotFound {
+		t.Fatalf("introspect without a secret configured = %d, want 404", rec.Code)
+	}
+
+	for _, tt := range []struct {
+		h    *HTTPServer
+		want bool
+	}{{h, true}, {disabled, false}} {
+		rec := httptest.NewRecorder()
+		tt.h.handleOAuthMetadata(rec, httptest.NewRequest(http.MethodGet, "/.well-known/oauth-authorization-server", nil))
+		var metadata map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &metadata)
+		if _, ok := metadata["introspection_endpoint"]; ok != tt.want {
+			t.Fatalf("metadata advertises introspection = %v, want %v", ok, tt.want)
+		}
+		if metadata["revocation_endpoint"] != "http://localhost:8080/oauth/revoke" {
+			t.Fatalf("revocation_endpoint = %v", metadata["revocation_endpoint"])
+		}
+	}
+}
+
+func TestHandleToolCatalog(t *testing.T) {
+	t.Parallel()
+
+	h := &HTTPServer{}
+	rec := httptest.NewRecorder()
+	h.handleToolCatalog(rec, httptest.NewRequest(http.MethodGet, "/tools", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var catalog struct {
+		Server serverInfo `json:"server"`
+		Tools  []mcpTool  `json:"tools"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &catalog); err != nil {
+		t.Fatalf("decode catalog: %v", err)
+	}
+	if catalog.Server.Name != serverName {
+		t.Fatalf("server name = %q, want %q", catalog.Server.Name, serverName)
+	}
+
+	byName := make(map[string]mcpTool)
+	for _, tool := range catalog.Tools {
+		byName[tool.Name] = tool
+	}
+	if _, ok := byName["authenticate"]; ok {
+		t.Fatal("catalog includes authenticate, which is not served in HTTP mode")
+	}
+	if len(byName) != len(allTools())-1 {
+		t.Fatalf("catalog has %d tools, want %d", len(byName), len(allTools())-1)
+	}
+	if tool := byName["create-event"]; tool.InputSchema.Properties["summary"].Type != "string" {
+		t.Fatalf("create-event schema missing summary: %+v", tool.InputSchema)
+	}
+	if tool := byName["show-calendar"]; tool.Meta["ui"] == nil {
+		t.Fatalf("show-calendar missing ui _meta: %+v", tool.Meta)
+	}
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	t.Parallel()
+
+	h := &HTTPServer{opts: Options{MaxRequestBytes: 64}}
+	oversized := `{"jsonrpc":"2.0","id":1,"method":"ping","params":{"pad":"` + strings.Repeat("x", 128) + `"}}`
+
+	rec := httptest.NewRecorder()
+	h.handleMCPRequest(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(oversized)), "user@example.com")
+	var resp jsonrpcResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v (%s)", err, rec.Body.String())
+	}
+	if resp.Error == nil || resp.Error.Code != codeInvalidRequest {
+		t.Fatalf("/mcp oversized body error = %+v, want code %d", resp.Error, codeInvalidRequest)
+	}
+
+	rec = httptest.NewRecorder()
+	h.handleMCPRequest(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)), "user@example.com")
+	resp = jsonrpcResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error != nil {
+		t.Fatalf("/mcp small body = %s, want success", rec.Body.String())
+	}
+
+	oauthHandlers := []struct {
+		path    string
+		handler http.HandlerFunc
+	}{
+		{"/oauth/register", h.handleOAuthRegister},
+		{"/oauth/token", h.handleOAuthToken},
+		{"/oauth/revoke", h.handleOAuthRevoke},
+	}
+	for _, tt := range oauthHandlers {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(oversized))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		tt.handler(rec, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("%s status = %d, want 413", tt.path, rec.Code)
+		}
+	}
+}
+
+func TestReadHeaderTimeout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		readTimeout time.Duration
+		want        time.Duration
+	}{
+		{0, maxReadHeaderTimeout},
+		{5 * time.Second, 5 * time.Second},
+		{time.Minute, maxReadHeaderTimeout},
+	}
+	for _, tt := range tests {
+		if got := readHeaderTimeout(tt.readTimeout); got != tt.want {
+			t.Fatalf("readHeaderTimeout(%v) = %v, want %v", tt.readTimeout, got, tt.want)
+		}
+	}
+}
+
+func TestHandleInitialize_SessionMeta(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewDB(filepath.Join(t.TempDir(), "http.db"))
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	if _, err := d.CreateOrUpdateUser("user@example.com", &oauth2.Token{AccessToken: "a", TokenType: "Bearer"}); err != nil {
+		t.Fatalf("CreateOrUpdateUser() error = %v", err)
+	}
+	h := &HTTPServer{database: d, oauthConfig: &oauth2.Config{}}
+
+	tests := []struct {
+		user string
+		want initializeMeta
+	}{
+		{"user@example.com", initializeMeta{Authenticated: true, Email: "user@example.com"}},
+		{"unknown@example.com", initializeMeta{Authenticated: false}},
+	}
+	for _, tt := range tests {
+		resp := h.handleInitialize(json.RawMessage("1"), tt.user)
+		result, ok := resp.Result.(*initializeResult)
+		if !ok || result.Meta == nil {
+			t.Fatalf("handleInitialize(%q) result = %+v, want _meta", tt.user, resp.Result)
+		}
+		if *result.Meta != tt.want {
+			t.Fatalf("handleInitialize(%q) _meta = %+v, want %+v", tt.user, *result.Meta, tt.want)
+		}
+	}
+}
+
+func TestHandleMCPRequest_Batch(t *testing.T) {
+	t.Parallel()
+
+	h := &HTTPServer{}
+	rec := httptest.NewRecorder()
+	batch := `[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"},{"jsonrpc":"2.0","id":"b","method":"ping"}]`
+	h.handleMCPRequest(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(batch)), "user@example.com")
+	var resps []jsonrpcResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resps); err != nil {
+		t.Fatalf("decode batch response: %v (%s)", err, rec.Body.String())
+	}
+	if len(resps) != 2 || string(resps[0].ID) != "1" || string(resps[1].ID) != `"b"` {
+		t.Fatalf("batch response = %s, want responses for ids 1 and \"b\"", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.handleMCPRequest(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`[{"jsonrpc":"2.0","method":"notifications/initialized"}]`)), "user@example.com")
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Fatalf("notification-only batch = %d %q, want 200 with no body", rec.Code, rec.Body.String())
+	}
+}
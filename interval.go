@@ -0,0 +1,113 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// interval is a half-open time range [Start, End). Intervals that merely
+// touch do not overlap.
+type interval struct {
+	Start, End time.Time
+}
+
+// empty reports whether the interval contains no time.
+func (iv interval) empty() bool {
+	return !iv.Start.Before(iv.End)
+}
+
+// Equal reports whether both intervals cover exactly the same range.
+func (iv interval) Equal(o interval) bool {
+	return iv.Start.Equal(o.Start) && iv.End.Equal(o.End)
+}
+
+// Overlaps reports whether the intervals share any time. A zero-length
+// interval strictly inside the other, such as an instantaneous event, counts.
+func (iv interval) Overlaps(o interval) bool {
+	return iv.Start.Before(o.End) && o.Start.Before(iv.End)
+}
+
+// Merge returns the union of two intervals that overlap or touch. ok is false,
+// and iv returned unchanged, if there is a gap between them.
+func (iv interval) Merge(o interval) (merged interval, ok bool) {
+	if iv.Start.After(o.End) || o.Start.After(iv.End) {
+		return iv, false
+	}
+	merged = iv
+	if o.Start.Before(merged.Start) {
+		merged.Start = o.Start
+	}
+	if o.End.After(merged.End) {
+		merged.End = o.End
+	}
+	return merged, true
+}
+
+// Subtract returns the parts of iv not covered by o: none, one, or two
+// intervals, in order.
+func (iv interval) Subtract(o interval) []interval {
+	if !iv.Overlaps(o) {
+		if iv.empty() {
+			return nil
+		}
+		return []interval{iv}
+	}
+	var rest []interval
+	if iv.Start.Before(o.Start) {
+		rest = append(rest, interval{iv.Start, o.Start})
+	}
+	if o.End.Before(iv.End) {
+		rest = append(rest, interval{o.End, iv.End})
+	}
+	return rest
+}
+
+// intervals is a set of time ranges, such as busy periods.
+type intervals []interval
+
+// Merge returns the set sorted by start, with overlapping and touching
+// intervals combined and empty ones dropped. The receiver is not modified.
+func (ivs intervals) Merge() intervals {
+	sorted := make(intervals, 0, len(ivs))
+	for _, iv := range ivs {
+		if !iv.empty() {
+			sorted = append(sorted, iv)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var merged intervals
+	for _, iv := range sorted {
+		if n := len(merged); n > 0 {
+			if m, ok := merged[n-1].Merge(iv); ok {
+				merged[n-1] = m
+				continue
+			}
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// Invert returns the gaps between the set's intervals within the given
+// range, e.g. the free time around busy periods.
+func (ivs intervals) Invert(within interval) intervals {
+	free := intervals{}
+	if !within.empty() {
+		free = append(free, within)
+	}
+	return free.Subtract(ivs)
+}
+
+// Subtract returns the parts of the set not covered by any interval in o.
+func (ivs intervals) Subtract(o intervals) intervals {
+	rest := ivs.Merge()
+	for _, cut := range o.Merge() {
+		var next intervals
+		for _, iv := range rest {
+			next = append(next, iv.Subtract(cut)...)
+		}
+		rest = next
+	}
+	return rest
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// iv builds an interval on 2025-03-10 from hours.
+func iv(startHour, endHour int) interval {
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	return interval{day.Add(time.Duration(startHour) * time.Hour), day.Add(time.Duration(endHour) * time.Hour)}
+}
+
+func equalIntervals(a, b intervals) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestInterval_Overlaps(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a, b interval
+		want bool
+	}{
+		{"overlapping", iv(9, 11), iv(10, 12), true},
+		{"contained", iv(9, 17), iv(10, 11), true},
+		{"identical", iv(9, 10), iv(9, 10), true},
+		{"touching", iv(9, 10), iv(10, 11), false},
+		{"disjoint", iv(9, 10), iv(11, 12), false},
+		{"instant inside", iv(9, 17), iv(10, 10), true},
+		{"instant at start", iv(9, 17), iv(9, 9), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.a.Overlaps(tt.b); got != tt.want {
+				t.Fatalf("%v.Overlaps(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := tt.b.Overlaps(tt.a); got != tt.want {
+				t.Fatalf("Overlaps is not symmetric for %v, %v", tt.a, tt.b)
+			}
+		})
+	}
+}
+
+func TestInterval_Merge(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		a, b   interval
+		want   interval
+		wantOK bool
+	}{
+		{"overlapping", iv(9, 11), iv(10, 12), iv(9, 12), true},
+		{"touching", iv(9, 10), iv(10, 11), iv(9, 11), true},
+		{"contained", iv(9, 17), iv(10, 11), iv(9, 17), true},
+		{"gap", iv(9, 10), iv(11, 12), iv(9, 10), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, ok := tt.a.Merge(tt.b)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Fatalf("%v.Merge(%v) = %v, %v, want %v, %v", tt.a, tt.b, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestInterval_Subtract(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a, b interval
+		want intervals
+	}{
+		{"middle", iv(9, 17), iv(12, 13), intervals{iv(9, 12), iv(13, 17)}},
+		{"start", iv(9, 17), iv(8, 10), intervals{iv(10, 17)}},
+		{"end", iv(9, 17), iv(16, 18), intervals{iv(9, 16)}},
+		{"covered", iv(9, 10), iv(8, 11), nil},
+		{"disjoint", iv(9, 10), iv(10, 11), intervals{iv(9, 10)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := intervals(tt.a.Subtract(tt.b)); !equalIntervals(got, tt.want) {
+				t.Fatalf("%v.Subtract(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntervals_Merge(t *testing.T) {
+	t.Parallel()
+
+	in := intervals{iv(13, 14), iv(9, 10), iv(10, 11), iv(15, 15), iv(9, 10), iv(13, 16)}
+	want := intervals{iv(9, 11), iv(13, 16)}
+	if got := in.Merge(); !equalIntervals(got, want) {
+		t.Fatalf("Merge() = %v, want %v", got, want)
+	}
+	if !in[0].Equal(iv(13, 14)) {
+		t.Fatal("Merge() modified its receiver")
+	}
+}
+
+func TestIntervals_Invert(t *testing.T) {
+	t.Parallel()
+
+	busy := intervals{iv(12, 13), iv(8, 10), iv(16, 18)}
+	tests := []struct {
+		name   string
+		within interval
+		want   intervals
+	}{
+		{"working day", iv(9, 17), intervals{iv(10, 12), iv(13, 16)}},
+		{"all busy", iv(8, 10), nil},
+		{"no busy time", iv(19, 21), intervals{iv(19, 21)}},
+		{"empty range", iv(11, 11), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := busy.Invert(tt.within); !equalIntervals(got, tt.want) {
+				t.Fatalf("Invert(%v) = %v, want %v", tt.within, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntervals_Subtract(t *testing.T) {
+	t.Parallel()
+
+	free := intervals{iv(9, 12), iv(13, 17)}
+	got := free.Subtract(intervals{iv(10, 11), iv(11, 14), iv(16, 20)})
+	want := intervals{iv(9, 10), iv(14, 16)}
+	if !equalIntervals(got, want) {
+		t.Fatalf("Subtract() = %v, want %v", got, want)
+	}
+}
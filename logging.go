@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// newLogger creates a structured logger writing to w. format is "text" or
+// "json"; level is one of debug, info, warn or error.
+func newLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: use debug, info, warn or error", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q: use text or json", format)
+	}
+}
+
+// logToolCall records the outcome of a tools/call. userEmail is empty in
+// stdio mode.
+func logToolCall(userEmail, tool string, start time.Time, err error) {
+	attrs := []any{"tool", tool, "duration", time.Since(start)}
+	if userEmail != "" {
+		attrs = append(attrs, "user", userEmail)
+	}
+	if err != nil {
+		slog.Warn("tool call failed", append(attrs, "error", err)...)
+		return
+	}
+	slog.Info("tool call", attrs...)
+}
+
+// requestLog collects what handlers learn about an HTTP request, such as the
+// user and the JSON-RPC methods and tools of a /mcp call, for its log line.
+type requestLog struct {
+	mu      sync.Mutex
+	user    string
+	methods []string
+	tools   []string
+}
+
+type requestLogKey struct{}
+
+// noteRequestUser records the authenticated user of the request in ctx.
+func noteRequestUser(ctx context.Context, userEmail string) {
+	if rl, ok := ctx.Value(requestLogKey{}).(*requestLog); ok {
+		rl.mu.Lock()
+		rl.user = userEmail
+		rl.mu.Unlock()
+	}
+}
+
+// noteRequestRPC records a JSON-RPC method, and for tools/call the tool, of
+// the request in ctx. A batch records one entry per message.
+func noteRequestRPC(ctx context.Context, method, tool string) {
+	if rl, ok := ctx.Value(requestLogKey{}).(*requestLog); ok {
+		rl.mu.Lock()
+		if method != "" {
+			rl.methods = append(rl.methods, method)
+		}
+		if tool != "" {
+			rl.tools = append(rl.tools, tool)
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// statusRecorder captures the status and size of a response. Unwrap lets
+// http.ResponseController reach the underlying writer, so SSE flushing and
+// write deadlines keep working.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// logRequests logs one line per HTTP request with its method, path, status
+// and duration. Only the path is logged: query strings carry OAuth codes and
+// headers carry Authorization, so neither is. Server errors log at warn level
+// and health checks at debug.
+func logRequests(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rl := &requestLog{}
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestLogKey{}, rl)))
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		attrs := []any{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"duration", time.Since(start),
+			"bytes", rec.bytes,
+		}
+		rl.mu.Lock()
+		if rl.user != "" {
+			attrs = append(attrs, "user", rl.user)
+		}
+		if len(rl.methods) > 0 {
+			attrs = append(attrs, "rpc_method", strings.Join(rl.methods, ","))
+		}
+		if len(rl.tools) > 0 {
+			attrs = append(attrs, "tool", strings.Join(rl.tools, ","))
+		}
+		rl.mu.Unlock()
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelWarn
+		case r.URL.Path == "/health":
+			level = slog.LevelDebug
+		}
+		logger.Log(r.Context(), level, "http request", attrs...)
+	})
+}
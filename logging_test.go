@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "json", "warn")
+	if err != nil {
+		t.Fatalf("newLogger() error = %v", err)
+	}
+	logger.Info("tool call", "tool", "list-events")
+	logger.Warn("tool call failed", "tool", "list-events", "user", "alice@example.com")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("logged %d lines, want only the warning: %q", len(lines), buf.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v (%s)", err, lines[0])
+	}
+	if entry["level"] != "WARN" || entry["msg"] != "tool call failed" || entry["user"] != "alice@example.com" {
+		t.Fatalf("log entry = %v", entry)
+	}
+}
+
+func TestNewLogger_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		format string
+		level  string
+	}{
+		{"unknown format", "xml", "info"},
+		{"unknown level", "text", "verbose"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := newLogger(&bytes.Buffer{}, tt.format, tt.level); err == nil {
+				t.Fatalf("newLogger(%q, %q) error = nil, want error", tt.format, tt.level)
+			}
+		})
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "json", "info")
+	if err != nil {
+		t.Fatalf("newLogger() error = %v", err)
+	}
+	h := &HTTPServer{}
+	handler := logRequests(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.handleMCPRequest(w, r, "alice@example.com")
+	}))
+
+	batch := `[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"get-current-time"}}]`
+	req := httptest.NewRequest(http.MethodPost, "/mcp?code=secret-code", strings.NewReader(batch))
+	req.Header.Set("Authorization", "Bearer secret-token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v (%s)", err, buf.String())
+	}
+	want := map[string]any{
+		"msg":        "http request",
+		"method":     "POST",
+		"path":       "/mcp",
+		"status":     float64(http.StatusOK),
+		"user":       "alice@example.com",
+		"rpc_method": "ping,tools/call",
+		"tool":       "get-current-time",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("log %s = %v, want %v", key, entry[key], value)
+		}
+	}
+	if _, ok := entry["duration"]; !ok {
+		t.Error("log entry has no duration")
+	}
+	for _, secret := range []string{"secret-code", "secret-token"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("log line contains %q: %s", secret, buf.String())
+		}
+	}
+}
+
+func TestLogRequests_StatusLevels(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "json", "info")
+	if err != nil {
+		t.Fatalf("newLogger() error = %v", err)
+	}
+	handler := logRequests(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("logged %d lines, want only the failure (health checks are debug): %q", len(lines), buf.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	if entry["level"] != "WARN" || entry["status"] != float64(http.StatusInternalServerError) {
+		t.Fatalf("log entry = %v, want a warning with status 500", entry)
+	}
+}
@@ -4,12 +4,27 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"syscall"
+	"text/tabwriter"
+	"time"
 )
 
+// defaultMaxRequestBytes is the default --max-request-bytes. A send-email
+// call carrying Gmail's full 25 MiB of attachments is about 34 MiB once
+// base64 encoded, so the limit leaves room for that and the JSON around it.
+const defaultMaxRequestBytes = 40 << 20
+
+// defaultDBPath returns the database path used when --db is not given:
+// $MCP_GCAL_DB, else mcp-gcal.db in the XDG config directory.
 func defaultDBPath() string {
+	if p := os.Getenv("MCP_GCAL_DB"); p != "" {
+		return p
+	}
 	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
 		return filepath.Join(xdg, "mcp-gcal", "mcp-gcal.db")
 	}
@@ -19,20 +34,82 @@ func defaultDBPath() string {
 
 func main() {
 	// Check for subcommand
-	if len(os.Args) > 1 && os.Args[1] == "auth" {
-		runAuthCommand()
-		return
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "auth":
+			runAuthCommand()
+			return
+		case "vacuum":
+			runVacuumCommand()
+			return
+		case "logout":
+			runLogoutCommand()
+			return
+		case "encrypt-tokens":
+			runEncryptTokensCommand()
+			return
+		case "users":
+			runUsersCommand()
+			return
+		}
 	}
 
 	// Default: run MCP server
 	runServer()
 }
 
+// dbKeyFileUsage describes the --db-key-file flag shared by commands that
+// read or write Google tokens.
+const dbKeyFileUsage = "File holding the base64 key Google tokens are encrypted with (env MCP_GCAL_DB_KEY_FILE; MCP_GCAL_DB_KEY takes precedence)"
+
+// applyTokenKey makes database encrypt Google tokens with the key from
+// $MCP_GCAL_DB_KEY or keyFile. With neither set, tokens stay in plaintext.
+func applyTokenKey(database *DB, keyFile string) error {
+	key, err := loadTokenKey(os.Getenv("MCP_GCAL_DB_KEY"), keyFile)
+	if err != nil {
+		return err
+	}
+	database.SetTokenKey(key)
+	return nil
+}
+
+// accountUsage describes the --account flag of stdio mode commands.
+const accountUsage = "Named Google account, e.g. work or personal (stdio mode)"
+
+// gmailFullAccessUsage describes the --gmail-full-access flag.
+const gmailFullAccessUsage = "Request full Gmail access instead of gmail.modify at sign-in (required by permanently-delete-email)"
+
+// scopesUsage describes the --scopes flag.
+const scopesUsage = "OAuth scopes to request at sign-in: full, or readonly for read-only Calendar, Gmail and Tasks access"
+
+// exitOnInvalidScopes exits with an error if scopeSet is not a valid --scopes
+// value or conflicts with --gmail-full-access.
+func exitOnInvalidScopes(scopeSet string, gmailFullAccess bool) {
+	if err := validateScopeSet(scopeSet, gmailFullAccess); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --scopes: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+// exitOnInvalidAccount exits with an error if account is not a valid account name.
+func exitOnInvalidAccount(account string) {
+	if err := validateAccountName(account); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+}
+
 func runAuthCommand() {
 	fs := flag.NewFlagSet("auth", flag.ExitOnError)
-	dbPath := fs.String("db", defaultDBPath(), "SQLite database path")
-	credFile := fs.String("credentials-file", "", "Path to OAuth2 credentials JSON file")
+	dbPath := fs.String("db", defaultDBPath(), "SQLite database path (env MCP_GCAL_DB)")
+	credFile := fs.String("credentials-file", "", "Path to OAuth2 credentials JSON file (env MCP_GCAL_CREDENTIALS)")
+	dbKeyFile := fs.String("db-key-file", os.Getenv("MCP_GCAL_DB_KEY_FILE"), dbKeyFileUsage)
+	account := fs.String("account", defaultAccount, accountUsage)
+	gmailFullAccess := fs.Bool("gmail-full-access", false, gmailFullAccessUsage)
+	scopes := fs.String("scopes", scopeSetFull, scopesUsage)
 	fs.Parse(os.Args[2:])
+	exitOnInvalidAccount(*account)
+	exitOnInvalidScopes(*scopes, *gmailFullAccess)
 
 	if err := os.MkdirAll(filepath.Dir(*dbPath), 0700); err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating config directory: %v\n", err)
@@ -45,8 +122,12 @@ func runAuthCommand() {
 		os.Exit(1)
 	}
 	defer database.Close()
+	if err := applyTokenKey(database, *dbKeyFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	config, err := loadOAuthConfig(*credFile, oauthScopes)
+	config, err := loadOAuthConfig(*credFile, selectScopes(oauthScopes, *scopes, *gmailFullAccess))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -58,58 +139,282 @@ func runAuthCommand() {
 		os.Exit(1)
 	}
 
-	if err := database.SaveToken(tok); err != nil {
+	if err := database.SaveToken(*account, tok); err != nil {
 		fmt.Fprintf(os.Stderr, "Error saving token: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Fprintf(os.Stderr, "Authentication successful! Token saved to %s\n", *dbPath)
+	fmt.Fprintf(os.Stderr, "Authentication successful! Token for account %q saved to %s\n", *account, *dbPath)
+}
+
+func runLogoutCommand() {
+	fs := flag.NewFlagSet("logout", flag.ExitOnError)
+	dbPath := fs.String("db", defaultDBPath(), "SQLite database path (env MCP_GCAL_DB)")
+	account := fs.String("account", defaultAccount, accountUsage)
+	fs.Parse(os.Args[2:])
+	exitOnInvalidAccount(*account)
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	database, err := NewDB(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	if err := database.DeleteToken(*account); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Logged out: token for account %q removed from %s. Run 'mcp-gcal auth' to sign in again.\n", *account, *dbPath)
+}
+
+func runEncryptTokensCommand() {
+	fs := flag.NewFlagSet("encrypt-tokens", flag.ExitOnError)
+	dbPath := fs.String("db", defaultDBPath(), "SQLite database path (env MCP_GCAL_DB)")
+	dbKeyFile := fs.String("db-key-file", os.Getenv("MCP_GCAL_DB_KEY_FILE"), dbKeyFileUsage)
+	fs.Parse(os.Args[2:])
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	database, err := NewDB(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+	if err := applyTokenKey(database, *dbKeyFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	n, err := database.EncryptTokens()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Encrypted %d plaintext token(s) in %s\n", n, *dbPath)
+}
+
+// runUsersCommand lists or revokes the users of an HTTP mode deployment.
+func runUsersCommand() {
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, "Usage: mcp-gcal users list [--db=PATH]\n       mcp-gcal users revoke --email=EMAIL [--db=PATH]")
+		os.Exit(2)
+	}
+	action := os.Args[2]
+	fs := flag.NewFlagSet("users "+action, flag.ExitOnError)
+	dbPath := fs.String("db", defaultDBPath(), "SQLite database path (env MCP_GCAL_DB)")
+	email := fs.String("email", "", "Email of the user to revoke (revoke only)")
+	fs.Parse(os.Args[3:])
+
+	if action != "list" && action != "revoke" {
+		fmt.Fprintf(os.Stderr, "Error: unknown users command %q (want list or revoke)\n", action)
+		os.Exit(2)
+	}
+	if action == "revoke" && *email == "" {
+		fmt.Fprintln(os.Stderr, "Error: --email is required")
+		os.Exit(2)
+	}
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	database, err := NewDB(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	if action == "revoke" {
+		if err := database.DeleteUser(*email); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Revoked %s: user and MCP tokens removed from %s\n", *email, *dbPath)
+		return
+	}
+
+	users, err := database.ListUsers()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := writeUsersTable(os.Stdout, users); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// writeUsersTable prints users as an aligned EMAIL / CREATED_AT table.
+func writeUsersTable(w io.Writer, users []UserSummary) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "EMAIL\tCREATED_AT")
+	for _, u := range users {
+		fmt.Fprintf(tw, "%s\t%s\n", u.Email, u.CreatedAt)
+	}
+	return tw.Flush()
+}
+
+func runVacuumCommand() {
+	fs := flag.NewFlagSet("vacuum", flag.ExitOnError)
+	dbPath := fs.String("db", defaultDBPath(), "SQLite database path (env MCP_GCAL_DB)")
+	fs.Parse(os.Args[2:])
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	database, err := NewDB(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	if _, _, err := database.CleanupExpiredMCPData(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error cleaning up expired data: %v\n", err)
+		os.Exit(1)
+	}
+
+	before, after, err := database.Vacuum()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Vacuumed %s: %d -> %d bytes (reclaimed %d bytes)\n", *dbPath, before, after, before-after)
 }
 
 func runServer() {
-	dbPath := flag.String("db", defaultDBPath(), "SQLite database path")
-	credFile := flag.String("credentials-file", "", "Path to OAuth2 credentials JSON file")
+	dbPath := flag.String("db", defaultDBPath(), "SQLite database path (env MCP_GCAL_DB)")
+	credFile := flag.String("credentials-file", "", "Path to OAuth2 credentials JSON file (env MCP_GCAL_CREDENTIALS)")
+	dbKeyFile := flag.String("db-key-file", os.Getenv("MCP_GCAL_DB_KEY_FILE"), dbKeyFileUsage)
 	mode := flag.String("mode", "stdio", "Server mode: stdio (single-user) or http (multi-user)")
+	account := flag.String("account", defaultAccount, "Google account tools use when not given an account argument (stdio mode only)")
 	addr := flag.String("addr", ":8080", "HTTP listen address (http mode only)")
 	baseURL := flag.String("base-url", "", "Public base URL for OAuth callback (http mode only, default derived from --addr)")
+	maxResultsCeiling := flag.Int64("max-results-ceiling", 250, "Upper limit for max_results on list and search tools (0 = no limit)")
+	fallbackCredFile := flag.String("fallback-credentials-file", "", "Previous OAuth2 credentials JSON, used to refresh tokens during credential rotation")
+	compactOutput := flag.Bool("compact-output", false, "Drop empty fields from tool results to reduce tokens")
+	maxAttachments := flag.Int("max-attachments", 25, "Maximum number of attachments on an outgoing email (0 = no limit)")
+	calendarListTTL := flag.Duration("calendar-list-ttl", time.Minute, "How long list-calendars results are cached per user (0 = no cache)")
+	maxRequestBytes := flag.Int64("max-request-bytes", defaultMaxRequestBytes, "Maximum HTTP request body size in bytes; the default fits 25 MiB of email attachments (http mode only, 0 = no limit)")
+	readTimeout := flag.Duration("read-timeout", 30*time.Second, "Maximum time to read an HTTP request, including the body (http mode only, 0 = no limit)")
+	writeTimeout := flag.Duration("write-timeout", 2*time.Minute, "Maximum time to write an HTTP response (http mode only, 0 = no limit)")
+	idleTimeout := flag.Duration("idle-timeout", 2*time.Minute, "Maximum time an idle keep-alive connection stays open (http mode only, 0 = no limit)")
+	gmailFullAccess := flag.Bool("gmail-full-access", false, gmailFullAccessUsage)
+	scopes := flag.String("scopes", scopeSetFull, scopesUsage)
+	timezone := flag.String("timezone", os.Getenv("MCP_GCAL_TIMEZONE"), "Default IANA timezone for calendar tools, e.g. Asia/Tokyo (env MCP_GCAL_TIMEZONE; default: UTC)")
+	cleanupInterval := flag.Duration("cleanup-interval", 15*time.Minute, "How often expired OAuth sessions and tokens are deleted (http mode only, 0 = never)")
+	requestTimeout := flag.Duration("request-timeout", 30*time.Second, "Maximum time for each Google API call, retries included (0 = no limit)")
+	introspectionSecret := flag.String("introspection-secret", os.Getenv("MCP_GCAL_INTROSPECTION_SECRET"), "Bearer token required to call /oauth/introspect (http mode only, env MCP_GCAL_INTROSPECTION_SECRET; empty disables introspection)")
+	locale := flag.String("locale", systemLocale(), "Locale for dates and times in UIs, e.g. en-GB or ja-JP (default: system locale)")
+	logFormat := flag.String("log-format", "text", "Log format: text or json")
+	logLevel := flag.String("log-level", "info", "Minimum log level: debug, info, warn or error")
 	flag.Parse()
 
+	// Logs go to stderr: in stdio mode stdout carries JSON-RPC.
+	logger, err := newLogger(os.Stderr, *logFormat, *logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
+	if *locale != "" {
+		normalized, err := normalizeLocale(*locale)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		*locale = normalized
+	}
+
+	exitOnInvalidAccount(*account)
+	exitOnInvalidScopes(*scopes, *gmailFullAccess)
+	if err := validateTimezone(*timezone); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --timezone: %v\n", err)
+		os.Exit(2)
+	}
+
+	opts := Options{
+		MaxResultsCeiling:       *maxResultsCeiling,
+		FallbackCredentialsFile: *fallbackCredFile,
+		CompactOutput:           *compactOutput,
+		MaxAttachments:          *maxAttachments,
+		Locale:                  *locale,
+		CalendarListTTL:         *calendarListTTL,
+		MaxRequestBytes:         *maxRequestBytes,
+		ReadTimeout:             *readTimeout,
+		WriteTimeout:            *writeTimeout,
+		IdleTimeout:             *idleTimeout,
+		CleanupInterval:         *cleanupInterval,
+		IntrospectionSecret:     *introspectionSecret,
+		RequestTimeout:          *requestTimeout,
+		Account:                 *account,
+		GmailFullAccess:         *gmailFullAccess,
+		Timezone:                *timezone,
+		Scopes:                  *scopes,
+	}
+
 	if err := os.MkdirAll(filepath.Dir(*dbPath), 0700); err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating config directory: %v\n", err)
+		slog.Error("create config directory failed", "error", err)
 		os.Exit(1)
 	}
 
 	database, err := NewDB(*dbPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		slog.Error("open database failed", "path", *dbPath, "error", err)
 		os.Exit(1)
 	}
 	defer database.Close()
+	if err := applyTokenKey(database, *dbKeyFile); err != nil {
+		slog.Error("load token key failed", "error", err)
+		os.Exit(1)
+	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	switch *mode {
 	case "stdio":
-		server := NewServer(database, *credFile)
+		// stdout is the JSON-RPC channel; everything else goes to stderr.
+		stdout, restoreStdout := redirectStdout()
+		defer restoreStdout()
+		server := NewServer(database, *credFile, opts)
+		server.writer = stdout
 		if err := server.Run(ctx); err != nil {
-			fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
+			slog.Error("server error", "error", err)
 			os.Exit(1)
 		}
 
 	case "http":
-		server, err := NewHTTPServer(database, *credFile, *addr, *baseURL)
+		server, err := NewHTTPServer(database, *credFile, *addr, *baseURL, opts)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating HTTP server: %v\n", err)
+			slog.Error("create HTTP server failed", "error", err)
 			os.Exit(1)
 		}
 		if err := server.Run(ctx); err != nil {
-			fmt.Fprintf(os.Stderr, "HTTP server error: %v\n", err)
+			slog.Error("HTTP server error", "error", err)
 			os.Exit(1)
 		}
 
 	default:
-		fmt.Fprintf(os.Stderr, "Unknown mode: %s (use stdio or http)\n", *mode)
+		slog.Error("unknown mode (use stdio or http)", "mode", *mode)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPathPrecedence sets environment variables, so it must not run in
+// parallel with other tests.
+func TestPathPrecedence(t *testing.T) {
+	tests := []struct {
+		name        string
+		flagName    string
+		envKey      string
+		defaultPath func() string
+		file        string
+	}{
+		{"db", "db", "MCP_GCAL_DB", defaultDBPath, "mcp-gcal.db"},
+		{"credentials", "credentials-file", "MCP_GCAL_CREDENTIALS", defaultCredentialsPath, "credentials.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			t.Setenv("XDG_CONFIG_HOME", "")
+			t.Setenv(tt.envKey, "")
+
+			// Without XDG_CONFIG_HOME or the variable, the path is under
+			// ~/.config; XDG_CONFIG_HOME replaces ~/.config, and the
+			// variable replaces the whole path.
+			if got, want := tt.defaultPath(), filepath.Join(home, ".config", "mcp-gcal", tt.file); got != want {
+				t.Fatalf("home default = %q, want %q", got, want)
+			}
+			xdg := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", xdg)
+			if got, want := tt.defaultPath(), filepath.Join(xdg, "mcp-gcal", tt.file); got != want {
+				t.Fatalf("XDG default = %q, want %q", got, want)
+			}
+			t.Setenv(tt.envKey, "/env/"+tt.file)
+			if got := tt.defaultPath(); got != "/env/"+tt.file {
+				t.Fatalf("with %s = %q, want env path", tt.envKey, got)
+			}
+
+			// A flag, whose default is the path above, overrides them all.
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			path := fs.String(tt.flagName, tt.defaultPath(), "")
+			if err := fs.Parse([]string{"-" + tt.flagName, "/flag/" + tt.file}); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if *path != "/flag/"+tt.file {
+				t.Fatalf("with flag and env = %q, want flag path", *path)
+			}
+		})
+	}
+}
+
+// TestLoadOAuthConfig_DefaultPath sets environment variables, so it must not
+// run in parallel with other tests.
+func TestLoadOAuthConfig_DefaultPath(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	t.Setenv("MCP_GCAL_CREDENTIALS", "")
+	want := filepath.Join(xdg, "mcp-gcal", "credentials.json")
+
+	// An empty path falls back to the default, which is named when missing.
+	_, err := loadOAuthConfig("", nil)
+	var notFound *credentialsNotFoundError
+	if !errors.As(err, &notFound) || notFound.path != want {
+		t.Fatalf("loadOAuthConfig(\"\") error = %v, want not found at %s", err, want)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(want), 0o700); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	creds := `{"installed":{"client_id":"xdg-client","client_secret":"s","auth_uri":"https://accounts.google.com/o/oauth2/auth","token_uri":"https://oauth2.googleapis.com/token","redirect_uris":["http://localhost"]}}`
+	if err := os.WriteFile(want, []byte(creds), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	config, err := loadOAuthConfig("", nil)
+	if err != nil {
+		t.Fatalf("loadOAuthConfig() error = %v", err)
+	}
+	if config.ClientID != "xdg-client" {
+		t.Fatalf("ClientID = %q, want the default file's xdg-client", config.ClientID)
+	}
+
+	// An explicit path is used as given.
+	_, err = loadOAuthConfig(filepath.Join(xdg, "other.json"), nil)
+	if !errors.As(err, &notFound) || notFound.path != filepath.Join(xdg, "other.json") {
+		t.Fatalf("loadOAuthConfig(other) error = %v, want not found at other.json", err)
+	}
+}
+
+func TestWriteUsersTable(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	err := writeUsersTable(&out, []UserSummary{
+		{Email: "alice@example.com", CreatedAt: "2025-03-10 09:00:00"},
+		{Email: "bo@example.com", CreatedAt: "2025-03-11 10:30:00"},
+	})
+	if err != nil {
+		t.Fatalf("writeUsersTable() error = %v", err)
+	}
+	want := "EMAIL              CREATED_AT\n" +
+		"alice@example.com  2025-03-10 09:00:00\n" +
+		"bo@example.com     2025-03-11 10:30:00\n"
+	if out.String() != want {
+		t.Fatalf("writeUsersTable() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -7,9 +7,9 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -24,16 +24,24 @@ const (
 
 // handleOAuthMetadata serves RFC 8414 OAuth Authorization Server Metadata.
 func (h *HTTPServer) handleOAuthMetadata(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]interface{}{
+	metadata := map[string]interface{}{
 		"issuer":                                h.baseURL,
 		"authorization_endpoint":                h.baseURL + "/oauth/authorize",
 		"token_endpoint":                        h.baseURL + "/oauth/token",
 		"registration_endpoint":                 h.baseURL + "/oauth/register",
+		"revocation_endpoint":                   h.baseURL + "/oauth/revoke",
 		"response_types_supported":              []string{"code"},
 		"grant_types_supported":                 []string{"authorization_code", "refresh_token"},
-		"token_endpoint_auth_methods_supported": []string{"none"},
+		"token_endpoint_auth_methods_supported": []string{"none", "client_secret_basic", "client_secret_post"},
 		"code_challenge_methods_supported":      []string{"S256"},
-	})
+
+		"revocation_endpoint_auth_methods_supported": []string{"none"},
+	}
+	if h.opts.IntrospectionSecret != "" {
+		metadata["introspection_endpoint"] = h.baseURL + "/oauth/introspect"
+		metadata["introspection_endpoint_auth_methods_supported"] = []string{"bearer"}
+	}
+	writeJSON(w, http.StatusOK, metadata)
 }
 
 // handleProtectedResourceMetadata serves RFC 9728 Protected Resource Metadata.
@@ -49,10 +57,16 @@ func (h *HTTPServer) handleProtectedResourceMetadata(w http.ResponseWriter, r *h
 // handleOAuthRegister implements RFC 7591 Dynamic Client Registration.
 func (h *HTTPServer) handleOAuthRegister(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		RedirectURIs []string `json:"redirect_uris"`
-		ClientName   string   `json:"client_name"`
+		RedirectURIs            []string `json:"redirect_uris"`
+		ClientName              string   `json:"client_name"`
+		TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
 	}
+	h.limitRequestBody(w, r)
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if isRequestTooLarge(err) {
+			writeOAuthError(w, http.StatusRequestEntityTooLarge, "invalid_request", "request body too large")
+			return
+		}
 		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
 		return
 	}
@@ -74,19 +88,40 @@ func (h *HTTPServer) handleOAuthRegister(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	clientID, err := h.database.RegisterMCPClient(req.ClientName, req.RedirectURIs)
+	// Public clients rely on PKCE alone; confidential ones also get a secret
+	// they must present at the token endpoint.
+	var confidential bool
+	switch req.TokenEndpointAuthMethod {
+	case "":
+		req.TokenEndpointAuthMethod = "none"
+	case "none":
+	case "client_secret_basic", "client_secret_post":
+		confidential = true
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "invalid_client_metadata",
+			fmt.Sprintf("unsupported token_endpoint_auth_method: %s", req.TokenEndpointAuthMethod))
+		return
+	}
+
+	clientID, clientSecret, err := h.database.RegisterMCPClient(req.ClientName, req.RedirectURIs, confidential)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] Register MCP client: %v\n", err)
+		slog.Error("register MCP client failed", "error", err)
 		writeOAuthError(w, http.StatusInternalServerError, "server_error", "failed to register client")
 		return
 	}
 
-	writeJSON(w, http.StatusCreated, map[string]interface{}{
-		"client_id":                    clientID,
-		"client_name":                  req.ClientName,
-		"redirect_uris":               req.RedirectURIs,
-		"token_endpoint_auth_method":   "none",
-	})
+	resp := map[string]interface{}{
+		"client_id":                  clientID,
+		"client_name":                req.ClientName,
+		"redirect_uris":              req.RedirectURIs,
+		"token_endpoint_auth_method": req.TokenEndpointAuthMethod,
+	}
+	if confidential {
+		// The secret is only ever returned here; just its hash is stored.
+		resp["client_secret"] = clientSecret
+		resp["client_secret_expires_at"] = 0
+	}
+	writeJSON(w, http.StatusCreated, resp)
 }
 
 // --- Authorization Endpoint ---
@@ -111,7 +146,7 @@ func (h *HTTPServer) handleOAuthAuthorize(w http.ResponseWriter, r *http.Request
 	// Validate client_id
 	client, err := h.database.GetMCPClient(clientID)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] Get MCP client: %v\n", err)
+		slog.Error("get MCP client failed", "client_id", clientID, "error", err)
 		writeOAuthError(w, http.StatusInternalServerError, "server_error", "database error")
 		return
 	}
@@ -123,7 +158,7 @@ func (h *HTTPServer) handleOAuthAuthorize(w http.ResponseWriter, r *http.Request
 	// Validate redirect_uri
 	validRedirect := false
 	for _, uri := range client.RedirectURIs {
-		if uri == redirectURI {
+		if redirectURIMatches(uri, redirectURI) {
 			validRedirect = true
 			break
 		}
@@ -153,7 +188,7 @@ func (h *HTTPServer) handleOAuthAuthorize(w http.ResponseWriter, r *http.Request
 	// Save session
 	expiresAt := time.Now().UTC().Add(mcpAuthSessionExpiration)
 	if err := h.database.CreateAuthSession(googleState, clientID, redirectURI, codeChallenge, "S256", mcpState, expiresAt); err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] Create auth session: %v\n", err)
+		slog.Error("create auth session failed", "client_id", clientID, "error", err)
 		writeOAuthError(w, http.StatusInternalServerError, "server_error", "failed to create session")
 		return
 	}
@@ -163,11 +198,45 @@ func (h *HTTPServer) handleOAuthAuthorize(w http.ResponseWriter, r *http.Request
 	http.Redirect(w, r, authURL, http.StatusFound)
 }
 
+// redirectURIMatches reports whether redirectURI may be used for a client
+// that registered registered. URIs must match exactly, except that the port
+// of an http loopback URI is ignored: native clients listen on whatever port
+// is free at the time (RFC 8252 section 7.3).
+func redirectURIMatches(registered, redirectURI string) bool {
+	if registered == redirectURI {
+		return true
+	}
+	reg, err := url.Parse(registered)
+	if err != nil || reg.Scheme != "http" || !isLoopbackHost(reg.Hostname()) {
+		return false
+	}
+	got, err := url.Parse(redirectURI)
+	if err != nil {
+		return false
+	}
+	return got.Scheme == reg.Scheme &&
+		got.Hostname() == reg.Hostname() &&
+		got.User == nil && reg.User == nil &&
+		got.EscapedPath() == reg.EscapedPath() &&
+		got.RawQuery == reg.RawQuery &&
+		got.Fragment == reg.Fragment
+}
+
+// isLoopbackHost reports whether host names the loopback interface.
+func isLoopbackHost(host string) bool {
+	return host == "127.0.0.1" || host == "::1" || host == "localhost"
+}
+
 // --- Token Endpoint ---
 
 // handleOAuthToken implements the OAuth 2.0 token endpoint.
 func (h *HTTPServer) handleOAuthToken(w http.ResponseWriter, r *http.Request) {
+	h.limitRequestBody(w, r)
 	if err := r.ParseForm(); err != nil {
+		if isRequestTooLarge(err) {
+			writeOAuthError(w, http.StatusRequestEntityTooLarge, "invalid_request", "request body too large")
+			return
+		}
 		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "failed to parse form")
 		return
 	}
@@ -183,16 +252,72 @@ func (h *HTTPServer) handleOAuthToken(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// authenticateClient returns the client_id of a token request, sent via
+// HTTP Basic (client_secret_basic) or the form. A client registered with a
+// secret must present it, via Basic or the form's client_secret
+// (client_secret_post); public clients need only their client_id. On
+// failure it writes the error response and returns false.
+func (h *HTTPServer) authenticateClient(w http.ResponseWriter, r *http.Request) (string, bool) {
+	clientID, secret := r.FormValue("client_id"), r.FormValue("client_secret")
+	user, pass, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749 section 2.3.1: Basic credentials are form-urlencoded.
+		basicID, err1 := url.QueryUnescape(user)
+		basicSecret, err2 := url.QueryUnescape(pass)
+		if err1 != nil || err2 != nil || (clientID != "" && clientID != basicID) {
+			writeClientAuthError(w, basic, "invalid client credentials")
+			return "", false
+		}
+		clientID, secret = basicID, basicSecret
+	}
+	if clientID == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "client_id is required")
+		return "", false
+	}
+
+	client, err := h.database.GetMCPClient(clientID)
+	if err != nil {
+		slog.Error("get MCP client failed", "client_id", clientID, "error", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "database error")
+		return "", false
+	}
+	if client == nil || client.ClientSecretHash == nil {
+		// Public (or unknown) clients are checked by the grant itself.
+		return clientID, true
+	}
+	if secret == "" {
+		writeClientAuthError(w, basic, "client_secret is required for this client")
+		return "", false
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(*client.ClientSecretHash)) != 1 {
+		writeClientAuthError(w, basic, "invalid client credentials")
+		return "", false
+	}
+	return clientID, true
+}
+
+// writeClientAuthError writes an invalid_client error, challenging for Basic
+// credentials if the client used them, as RFC 6749 section 5.2 requires.
+func writeClientAuthError(w http.ResponseWriter, basic bool, description string) {
+	if basic {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	writeOAuthError(w, http.StatusUnauthorized, "invalid_client", description)
+}
+
 func (h *HTTPServer) handleAuthorizationCodeGrant(w http.ResponseWriter, r *http.Request) {
 	code := r.FormValue("code")
 	codeVerifier := r.FormValue("code_verifier")
-	clientID := r.FormValue("client_id")
 	redirectURI := r.FormValue("redirect_uri")
 
-	if code == "" || codeVerifier == "" || clientID == "" {
+	if code == "" || codeVerifier == "" {
 		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "code, code_verifier, and client_id are required")
 		return
 	}
+	clientID, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
 
 	// Consume auth code
 	session, err := h.database.ConsumeAuthCode(hashToken(code))
@@ -220,7 +345,7 @@ func (h *HTTPServer) handleAuthorizationCodeGrant(w http.ResponseWriter, r *http
 	// Issue tokens
 	accessToken, refreshToken, err := h.database.CreateMCPToken(clientID, session.UserEmail)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] Create MCP token: %v\n", err)
+		slog.Error("create MCP token failed", "client_id", clientID, "user", session.UserEmail, "error", err)
 		writeOAuthError(w, http.StatusInternalServerError, "server_error", "failed to create token")
 		return
 	}
@@ -235,12 +360,15 @@ func (h *HTTPServer) handleAuthorizationCodeGrant(w http.ResponseWriter, r *http
 
 func (h *HTTPServer) handleRefreshTokenGrant(w http.ResponseWriter, r *http.Request) {
 	refreshToken := r.FormValue("refresh_token")
-	clientID := r.FormValue("client_id")
 
-	if refreshToken == "" || clientID == "" {
+	if refreshToken == "" {
 		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "refresh_token and client_id are required")
 		return
 	}
+	clientID, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
 
 	newAccess, newRefresh, err := h.database.RefreshMCPToken(refreshToken, clientID)
 	if err != nil {
@@ -256,6 +384,89 @@ func (h *HTTPServer) handleRefreshTokenGrant(w http.ResponseWriter, r *http.Requ
 	})
 }
 
+// --- Revocation Endpoint ---
+
+// handleOAuthRevoke implements RFC 7009 token revocation. The client
+// authenticates as at the token endpoint, and only tokens issued to it are
+// revoked (section 2.1). Revoking either token of a pair revokes both.
+// token_type_hint is accepted but not needed, as both kinds of token are
+// looked up. Unknown tokens, and other clients' tokens, still get 200, so
+// the response reveals nothing about the token.
+func (h *HTTPServer) handleOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	h.limitRequestBody(w, r)
+	if err := r.ParseForm(); err != nil {
+		if isRequestTooLarge(err) {
+			writeOAuthError(w, http.StatusRequestEntityTooLarge, "invalid_request", "request body too large")
+			return
+		}
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "failed to parse form")
+		return
+	}
+
+	token := r.FormValue("token")
+	if token == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+	clientID, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+	if err := h.database.RevokeMCPToken(hashToken(token), clientID); err != nil {
+		slog.Error("revoke MCP token failed", "error", err)
+		writeOAuthError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "failed to revoke token")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// --- Introspection Endpoint ---
+
+// handleOAuthIntrospect implements RFC 7662 token introspection for MCP
+// access tokens. Callers must present Options.IntrospectionSecret as a
+// Bearer token; without a configured secret the endpoint is disabled.
+// Refresh tokens, and unknown or expired access tokens, are reported inactive.
+func (h *HTTPServer) handleOAuthIntrospect(w http.ResponseWriter, r *http.Request) {
+	secret := h.opts.IntrospectionSecret
+	if secret == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(extractBearerToken(r)), []byte(secret)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="introspection"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "introspection requires the configured Bearer token")
+		return
+	}
+
+	h.limitRequestBody(w, r)
+	if err := r.ParseForm(); err != nil {
+		if isRequestTooLarge(err) {
+			writeOAuthError(w, http.StatusRequestEntityTooLarge, "invalid_request", "request body too large")
+			return
+		}
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "failed to parse form")
+		return
+	}
+	token := r.FormValue("token")
+	if token == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	t, err := h.database.LookupMCPAccessToken(token)
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"active": false})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"active":     true,
+		"sub":        t.UserEmail,
+		"client_id":  t.ClientID,
+		"exp":        t.ExpiresAt.Unix(),
+		"token_type": "Bearer",
+	})
+}
+
 // --- MCP Auth Callback Handler ---
 
 // handleMCPAuthCallback is called from handleAuthCallback when the state
@@ -276,7 +487,7 @@ func (h *HTTPServer) handleMCPAuthCallback(w http.ResponseWriter, r *http.Reques
 	// Exchange Google auth code for token
 	tok, err := h.oauthConfig.Exchange(context.Background(), code)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] MCP OAuth exchange failed: %v\n", err)
+		slog.Error("MCP OAuth exchange failed", "client_id", session.ClientID, "error", err)
 		redirectWithError(w, r, session.RedirectURI, "server_error", "token exchange failed", session.MCPState)
 		return
 	}
@@ -284,19 +495,20 @@ func (h *HTTPServer) handleMCPAuthCallback(w http.ResponseWriter, r *http.Reques
 	// Fetch user email
 	email, err := fetchUserEmail(tok)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] MCP fetch email failed: %v\n", err)
+		slog.Error("MCP fetch user email failed", "client_id", session.ClientID, "error", err)
 		redirectWithError(w, r, session.RedirectURI, "server_error", "failed to get user email", session.MCPState)
 		return
 	}
 
 	// Save/update Google token in users table (reuse existing logic, ignore returned API key)
 	if _, err := h.database.CreateOrUpdateUser(email, tok); err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] MCP create user failed: %v\n", err)
+		slog.Error("MCP create user failed", "user", email, "error", err)
 		redirectWithError(w, r, session.RedirectURI, "server_error", "failed to create user", session.MCPState)
 		return
 	}
 
-	fmt.Fprintf(os.Stderr, "[INFO] MCP OAuth user authenticated: %s\n", email)
+	h.calendarCaches.invalidate(email)
+	slog.Info("user authenticated", "user", email, "flow", "mcp_oauth", "client_id", session.ClientID)
 
 	// Generate MCP auth code
 	mcpAuthCode, err := generateSecureToken(32)
@@ -307,7 +519,7 @@ func (h *HTTPServer) handleMCPAuthCallback(w http.ResponseWriter, r *http.Reques
 
 	// Store auth code hash and email in session
 	if err := h.database.SetAuthSessionCode(session.State, hashToken(mcpAuthCode), email); err != nil {
-		fmt.Fprintf(os.Stderr, "[ERROR] Set auth session code: %v\n", err)
+		slog.Error("set auth session code failed", "user", email, "error", err)
 		redirectWithError(w, r, session.RedirectURI, "server_error", "failed to save auth code", session.MCPState)
 		return
 	}
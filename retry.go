@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+)
+
+const (
+	// maxAPIAttempts caps how often a Google API request is sent, first
+	// attempt included.
+	maxAPIAttempts = 5
+	// retryBaseDelay and retryMaxDelay bound the exponential backoff between
+	// attempts.
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 8 * time.Second
+	// maxRetryAfter is the longest Retry-After wait honored; a longer one
+	// fails the call instead of holding it open.
+	maxRetryAfter = 30 * time.Second
+)
+
+// googleClientOption returns the client option Google API services are built
+// with: requests are authorized by ts and retried by retryTransport, each call
+// bounded by timeout (zero means no limit beyond the caller's context).
+func googleClientOption(ts oauth2.TokenSource, timeout time.Duration) option.ClientOption {
+	return option.WithHTTPClient(&http.Client{
+		Transport: &retryTransport{base: &oauth2.Transport{Source: ts}, timeout: timeout},
+	})
+}
+
+// retryTransport retries Google API requests that failed transiently, with
+// exponential backoff and jitter, or after the response's Retry-After.
+// Rate limited requests (429, or 403 with a rate limit reason) were not
+// processed and are always retried. Server errors (5xx) are only retried for
+// idempotent methods, so a create or send is never duplicated.
+type retryTransport struct {
+	base http.RoundTripper
+	// timeout bounds a call, retries and reading the response body included,
+	// under a context derived from the request's. Zero means no limit.
+	timeout time.Duration
+	// sleep waits d or until ctx is done; nil means sleepContext.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.roundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.roundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *retryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 {
+			attemptReq = req.Clone(ctx)
+			if req.Body != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err != nil || attempt == maxAPIAttempts || !t.retryable(req, resp) {
+			return resp, err
+		}
+		delay, ok := retryDelay(resp, attempt)
+		if !ok {
+			return resp, nil
+		}
+		slog.Debug("retrying Google API request", "method", req.Method, "url", req.URL.Redacted(),
+			"status", resp.StatusCode, "attempt", attempt, "delay", delay)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		sleep := t.sleep
+		if sleep == nil {
+			sleep = sleepContext
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// cancelOnClose releases a call's timeout context once its response body is
+// closed, since the body is read after RoundTrip returns.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retryable reports whether resp is a transient failure worth retrying req
+// for. A request whose body cannot be replayed is never retried.
+func (t *retryTransport) retryable(req *http.Request, resp *http.Response) bool {
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode == http.StatusForbidden:
+		return isRateLimitResponse(resp)
+	case resp.StatusCode >= 500:
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+			return true
+		}
+	}
+	return false
+}
+
+// isRateLimitResponse reports whether a Google API error response gives a
+// rate limit reason. The body is read and replaced, so it can still be
+// decoded afterwards.
+func isRateLimitResponse(resp *http.Response) bool {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	var apiErr struct {
+		Error struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &apiErr) != nil {
+		return false
+	}
+	for _, item := range apiErr.Error.Errors {
+		if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay returns how long to wait before the attempt after attempt: the
+// response's Retry-After if it has one, else exponential backoff with full
+// jitter. ok is false if Retry-After asks for longer than maxRetryAfter.
+func retryDelay(resp *http.Response, attempt int) (delay time.Duration, ok bool) {
+	if d, found := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); found {
+		return d, d <= maxRetryAfter
+	}
+	backoff := retryBaseDelay << (attempt - 1)
+	if backoff > retryMaxDelay {
+		backoff = retryMaxDelay
+	}
+	return rand.N(backoff) + 1, true
+}
+
+// parseRetryAfter parses a Retry-After value, in seconds or as an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// sleepContext waits d, returning early with ctx's error if ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newRetryTestClient returns a client retrying through retryTransport, the
+// URL of a server running handler, and the delays the client slept for.
+func newRetryTestClient(t *testing.T, handler http.HandlerFunc) (*http.Client, string, *[]time.Duration) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	var delays []time.Duration
+	transport := &retryTransport{
+		base: srv.Client().Transport,
+		sleep: func(ctx context.Context, d time.Duration) error {
+			delays = append(delays, d)
+			return ctx.Err()
+		},
+	}
+	return &http.Client{Transport: transport}, srv.URL, &delays
+}
+
+func TestRetryTransport(t *testing.T) {
+	t.Parallel()
+
+	rateLimited := `{"error":{"code":403,"errors":[{"reason":"rateLimitExceeded"}]}}`
+	forbidden := `{"error":{"code":403,"errors":[{"reason":"forbidden"}]}}`
+	tests := []struct {
+		name       string
+		method     string
+		failures   int
+		status     int
+		body       string
+		retryAfter string
+		wantCalls  int
+		wantStatus int
+	}{
+		{"429 then ok", http.MethodPost, 1, http.StatusTooManyRequests, "", "2", 2, http.StatusOK},
+		{"rate limit reason", http.MethodPost, 2, http.StatusForbidden, rateLimited, "", 3, http.StatusOK},
+		{"other 403", http.MethodGet, 1, http.StatusForbidden, forbidden, "", 1, http.StatusForbidden},
+		{"GET 503", http.MethodGet, 1, http.StatusServiceUnavailable, "", "", 2, http.StatusOK},
+		{"POST 503 not retried", http.MethodPost, 1, http.StatusServiceUnavailable, "", "", 1, http.StatusServiceUnavailable},
+		{"gives up after max attempts", http.MethodGet, 10, http.StatusInternalServerError, "", "", maxAPIAttempts, http.StatusInternalServerError},
+		{"Retry-After too long", http.MethodGet, 1, http.StatusTooManyRequests, "3600", "3600", 1, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			calls := 0
+			client, srvURL, delays := newRetryTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if r.Method == http.MethodPost && string(body) != `{"summary":"Sync"}` {
+					t.Errorf("attempt body = %q, want the original body", body)
+				}
+				mu.Lock()
+				calls++
+				n := calls
+				mu.Unlock()
+				if n <= tt.failures {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.status)
+					_, _ = w.Write([]byte(tt.body))
+					return
+				}
+				_, _ = w.Write([]byte(`{}`))
+			})
+
+			var body io.Reader
+			if tt.method == http.MethodPost {
+				body = strings.NewReader(`{"summary":"Sync"}`)
+			}
+			req, _ := http.NewRequest(tt.method, srvURL, body)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			got, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus || calls != tt.wantCalls {
+				t.Fatalf("status = %d after %d calls, want %d after %d", resp.StatusCode, calls, tt.wantStatus, tt.wantCalls)
+			}
+			if tt.wantStatus == http.StatusForbidden && string(got) != tt.body {
+				t.Fatalf("final body = %q, want the error body intact", got)
+			}
+			if tt.retryAfter == "2" && (*delays)[0] != 2*time.Second {
+				t.Fatalf("delays = %v, want Retry-After of 2s honored", *delays)
+			}
+			for _, d := range *delays {
+				if d <= 0 || (d > retryMaxDelay && d != 2*time.Second) {
+					t.Fatalf("delay %v out of range", d)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryTransport_ContextCanceled(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(srv.Close)
+	client := &http.Client{Transport: &retryTransport{base: srv.Client().Transport}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	start := time.Now()
+	_, err := client.Do(req)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Do() error = %v, want context.Canceled", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("Do() took %v after cancel", time.Since(start))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"Mon, 10 Mar 2025 12:00:30 GMT", 30 * time.Second, true},
+		{"Mon, 10 Mar 2025 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+		{"-1", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRetryTransport_Timeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+	client := &http.Client{Transport: &retryTransport{base: srv.Client().Transport, timeout: 50 * time.Millisecond}}
+
+	start := time.Now()
+	_, err := client.Get(srv.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get() error = %v, want context.DeadlineExceeded", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("Get() took %v with a 50ms timeout", time.Since(start))
+	}
+}
+
+func TestGoogleCalls_CanceledContext(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := map[string]func() error{
+		"calendar": func() error { _, err := fake.calendarService().ListCalendars(ctx, false); return err },
+		"gmail":    func() error { _, err := fake.gmailService().ListLabels(ctx); return err },
+		"tasks":    func() error { _, err := fake.tasksService().ListTasks(ctx, "@default", false, 10); return err },
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: error = %v, want context.Canceled", name, err)
+		}
+	}
+	if len(fake.requests) != 0 {
+		t.Fatalf("requests = %v, want none sent with a canceled context", fake.requests)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// schedulerInterval is how often background jobs look for due work.
+const schedulerInterval = time.Minute
+
+// gmailServiceFunc returns a GmailService acting for userEmail ("" in stdio mode).
+type gmailServiceFunc func(ctx context.Context, userEmail string) (*GmailService, error)
+
+// notifyFunc tells userEmail about the outcome of a background job, at an
+// MCP logging level ("info", "warning", ...). A nil notifyFunc drops it.
+type notifyFunc func(userEmail, level string, data map[string]any)
+
+// send calls n if it is set.
+func (n notifyFunc) send(userEmail, level string, data map[string]any) {
+	if n != nil {
+		n(userEmail, level, data)
+	}
+}
+
+// runPeriodic calls fn every interval until ctx is done.
+func runPeriodic(ctx context.Context, interval time.Duration, fn func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn(ctx)
+		}
+	}
+}
+
+// runBackgroundJobs performs one round of every background job on the rows
+// scope owns, reporting woken, sent and failed emails through notify.
+func runBackgroundJobs(ctx context.Context, database *DB, scope stateKeyScope, gmailFor gmailServiceFunc, notify notifyFunc) {
+	wakeSnoozedEmails(ctx, database, scope, gmailFor, notify)
+	sendScheduledEmails(ctx, database, scope, gmailFor, notify)
+}
+
+// cleanupExpiredMCPData deletes expired OAuth sessions and stale tokens,
+// logging how many were removed.
+func cleanupExpiredMCPData(database *DB) {
+	sessions, tokens, err := database.CleanupExpiredMCPData()
+	if err != nil {
+		slog.Error("cleanup expired OAuth data failed", "error", err)
+		return
+	}
+	level := slog.LevelDebug
+	if sessions > 0 || tokens > 0 {
+		level = slog.LevelInfo
+	}
+	slog.Log(context.Background(), level, "cleaned up expired OAuth data", "sessions", sessions, "tokens", tokens)
+}
+
+// wakeSnoozedEmails returns scope's due snoozed emails to their inboxes. Each
+// is claimed first, so concurrent servers do not wake it twice. An email that
+// cannot be woken (e.g. its user's token has expired) is retried once its
+// claim lapses; one that no longer exists is dropped.
+func wakeSnoozedEmails(ctx context.Context, database *DB, scope stateKeyScope, gmailFor gmailServiceFunc, notify notifyFunc) {
+	now := time.Now()
+	due, err := database.DueSnoozedEmails(now, scope)
+	if err != nil {
+		slog.Error("list due snoozed emails failed", "error", err)
+		return
+	}
+	for _, se := range due {
+		claimed, err := database.ClaimSnoozedEmail(se, now)
+		if err != nil {
+			slog.Error("claim snoozed email failed", "user", se.UserEmail, "message_id", se.MessageID, "error", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		svc, err := gmailFor(ctx, se.UserEmail)
+		if err != nil {
+			slog.Error("wake snoozed email failed", "user", se.UserEmail, "message_id", se.MessageID, "error", err)
+			continue
+		}
+		err = svc.UnsnoozeEmail(ctx, se.MessageID, se.LabelID)
+		if err != nil && !isNotFound(err) {
+			slog.Error("wake snoozed email failed", "user", se.UserEmail, "message_id", se.MessageID, "error", err)
+			continue
+		}
+		if err := database.DeleteSnoozedEmail(se.ID); err != nil {
+			slog.Error("delete snoozed email failed", "user", se.UserEmail, "message_id", se.MessageID, "error", err)
+		}
+		if err == nil {
+			notify.send(se.UserEmail, "info", map[string]any{"event": "snoozed_email_woken", "message_id": se.MessageID})
+		}
+	}
+}
+
+// sendScheduledEmails sends scope's due scheduled emails. Each is claimed
+// first, so concurrent servers do not send it twice. An email that can never
+// be sent, because its user can no longer be authenticated or Gmail rejected
+// it, is marked failed so it shows up in list-scheduled-emails; transient
+// errors are retried on the next run.
+func sendScheduledEmails(ctx context.Context, database *DB, scope stateKeyScope, gmailFor gmailServiceFunc, notify notifyFunc) {
+	due, err := database.DueScheduledEmails(time.Now(), scope)
+	if err != nil {
+		slog.Error("list due scheduled emails failed", "error", err)
+		return
+	}
+	for _, se := range due {
+		claimed, err := database.ClaimScheduledEmail(se.ID)
+		if err != nil {
+			slog.Error("claim scheduled email failed", "user", se.UserEmail, "id", se.ID, "error", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		messageID, err := sendScheduledEmail(ctx, se, gmailFor)
+		switch {
+		case err == nil:
+			if err := database.MarkScheduledEmailSent(se.ID, messageID); err != nil {
+				slog.Error("mark scheduled email sent failed", "user", se.UserEmail, "id", se.ID, "error", err)
+			}
+			notify.send(se.UserEmail, "info", map[string]any{"event": "scheduled_email_sent", "id": se.ID, "message_id": messageID})
+		case isAuthError(err):
+			slog.Error("send scheduled email failed", "user", se.UserEmail, "id", se.ID, "error", err)
+			reason := fmt.Sprintf("authentication failed: %v. Re-authenticate and schedule the email again.", err)
+			if err := database.MarkScheduledEmailFailed(se.ID, reason); err != nil {
+				slog.Error("record scheduled email failure failed", "user", se.UserEmail, "id", se.ID, "error", err)
+			}
+			notify.send(se.UserEmail, "warning", map[string]any{"event": "scheduled_email_failed", "id": se.ID, "reason": reason})
+		case isRejectedRequest(err):
+			slog.Error("send scheduled email failed", "user", se.UserEmail, "id", se.ID, "error", err)
+			if err := database.MarkScheduledEmailFailed(se.ID, err.Error()); err != nil {
+				slog.Error("record scheduled email failure failed", "user", se.UserEmail, "id", se.ID, "error", err)
+			}
+			notify.send(se.UserEmail, "warning", map[string]any{"event": "scheduled_email_failed", "id": se.ID, "reason": err.Error()})
+		default:
+			slog.Error("send scheduled email failed, will retry", "user", se.UserEmail, "id", se.ID, "error", err)
+			if err := database.ReleaseScheduledEmail(se.ID); err != nil {
+				slog.Error("release scheduled email failed", "user", se.UserEmail, "id", se.ID, "error", err)
+			}
+		}
+	}
+}
+
+// sendScheduledEmail sends se and returns the sent message ID.
+func sendScheduledEmail(ctx context.Context, se ScheduledEmail, gmailFor gmailServiceFunc) (string, error) {
+	svc, err := gmailFor(ctx, se.UserEmail)
+	if err != nil {
+		return "", err
+	}
+	sent, err := svc.SendScheduledEmail(ctx, se.Raw, se.ThreadID)
+	if err != nil {
+		return "", err
+	}
+	return sent.ID, nil
+}
+
+// isAuthError reports whether err means the user's credentials are unusable
+// until they sign in again: no stored token or user, or a refresh token the
+// token endpoint rejected. Token endpoint server errors are transient.
+func isAuthError(err error) bool {
+	if errors.Is(err, errUserNotFound) || errors.Is(err, errNoStoredToken) {
+		return true
+	}
+	var retrieveErr *oauth2.RetrieveError
+	return errors.As(err, &retrieveErr) && (retrieveErr.Response == nil || retrieveErr.Response.StatusCode < 500)
+}
+
+// isRejectedRequest reports whether err is a Google API 4xx that retrying
+// the same request will not fix, i.e. anything but rate limiting.
+func isRejectedRequest(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code < 400 || apiErr.Code >= 500 || apiErr.Code == http.StatusTooManyRequests {
+		return false
+	}
+	for _, item := range apiErr.Errors {
+		switch item.Reason {
+		case "rateLimitExceeded", "userRateLimitExceeded", "quotaExceeded":
+			return false
+		}
+	}
+	return true
+}
+
+// isNotFound reports whether err is a Google API 404.
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
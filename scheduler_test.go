@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestWakeSnoozedEmails(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	past := time.Now().Add(-time.Minute)
+	for _, id := range []string{"due", "gone", "failing"} {
+		if err := d.SnoozeEmail("a@example.com", id, "Label_9", past); err != nil {
+			t.Fatalf("SnoozeEmail() error = %v", err)
+		}
+	}
+	if err := d.SnoozeEmail("a@example.com", "later", "Label_9", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("SnoozeEmail() error = %v", err)
+	}
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("POST", "/gmail/v1/users/me/messages/due/modify", &gmail.Message{Id: "due"})
+	fake.fail("POST", "/gmail/v1/users/me/messages/gone/modify", http.StatusNotFound, "Requested entity was not found.")
+	fake.fail("POST", "/gmail/v1/users/me/messages/failing/modify", http.StatusInternalServerError, "backend error")
+
+	// A stdio mode row is not the http server's to wake.
+	if err := d.SnoozeEmail("", "stdio", "Label_9", past); err != nil {
+		t.Fatalf("SnoozeEmail() error = %v", err)
+	}
+
+	var woken []recordedNotification
+	wakeSnoozedEmails(context.Background(), d, httpStateKeys, func(_ context.Context, userEmail string) (*GmailService, error) {
+		if userEmail != "a@example.com" {
+			t.Errorf("gmailFor(%q), want a@example.com", userEmail)
+		}
+		return fake.gmailService(), nil
+	}, recordNotifications(&woken))
+
+	req, ok := fake.request("POST", "/gmail/v1/users/me/messages/due/modify")
+	if !ok {
+		t.Fatalf("due email was not woken")
+	}
+	var modify gmail.ModifyMessageRequest
+	fake.decodeBody(req, &modify)
+	if !reflect.DeepEqual(modify.AddLabelIds, []string{"INBOX"}) || !reflect.DeepEqual(modify.RemoveLabelIds, []string{"Label_9"}) {
+		t.Fatalf("wake modify request = %+v", modify)
+	}
+	if _, ok := fake.request("POST", "/gmail/v1/users/me/messages/later/modify"); ok {
+		t.Fatalf("email snoozed until later was woken early")
+	}
+
+	// Only the failed wake-up is retried, once its claim lapses; the woken
+	// and deleted emails are done.
+	if remaining, err := d.DueSnoozedEmails(time.Now(), httpStateKeys); err != nil || len(remaining) != 0 {
+		t.Fatalf("DueSnoozedEmails() = %+v, %v, want none while claimed", remaining, err)
+	}
+	remaining, err := d.DueSnoozedEmails(time.Now().Add(snoozeClaimLease+time.Minute), httpStateKeys)
+	if err != nil {
+		t.Fatalf("DueSnoozedEmails() error = %v", err)
+	}
+	if len(remaining) != 1 || remaining[0].MessageID != "failing" {
+		t.Fatalf("remaining due emails = %+v, want only failing", remaining)
+	}
+	if stdio, err := d.DueSnoozedEmails(time.Now(), stdioStateKeys); err != nil || len(stdio) != 1 || stdio[0].MessageID != "stdio" {
+		t.Fatalf("stdio due emails = %+v, %v, want the stdio row untouched", stdio, err)
+	}
+
+	// The user hears about the woken email, but not the deleted one or the
+	// one that failed and will be retried.
+	want := []recordedNotification{{"a@example.com", "info", map[string]any{"event": "snoozed_email_woken", "message_id": "due"}}}
+	if !reflect.DeepEqual(woken, want) {
+		t.Fatalf("notifications = %+v, want %+v", woken, want)
+	}
+}
+
+// recordedNotification is one call to a notifyFunc.
+type recordedNotification struct {
+	userEmail string
+	level     string
+	data      map[string]any
+}
+
+// recordNotifications returns a notifyFunc that appends to got.
+func recordNotifications(got *[]recordedNotification) notifyFunc {
+	return func(userEmail, level string, data map[string]any) {
+		*got = append(*got, recordedNotification{userEmail, level, data})
+	}
+}
+
+func TestClaimSnoozedEmail(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	if err := d.SnoozeEmail("", "m1", "Label_9", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("SnoozeEmail() error = %v", err)
+	}
+	due, err := d.DueSnoozedEmails(time.Now(), stdioStateKeys)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("DueSnoozedEmails() = %+v, %v, want one", due, err)
+	}
+	if ok, err := d.ClaimSnoozedEmail(due[0], time.Now()); err != nil || !ok {
+		t.Fatalf("first ClaimSnoozedEmail() = %v, %v, want true", ok, err)
+	}
+	if ok, err := d.ClaimSnoozedEmail(due[0], time.Now()); err != nil || ok {
+		t.Fatalf("second ClaimSnoozedEmail() = %v, %v, want false", ok, err)
+	}
+}
+
+func TestSendScheduledEmails(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	past := time.Now().Add(-time.Minute)
+	schedule := func(userEmail string, sendAt time.Time) int64 {
+		t.Helper()
+		id, err := d.ScheduleEmail(ScheduledEmail{UserEmail: userEmail, To: "bob@example.com", Subject: "Hi", Raw: []byte("Subject: Hi\r\n\r\nhello")}, sendAt)
+		if err != nil {
+			t.Fatalf("ScheduleEmail() error = %v", err)
+		}
+		return id
+	}
+	sentID := schedule("a@example.com", past)
+	expiredID := schedule("expired@example.com", past)
+	offlineID := schedule("offline@example.com", past)
+	rejectedID := schedule("rejected@example.com", past)
+	laterID := schedule("a@example.com", time.Now().Add(time.Hour))
+	stdioID := schedule("", past)
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("POST", "/gmail/v1/users/me/messages/send", &gmail.Message{Id: "sent1", ThreadId: "t1"})
+	fake.respond("GET", "/gmail/v1/users/me/messages/sent1", &gmail.Message{Id: "sent1", ThreadId: "t1"})
+	rejecting := newFakeGoogleAPI(t)
+	rejecting.fail("POST", "/gmail/v1/users/me/messages/send", http.StatusBadRequest, "Invalid To header")
+
+	var notified []recordedNotification
+	sendScheduledEmails(context.Background(), d, httpStateKeys, func(_ context.Context, userEmail string) (*GmailService, error) {
+		switch userEmail {
+		case "expired@example.com":
+			return nil, &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadRequest}, ErrorCode: "invalid_grant"}
+		case "offline@example.com":
+			return nil, errors.New("dial tcp: connection refused")
+		case "rejected@example.com":
+			return rejecting.gmailService(), nil
+		case "":
+			t.Errorf("http server sent a stdio mode email")
+		}
+		return fake.gmailService(), nil
+	}, recordNotifications(&notified))
+
+	req, ok := fake.request("POST", "/gmail/v1/users/me/messages/send")
+	if !ok {
+		t.Fatalf("due email was not sent")
+	}
+	var msg gmail.Message
+	fake.decodeBody(req, &msg)
+	if raw, _ := base64.RawURLEncoding.DecodeString(msg.Raw); !strings.Contains(string(raw), "hello") {
+		t.Fatalf("sent raw = %q, want stored message", raw)
+	}
+
+	list, err := d.ListScheduledEmails("a@example.com")
+	if err != nil {
+		t.Fatalf("ListScheduledEmails() error = %v", err)
+	}
+	status := map[int64]ScheduledEmail{}
+	for _, se := range list {
+		status[se.ID] = se
+	}
+	if se := status[sentID]; se.Status != scheduledSent || se.MessageID != "sent1" {
+		t.Fatalf("sent email = %+v, want status sent with message ID", se)
+	}
+	if se := status[laterID]; se.Status != scheduledPending {
+		t.Fatalf("later email = %+v, want pending", se)
+	}
+
+	list, err = d.ListScheduledEmails("expired@example.com")
+	if err != nil {
+		t.Fatalf("ListScheduledEmails() error = %v", err)
+	}
+	if len(list) != 1 || list[0].ID != expiredID || list[0].Status != scheduledFailed || !strings.Contains(list[0].Error, "invalid_grant") {
+		t.Fatalf("expired user's emails = %+v, want one failed with reason", list)
+	}
+
+	// A transient failure is retried; a message Gmail rejects is not.
+	for _, tt := range []struct {
+		user   string
+		id     int64
+		status string
+	}{
+		{"offline@example.com", offlineID, scheduledPending},
+		{"rejected@example.com", rejectedID, scheduledFailed},
+		{"", stdioID, scheduledPending},
+	} {
+		list, err := d.ListScheduledEmails(tt.user)
+		if err != nil {
+			t.Fatalf("ListScheduledEmails(%q) error = %v", tt.user, err)
+		}
+		if len(list) != 1 || list[0].ID != tt.id || list[0].Status != tt.status {
+			t.Fatalf("%q's emails = %+v, want one %s", tt.user, list, tt.status)
+		}
+	}
+
+	// The sent and permanently failed emails are reported to their users;
+	// the one to be retried is not.
+	events := map[string]string{}
+	for _, n := range notified {
+		events[n.userEmail] = n.level + " " + n.data["event"].(string)
+	}
+	wantEvents := map[string]string{
+		"a@example.com":        "info scheduled_email_sent",
+		"expired@example.com":  "warning scheduled_email_failed",
+		"rejected@example.com": "warning scheduled_email_failed",
+	}
+	if len(notified) != len(wantEvents) || !reflect.DeepEqual(events, wantEvents) {
+		t.Fatalf("notifications = %+v, want %v", notified, wantEvents)
+	}
+
+	// A claimed email cannot be claimed again, e.g. by another server.
+	if ok, err := d.ClaimScheduledEmail(stdioID); err != nil || !ok {
+		t.Fatalf("first ClaimScheduledEmail() = %v, %v, want true", ok, err)
+	}
+	if ok, err := d.ClaimScheduledEmail(stdioID); err != nil || ok {
+		t.Fatalf("second ClaimScheduledEmail() = %v, %v, want false", ok, err)
+	}
+}
@@ -2,12 +2,21 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
 )
 
 const (
@@ -63,6 +72,15 @@ type initializeResult struct {
 	ProtocolVersion string             `json:"protocolVersion"`
 	Capabilities    serverCapabilities `json:"capabilities"`
 	ServerInfo      serverInfo         `json:"serverInfo"`
+	Meta            *initializeMeta    `json:"_meta,omitempty"`
+}
+
+// initializeMeta is a non-standard extension reporting whether the caller
+// has a usable Google session, so clients can show who they are connected as
+// without a probe call.
+type initializeMeta struct {
+	Authenticated bool   `json:"authenticated"`
+	Email         string `json:"email,omitempty"`
 }
 
 type serverCapabilities struct {
@@ -94,9 +112,10 @@ type mcpTool struct {
 }
 
 type inputSchema struct {
-	Type       string              `json:"type"`
-	Properties map[string]property `json:"properties,omitempty"`
-	Required   []string            `json:"required,omitempty"`
+	Type                 string              `json:"type"`
+	Properties           map[string]property `json:"properties,omitempty"`
+	Required             []string            `json:"required,omitempty"`
+	AdditionalProperties *bool               `json:"additionalProperties,omitempty"`
 }
 
 type property struct {
@@ -149,63 +168,287 @@ type readResourceResult struct {
 	Contents []resourceContent `json:"contents"`
 }
 
+type cancelledParams struct {
+	RequestID json.RawMessage `json:"requestId"`
+	Reason    string          `json:"reason,omitempty"`
+}
+
+// inflightRequests tracks cancel functions for requests that are being processed,
+// keyed by JSON-RPC request ID, so notifications/cancelled can abort them.
+type inflightRequests struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+// begin registers a cancelable context for key. The returned function must be
+// called when the request finishes.
+func (f *inflightRequests) begin(ctx context.Context, key string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	f.mu.Lock()
+	if f.cancels == nil {
+		f.cancels = make(map[string]context.CancelFunc)
+	}
+	f.cancels[key] = cancel
+	f.mu.Unlock()
+	return ctx, func() {
+		f.mu.Lock()
+		delete(f.cancels, key)
+		f.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancel aborts the in-flight request registered under key, if any.
+func (f *inflightRequests) cancel(key string) bool {
+	f.mu.Lock()
+	cancel, ok := f.cancels[key]
+	f.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// requestKey normalizes a JSON-RPC ID for use as an in-flight map key.
+func requestKey(id json.RawMessage) string {
+	return strings.TrimSpace(string(id))
+}
+
+// Options holds runtime settings shared by the stdio and HTTP servers.
+type Options struct {
+	// MaxResultsCeiling caps max_results on list and search tools. Zero disables the cap.
+	MaxResultsCeiling int64
+	// FallbackCredentialsFile is a second OAuth client credentials file used to
+	// refresh tokens the primary client rejects with invalid_client, so users
+	// need not re-authenticate while client credentials are rotated.
+	FallbackCredentialsFile string
+	// CompactOutput drops empty fields from tool results to reduce tokens.
+	CompactOutput bool
+	// MaxAttachments caps the number of attachments on an outgoing email.
+	// Zero disables the cap.
+	MaxAttachments int
+	// Locale is the BCP 47 tag UIs format dates and times with. Empty leaves
+	// it to the client.
+	Locale string
+	// CalendarListTTL is how long list-calendars results are reused per
+	// user. Zero disables the cache.
+	CalendarListTTL time.Duration
+	// MaxRequestBytes caps the size of HTTP request bodies on /mcp and the
+	// OAuth endpoints. Zero disables the cap.
+	MaxRequestBytes int64
+	// ReadTimeout, WriteTimeout and IdleTimeout bound HTTP connections in
+	// http mode; zero disables each. Streaming responses must lift the write
+	// deadline per request (http.ResponseController.SetWriteDeadline), since
+	// WriteTimeout would otherwise cut them off.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// CleanupInterval is how often http mode deletes expired OAuth sessions
+	// and tokens. Zero disables the cleanup.
+	CleanupInterval time.Duration
+	// IntrospectionSecret is the Bearer token callers of the token
+	// introspection endpoint must present. Empty disables introspection.
+	IntrospectionSecret string
+	// RequestTimeout bounds each outbound Google API call, retries
+	// included, so a hung request cannot block a tool call indefinitely.
+	// Zero disables the limit.
+	RequestTimeout time.Duration
+	// Account is the stdio mode Google account tools use when not given an
+	// account argument. Empty means defaultAccount.
+	Account string
+	// GmailFullAccess requests the full Gmail scope instead of gmail.modify
+	// at sign-in, which permanently-delete-email requires.
+	GmailFullAccess bool
+	// Timezone is the IANA timezone used when a calendar tool is not given
+	// one. Empty means UTC.
+	Timezone string
+	// Scopes is the OAuth scope set requested at sign-in: "full" (the
+	// default when empty) or "readonly", which grants read-only Calendar,
+	// Gmail and Tasks access so write tools fail.
+	Scopes string
+}
+
 // Server is the MCP stdio server.
 type Server struct {
-	database        *DB
-	oauthConfig     *oauthConfigHolder
-	calendarService *CalendarService
-	gmailService    *GmailService
-	initialized     bool
-	reader          *bufio.Reader
-	writer          io.Writer
+	database    *DB
+	opts        Options
+	oauthConfig *oauthConfigHolder
+	initialized bool
+	reader      *bufio.Reader
+	writer      io.Writer
+	inflight    inflightRequests
+
+	// servicesMu guards the lazily created Google API services, keyed by
+	// account.
+	servicesMu sync.Mutex
+	services   map[string]*accountServices
+
+	// oauthFlow runs the browser consent flow; nil means runOAuthFlow.
+	oauthFlow func(config *oauth2.Config) (*oauth2.Token, error)
+	// accountEmail identifies the account of a token; nil means
+	// primaryCalendarEmail.
+	accountEmail func(ctx context.Context, ts oauth2.TokenSource, timeout time.Duration) (string, error)
+}
+
+// accountServices are the Google API services of one stdio mode account.
+type accountServices struct {
+	calendar *CalendarService
+	gmail    *GmailService
+	tasks    *TasksService
 }
 
 // oauthConfigHolder lazily holds the OAuth config.
 type oauthConfigHolder struct {
-	credentialsFile string
+	credentialsFile         string
+	fallbackCredentialsFile string
 }
 
 // NewServer creates a new MCP server.
-func NewServer(database *DB, credentialsFile string) *Server {
+func NewServer(database *DB, credentialsFile string, opts Options) *Server {
 	return &Server{
 		database: database,
+		opts:     opts,
 		oauthConfig: &oauthConfigHolder{
-			credentialsFile: credentialsFile,
+			credentialsFile:         credentialsFile,
+			fallbackCredentialsFile: opts.FallbackCredentialsFile,
 		},
 		reader: bufio.NewReader(os.Stdin),
 		writer: os.Stdout,
 	}
 }
 
+// redirectStdout points os.Stdout at stderr, so a stray print from this
+// program or a library cannot corrupt the JSON-RPC stream, and returns the
+// original stdout for responses. restore undoes the swap.
+func redirectStdout() (responses *os.File, restore func()) {
+	responses = os.Stdout
+	os.Stdout = os.Stderr
+	return responses, func() { os.Stdout = responses }
+}
+
 // Run reads JSON-RPC messages from stdin and writes responses to stdout.
+// Requests are handled one at a time; cancellation notifications are handled
+// by the reader as soon as they arrive so they can abort the running request.
 func (s *Server) Run(ctx context.Context) error {
+	// Buffered so the reader can keep consuming (and see cancellations) while
+	// a slow request is being processed.
+	lines := make(chan []byte, 32)
+	readErr := make(chan error, 1)
+	go s.readLoop(lines, readErr)
+
+	if s.database != nil {
+		go runPeriodic(ctx, schedulerInterval, func(ctx context.Context) {
+			runBackgroundJobs(ctx, s.database, stdioStateKeys, s.newGmailService, nil)
+		})
+	}
+
+	// A request being handled when ctx is done may finish and have its
+	// response written; the loop stops before taking another.
+	reqCtx, cancel := drainingContext(ctx, shutdownGrace)
+	defer cancel()
+
 	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		default:
+		case line, ok := <-lines:
+			if !ok {
+				return <-readErr
+			}
+			if resp := s.handleInput(reqCtx, line); resp != nil {
+				if err := s.writeResponse(resp); err != nil {
+					return fmt.Errorf("write response: %w", err)
+				}
+			}
 		}
+	}
+}
 
+// readLoop reads newline-delimited messages and forwards them to lines. It closes
+// lines on EOF or read error, reporting the error (nil on EOF) on readErr.
+func (s *Server) readLoop(lines chan<- []byte, readErr chan<- error) {
+	defer close(lines)
+	for {
 		line, err := s.reader.ReadString('\n')
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			if !s.handleCancellation([]byte(trimmed)) {
+				lines <- []byte(trimmed)
+			}
+		}
 		if err != nil {
 			if err == io.EOF {
-				return nil
+				readErr <- nil
+			} else {
+				readErr <- fmt.Errorf("read: %w", err)
 			}
-			return fmt.Errorf("read: %w", err)
+			return
 		}
+	}
+}
 
-		line = strings.TrimSpace(line)
-		if line == "" {
+// handleCancellation processes data immediately if it is a notifications/cancelled
+// message and reports whether it did so.
+func (s *Server) handleCancellation(data []byte) bool {
+	var req jsonrpcRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return false
+	}
+	if req.Method != "notifications/cancelled" || (req.ID != nil && string(req.ID) != "null") {
+		return false
+	}
+	s.handleNotification(&req)
+	return true
+}
+
+// handleInput processes one line of input, a single message or a batch. It
+// returns nil if nothing is to be written back.
+func (s *Server) handleInput(ctx context.Context, data []byte) any {
+	handle := func(msg []byte) *jsonrpcResponse {
+		return s.handleMessage(ctx, msg)
+	}
+	if isBatch(data) {
+		return handleBatch(data, handle)
+	}
+	if resp := handle(data); resp != nil {
+		return resp
+	}
+	return nil
+}
+
+// isBatch reports whether data is a JSON-RPC batch, i.e. a JSON array.
+func isBatch(data []byte) bool {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// handleBatch runs each message of a JSON-RPC batch through handle and
+// returns the responses, omitting those of notifications, or nil if there
+// are none. A malformed or empty batch gets a single error response.
+func handleBatch(data []byte, handle func([]byte) *jsonrpcResponse) any {
+	var msgs []json.RawMessage
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return errorResponse(nil, codeParseError, "Parse error", err.Error())
+	}
+	if len(msgs) == 0 {
+		return errorResponse(nil, codeInvalidRequest, "Invalid Request", "batch must not be empty")
+	}
+	var resps []*jsonrpcResponse
+	for _, msg := range msgs {
+		if trimmed := bytes.TrimSpace(msg); len(trimmed) == 0 || trimmed[0] != '{' {
+			resps = append(resps, errorResponse(nil, codeInvalidRequest, "Invalid Request", "batch entries must be objects"))
 			continue
 		}
-
-		resp := s.handleMessage(ctx, []byte(line))
-		if resp != nil {
-			if err := s.writeResponse(resp); err != nil {
-				return fmt.Errorf("write response: %w", err)
-			}
+		if resp := handle(msg); resp != nil {
+			resps = append(resps, resp)
 		}
 	}
+	if len(resps) == 0 {
+		return nil
+	}
+	return resps
 }
 
 func (s *Server) handleMessage(ctx context.Context, data []byte) *jsonrpcResponse {
@@ -232,9 +475,12 @@ func (s *Server) handleNotification(req *jsonrpcRequest) {
 	case "notifications/initialized":
 		s.initialized = true
 	case "notifications/cancelled":
-		// no-op
+		var params cancelledParams
+		if err := json.Unmarshal(req.Params, &params); err == nil && params.RequestID != nil {
+			s.inflight.cancel(requestKey(params.RequestID))
+		}
 	default:
-		fmt.Fprintf(os.Stderr, "unknown notification: %s\n", req.Method)
+		slog.Warn("unknown notification", "method", req.Method)
 	}
 }
 
@@ -278,7 +524,7 @@ func (s *Server) handleToolsList(req *jsonrpcRequest) *jsonrpcResponse {
 	for _, t := range all {
 		if t.isVisibleToModel() {
 			t.Meta = buildToolMeta(t)
-			tools = append(tools, t)
+			tools = append(tools, withAccountProperty(t))
 		}
 	}
 	return successResponse(req.ID, &listToolsResult{Tools: tools})
@@ -290,16 +536,19 @@ func (s *Server) handleToolsCall(ctx context.Context, req *jsonrpcRequest) *json
 		return errorResponse(req.ID, codeInvalidParams, "Invalid params", err.Error())
 	}
 
+	ctx, done := s.inflight.begin(ctx, requestKey(req.ID))
+	defer done()
+
+	start := time.Now()
 	result, err := s.dispatchTool(ctx, params.Name, params.Arguments)
+	logToolCall("", params.Name, start, err)
 	if err != nil {
-		return successResponse(req.ID, &callToolResult{
-			Content: []content{{Type: "text", Text: err.Error()}},
-			IsError: true,
-		})
+		return successResponse(req.ID, toolErrorResult(err))
 	}
 
 	// Marshal result to JSON text
-	jsonBytes, err := json.Marshal(result)
+	result, capMeta := splitCapIndicator(result)
+	jsonBytes, err := marshalToolResult(params.Name, params.Arguments, result, s.opts.CompactOutput)
 	if err != nil {
 		return successResponse(req.ID, &callToolResult{
 			Content: []content{{Type: "text", Text: fmt.Sprintf("marshal error: %v", err)}},
@@ -311,8 +560,9 @@ func (s *Server) handleToolsCall(ctx context.Context, req *jsonrpcRequest) *json
 		Content: []content{{Type: "text", Text: string(jsonBytes)}},
 	}
 	if tool := findTool(params.Name); tool != nil && tool.hasUI() {
-		res.Meta = buildResultMeta(*tool, string(jsonBytes))
+		res.Meta = buildResultMeta(*tool, string(jsonBytes), callLocale(params.Arguments, s.opts.Locale))
 	}
+	res.Meta = mergeMeta(res.Meta, capMeta)
 	return successResponse(req.ID, res)
 }
 
@@ -342,7 +592,7 @@ func (s *Server) handleResourcesRead(req *jsonrpcRequest) *jsonrpcResponse {
 		return errorResponse(req.ID, codeInvalidParams, "Invalid resource URI", err.Error())
 	}
 
-	htmlContent, err := generateUIHTML(*tool, encodedData, "")
+	htmlContent, err := generateUIHTML(*tool, encodedData, "", uiResourceLocale(params.URI, s.opts.Locale))
 	if err != nil {
 		return errorResponse(req.ID, codeInternalError, "Failed to generate UI", err.Error())
 	}
@@ -356,7 +606,7 @@ func (s *Server) handleResourcesRead(req *jsonrpcRequest) *jsonrpcResponse {
 	})
 }
 
-func (s *Server) writeResponse(resp *jsonrpcResponse) error {
+func (s *Server) writeResponse(resp any) error {
 	data, err := json.Marshal(resp)
 	if err != nil {
 		return err
@@ -365,56 +615,194 @@ func (s *Server) writeResponse(resp *jsonrpcResponse) error {
 	return err
 }
 
-// ensureCalendarService lazily initializes the CalendarService.
-func (s *Server) ensureCalendarService(ctx context.Context) (*CalendarService, error) {
-	if s.calendarService != nil {
-		return s.calendarService, nil
+// accountServicesLocked returns the service set of account, creating an
+// empty one if needed. servicesMu must be held.
+func (s *Server) accountServicesLocked(account string) *accountServices {
+	if s.services == nil {
+		s.services = make(map[string]*accountServices)
 	}
+	svcs := s.services[account]
+	if svcs == nil {
+		svcs = &accountServices{}
+		s.services[account] = svcs
+	}
+	return svcs
+}
 
-	config, err := loadOAuthConfig(s.oauthConfig.credentialsFile, oauthScopes)
+// tokenSource returns a refreshing token source for account's stored token.
+func (s *Server) tokenSource(account string) (oauth2.TokenSource, error) {
+	config, fallback, err := s.loadOAuthConfigs()
 	if err != nil {
 		return nil, err
 	}
+	return getTokenSource(config, fallback, s.database, account)
+}
 
-	ts, err := getTokenSource(config, s.database)
+// ensureCalendarService lazily initializes account's CalendarService.
+func (s *Server) ensureCalendarService(ctx context.Context, account string) (*CalendarService, error) {
+	s.servicesMu.Lock()
+	defer s.servicesMu.Unlock()
+	svcs := s.accountServicesLocked(account)
+	if svcs.calendar != nil {
+		return svcs.calendar, nil
+	}
+
+	ts, err := s.tokenSource(account)
 	if err != nil {
 		return nil, err
 	}
 
-	svc, err := NewCalendarService(ctx, ts)
+	svc, err := NewCalendarService(ctx, ts, s.opts)
 	if err != nil {
 		return nil, err
 	}
+	// The caches live and die with the service, so re-authenticating as
+	// another Google account never serves the previous account's calendars.
+	svc.caches = newCalendarCaches(s.opts.CalendarListTTL)
 
-	s.calendarService = svc
+	svcs.calendar = svc
 	return svc, nil
 }
 
-// ensureGmailService lazily initializes the GmailService.
-func (s *Server) ensureGmailService(ctx context.Context) (*GmailService, error) {
-	if s.gmailService != nil {
-		return s.gmailService, nil
+// ensureTasksService lazily creates account's Tasks service.
+func (s *Server) ensureTasksService(ctx context.Context, account string) (*TasksService, error) {
+	s.servicesMu.Lock()
+	defer s.servicesMu.Unlock()
+	svcs := s.accountServicesLocked(account)
+	if svcs.tasks != nil {
+		return svcs.tasks, nil
+	}
+
+	ts, err := s.tokenSource(account)
+	if err != nil {
+		return nil, err
 	}
 
-	config, err := loadOAuthConfig(s.oauthConfig.credentialsFile, oauthScopes)
+	svc, err := NewTasksService(ctx, ts, s.opts)
 	if err != nil {
 		return nil, err
 	}
 
-	ts, err := getTokenSource(config, s.database)
+	svcs.tasks = svc
+	return svc, nil
+}
+
+// resetServices drops account's cached services so the next call builds them
+// from its current token.
+func (s *Server) resetServices(account string) {
+	s.servicesMu.Lock()
+	defer s.servicesMu.Unlock()
+	delete(s.services, account)
+}
+
+// loadOAuthConfigs loads the primary OAuth client config and, if configured,
+// the fallback used while credentials are being rotated.
+func (s *Server) loadOAuthConfigs() (config, fallback *oauth2.Config, err error) {
+	scopes := selectScopes(oauthScopes, s.opts.Scopes, s.opts.GmailFullAccess)
+	config, err = loadOAuthConfig(s.oauthConfig.credentialsFile, scopes)
+	if err != nil {
+		return nil, nil, err
+	}
+	fallback, err = loadFallbackOAuthConfig(s.oauthConfig.fallbackCredentialsFile, scopes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, fallback, nil
+}
+
+// ensureGmailService lazily initializes account's GmailService.
+func (s *Server) ensureGmailService(ctx context.Context, account string) (*GmailService, error) {
+	s.servicesMu.Lock()
+	defer s.servicesMu.Unlock()
+	svcs := s.accountServicesLocked(account)
+	if svcs.gmail != nil {
+		return svcs.gmail, nil
+	}
+
+	ts, err := s.tokenSource(account)
 	if err != nil {
 		return nil, err
 	}
 
-	svc, err := NewGmailService(ctx, ts)
+	svc, err := NewGmailService(ctx, ts, s.opts)
 	if err != nil {
 		return nil, err
 	}
 
-	s.gmailService = svc
+	svcs.gmail = svc
 	return svc, nil
 }
 
+// newGmailService builds an uncached Gmail service for the account whose
+// state is stored under stateKey (see accountStateKey). It is used by
+// background jobs, which must not touch the request path's cache.
+func (s *Server) newGmailService(ctx context.Context, stateKey string) (*GmailService, error) {
+	account, ok := accountFromStateKey(stateKey)
+	if !ok {
+		return nil, fmt.Errorf("state key %q does not belong to a stdio mode account", stateKey)
+	}
+	ts, err := s.tokenSource(account)
+	if err != nil {
+		return nil, err
+	}
+	return NewGmailService(ctx, ts, s.opts)
+}
+
+// toolErrorResult reports a failed tool call. The raw error is kept as the
+// text for debugging; _meta.explanation, when known, tells the model how to
+// recover.
+func toolErrorResult(err error) *callToolResult {
+	res := &callToolResult{
+		Content: []content{{Type: "text", Text: err.Error()}},
+		IsError: true,
+	}
+	if explanation := explainError(err); explanation != "" {
+		res.Meta = map[string]interface{}{"explanation": explanation}
+	}
+	return res
+}
+
+// explainError maps common Google API and OAuth failures to a short remediation.
+// It returns "" for errors it does not recognize.
+func explainError(err error) string {
+	if isAuthError(err) {
+		return "The stored Google authorization is no longer valid. Ask the user to re-authenticate, then retry."
+	}
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+	for _, item := range apiErr.Errors {
+		switch item.Reason {
+		case "rateLimitExceeded", "userRateLimitExceeded", "quotaExceeded":
+			return "Google's rate limit or quota was exceeded. Wait a little before retrying, and make fewer calls."
+		case "insufficientPermissions":
+			return "The authorization is missing a required scope. If the server runs with --scopes=readonly, this tool is unavailable; otherwise ask the user to re-authenticate to grant it."
+		}
+	}
+	switch code := apiErr.Code; {
+	case code == http.StatusBadRequest:
+		return "Google rejected the request as invalid. Check argument formats (RFC3339 times, IDs, email addresses) and retry."
+	case code == http.StatusUnauthorized:
+		return "Google rejected the credentials. Ask the user to re-authenticate, then retry."
+	case code == http.StatusForbidden:
+		return "The account lacks permission for this item, e.g. a calendar shared read-only or an event organized by someone else."
+	case code == http.StatusNotFound:
+		return "The item wasn't found. It may have been deleted, or its ID belongs to another calendar or account; list or search again to get a current ID."
+	case code == http.StatusConflict:
+		return "An item with this ID already exists. Use a different ID or update the existing item."
+	case code == http.StatusGone:
+		return "The item was deleted, or a sync token expired. Fetch the current state again."
+	case code == http.StatusPreconditionFailed:
+		return "The item changed since it was read. Fetch it again and reapply the change."
+	case code == http.StatusTooManyRequests:
+		return "Google's rate limit was exceeded. Wait a little before retrying, and make fewer calls."
+	case code >= 500:
+		return "Google had a temporary server problem. Retry shortly."
+	}
+	return ""
+}
+
 func successResponse(id json.RawMessage, result any) *jsonrpcResponse {
 	return &jsonrpcResponse{
 		JSONRPC: "2.0",
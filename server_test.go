@@ -0,0 +1,483 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+func TestNotificationsCancelled_CancelsToolCall(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	t.Cleanup(fake.Close)
+
+	svc, err := calendar.NewService(context.Background(),
+		option.WithHTTPClient(fake.Client()),
+		option.WithEndpoint(fake.URL+"/"),
+	)
+	if err != nil {
+		t.Fatalf("calendar.NewService() error = %v", err)
+	}
+	s := &Server{services: map[string]*accountServices{
+		defaultAccount: {calendar: &CalendarService{svc: svc}},
+	}}
+
+	respCh := make(chan *jsonrpcResponse, 1)
+	go func() {
+		respCh <- s.handleMessage(context.Background(),
+			[]byte(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"list-events","arguments":{}}}`))
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Google API request was never made")
+	}
+
+	if !s.handleCancellation([]byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7}}`)) {
+		t.Fatal("handleCancellation() = false for notifications/cancelled")
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Google API request context was not cancelled")
+	}
+
+	resp := <-respCh
+	result, ok := resp.Result.(*callToolResult)
+	if !ok || !result.IsError {
+		t.Fatalf("expected error tool result, got %+v", resp.Result)
+	}
+	if !strings.Contains(result.Content[0].Text, "context canceled") {
+		t.Fatalf("error text = %q, want context canceled", result.Content[0].Text)
+	}
+}
+
+func TestHandleCancellation_IgnoresOtherMessages(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{}
+	for _, msg := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`not json`,
+	} {
+		if s.handleCancellation([]byte(msg)) {
+			t.Errorf("handleCancellation(%s) = true, want false", msg)
+		}
+	}
+}
+
+func TestInflightRequests(t *testing.T) {
+	t.Parallel()
+
+	var f inflightRequests
+	ctx, done := f.begin(context.Background(), requestKey(json.RawMessage(` "abc" `)))
+	if !f.cancel(`"abc"`) {
+		t.Fatal("cancel() = false for registered request")
+	}
+	if ctx.Err() == nil {
+		t.Fatal("context not cancelled")
+	}
+	done()
+	if f.cancel(`"abc"`) {
+		t.Fatal("cancel() = true after request finished")
+	}
+}
+
+func TestDispatchTool_MissingCredentialsFile(t *testing.T) {
+	t.Parallel()
+
+	credFile := filepath.Join(t.TempDir(), "credentials.json")
+	s := NewServer(nil, credFile, Options{})
+
+	for _, name := range []string{"list-calendars", "search-emails"} {
+		_, err := s.dispatchTool(context.Background(), name, map[string]interface{}{})
+		if err == nil {
+			t.Fatalf("dispatchTool(%q) error = nil, want missing credentials error", name)
+		}
+		msg := err.Error()
+		if !strings.Contains(msg, credFile) || !strings.Contains(msg, "Google Cloud Console") {
+			t.Fatalf("dispatchTool(%q) error = %q, want path and setup guidance", name, msg)
+		}
+		if strings.Contains(msg, "'authenticate'") {
+			t.Fatalf("dispatchTool(%q) error = %q, should not suggest authenticate", name, msg)
+		}
+	}
+}
+
+func TestEnsureServices_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	credFile := writeTestCredentials(t)
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	if err := d.SaveToken(defaultAccount, &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("SaveToken() error = %v", err)
+	}
+	s := NewServer(d, credFile, Options{})
+
+	// Run with -race to check the lazy init; every caller must also see the
+	// same cached service.
+	const n = 8
+	cals := make([]*CalendarService, n)
+	gmails := make([]*GmailService, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i == n/2 {
+				s.resetServices(defaultAccount)
+			}
+			var err error
+			if cals[i], err = s.ensureCalendarService(context.Background(), defaultAccount); err != nil {
+				t.Errorf("ensureCalendarService() error = %v", err)
+			}
+			if gmails[i], err = s.ensureGmailService(context.Background(), defaultAccount); err != nil {
+				t.Errorf("ensureGmailService() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	cal, err := s.ensureCalendarService(context.Background(), defaultAccount)
+	if err != nil {
+		t.Fatalf("ensureCalendarService() error = %v", err)
+	}
+	if again, _ := s.ensureCalendarService(context.Background(), defaultAccount); again != cal {
+		t.Fatalf("ensureCalendarService() returned a new service for a cached one")
+	}
+	for i := range cals {
+		if cals[i] == nil || gmails[i] == nil {
+			t.Fatalf("caller %d got nil service", i)
+		}
+	}
+}
+
+// writeTestCredentials writes an installed-app OAuth client file and returns
+// its path.
+func writeTestCredentials(t *testing.T) string {
+	t.Helper()
+	credFile := filepath.Join(t.TempDir(), "credentials.json")
+	creds := `{"installed":{"client_id":"id","client_secret":"secret","auth_uri":"https://accounts.google.com/o/oauth2/auth","token_uri":"https://oauth2.googleapis.com/token","redirect_uris":["http://localhost"]}}`
+	if err := os.WriteFile(credFile, []byte(creds), 0o600); err != nil {
+		t.Fatalf("write credentials: %v", err)
+	}
+	return credFile
+}
+
+func TestHandleAuthenticate_AlreadyAuthenticatedAndForce(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	if err := d.SaveToken(defaultAccount, &oauth2.Token{AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("SaveToken() error = %v", err)
+	}
+	s := NewServer(d, writeTestCredentials(t), Options{})
+	flows := 0
+	s.oauthFlow = func(*oauth2.Config) (*oauth2.Token, error) {
+		flows++
+		return &oauth2.Token{AccessToken: "new", RefreshToken: "refresh2", Expiry: time.Now().Add(time.Hour)}, nil
+	}
+	s.accountEmail = func(context.Context, oauth2.TokenSource, time.Duration) (string, error) {
+		return "", errors.New("offline")
+	}
+
+	// A valid stored token skips the browser flow.
+	result, err := s.dispatchTool(context.Background(), "authenticate", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("dispatchTool(authenticate) error = %v", err)
+	}
+	if got := result.(map[string]string)["status"]; got != "already_authenticated" || flows != 0 {
+		t.Fatalf("authenticate status = %q after %d flows, want already_authenticated without a flow", got, flows)
+	}
+
+	// force runs the flow anyway and replaces the stored token.
+	result, err = s.dispatchTool(context.Background(), "authenticate", map[string]interface{}{"force": true})
+	if err != nil {
+		t.Fatalf("dispatchTool(authenticate, force) error = %v", err)
+	}
+	if got := result.(map[string]string)["status"]; got != "authenticated" || flows != 1 {
+		t.Fatalf("forced authenticate status = %q after %d flows, want authenticated after one flow", got, flows)
+	}
+	if tok, err := d.LoadToken(defaultAccount); err != nil || tok.AccessToken != "new" {
+		t.Fatalf("LoadToken() = %+v, %v, want the new token", tok, err)
+	}
+
+	// Without a stored token the flow runs even without force.
+	result, err = s.dispatchTool(context.Background(), "authenticate", map[string]interface{}{"account": "work"})
+	if err != nil {
+		t.Fatalf("dispatchTool(authenticate, work) error = %v", err)
+	}
+	if got := result.(map[string]string)["status"]; got != "authenticated" || flows != 2 {
+		t.Fatalf("authenticate(work) status = %q after %d flows, want authenticated after a flow", got, flows)
+	}
+}
+
+func TestHandleAuthenticate_ReturnsEmail(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	s := NewServer(d, writeTestCredentials(t), Options{})
+	s.oauthFlow = func(*oauth2.Config) (*oauth2.Token, error) {
+		return &oauth2.Token{AccessToken: "new", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}, nil
+	}
+	s.accountEmail = func(_ context.Context, ts oauth2.TokenSource, _ time.Duration) (string, error) {
+		tok, err := ts.Token()
+		if err != nil || tok.AccessToken != "new" {
+			t.Errorf("accountEmail() got token %+v, %v, want the new token", tok, err)
+		}
+		return "me@example.com", nil
+	}
+
+	for _, want := range []string{"authenticated", "already_authenticated"} {
+		result, err := s.dispatchTool(context.Background(), "authenticate", map[string]interface{}{})
+		if err != nil {
+			t.Fatalf("dispatchTool(authenticate) error = %v", err)
+		}
+		got := result.(map[string]string)
+		if got["status"] != want || got["email"] != "me@example.com" || got["account"] != defaultAccount {
+			t.Fatalf("authenticate = %v, want status %s with the account email", got, want)
+		}
+	}
+
+	// A failed lookup leaves the email out without failing the login.
+	s.accountEmail = func(context.Context, oauth2.TokenSource, time.Duration) (string, error) {
+		return "", errors.New("offline")
+	}
+	result, err := s.dispatchTool(context.Background(), "authenticate", map[string]interface{}{"force": true})
+	if err != nil {
+		t.Fatalf("dispatchTool(authenticate, force) error = %v", err)
+	}
+	if email, ok := result.(map[string]string)["email"]; ok {
+		t.Fatalf("authenticate email = %q, want none when the lookup fails", email)
+	}
+}
+
+// TestRedirectStdout swaps the process-wide os.Stdout, so it must not run in
+// parallel with other tests.
+func TestRedirectStdout(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe() error = %v", err)
+	}
+	defer r.Close()
+	realStdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = realStdout }()
+
+	responses, restore := redirectStdout()
+	s := &Server{writer: responses}
+	fmt.Println("stray library output")
+	if err := s.writeResponse(successResponse(json.RawMessage("1"), struct{}{})); err != nil {
+		t.Fatalf("writeResponse() error = %v", err)
+	}
+	restore()
+	if os.Stdout != w {
+		t.Fatal("restore did not put back the original stdout")
+	}
+	w.Close()
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read response stream: %v", err)
+	}
+	if strings.Contains(string(out), "stray") {
+		t.Fatalf("response stream contains stray print: %q", out)
+	}
+	if string(out) != `{"jsonrpc":"2.0","id":1,"result":{}}`+"\n" {
+		t.Fatalf("response stream = %q", out)
+	}
+}
+
+func TestToolErrorResult_Explanation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"not found", fmt.Errorf("get event: %w", &googleapi.Error{Code: 404, Message: "Not Found"}), "wasn't found"},
+		{"rate limited", &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}, "rate limit"},
+		{"forbidden", &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "forbidden"}}}, "lacks permission"},
+		{"server error", &googleapi.Error{Code: 503}, "temporary"},
+		{"token revoked", &oauth2.RetrieveError{ErrorCode: "invalid_grant"}, "re-authenticate"},
+		{"plain", fmt.Errorf("event_id is required"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			res := toolErrorResult(tt.err)
+			if !res.IsError || res.Content[0].Text != tt.err.Error() {
+				t.Fatalf("toolErrorResult() = %+v, want raw error text", res)
+			}
+			explanation, _ := res.Meta["explanation"].(string)
+			if tt.want == "" {
+				if res.Meta != nil {
+					t.Fatalf("_meta = %v, want none", res.Meta)
+				}
+				return
+			}
+			if !strings.Contains(explanation, tt.want) {
+				t.Fatalf("explanation = %q, want it to mention %q", explanation, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleInput_Batch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "requests and a notification",
+			input: `[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"},{"jsonrpc":"2.0","id":2,"method":"nope"}]`,
+			want:  `[{"jsonrpc":"2.0","id":1,"result":{}},{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"Method not found","data":"nope"}}]`,
+		},
+		{
+			name:  "notifications only",
+			input: ` [{"jsonrpc":"2.0","method":"notifications/initialized"}]`,
+			want:  "",
+		},
+		{
+			name:  "empty batch",
+			input: `[]`,
+			want:  `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"batch must not be empty"}}`,
+		},
+		{
+			name:  "non-object entry",
+			input: `[1]`,
+			want:  `[{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"batch entries must be objects"}}]`,
+		},
+		{
+			name:  "malformed",
+			input: `[{"jsonrpc":"2.0"`,
+			want:  `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error","data":"unexpected end of JSON input"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out strings.Builder
+			s := &Server{writer: &out}
+			if resp := s.handleInput(context.Background(), []byte(tt.input)); resp != nil {
+				if err := s.writeResponse(resp); err != nil {
+					t.Fatalf("writeResponse() error = %v", err)
+				}
+			}
+			if got := strings.TrimSuffix(out.String(), "\n"); got != tt.want {
+				t.Fatalf("output = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatchTool_Account(t *testing.T) {
+	t.Parallel()
+
+	fakes := map[string]*fakeGoogleAPI{}
+	services := map[string]*accountServices{}
+	for _, account := range []string{defaultAccount, "work"} {
+		fake := newFakeGoogleAPI(t)
+		fake.respond("GET", "/users/me/calendarList", &calendar.CalendarList{Items: []*calendar.CalendarListEntry{
+			{Id: account + "@example.com", Summary: account},
+		}})
+		fakes[account] = fake
+		services[account] = &accountServices{calendar: fake.calendarService()}
+	}
+
+	tests := []struct {
+		name          string
+		serverAccount string
+		args          map[string]interface{}
+		want          string
+	}{
+		{"default", "", nil, defaultAccount},
+		{"argument", "", map[string]interface{}{"account": "work"}, "work"},
+		{"server flag", "work", nil, "work"},
+		{"argument over flag", "work", map[string]interface{}{"account": defaultAccount}, defaultAccount},
+	}
+	for _, tt := range tests {
+		s := &Server{opts: Options{Account: tt.serverAccount}, services: services}
+		result, err := s.dispatchTool(context.Background(), "list-calendars", tt.args)
+		if err != nil {
+			t.Fatalf("%s: dispatchTool() error = %v", tt.name, err)
+		}
+		cals, ok := result.([]calendarJSON)
+		if !ok || len(cals) != 1 || cals[0].Summary != tt.want {
+			t.Fatalf("%s: dispatchTool() = %+v, want the %s account's calendars", tt.name, result, tt.want)
+		}
+	}
+
+	s := &Server{services: services}
+	if _, err := s.dispatchTool(context.Background(), "list-calendars", map[string]interface{}{"account": "../work"}); err == nil {
+		t.Fatal("dispatchTool() with an invalid account succeeded")
+	}
+}
+
+func TestHandleToolsList_AccountProperty(t *testing.T) {
+	t.Parallel()
+
+	resp := (&Server{}).handleToolsList(&jsonrpcRequest{ID: json.RawMessage("1")})
+	for _, tool := range resp.Result.(*listToolsResult).Tools {
+		if _, ok := tool.InputSchema.Properties["account"]; !ok {
+			t.Errorf("tool %q has no account property", tool.Name)
+		}
+	}
+	for _, tool := range allTools() {
+		if _, ok := tool.InputSchema.Properties["account"]; ok {
+			t.Errorf("allTools() %q has an account property; it is stdio only", tool.Name)
+		}
+	}
+}
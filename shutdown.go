@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// shutdownGrace is how long requests in flight at shutdown may take to
+// finish before they are cut off.
+const shutdownGrace = 20 * time.Second
+
+// drainingContext returns a context for handling requests that outlives ctx
+// by grace, so a request started before shutdown can finish. Values of ctx
+// are kept. cancel must be called to release its resources.
+func drainingContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	drain, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.AfterFunc(grace, cancel)
+		context.AfterFunc(drain, func() { timer.Stop() })
+	})
+	return drain, func() {
+		stop()
+		cancel()
+	}
+}
+
+// serveUntilDone serves on ln until ctx is done, then shuts server down,
+// letting in-flight requests finish for up to grace before closing their
+// connections.
+func serveUntilDone(ctx context.Context, server *http.Server, ln net.Listener, grace time.Duration) error {
+	shutdownErr := make(chan error, 1)
+	stop := context.AfterFunc(ctx, func() {
+		slog.Info("shutting down, draining in-flight requests", "grace", grace)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+		defer cancel()
+		err := server.Shutdown(shutdownCtx)
+		if err != nil {
+			server.Close()
+		}
+		shutdownErr <- err
+	})
+	defer stop()
+
+	if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	// Serve returns as soon as Shutdown starts; wait for the drain.
+	if err := <-shutdownErr; err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeUntilDone_DrainsInFlightRequest(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- serveUntilDone(ctx, server, ln, 5*time.Second) }()
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	got := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/")
+		if err != nil {
+			got <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		got <- result{status: resp.StatusCode, body: string(body), err: err}
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request never reached the handler")
+	}
+	cancel()
+	// Give Shutdown time to close the listener before the handler returns.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	r := <-got
+	if r.err != nil {
+		t.Fatalf("in-flight request error = %v", r.err)
+	}
+	if r.status != http.StatusOK || r.body != "done" {
+		t.Fatalf("in-flight request = %d %q, want 200 \"done\"", r.status, r.body)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("serveUntilDone() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveUntilDone() did not return after shutdown")
+	}
+}
+
+func TestServerRun_FinishesRequestOnShutdown(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"items":[]}`)
+	})
+
+	stdin, input := io.Pipe()
+	defer input.Close()
+	var out bytes.Buffer
+	s := &Server{
+		services: map[string]*accountServices{
+			defaultAccount: {calendar: newTestCalendarService(t, handler)},
+		},
+		reader: bufio.NewReader(stdin),
+		writer: &out,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan error, 1)
+	go func() { ran <- s.Run(ctx) }()
+
+	go io.WriteString(input, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"list-events","arguments":{}}}`+"\n")
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Google API request was never made")
+	}
+	cancel()
+	close(release)
+
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after shutdown")
+	}
+
+	var resp jsonrpcResponse
+	if err := json.Unmarshal(bytes.TrimSpace(out.Bytes()), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", out.String(), err)
+	}
+	result, err := json.Marshal(resp.Result)
+	if err != nil {
+		t.Fatalf("encode result: %v", err)
+	}
+	if resp.Error != nil || strings.Contains(string(result), `"isError":true`) {
+		t.Fatalf("response = %s, want a successful result", out.String())
+	}
+}
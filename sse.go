@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sseKeepAlive is how often an idle GET /mcp stream gets a comment line, so
+// proxies do not close it for inactivity.
+const sseKeepAlive = 25 * time.Second
+
+// sseBuffer is how many undelivered messages a stream holds before further
+// notifications to it are dropped.
+const sseBuffer = 16
+
+// jsonrpcNotification is a server-initiated JSON-RPC notification.
+type jsonrpcNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// eventStreams tracks the open GET /mcp streams, keyed by user email, so
+// server-initiated notifications reach every session of a user.
+type eventStreams struct {
+	mu      sync.Mutex
+	streams map[string]map[chan []byte]struct{}
+}
+
+// subscribe opens a stream for userEmail. The returned function must be
+// called when the stream closes.
+func (s *eventStreams) subscribe(userEmail string) (<-chan []byte, func()) {
+	ch := make(chan []byte, sseBuffer)
+	s.mu.Lock()
+	if s.streams == nil {
+		s.streams = make(map[string]map[chan []byte]struct{})
+	}
+	if s.streams[userEmail] == nil {
+		s.streams[userEmail] = make(map[chan []byte]struct{})
+	}
+	s.streams[userEmail][ch] = struct{}{}
+	s.mu.Unlock()
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.streams[userEmail], ch)
+		if len(s.streams[userEmail]) == 0 {
+			delete(s.streams, userEmail)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// notify sends a notification to userEmail's open streams and reports how
+// many received it. A stream whose buffer is full misses it.
+func (s *eventStreams) notify(userEmail, method string, params any) int {
+	data, err := json.Marshal(jsonrpcNotification{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		slog.Error("encode notification failed", "method", method, "error", err)
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sent := 0
+	for ch := range s.streams[userEmail] {
+		select {
+		case ch <- data:
+			sent++
+		default:
+			slog.Warn("event stream full, notification dropped", "user", userEmail, "method", method)
+		}
+	}
+	return sent
+}
+
+// notifyUser sends data to userEmail's open GET /mcp streams as an MCP
+// notifications/message (a log message) at level. Background jobs use it to
+// report woken snoozed emails and sent or failed scheduled emails.
+func (h *HTTPServer) notifyUser(userEmail, level string, data map[string]any) {
+	h.streams.notify(userEmail, "notifications/message", map[string]any{
+		"level":  level,
+		"logger": "mcp-gcal",
+		"data":   data,
+	})
+}
+
+// acceptsEventStream reports whether the client asked for Server-Sent Events.
+func acceptsEventStream(r *http.Request) bool {
+	for _, v := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(v, ",") {
+			mediaType, _, _ := strings.Cut(part, ";")
+			if strings.EqualFold(strings.TrimSpace(mediaType), "text/event-stream") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// startEventStream writes the headers of an SSE response. The write deadline
+// is lifted, since WriteTimeout would otherwise cut the stream off.
+func startEventStream(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		slog.Warn("lift write deadline failed", "error", err)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+}
+
+// writeSSEMessage writes data, a JSON-RPC message, as an "event: message"
+// frame and flushes it to the client.
+func writeSSEMessage(w http.ResponseWriter, data []byte) error {
+	if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	if err := http.NewResponseController(w).Flush(); err != nil && err != http.ErrNotSupported {
+		return err
+	}
+	return nil
+}
+
+// writeJSONRPCEventStream writes resp, a response or a batch of them, as the
+// single message of an SSE response.
+func writeJSONRPCEventStream(w http.ResponseWriter, resp any) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		slog.Error("encode response failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	startEventStream(w)
+	if err := writeSSEMessage(w, data); err != nil {
+		slog.Debug("write event stream failed", "error", err)
+	}
+}
+
+// handleMCPStream serves GET /mcp: a Server-Sent Events stream carrying
+// server-initiated notifications for the authenticated user.
+func (h *HTTPServer) handleMCPStream(w http.ResponseWriter, r *http.Request) {
+	userEmail, status, err := h.authenticateRequest(r)
+	if err != nil {
+		if status == http.StatusUnauthorized {
+			setWWWAuthenticate(w, h.baseURL)
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	if !acceptsEventStream(r) {
+		writeJSON(w, http.StatusNotAcceptable, map[string]string{"error": "GET /mcp requires Accept: text/event-stream"})
+		return
+	}
+	h.serveEventStream(w, r, userEmail)
+}
+
+// serveEventStream relays userEmail's notifications to w until the client
+// disconnects or the server starts shutting down.
+func (h *HTTPServer) serveEventStream(w http.ResponseWriter, r *http.Request, userEmail string) {
+	messages, unsubscribe := h.streams.subscribe(userEmail)
+	defer unsubscribe()
+
+	startEventStream(w)
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.shuttingDown:
+			return
+		case data := <-messages:
+			if err := writeSSEMessage(w, data); err != nil {
+				slog.Debug("write event stream failed", "user", userEmail, "error", err)
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			_ = http.NewResponseController(w).Flush()
+		}
+	}
+}
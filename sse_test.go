@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptsEventStream(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"application/json, text/event-stream", true},
+		{"Text/Event-Stream;q=0.9", true},
+		{"text/event-streaming", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := acceptsEventStream(r); got != tt.want {
+			t.Errorf("acceptsEventStream(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestHandleMCPRequest_EventStream(t *testing.T) {
+	t.Parallel()
+
+	h := &HTTPServer{}
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"ping"}`))
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	rec := httptest.NewRecorder()
+	h.handleMCPRequest(rec, req, "user@example.com")
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	body := rec.Body.String()
+	data, ok := strings.CutPrefix(body, "event: message\ndata: ")
+	if !ok || !strings.HasSuffix(data, "\n\n") {
+		t.Fatalf("body = %q, want one event: message frame", body)
+	}
+	var resp jsonrpcResponse
+	if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &resp); err != nil {
+		t.Fatalf("decode frame data: %v (%s)", err, data)
+	}
+	if string(resp.ID) != "7" || resp.Error != nil {
+		t.Fatalf("response = %+v, want ping result for id 7", resp)
+	}
+
+	// Without the Accept header the response stays plain JSON.
+	rec = httptest.NewRecorder()
+	h.handleMCPRequest(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"ping"}`)), "user@example.com")
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type without SSE = %q, want application/json", ct)
+	}
+}
+
+func TestServeEventStream(t *testing.T) {
+	t.Parallel()
+
+	h := &HTTPServer{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.serveEventStream(w, r, r.URL.Query().Get("user"))
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"?user=alice@example.com", nil)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET stream error = %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	// The handler subscribes before writing headers, so the stream is open.
+	if n := h.streams.notify("bob@example.com", "notifications/message", nil); n != 0 {
+		t.Fatalf("notify(other user) reached %d streams, want 0", n)
+	}
+	if n := h.streams.notify("alice@example.com", "notifications/progress", map[string]any{"progress": 1}); n != 1 {
+		t.Fatalf("notify() reached %d streams, want 1", n)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	event, _ := reader.ReadString('\n')
+	data, _ := reader.ReadString('\n')
+	if event != "event: message\n" {
+		t.Fatalf("event line = %q, want event: message", event)
+	}
+	var note jsonrpcNotification
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &note); err != nil {
+		t.Fatalf("decode frame data: %v (%q)", err, data)
+	}
+	if note.JSONRPC != "2.0" || note.Method != "notifications/progress" {
+		t.Fatalf("notification = %+v, want notifications/progress", note)
+	}
+
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for h.streams.notify("alice@example.com", "notifications/message", nil) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream still subscribed after the client disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
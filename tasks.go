@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/tasks/v1"
+)
+
+// TasksService wraps the Google Tasks API.
+type TasksService struct {
+	svc  *tasks.Service
+	opts Options
+}
+
+// NewTasksService creates a Tasks API client from a token source.
+func NewTasksService(ctx context.Context, ts oauth2.TokenSource, opts Options) (*TasksService, error) {
+	svc, err := tasks.NewService(ctx, googleClientOption(ts, opts.RequestTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("create tasks service: %w", err)
+	}
+	return &TasksService{svc: svc, opts: opts}, nil
+}
+
+// JSON output types
+
+type taskJSON struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Notes     string `json:"notes,omitempty"`
+	Due       string `json:"due,omitempty"`
+	Status    string `json:"status"`
+	Completed string `json:"completed,omitempty"`
+}
+
+func convertTask(t *tasks.Task) taskJSON {
+	task := taskJSON{
+		ID:     t.Id,
+		Title:  t.Title,
+		Notes:  t.Notes,
+		Status: t.Status,
+	}
+	// The API only stores the date part of due; drop the meaningless time.
+	if due, err := time.Parse(time.RFC3339, t.Due); err == nil {
+		task.Due = due.Format("2006-01-02")
+	}
+	if t.Completed != nil {
+		task.Completed = *t.Completed
+	}
+	return task
+}
+
+// tasksScopeHint adds a re-authentication hint to errors caused by a token
+// granted before the Tasks scope was requested.
+func tasksScopeHint(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden && strings.Contains(strings.ToLower(apiErr.Message), "insufficient") {
+		return fmt.Errorf("%w\nThe Tasks scope was added after this account signed in; re-authenticate to grant it.", err)
+	}
+	return err
+}
+
+// ListTasks lists tasks in a task list ("@default" if empty).
+func (ts *TasksService) ListTasks(ctx context.Context, taskListID string, showCompleted bool, maxResults int64) ([]taskJSON, error) {
+	if taskListID == "" {
+		taskListID = "@default"
+	}
+	if maxResults <= 0 {
+		maxResults = 50
+	}
+	maxResults, _ = clampMaxResults(maxResults, ts.opts.MaxResultsCeiling)
+
+	resp, err := ts.svc.Tasks.List(taskListID).
+		ShowCompleted(showCompleted).
+		ShowHidden(showCompleted).
+		MaxResults(maxResults).
+		Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("list tasks: %w", err)
+	}
+
+	result := make([]taskJSON, 0, len(resp.Items))
+	for _, t := range resp.Items {
+		result = append(result, convertTask(t))
+	}
+	return result, nil
+}
+
+// CreateTask creates a task in a task list ("@default" if empty). due is an
+// optional YYYY-MM-DD date.
+func (ts *TasksService) CreateTask(ctx context.Context, taskListID, title, notes, due string) (*taskJSON, error) {
+	if taskListID == "" {
+		taskListID = "@default"
+	}
+	if title == "" {
+		return nil, fmt.Errorf("title is required")
+	}
+	task := &tasks.Task{Title: title, Notes: notes}
+	if due != "" {
+		d, err := time.Parse("2006-01-02", due)
+		if err != nil {
+			return nil, fmt.Errorf("due must be a YYYY-MM-DD date: %w", err)
+		}
+		task.Due = d.Format(time.RFC3339)
+	}
+
+	created, err := ts.svc.Tasks.Insert(taskListID, task).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("create task: %w", err)
+	}
+	result := convertTask(created)
+	return &result, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/tasks/v1"
+)
+
+// newTestTasksService returns a TasksService whose API calls are served by handler.
+func newTestTasksService(t *testing.T, handler http.Handler) *TasksService {
+	t.Helper()
+	fake := httptest.NewServer(handler)
+	t.Cleanup(fake.Close)
+	svc, err := tasks.NewService(context.Background(),
+		option.WithHTTPClient(fake.Client()),
+		option.WithEndpoint(fake.URL+"/"),
+	)
+	if err != nil {
+		t.Fatalf("tasks.NewService() error = %v", err)
+	}
+	return &TasksService{svc: svc}
+}
+
+func TestDispatchTasksTool_ListAndCreate(t *testing.T) {
+	t.Parallel()
+
+	completed := "2025-03-02T08:00:00.000Z"
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/tasks/v1/lists/@default/tasks", &tasks.Tasks{Items: []*tasks.Task{
+		{Id: "t1", Title: "File taxes", Due: "2025-04-15T00:00:00.000Z", Status: "needsAction"},
+		{Id: "t2", Title: "Book flights", Status: "completed", Completed: &completed},
+	}})
+	fake.handle("POST", "/tasks/v1/lists/@default/tasks", func(r *http.Request, body []byte) any {
+		return &tasks.Task{Id: "t3", Title: "Call Bob", Due: "2025-03-10T00:00:00.000Z", Status: "needsAction"}
+	})
+	ts := fake.tasksService()
+
+	result, err := dispatchTasksTool(context.Background(), ts, "list-tasks", map[string]interface{}{"show_completed": true})
+	if err != nil {
+		t.Fatalf("dispatchTasksTool(list-tasks) error = %v", err)
+	}
+	list, ok := result.([]taskJSON)
+	if !ok || len(list) != 2 || list[0].Due != "2025-04-15" || list[1].Completed != completed {
+		t.Fatalf("list-tasks result = %#v", result)
+	}
+	if req, _ := fake.request("GET", "/tasks/v1/lists/@default/tasks"); req.Query.Get("showCompleted") != "true" {
+		t.Fatalf("list-tasks query = %v, want showCompleted", req.Query)
+	}
+
+	if _, err := dispatchTasksTool(context.Background(), ts, "create-task", map[string]interface{}{"title": "x", "due": "next week"}); err == nil {
+		t.Fatalf("create-task with invalid due expected error")
+	}
+	result, err = dispatchTasksTool(context.Background(), ts, "create-task", map[string]interface{}{"title": "Call Bob", "due": "2025-03-10"})
+	if err != nil {
+		t.Fatalf("dispatchTasksTool(create-task) error = %v", err)
+	}
+	if task := result.(*taskJSON); task.ID != "t3" || task.Due != "2025-03-10" {
+		t.Fatalf("create-task result = %+v", task)
+	}
+	req, _ := fake.request("POST", "/tasks/v1/lists/@default/tasks")
+	var created tasks.Task
+	fake.decodeBody(req, &created)
+	if created.Title != "Call Bob" || created.Due != "2025-03-10T00:00:00Z" {
+		t.Fatalf("create-task request = %+v", created)
+	}
+}
+
+func TestDispatchTasksTool_InsufficientScopeHint(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.fail("GET", "/tasks/v1/lists/@default/tasks", http.StatusForbidden, "Request had insufficient authentication scopes.")
+
+	_, err := dispatchTasksTool(context.Background(), fake.tasksService(), "list-tasks", nil)
+	if err == nil || !strings.Contains(err.Error(), "re-authenticate") {
+		t.Fatalf("list-tasks error = %v, want re-authentication hint", err)
+	}
+}
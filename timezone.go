@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// zoneinfoDirs are where Unix systems keep the IANA timezone database, in the
+// order the time package searches them. $ZONEINFO takes precedence.
+var zoneinfoDirs = []string{
+	"/usr/share/zoneinfo",
+	"/usr/share/lib/zoneinfo",
+	"/usr/lib/locale/TZ",
+	"/etc/zoneinfo",
+}
+
+// maxTimezoneSuggestions caps the alternatives offered for an unknown timezone.
+const maxTimezoneSuggestions = 5
+
+// knownTimezones returns the IANA timezone names in the system database,
+// sorted. It is empty if no database is installed.
+var knownTimezones = sync.OnceValue(func() []string {
+	dirs := zoneinfoDirs
+	if dir := os.Getenv("ZONEINFO"); dir != "" {
+		dirs = append([]string{dir}, dirs...)
+	}
+	for _, dir := range dirs {
+		if zones := listTimezones(os.DirFS(dir)); len(zones) > 0 {
+			return zones
+		}
+	}
+	return nil
+})
+
+// listTimezones returns the zone names of the TZif files in a zoneinfo tree.
+// The posix/ and right/ variant trees, and aliases such as localtime, are
+// skipped.
+func listTimezones(fsys fs.FS) []string {
+	var zones []string
+	_ = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return nil
+		}
+		base := path.Base(name)
+		if d.IsDir() {
+			if base == "posix" || base == "right" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		// Zone names start with an uppercase letter; data files such as
+		// zone.tab or leapseconds do not, or contain a dot.
+		if base[0] < 'A' || base[0] > 'Z' || strings.Contains(base, ".") || base == "Factory" {
+			return nil
+		}
+		if isTZif(fsys, name) {
+			zones = append(zones, name)
+		}
+		return nil
+	})
+	sort.Strings(zones)
+	return zones
+}
+
+// isTZif reports whether the named file starts with the TZif magic.
+func isTZif(fsys fs.FS, name string) bool {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return string(magic) == "TZif"
+}
+
+// filterTimezones returns the zones matching query, ignoring case and
+// treating spaces as underscores ("new york" matches America/New_York).
+func filterTimezones(zones []string, query string) []string {
+	query = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(query), " ", "_"))
+	if query == "" {
+		return zones
+	}
+	matches := []string{}
+	for _, z := range zones {
+		if strings.Contains(strings.ToLower(z), query) {
+			matches = append(matches, z)
+		}
+	}
+	return matches
+}
+
+// validateTimezone checks that tz is an IANA timezone name. Empty means
+// unset. On failure the error suggests similar known names.
+func validateTimezone(tz string) error {
+	if tz == "" {
+		return nil
+	}
+	// "Local" is the server's own zone, which Google would not understand.
+	if tz != "Local" {
+		if _, err := time.LoadLocation(tz); err == nil {
+			return nil
+		}
+	}
+	msg := fmt.Sprintf("unknown timezone %q: use an IANA name such as America/New_York (see list-timezones)", tz)
+	if suggestions := suggestTimezones(knownTimezones(), tz); len(suggestions) > 0 {
+		msg += "; did you mean " + strings.Join(suggestions, ", ") + "?"
+	}
+	return errors.New(msg)
+}
+
+// suggestTimezones returns zones resembling name, comparing without case,
+// spaces, underscores or hyphens against the city part of name (so
+// "America/NewYork" and "tokyo" both find a match).
+func suggestTimezones(zones []string, name string) []string {
+	normalize := strings.NewReplacer(" ", "", "_", "", "-", "").Replace
+	want := strings.ToLower(normalize(path.Base(name)))
+	if want == "" || want == "." || want == "/" {
+		return nil
+	}
+	var suggestions []string
+	for _, z := range zones {
+		if strings.Contains(strings.ToLower(normalize(z)), want) {
+			suggestions = append(suggestions, z)
+			if len(suggestions) == maxTimezoneSuggestions {
+				break
+			}
+		}
+	}
+	return suggestions
+}
+
+// defaultTimezone returns tz, or the server's --timezone if tz is empty.
+func (cs *CalendarService) defaultTimezone(tz string) string {
+	if tz == "" {
+		return cs.opts.Timezone
+	}
+	return tz
+}
+
+// location returns the server's default timezone, UTC if none is set.
+func (cs *CalendarService) location() *time.Location {
+	if cs.opts.Timezone != "" {
+		if loc, err := time.LoadLocation(cs.opts.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// currentTimeJSON is the result of get-current-time.
+type currentTimeJSON struct {
+	Now      string `json:"now"`
+	Date     string `json:"date"`
+	Weekday  string `json:"weekday"`
+	Timezone string `json:"timezone"`
+}
+
+// currentTime describes now in timezone tz, UTC if empty.
+func currentTime(now time.Time, tz string) (*currentTimeJSON, error) {
+	if tz == "" {
+		tz = "UTC"
+	}
+	if err := validateTimezone(tz); err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+	now = now.In(loc)
+	return &currentTimeJSON{
+		Now:      now.Format(time.RFC3339),
+		Date:     now.Format("2006-01-02"),
+		Weekday:  now.Weekday().String(),
+		Timezone: tz,
+	}, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestListTimezones(t *testing.T) {
+	t.Parallel()
+
+	tzif := &fstest.MapFile{Data: []byte("TZif2...")}
+	fsys := fstest.MapFS{
+		"America/New_York":          tzif,
+		"America/Argentina/Cordoba": tzif,
+		"Asia/Tokyo":                tzif,
+		"UTC":                       tzif,
+		"posix/Asia/Tokyo":          tzif,
+		"right/UTC":                 tzif,
+		"localtime":                 tzif,
+		"Factory":                   tzif,
+		"zone.tab":                  {Data: []byte("# tz zone descriptions")},
+		"Asia/README":               {Data: []byte("not a zone")},
+	}
+
+	got := strings.Join(listTimezones(fsys), " ")
+	want := "America/Argentina/Cordoba America/New_York Asia/Tokyo UTC"
+	if got != want {
+		t.Fatalf("listTimezones() = %q, want %q", got, want)
+	}
+}
+
+func TestFilterTimezones(t *testing.T) {
+	t.Parallel()
+
+	zones := []string{"America/New_York", "America/St_Johns", "Asia/Tokyo"}
+	if got := filterTimezones(zones, "new york"); len(got) != 1 || got[0] != "America/New_York" {
+		t.Fatalf("filterTimezones(new york) = %q", got)
+	}
+	if got := filterTimezones(zones, "AMERICA"); len(got) != 2 {
+		t.Fatalf("filterTimezones(AMERICA) = %q, want 2 zones", got)
+	}
+	if got := filterTimezones(zones, ""); len(got) != 3 {
+		t.Fatalf("filterTimezones(\"\") = %q, want all zones", got)
+	}
+}
+
+func TestSuggestTimezones(t *testing.T) {
+	t.Parallel()
+
+	zones := []string{"America/New_York", "Asia/Tokyo", "Europe/London"}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"America/NewYork", "America/New_York"},
+		{"tokyo", "Asia/Tokyo"},
+		{"Pacific/Atlantis", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(suggestTimezones(zones, tt.name), ","); got != tt.want {
+			t.Fatalf("suggestTimezones(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTimezone(t *testing.T) {
+	t.Parallel()
+
+	for _, tz := range []string{"", "UTC", "Asia/Tokyo"} {
+		if err := validateTimezone(tz); err != nil {
+			t.Fatalf("validateTimezone(%q) error = %v", tz, err)
+		}
+	}
+	for _, tz := range []string{"Local", "Mars/Olympus_Mons", "America/NewYork"} {
+		err := validateTimezone(tz)
+		if err == nil || !strings.Contains(err.Error(), "unknown timezone") {
+			t.Fatalf("validateTimezone(%q) error = %v, want unknown timezone", tz, err)
+		}
+	}
+}
+
+func TestCurrentTime(t *testing.T) {
+	t.Parallel()
+
+	if _, err := time.LoadLocation("Asia/Tokyo"); err != nil {
+		t.Skip("timezone database not available")
+	}
+	now := time.Date(2025, 3, 10, 20, 30, 0, 0, time.UTC)
+
+	got, err := currentTime(now, "Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("currentTime() error = %v", err)
+	}
+	want := &currentTimeJSON{Now: "2025-03-11T05:30:00+09:00", Date: "2025-03-11", Weekday: "Tuesday", Timezone: "Asia/Tokyo"}
+	if *got != *want {
+		t.Fatalf("currentTime() = %+v, want %+v", got, want)
+	}
+
+	got, err = currentTime(now, "")
+	if err != nil || got.Now != "2025-03-10T20:30:00Z" || got.Timezone != "UTC" {
+		t.Fatalf("currentTime(\"\") = %+v, %v, want UTC", got, err)
+	}
+	if _, err := currentTime(now, "Mars/Olympus"); err == nil {
+		t.Fatal("currentTime() with an unknown timezone succeeded")
+	}
+}
+
+func TestDispatchTool_GetCurrentTimeWithoutAuth(t *testing.T) {
+	t.Parallel()
+
+	// No token or services: the tool must not need Google.
+	s := &Server{opts: Options{Timezone: "UTC"}}
+	result, err := s.dispatchTool(context.Background(), "get-current-time", nil)
+	if err != nil {
+		t.Fatalf("dispatchTool(get-current-time) error = %v", err)
+	}
+	if ct, ok := result.(*currentTimeJSON); !ok || ct.Timezone != "UTC" || ct.Weekday == "" {
+		t.Fatalf("get-current-time result = %#v", result)
+	}
+
+	// Neither do the other tools answered locally.
+	for _, name := range []string{"list-timezones", "parse-event-metadata"} {
+		if !isLocalTool(name) {
+			t.Fatalf("isLocalTool(%s) = false, want true", name)
+		}
+	}
+	result, err = s.dispatchTool(context.Background(), "parse-event-metadata", map[string]interface{}{"description": "Notes"})
+	if err != nil {
+		t.Fatalf("dispatchTool(parse-event-metadata) error = %v", err)
+	}
+	if m, ok := result.(map[string]any); !ok || m["description"] != "Notes" {
+		t.Fatalf("parse-event-metadata result = %#v", result)
+	}
+}
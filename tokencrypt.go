@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// encryptedTokenPrefix marks a token_json value sealed with AES-256-GCM. The
+// rest of the value is base64(nonce || ciphertext). Values without it are
+// plaintext JSON, as written before a key was configured.
+const encryptedTokenPrefix = "enc:v1:"
+
+// tokenKeySize is the AES-256 key size. The key is used as given rather than
+// derived from a passphrase, so it must be random.
+const tokenKeySize = 32
+
+// loadTokenKey decodes the token encryption key from secret (the
+// MCP_GCAL_DB_KEY value) or, if secret is empty, the contents of keyFile.
+// The key is 32 random bytes, base64-encoded as by "openssl rand -base64 32".
+// It returns nil when neither is set, leaving tokens in plaintext.
+func loadTokenKey(secret, keyFile string) ([]byte, error) {
+	if secret == "" && keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read token key file: %w", err)
+		}
+		secret = strings.TrimSpace(string(data))
+		if secret == "" {
+			return nil, fmt.Errorf("token key file %s is empty", keyFile)
+		}
+	}
+	if secret == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil || len(key) != tokenKeySize {
+		return nil, fmt.Errorf("token key must be %d random bytes, base64-encoded (generate one with: openssl rand -base64 %d)", tokenKeySize, tokenKeySize)
+	}
+	return key, nil
+}
+
+// isEncryptedToken reports whether a stored token_json value is encrypted.
+func isEncryptedToken(stored string) bool {
+	return strings.HasPrefix(stored, encryptedTokenPrefix)
+}
+
+// sealToken encrypts plaintext under key, binding it to aad (the row it is
+// stored in) so a ciphertext copied to another row fails to decrypt.
+func sealToken(key []byte, plaintext, aad string) (string, error) {
+	gcm, err := newTokenGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(aad))
+	return encryptedTokenPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openToken returns the plaintext of a stored token_json value, decrypting it
+// with key if it is encrypted.
+func openToken(key []byte, stored, aad string) (string, error) {
+	if !isEncryptedToken(stored) {
+		return stored, nil
+	}
+	if key == nil {
+		return "", fmt.Errorf("token is encrypted; set MCP_GCAL_DB_KEY or --db-key-file")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedTokenPrefix))
+	if err != nil {
+		return "", fmt.Errorf("decode encrypted token: %w", err)
+	}
+	gcm, err := newTokenGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("decrypt token: ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("decrypt token (wrong key?): %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func newTokenGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create token cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
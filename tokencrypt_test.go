@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testTokenKey and otherTestTokenKey are valid MCP_GCAL_DB_KEY values.
+const (
+	testTokenKey      = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	otherTestTokenKey = "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+)
+
+func TestSealOpenToken(t *testing.T) {
+	t.Parallel()
+
+	key, err := loadTokenKey(testTokenKey, "")
+	if err != nil || len(key) != 32 {
+		t.Fatalf("loadTokenKey() = %d bytes, %v, want a 32-byte key", len(key), err)
+	}
+	sealed, err := sealToken(key, `{"access_token":"a"}`, "users:a@example.com")
+	if err != nil {
+		t.Fatalf("sealToken() error = %v", err)
+	}
+	if !isEncryptedToken(sealed) {
+		t.Fatalf("sealToken() = %q, want %s prefix", sealed, encryptedTokenPrefix)
+	}
+	if again, _ := sealToken(key, `{"access_token":"a"}`, "users:a@example.com"); again == sealed {
+		t.Fatal("sealToken() reused a nonce")
+	}
+
+	got, err := openToken(key, sealed, "users:a@example.com")
+	if err != nil || got != `{"access_token":"a"}` {
+		t.Fatalf("openToken() = %q, %v, want the plaintext", got, err)
+	}
+	if got, err := openToken(nil, `{"access_token":"p"}`, "users:a@example.com"); err != nil || got != `{"access_token":"p"}` {
+		t.Fatalf("openToken(plaintext) = %q, %v, want it unchanged", got, err)
+	}
+
+	otherKey, _ := loadTokenKey(otherTestTokenKey, "")
+	for name, open := range map[string]func() (string, error){
+		"wrong key": func() (string, error) { return openToken(otherKey, sealed, "users:a@example.com") },
+		"wrong row": func() (string, error) { return openToken(key, sealed, "users:b@example.com") },
+		"no key":    func() (string, error) { return openToken(nil, sealed, "users:a@example.com") },
+		"truncated": func() (string, error) { return openToken(key, encryptedTokenPrefix+"AAAA", "users:a@example.com") },
+	} {
+		if _, err := open(); err == nil {
+			t.Errorf("openToken(%s) succeeded", name)
+		}
+	}
+}
+
+func TestLoadTokenKey(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, []byte(testTokenKey+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fromFile, err := loadTokenKey("", keyFile)
+	if err != nil {
+		t.Fatalf("loadTokenKey(file) error = %v", err)
+	}
+	fromSecret, _ := loadTokenKey(testTokenKey, "")
+	if string(fromFile) != string(fromSecret) {
+		t.Fatal("key file contents not trimmed like the env value")
+	}
+	if key, err := loadTokenKey("", ""); key != nil || err != nil {
+		t.Fatalf("loadTokenKey(none) = %v, %v, want nil, nil", key, err)
+	}
+	if _, err := loadTokenKey("", emptyFile); err == nil {
+		t.Fatal("loadTokenKey(empty file) succeeded")
+	}
+	if _, err := loadTokenKey("", filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("loadTokenKey(missing file) succeeded")
+	}
+	// A passphrase is not a key: it would be used without a KDF.
+	for _, secret := range []string{"correct horse battery staple", "MDEyMzQ1Njc4OWFiY2RlZg=="} {
+		if _, err := loadTokenKey(secret, ""); err == nil {
+			t.Errorf("loadTokenKey(%q) succeeded, want a 32-byte key required", secret)
+		}
+	}
+}
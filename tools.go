@@ -3,42 +3,156 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/oauth2"
 )
 
 // allTools returns all MCP tool definitions with input schemas.
+// Every schema rejects arguments that are not declared in its properties.
 func allTools() []mcpTool {
-	return []mcpTool{
+	tools := []mcpTool{
 		{
 			Name:        "authenticate",
-			Description: "Authenticate with Google Calendar and Gmail via OAuth2. Opens a browser for Google login. Must be called before using other tools if not already authenticated.",
+			Description: "Authenticate with Google Calendar and Gmail via OAuth2. Opens a browser for Google login. Must be called before using other tools if not already authenticated. Returns immediately if a valid token already exists unless force is set.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"force": {Type: "boolean", Description: "Re-run the browser login even if a valid token exists (default: false)"},
+				},
+			},
+		},
+		{
+			Name:        "list-calendars",
+			Description: "List all Google Calendar calendars accessible to the authenticated user, with their colors.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"show_hidden": {Type: "boolean", Description: "Include calendars hidden from the user's calendar list (default: false)"},
+				},
+			},
+		},
+		{
+			Name:        "get-calendar",
+			Description: "Get details of a calendar, including your access role (owner, writer, reader, freeBusyReader), color, default reminders, and notification settings.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"calendar_id": {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
+				},
+			},
+		},
+		{
+			Name:        "get-calendar-settings",
+			Description: "Get the user's Google Calendar settings, such as default timezone, week start, locale, and date/time format.",
 			InputSchema: inputSchema{
 				Type:       "object",
 				Properties: map[string]property{},
 			},
 		},
 		{
-			Name:        "list-calendars",
-			Description: "List all Google Calendar calendars accessible to the authenticated user.",
+			Name:        "create-calendar",
+			Description: "Create a new secondary calendar, e.g. for a project. Returns the new calendar's ID.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"summary":     {Type: "string", Description: "Calendar name (required)"},
+					"description": {Type: "string", Description: "Calendar description"},
+					"timezone":    {Type: "string", Description: "IANA timezone (e.g., America/New_York; see list-timezones; default: the account's timezone)"},
+				},
+				Required: []string{"summary"},
+			},
+		},
+		{
+			Name:        "delete-calendar",
+			Description: "Permanently delete a secondary calendar and all its events. The primary calendar cannot be deleted.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"calendar_id": {Type: "string", Description: "ID of the calendar to delete (required)"},
+				},
+				Required: []string{"calendar_id"},
+			},
+		},
+		{
+			Name:        "list-recent-calendars",
+			Description: "List calendar IDs recently used to create, update, move, or delete events, most recent first. Use this to pick a better default than primary.",
+			InputSchema: inputSchema{
+				Type:       "object",
+				Properties: map[string]property{},
+			},
+		},
+		{
+			Name:        "set-default-calendar",
+			Description: "Set the calendar used by calendar tools when calendar_id is omitted, instead of the primary calendar. Set \"primary\" to go back to the primary calendar.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"calendar_id": {Type: "string", Description: "Calendar ID (required)"},
+				},
+				Required: []string{"calendar_id"},
+			},
+		},
+		{
+			Name:        "get-default-calendar",
+			Description: "Get the calendar used by calendar tools when calendar_id is omitted.",
+			InputSchema: inputSchema{
+				Type:       "object",
+				Properties: map[string]property{},
+			},
+		},
+		{
+			Name:        "watch-calendar",
+			Description: "Open a push notification channel (watch): Google posts to an HTTPS address you run whenever an event in the calendar changes. Stop it with stop-watch.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"address":     {Type: "string", Description: "HTTPS URL Google sends the notifications to (required)"},
+					"calendar_id": {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
+					"ttl_hours":   {Type: "number", Description: "Hours until the channel expires; Google may choose a shorter time (default: Google's, about a week)"},
+				},
+				Required: []string{"address"},
+			},
+		},
+		{
+			Name:        "list-watches",
+			Description: "List the calendar push notification channels (watches) opened for this account, with their expiration. Expired channels are flagged.",
 			InputSchema: inputSchema{
 				Type:       "object",
 				Properties: map[string]property{},
 			},
 		},
+		{
+			Name:        "stop-watch",
+			Description: "Stop a calendar push notification channel and forget it.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"channel_id": {Type: "string", Description: "Channel ID from list-watches (required)"},
+				},
+				Required: []string{"channel_id"},
+			},
+		},
 		{
 			Name:        "list-events",
-			Description: "List upcoming events from a Google Calendar.",
+			Description: "List upcoming events from a Google Calendar. Returns an events array and, when more events match, a nextPageToken for the next page.",
 			InputSchema: inputSchema{
 				Type: "object",
 				Properties: map[string]property{
-					"calendar_id":   {Type: "string", Description: "Calendar ID (default: primary)"},
-					"time_min":      {Type: "string", Description: "Start of time range in RFC3339 format (default: now)"},
-					"time_max":      {Type: "string", Description: "End of time range in RFC3339 format (default: 7 days from now)"},
-					"max_results":   {Type: "number", Description: "Maximum number of events to return (default: 50)"},
-					"single_events": {Type: "boolean", Description: "Whether to expand recurring events (default: true)"},
-					"order_by":      {Type: "string", Description: "Sort order: startTime or updated (default: startTime)"},
+					"calendar_id":      {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
+					"time_min":         {Type: "string", Description: "Start of time range in RFC3339 format (default: now)"},
+					"time_max":         {Type: "string", Description: "End of time range in RFC3339 format (default: 7 days from now)"},
+					"max_results":      {Type: "number", Description: "Maximum number of events to return (default: 50)"},
+					"single_events":    {Type: "boolean", Description: "Whether to expand recurring events (default: true)"},
+					"order_by":         {Type: "string", Description: "Sort order: startTime or updated (default: startTime)"},
+					"my_events_only":   {Type: "boolean", Description: "Only return events you are attending (accepted, tentative, or not yet responded) or organized without guests (default: false)"},
+					"exclude_declined": {Type: "boolean", Description: "Omit events you have declined (default: false)"},
+					"page_token":       {Type: "string", Description: "nextPageToken from a previous call with the same arguments, to fetch the next page of events"},
 				},
 			},
 		},
@@ -48,12 +162,77 @@ func allTools() []mcpTool {
 			InputSchema: inputSchema{
 				Type: "object",
 				Properties: map[string]property{
-					"event_id":    {Type: "string", Description: "Event ID (required)"},
-					"calendar_id": {Type: "string", Description: "Calendar ID (default: primary)"},
+					"event_id":             {Type: "string", Description: "Event ID (required)"},
+					"calendar_id":          {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
+					"include_availability": {Type: "boolean", Description: "Mark each attendee busy or free during the event, to spot conflicts (default: false)"},
+					"include_metadata":     {Type: "boolean", Description: "Move the description's metadata footer (see create-event description_template) into a metadata field (default: false)"},
 				},
 				Required: []string{"event_id"},
 			},
 		},
+		{
+			Name:        "get-events",
+			Description: "Get details of several calendar events at once, e.g. to follow up on search results. Each entry has the event or an error.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"event_ids":   {Type: "string", Description: "Comma-separated event IDs, at most 50 (required)"},
+					"calendar_id": {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
+				},
+				Required: []string{"event_ids"},
+			},
+		},
+		{
+			Name:        "get-freebusy",
+			Description: "Get the busy time of several calendars or people, to find when they are all free for a meeting. Returns busy periods per calendar ID.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"calendar_ids": {Type: "string", Description: "Comma-separated calendar IDs or attendee emails, at most 50 (default: primary)"},
+					"time_min":     {Type: "string", Description: "Start of time range in RFC3339 format (default: now)"},
+					"time_max":     {Type: "string", Description: "End of time range in RFC3339 format (default: 7 days from now)"},
+				},
+			},
+		},
+		{
+			Name:        "list-timezones",
+			Description: "List the IANA timezone names accepted by create-event's timezone argument.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"query": {Type: "string", Description: "Only return names containing this text, ignoring case; spaces match underscores (e.g. \"new york\")"},
+				},
+			},
+		},
+		{
+			Name:        "list-colors",
+			Description: "List the color IDs accepted by create-event and update-event's color_id, with the background and foreground colors each one is shown in.",
+			InputSchema: inputSchema{
+				Type:       "object",
+				Properties: map[string]property{},
+			},
+		},
+		{
+			Name:        "get-current-time",
+			Description: "Get the current date, time and weekday. Call this before working out relative dates such as \"next Tuesday\" or \"tomorrow\".",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"timezone": {Type: "string", Description: "IANA timezone to report the time in (default: the server's default timezone)"},
+				},
+			},
+		},
+		{
+			Name:        "parse-event-metadata",
+			Description: "Split an event description into its text and the metadata footer added by create-event's description_template.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"description": {Type: "string", Description: "Event description (required)"},
+				},
+				Required: []string{"description"},
+			},
+		},
 		{
 			Name:        "search-events",
 			Description: "Search calendar events by text query.",
@@ -61,46 +240,89 @@ func allTools() []mcpTool {
 				Type: "object",
 				Properties: map[string]property{
 					"query":       {Type: "string", Description: "Search query text (required)"},
-					"calendar_id": {Type: "string", Description: "Calendar ID (default: primary)"},
+					"calendar_id": {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
 					"time_min":    {Type: "string", Description: "Start of time range in RFC3339 format"},
 					"time_max":    {Type: "string", Description: "End of time range in RFC3339 format"},
 					"max_results": {Type: "number", Description: "Maximum number of events to return (default: 50)"},
+					"order_by":    {Type: "string", Description: "Sort order: startTime or updated (default: startTime)"},
 				},
 				Required: []string{"query"},
 			},
 		},
+		{
+			Name:        "whats-changed",
+			Description: "Catch up on calendar changes: the events created, updated or deleted across all your calendars since a time, or since a previous call. Pass the returned token next time to continue from here.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"since": {Type: "string", Description: "Report changes since this time, in RFC3339 format; at most 30 days ago"},
+					"token": {Type: "string", Description: "Token from a previous whats-changed call within the last 30 days, to report changes since that call. Use instead of since"},
+				},
+			},
+		},
 		{
 			Name:        "create-event",
 			Description: "Create a new calendar event. Use RFC3339 for timed events or YYYY-MM-DD for all-day events.",
 			InputSchema: inputSchema{
 				Type: "object",
 				Properties: map[string]property{
-					"summary":     {Type: "string", Description: "Event title (required)"},
-					"start":       {Type: "string", Description: "Start time in RFC3339 or YYYY-MM-DD (required)"},
-					"end":         {Type: "string", Description: "End time in RFC3339 or YYYY-MM-DD (required)"},
-					"calendar_id": {Type: "string", Description: "Calendar ID (default: primary)"},
-					"description": {Type: "string", Description: "Event description"},
-					"location":    {Type: "string", Description: "Event location"},
-					"attendees":   {Type: "string", Description: "Comma-separated attendee email addresses"},
-					"timezone":    {Type: "string", Description: "Timezone (e.g., America/New_York)"},
+					"summary":              {Type: "string", Description: "Event title (required)"},
+					"start":                {Type: "string", Description: "Start time in RFC3339 or YYYY-MM-DD (required)"},
+					"end":                  {Type: "string", Description: "End time in RFC3339 or YYYY-MM-DD (required)"},
+					"calendar_id":          {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
+					"description":          {Type: "string", Description: "Event description"},
+					"location":             {Type: "string", Description: "Event location"},
+					"attendees":            {Type: "string", Description: `Comma-separated attendee email addresses, each optionally suffixed with :optional (e.g. "a@example.com,b@example.com:optional"), or a JSON array of objects with "email" (string), "optional" (boolean), and "displayName" (string). Example: [{"email":"a@example.com","optional":true}]`},
+					"send_updates":         {Type: "string", Description: "Who Google emails the invitation to: all, externalOnly (guests outside your organization), or none. Omit to send none"},
+					"add_conference":       {Type: "boolean", Description: "Add a Google Meet video conference; the join URL is returned as hangoutLink, or a warning if Google could not add one (default: false)"},
+					"timezone":             {Type: "string", Description: "IANA timezone (e.g., America/New_York; see list-timezones)"},
+					"recurrence":           {Type: "string", Description: "Recurrence rules making the event repeat, e.g. RRULE:FREQ=WEEKLY;BYDAY=MO for a weekly standup. Separate RRULE, EXDATE and RDATE lines with newlines or commas. Timed events default to the calendar's timezone"},
+					"reminders":            {Type: "string", Description: "Comma-separated reminders as method:minutes before the event, method being popup or email (e.g. popup:10,email:60). At most 5. Omit to use the calendar's default reminders"},
+					"ical_uid":             {Type: "string", Description: "iCalendar UID of the event (required when import is true)"},
+					"import":               {Type: "boolean", Description: "Import a copy of an event from another system, preserving ical_uid so re-imports update instead of duplicating. No invitations are sent and the organizer is not changed (default: false)"},
+					"anyone_can_add_self":  {Type: "boolean", Description: "Let anyone with the event link add themselves as a guest, e.g. for office hours. Not allowed with event_type focusTime, outOfOffice, or workingLocation, which have no guests (default: false)"},
+					"visibility":           {Type: "string", Description: "Event visibility: default, public, private, or confidential. Private hides the details and guest list from others who can see the calendar"},
+					"color_id":             {Type: "string", Description: "Event color ID from list-colors (e.g. \"11\"). Omit to use the calendar's color"},
+					"event_type":           {Type: "string", Description: "Special event type: default, focusTime, outOfOffice, or workingLocation. focusTime and outOfOffice auto-decline conflicting invitations; workingLocation uses location as the place (\"home\" or empty for home office). Attendees are not allowed on these types"},
+					"decline_message":      {Type: "string", Description: "Message sent when a focusTime or outOfOffice event declines an invitation"},
+					"check_conflicts":      {Type: "boolean", Description: "Before creating, look for overlapping events on the calendar and return them in a conflicts array alongside the created event (default: false)"},
+					"fail_on_conflict":     {Type: "boolean", Description: "Do not create the event if it overlaps existing events; implies check_conflicts (default: false)"},
+					"description_template": {Type: "string", Description: `JSON object of app metadata to append to the description as a machine-readable footer, read back with get-event include_metadata or parse-event-metadata. Example: {"ticket":"OPS-12"}`},
 				},
 				Required: []string{"summary", "start", "end"},
 			},
 		},
+		{
+			Name:        "quick-add-event",
+			Description: "Create an event from a natural language description, e.g. \"Dinner with Bob tomorrow 7pm\". Google picks the time from the text; check the returned start and end.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"text":        {Type: "string", Description: "Event description including when it happens (required)"},
+					"calendar_id": {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
+				},
+				Required: []string{"text"},
+			},
+		},
 		{
 			Name:        "update-event",
 			Description: "Update an existing calendar event. Only specified fields are changed.",
 			InputSchema: inputSchema{
 				Type: "object",
 				Properties: map[string]property{
-					"event_id":    {Type: "string", Description: "Event ID (required)"},
-					"calendar_id": {Type: "string", Description: "Calendar ID (default: primary)"},
-					"summary":     {Type: "string", Description: "New event title"},
-					"description": {Type: "string", Description: "New description"},
-					"location":    {Type: "string", Description: "New location"},
-					"start":       {Type: "string", Description: "New start time (RFC3339 or YYYY-MM-DD)"},
-					"end":         {Type: "string", Description: "New end time (RFC3339 or YYYY-MM-DD)"},
-					"attendees":   {Type: "string", Description: "New comma-separated attendee emails"},
+					"event_id":            {Type: "string", Description: "Event ID (required)"},
+					"calendar_id":         {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
+					"summary":             {Type: "string", Description: "New event title"},
+					"description":         {Type: "string", Description: "New description"},
+					"location":            {Type: "string", Description: "New location"},
+					"start":               {Type: "string", Description: "New start time (RFC3339 or YYYY-MM-DD)"},
+					"end":                 {Type: "string", Description: "New end time (RFC3339 or YYYY-MM-DD)"},
+					"attendees":           {Type: "string", Description: "New attendee list (replaces existing): comma-separated emails, each optionally suffixed with :optional, or a JSON array of {email, optional, displayName} objects"},
+					"send_updates":        {Type: "string", Description: "Who Google emails the change to: all, externalOnly (guests outside your organization), or none. Omit to send none"},
+					"anyone_can_add_self": {Type: "boolean", Description: "Whether anyone with the event link can add themselves as a guest. Not allowed on focusTime, outOfOffice, or workingLocation events"},
+					"visibility":          {Type: "string", Description: "New visibility: default, public, private, or confidential"},
+					"color_id":            {Type: "string", Description: "New event color ID from list-colors. Empty string resets it to the calendar's color"},
+					"recurrence":          {Type: "string", Description: "New recurrence rules, separated by newlines or commas (e.g. RRULE:FREQ=WEEKLY;BYDAY=MO). Empty string makes it a single event. Only valid on the recurring event itself, not an instance"},
 				},
 				Required: []string{"event_id"},
 			},
@@ -112,20 +334,36 @@ func allTools() []mcpTool {
 				Type: "object",
 				Properties: map[string]property{
 					"event_id":    {Type: "string", Description: "Event ID (required)"},
-					"calendar_id": {Type: "string", Description: "Calendar ID (default: primary)"},
+					"calendar_id": {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
 				},
 				Required: []string{"event_id"},
 			},
 		},
+		{
+			Name:        "move-event",
+			Description: "Move an event to another calendar, keeping its ID and attendee responses. You must be able to edit the destination calendar.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"event_id":                {Type: "string", Description: "Event ID (required)"},
+					"destination_calendar_id": {Type: "string", Description: "Calendar ID to move the event to (required)"},
+					"calendar_id":             {Type: "string", Description: "Calendar ID the event is in (default: your default calendar from set-default-calendar, or primary if none is set)"},
+				},
+				Required: []string{"event_id", "destination_calendar_id"},
+			},
+		},
 		{
 			Name:        "respond-to-event",
-			Description: "Respond to a calendar event invitation with accepted, declined, or tentative.",
+			Description: "Respond to a calendar event invitation with accepted, declined, or tentative. Use declined_with_proposal to decline and suggest a new time.",
 			InputSchema: inputSchema{
 				Type: "object",
 				Properties: map[string]property{
-					"event_id":    {Type: "string", Description: "Event ID (required)"},
-					"response":    {Type: "string", Description: "Response: accepted, declined, or tentative (required)"},
-					"calendar_id": {Type: "string", Description: "Calendar ID (default: primary)"},
+					"event_id":       {Type: "string", Description: "Event ID (required)"},
+					"response":       {Type: "string", Description: "Response: accepted, declined, tentative, or declined_with_proposal (required)"},
+					"calendar_id":    {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
+					"proposed_start": {Type: "string", Description: "Proposed new start time in RFC3339 (required for declined_with_proposal)"},
+					"proposed_end":   {Type: "string", Description: "Proposed new end time in RFC3339 (required for declined_with_proposal)"},
+					"comment":        {Type: "string", Description: "Optional note to the organizer"},
 				},
 				Required: []string{"event_id", "response"},
 			},
@@ -136,24 +374,51 @@ func allTools() []mcpTool {
 			InputSchema: inputSchema{
 				Type: "object",
 				Properties: map[string]property{
-					"calendar_id":   {Type: "string", Description: "Calendar ID (default: primary)"},
+					"calendar_id":   {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
 					"time_min":      {Type: "string", Description: "Start of time range in RFC3339 format (default: now)"},
 					"time_max":      {Type: "string", Description: "End of time range in RFC3339 format (default: 7 days from now)"},
 					"max_results":   {Type: "number", Description: "Maximum number of events to return (default: 50)"},
 					"single_events": {Type: "boolean", Description: "Whether to expand recurring events (default: true)"},
 					"order_by":      {Type: "string", Description: "Sort order: startTime or updated (default: startTime)"},
+					"locale":        {Type: "string", Description: "Locale for dates and times in the calendar view, e.g. en-GB or ja-JP (default: server locale)"},
 				},
 			},
 			uiTemplate: "templates/calendar.html",
 			visibility: []string{"model", "app"},
 		},
+		{
+			Name:        "list-tasks",
+			Description: "List Google Tasks with their title, due date, and status. Requires signing in again if the account was authorized before Tasks support was added.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"task_list_id":   {Type: "string", Description: "Task list ID (default: the user's default list)"},
+					"show_completed": {Type: "boolean", Description: "Include completed tasks (default: false)"},
+					"max_results":    {Type: "number", Description: "Maximum number of tasks to return (default: 50)"},
+				},
+			},
+		},
+		{
+			Name:        "create-task",
+			Description: "Create a Google Task.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"title":        {Type: "string", Description: "Task title (required)"},
+					"notes":        {Type: "string", Description: "Task notes"},
+					"due":          {Type: "string", Description: "Due date in YYYY-MM-DD format (Google Tasks has no due time)"},
+					"task_list_id": {Type: "string", Description: "Task list ID (default: the user's default list)"},
+				},
+				Required: []string{"title"},
+			},
+		},
 		{
 			Name:        "gcal-list-events-app",
 			Description: "List upcoming events from a Google Calendar (app-only).",
 			InputSchema: inputSchema{
 				Type: "object",
 				Properties: map[string]property{
-					"calendar_id":   {Type: "string", Description: "Calendar ID (default: primary)"},
+					"calendar_id":   {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
 					"time_min":      {Type: "string", Description: "Start of time range in RFC3339 format (default: now)"},
 					"time_max":      {Type: "string", Description: "End of time range in RFC3339 format (default: 7 days from now)"},
 					"max_results":   {Type: "number", Description: "Maximum number of events to return (default: 50)"},
@@ -172,11 +437,12 @@ func allTools() []mcpTool {
 					"summary":     {Type: "string", Description: "Event title (required)"},
 					"start":       {Type: "string", Description: "Start time in RFC3339 or YYYY-MM-DD (required)"},
 					"end":         {Type: "string", Description: "End time in RFC3339 or YYYY-MM-DD (required)"},
-					"calendar_id": {Type: "string", Description: "Calendar ID (default: primary)"},
+					"calendar_id": {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
 					"description": {Type: "string", Description: "Event description"},
 					"location":    {Type: "string", Description: "Event location"},
-					"attendees":   {Type: "string", Description: "Comma-separated attendee email addresses"},
-					"timezone":    {Type: "string", Description: "Timezone (e.g., America/New_York)"},
+					"attendees":   {Type: "string", Description: `Comma-separated attendee email addresses, or a JSON array of objects with "email" (string), "optional" (boolean), and "displayName" (string). Example: [{"email":"a@example.com","optional":true}]`},
+					"timezone":    {Type: "string", Description: "IANA timezone (e.g., America/New_York; see list-timezones)"},
+					"recurrence":  {Type: "string", Description: "Recurrence rules making the event repeat, e.g. RRULE:FREQ=WEEKLY;BYDAY=MO for a weekly standup. Separate RRULE, EXDATE and RDATE lines with newlines or commas. Timed events default to the calendar's timezone"},
 				},
 				Required: []string{"summary", "start", "end"},
 			},
@@ -189,7 +455,7 @@ func allTools() []mcpTool {
 				Type: "object",
 				Properties: map[string]property{
 					"event_id":    {Type: "string", Description: "Event ID (required)"},
-					"calendar_id": {Type: "string", Description: "Calendar ID (default: primary)"},
+					"calendar_id": {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
 				},
 				Required: []string{"event_id"},
 			},
@@ -202,7 +468,7 @@ func allTools() []mcpTool {
 				Type: "object",
 				Properties: map[string]property{
 					"event_id":    {Type: "string", Description: "Event ID (required)"},
-					"calendar_id": {Type: "string", Description: "Calendar ID (default: primary)"},
+					"calendar_id": {Type: "string", Description: "Calendar ID (default: your default calendar from set-default-calendar, or primary if none is set)"},
 				},
 				Required: []string{"event_id"},
 			},
@@ -228,26 +494,53 @@ func allTools() []mcpTool {
 				Type: "object",
 				Properties: map[string]property{
 					"message_id": {Type: "string", Description: "Email message ID (required)"},
+					"format":     {Type: "string", Description: "Response format: full (default), metadata (headers only), or raw (complete RFC822 source in the raw field)"},
+					"mark_read":  {Type: "boolean", Description: "Mark the email as read after fetching it; the returned labels reflect the change (default: false)"},
 				},
 				Required: []string{"message_id"},
 			},
 		},
+		{
+			Name:        "read-thread",
+			Description: "Read every message in an email thread, oldest first, e.g. to summarize a whole conversation in one call.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"thread_id": {Type: "string", Description: "Thread ID, the threadId of any email in the conversation (required)"},
+					"mark_read": {Type: "boolean", Description: "Mark every message in the thread as read after fetching it; the returned labels reflect the change (default: false)"},
+				},
+				Required: []string{"thread_id"},
+			},
+		},
+		{
+			Name:        "get-attachment",
+			Description: "Download an email attachment, e.g. a PDF or CSV to read. Returns the base64url-encoded data and its size in bytes. read-email lists each attachment's ID and size, so check the size before downloading large files.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"message_id":    {Type: "string", Description: "Email message ID (required)"},
+					"attachment_id": {Type: "string", Description: "Attachment ID from read-email's attachments (required)"},
+				},
+				Required: []string{"message_id", "attachment_id"},
+			},
+		},
 		{
 			Name:        "send-email",
 			Description: "Send an email. Supports file attachments via base64-encoded data.",
 			InputSchema: inputSchema{
 				Type: "object",
 				Properties: map[string]property{
-					"to":          {Type: "string", Description: "Recipient email address (required)"},
+					"to":          {Type: "string", Description: "Recipient email addresses (comma-separated). At least one of to, cc or bcc is required"},
 					"subject":     {Type: "string", Description: "Email subject (required)"},
 					"body":        {Type: "string", Description: "Email body in plain text (required)"},
 					"cc":          {Type: "string", Description: "CC recipients (comma-separated)"},
 					"bcc":         {Type: "string", Description: "BCC recipients (comma-separated)"},
+					"reply_to":    {Type: "string", Description: "Reply-To address, e.g. a shared mailbox that replies should go to"},
 					"thread_id":   {Type: "string", Description: "Thread ID for replying to a thread"},
-					"in_reply_to": {Type: "string", Description: "Message-ID header of the email being replied to"},
+					"in_reply_to": {Type: "string", Description: "Message-ID header of the email being replied to (default with thread_id: the thread's latest message)"},
 					"attachments": {Type: "string", Description: `JSON array of attachments. Each object has: "filename" (string), "mime_type" (string, e.g. "application/pdf"), "data" (base64-encoded file content). Example: [{"filename":"doc.pdf","mime_type":"application/pdf","data":"base64..."}]`},
 				},
-				Required: []string{"to", "subject", "body"},
+				Required: []string{"subject", "body"},
 			},
 		},
 		{
@@ -256,14 +549,63 @@ func allTools() []mcpTool {
 			InputSchema: inputSchema{
 				Type: "object",
 				Properties: map[string]property{
-					"to":          {Type: "string", Description: "Recipient email address (required)"},
+					"to":          {Type: "string", Description: "Recipient email addresses (comma-separated). At least one of to, cc or bcc is required"},
 					"subject":     {Type: "string", Description: "Email subject (required)"},
 					"body":        {Type: "string", Description: "Email body in plain text (required)"},
 					"cc":          {Type: "string", Description: "CC recipients (comma-separated)"},
 					"bcc":         {Type: "string", Description: "BCC recipients (comma-separated)"},
+					"reply_to":    {Type: "string", Description: "Reply-To address, e.g. a shared mailbox that replies should go to"},
 					"attachments": {Type: "string", Description: `JSON array of attachments. Each object has: "filename" (string), "mime_type" (string, e.g. "application/pdf"), "data" (base64-encoded file content). Example: [{"filename":"doc.pdf","mime_type":"application/pdf","data":"base64..."}]`},
 				},
-				Required: []string{"to", "subject", "body"},
+				Required: []string{"subject", "body"},
+			},
+		},
+		{
+			Name:        "list-drafts",
+			Description: "List draft emails with their subject, recipients and a snippet. Use send-draft to send one.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"query":       {Type: "string", Description: "Gmail search query to filter drafts (e.g., 'subject:invoice')"},
+					"max_results": {Type: "number", Description: "Maximum number of drafts to return (default: 20)"},
+				},
+			},
+		},
+		{
+			Name:        "send-draft",
+			Description: "Send an existing draft email. The draft is removed once sent.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"draft_id": {Type: "string", Description: "Draft ID, as returned by draft-email or list-drafts (required)"},
+				},
+				Required: []string{"draft_id"},
+			},
+		},
+		{
+			Name:        "reply-email",
+			Description: "Reply to an email in its thread. Recipients, subject and threading headers are derived from the original.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"message_id": {Type: "string", Description: "Email message ID to reply to (required)"},
+					"body":       {Type: "string", Description: "Reply body in plain text (required)"},
+					"reply_all":  {Type: "boolean", Description: "Also reply to the original To and Cc recipients (default: false)"},
+				},
+				Required: []string{"message_id", "body"},
+			},
+		},
+		{
+			Name:        "forward-email",
+			Description: "Forward an email to new recipients, keeping its attachments. The original headers are quoted below the optional note.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"message_id": {Type: "string", Description: "Email message ID to forward (required)"},
+					"to":         {Type: "string", Description: "Recipient email address(es), comma-separated (required)"},
+					"body":       {Type: "string", Description: "Note to include above the forwarded message"},
+				},
+				Required: []string{"message_id", "to"},
 			},
 		},
 		{
@@ -291,59 +633,381 @@ func allTools() []mcpTool {
 			},
 		},
 		{
-			Name:        "list-email-labels",
-			Description: "List all Gmail labels (system and user-created).",
+			Name:        "permanently-delete-email",
+			Description: "Permanently delete an email, bypassing trash. This cannot be undone; use delete-email to move it to trash instead. Requires confirm=true and a server started with --gmail-full-access.",
 			InputSchema: inputSchema{
-				Type:       "object",
-				Properties: map[string]property{},
+				Type: "object",
+				Properties: map[string]property{
+					"message_id": {Type: "string", Description: "Email message ID (required)"},
+					"confirm":    {Type: "boolean", Description: "Must be true to perform the deletion (required)"},
+				},
+				Required: []string{"message_id", "confirm"},
 			},
 		},
-	}
-}
-
-// isVisibleToModel returns true if the tool should be visible to model (LLM).
-func (t mcpTool) isVisibleToModel() bool {
-	if len(t.visibility) == 0 {
-		return true
-	}
-	for _, v := range t.visibility {
-		if v == "model" {
-			return true
-		}
-	}
-	return false
-}
-
-// hasUI returns true if the tool has a UI template.
-func (t mcpTool) hasUI() bool {
-	return t.uiTemplate != ""
-}
-
-// Helper functions for extracting typed values from arguments map
-
-func argString(args map[string]interface{}, key string) string {
-	if v, ok := args[key]; ok {
-		if s, ok := v.(string); ok {
-			return s
-		}
-	}
-	return ""
-}
-
-func argFloat(args map[string]interface{}, key string) float64 {
-	if v, ok := args[key]; ok {
-		if f, ok := v.(float64); ok {
-			return f
-		}
-	}
-	return 0
-}
-
-func argOptionalString(args map[string]interface{}, key string) (string, bool) {
-	v, ok := args[key]
-	if !ok {
-		return "", false
-	}
+		{
+			Name:        "archive-email",
+			Description: "Archive an email (remove it from the inbox without deleting it).",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"message_id": {Type: "string", Description: "Email message ID (required)"},
+				},
+				Required: []string{"message_id"},
+			},
+		},
+		{
+			Name:        "mark-read",
+			Description: "Mark an email as read.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"message_id": {Type: "string", Description: "Email message ID (required)"},
+				},
+				Required: []string{"message_id"},
+			},
+		},
+		{
+			Name:        "mark-unread",
+			Description: "Mark an email as unread.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"message_id": {Type: "string", Description: "Email message ID (required)"},
+				},
+				Required: []string{"message_id"},
+			},
+		},
+		{
+			Name:        "trash-email",
+			Description: "Move an email to trash. Use untrash-email to restore it.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"message_id": {Type: "string", Description: "Email message ID (required)"},
+				},
+				Required: []string{"message_id"},
+			},
+		},
+		{
+			Name:        "untrash-email",
+			Description: "Restore an email from trash.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"message_id": {Type: "string", Description: "Email message ID (required)"},
+				},
+				Required: []string{"message_id"},
+			},
+		},
+		{
+			Name:        "batch-delete-emails",
+			Description: "Move every email matching a Gmail search query to trash. Requires confirm=true. Use search-emails first to check what matches.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"query":        {Type: "string", Description: "Gmail search query selecting the emails to trash (required)"},
+					"confirm":      {Type: "boolean", Description: "Must be true to perform the deletion (required)"},
+					"max_messages": {Type: "number", Description: "Maximum number of messages to trash (default and limit: 500)"},
+				},
+				Required: []string{"query", "confirm"},
+			},
+		},
+		{
+			Name:        "batch-modify-emails",
+			Description: "Add or remove labels on every email matching a Gmail search query (e.g., remove UNREAD to mark as read). More than 50 matches require confirm=true.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"query":         {Type: "string", Description: "Gmail search query selecting the emails to modify (required)"},
+					"add_labels":    {Type: "string", Description: "Label IDs to add (comma-separated, e.g., 'STARRED,IMPORTANT')"},
+					"remove_labels": {Type: "string", Description: "Label IDs to remove (comma-separated, e.g., 'UNREAD,INBOX')"},
+					"confirm":       {Type: "boolean", Description: "Set to true to modify more than 50 emails (up to 1000 per call)"},
+				},
+				Required: []string{"query"},
+			},
+		},
+		{
+			Name:        "snooze-email",
+			Description: "Snooze an email: remove it from the inbox until the given time, when it is returned to the inbox automatically.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"message_id": {Type: "string", Description: "Email message ID (required)"},
+					"until":      {Type: "string", Description: "When to return the email to the inbox, in RFC3339 format (required)"},
+				},
+				Required: []string{"message_id", "until"},
+			},
+		},
+		{
+			Name:        "schedule-email",
+			Description: "Compose an email now and send it automatically at a later time. The server must be running at that time for the email to go out.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"to":          {Type: "string", Description: "Recipient email addresses (comma-separated). At least one of to, cc or bcc is required"},
+					"subject":     {Type: "string", Description: "Email subject (required)"},
+					"body":        {Type: "string", Description: "Email body in plain text (required)"},
+					"send_at":     {Type: "string", Description: "When to send the email, in RFC3339 format (required)"},
+					"cc":          {Type: "string", Description: "CC recipients (comma-separated)"},
+					"bcc":         {Type: "string", Description: "BCC recipients (comma-separated)"},
+					"reply_to":    {Type: "string", Description: "Reply-To address, e.g. a shared mailbox that replies should go to"},
+					"thread_id":   {Type: "string", Description: "Thread ID for replying to a thread"},
+					"in_reply_to": {Type: "string", Description: "Message-ID header of the email being replied to (default with thread_id: the thread's latest message when sent)"},
+					"attachments": {Type: "string", Description: `JSON array of attachments. Each object has: "filename" (string), "mime_type" (string, e.g. "application/pdf"), "data" (base64-encoded file content). Example: [{"filename":"doc.pdf","mime_type":"application/pdf","data":"base64..."}]`},
+				},
+				Required: []string{"subject", "body", "send_at"},
+			},
+		},
+		{
+			Name:        "list-scheduled-emails",
+			Description: "List emails queued by schedule-email, with their status (pending, sending, sent or failed).",
+			InputSchema: inputSchema{
+				Type:       "object",
+				Properties: map[string]property{},
+			},
+		},
+		{
+			Name:        "cancel-scheduled-email",
+			Description: "Cancel a pending scheduled email.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"id": {Type: "number", Description: "Scheduled email ID from schedule-email or list-scheduled-emails (required)"},
+				},
+				Required: []string{"id"},
+			},
+		},
+		{
+			Name:        "list-email-labels",
+			Description: "List all Gmail labels (system and user-created).",
+			InputSchema: inputSchema{
+				Type:       "object",
+				Properties: map[string]property{},
+			},
+		},
+		{
+			Name:        "create-label",
+			Description: "Create a Gmail label. Nest labels with '/' in the name (e.g., 'Projects/Alpha').",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"name":                    {Type: "string", Description: "Label name (required)"},
+					"label_list_visibility":   {Type: "string", Description: "Visibility in the label list: labelShow, labelShowIfUnread or labelHide (default: labelShow)"},
+					"message_list_visibility": {Type: "string", Description: "Visibility in the message list: show or hide (default: show)"},
+				},
+				Required: []string{"name"},
+			},
+		},
+		{
+			Name:        "update-label",
+			Description: "Rename a Gmail label or change its visibility. Omitted settings are left unchanged.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"label_id":                {Type: "string", Description: "Label ID, as returned by list-email-labels (required)"},
+					"name":                    {Type: "string", Description: "New label name"},
+					"label_list_visibility":   {Type: "string", Description: "Visibility in the label list: labelShow, labelShowIfUnread or labelHide"},
+					"message_list_visibility": {Type: "string", Description: "Visibility in the message list: show or hide"},
+				},
+				Required: []string{"label_id"},
+			},
+		},
+		{
+			Name:        "delete-label",
+			Description: "Permanently delete a Gmail label. Messages keep everything except the label.",
+			InputSchema: inputSchema{
+				Type: "object",
+				Properties: map[string]property{
+					"label_id": {Type: "string", Description: "Label ID, as returned by list-email-labels (required)"},
+				},
+				Required: []string{"label_id"},
+			},
+		},
+	}
+	for i := range tools {
+		tools[i].InputSchema.AdditionalProperties = boolPtr(false)
+		if shapesOutput(tools[i]) {
+			tools[i].InputSchema.Properties["fields"] = property{
+				Type:        "string",
+				Description: "Comma-separated result fields to return, e.g. id,summary,start. Applied to each item of list results",
+			}
+		}
+	}
+	return tools
+}
+
+// shapesOutput reports whether a tool's result may be projected or compacted.
+// Results read by the UI templates (tools with a UI, and app-only tools) are
+// always returned in full.
+func shapesOutput(t mcpTool) bool {
+	return !t.hasUI() && t.isVisibleToModel()
+}
+
+// marshalToolResult encodes a tool result as JSON text. For tools where
+// shapesOutput holds, the "fields" argument projects objects onto the listed
+// keys and compact drops empty values, both to save tokens on list-heavy
+// responses.
+func marshalToolResult(name string, args map[string]interface{}, result any, compact bool) ([]byte, error) {
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	fields := splitFields(argString(args, "fields"))
+	if tool := findTool(name); tool == nil || !shapesOutput(*tool) || (len(fields) == 0 && !compact) {
+		return jsonBytes, nil
+	}
+
+	var v any
+	if err := json.Unmarshal(jsonBytes, &v); err != nil {
+		return nil, err
+	}
+	if len(fields) > 0 {
+		v = projectFields(v, fields)
+	}
+	if compact {
+		v = compactValue(v)
+	}
+	return json.Marshal(v)
+}
+
+func splitFields(s string) map[string]bool {
+	fields := make(map[string]bool)
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields[f] = true
+		}
+	}
+	return fields
+}
+
+// listResultKeys are the keys under which wrapper results (e.g.
+// {"events": [...], "nextPageToken": "..."}) hold their list items.
+var listResultKeys = []string{"events", "created", "updated", "deleted", "conflicts"}
+
+// projectFields keeps only the requested keys of each list item in v: the
+// elements of a top-level array, or of a wrapper's listResultKeys lists. Other
+// values, such as a wrapper's own keys, are returned unchanged.
+func projectFields(v any, fields map[string]bool) any {
+	switch val := v.(type) {
+	case []any:
+		return projectItems(val, fields)
+	case map[string]any:
+		for _, key := range listResultKeys {
+			if items, ok := val[key].([]any); ok {
+				val[key] = projectItems(items, fields)
+			}
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// projectItems projects each object in items onto fields. An object with
+// none of the fields becomes {}.
+func projectItems(items []any, fields map[string]bool) []any {
+	for i, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		projected := make(map[string]any)
+		for k, v := range obj {
+			if fields[k] {
+				projected[k] = v
+			}
+		}
+		items[i] = projected
+	}
+	return items
+}
+
+// compactValue removes empty strings, nulls, and empty arrays and objects.
+func compactValue(v any) any {
+	switch val := v.(type) {
+	case []any:
+		out := make([]any, 0, len(val))
+		for _, item := range val {
+			if item = compactValue(item); !isEmptyValue(item) {
+				out = append(out, item)
+			}
+		}
+		return out
+	case map[string]any:
+		for k, item := range val {
+			if item = compactValue(item); isEmptyValue(item) {
+				delete(val, k)
+			} else {
+				val[k] = item
+			}
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+func isEmptyValue(v any) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return val == ""
+	case []any:
+		return len(val) == 0
+	case map[string]any:
+		return len(val) == 0
+	}
+	return false
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// isVisibleToModel returns true if the tool should be visible to model (LLM).
+func (t mcpTool) isVisibleToModel() bool {
+	if len(t.visibility) == 0 {
+		return true
+	}
+	for _, v := range t.visibility {
+		if v == "model" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasUI returns true if the tool has a UI template.
+func (t mcpTool) hasUI() bool {
+	return t.uiTemplate != ""
+}
+
+// Helper functions for extracting typed values from arguments map
+
+func argString(args map[string]interface{}, key string) string {
+	if v, ok := args[key]; ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+func argFloat(args map[string]interface{}, key string) float64 {
+	if v, ok := args[key]; ok {
+		if f, ok := v.(float64); ok {
+			return f
+		}
+	}
+	return 0
+}
+
+func argOptionalString(args map[string]interface{}, key string) (string, bool) {
+	v, ok := args[key]
+	if !ok {
+		return "", false
+	}
 	s, ok := v.(string)
 	if !ok {
 		return "", false
@@ -360,6 +1024,20 @@ func argBool(args map[string]interface{}, key string, defaultVal bool) bool {
 	return defaultVal
 }
 
+// argOptionalBool returns a pointer to a boolean argument, or nil when it is absent.
+// Unlike argBool, a value of the wrong type is an error rather than ignored.
+func argOptionalBool(args map[string]interface{}, key string) (*bool, error) {
+	v, ok := args[key]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a boolean, got %v", key, v)
+	}
+	return &b, nil
+}
+
 // argAttachments parses the attachments argument, accepting either a JSON string or a JSON array.
 func argAttachments(args map[string]interface{}, key string) ([]Attachment, error) {
 	v, ok := args[key]
@@ -392,40 +1070,231 @@ func argAttachments(args map[string]interface{}, key string) ([]Attachment, erro
 	return attachments, nil
 }
 
+// argAttendees parses the attendees argument. It accepts a comma-separated email
+// string, a JSON array of {email, optional, displayName} objects, or that array
+// encoded as a JSON string. It returns nil when the argument is absent and a
+// non-nil empty slice when it is present but empty, so updates can clear the list.
+func argAttendees(args map[string]interface{}, key string) ([]Attendee, error) {
+	v, ok := args[key]
+	if !ok {
+		return nil, nil
+	}
+
+	var jsonBytes []byte
+	switch val := v.(type) {
+	case string:
+		trimmed := strings.TrimSpace(val)
+		if !strings.HasPrefix(trimmed, "[") {
+			attendees := []Attendee{}
+			for _, email := range strings.Split(trimmed, ",") {
+				email = strings.TrimSpace(email)
+				if email == "" {
+					continue
+				}
+				// "a@example.com:optional" invites a@example.com as optional.
+				email, optional := strings.CutSuffix(email, ":optional")
+				attendees = append(attendees, Attendee{Email: strings.TrimSpace(email), Optional: optional})
+			}
+			return attendees, nil
+		}
+		jsonBytes = []byte(trimmed)
+	default:
+		var err error
+		jsonBytes, err = json.Marshal(val)
+		if err != nil {
+			return nil, fmt.Errorf("marshal attendees: %w", err)
+		}
+	}
+
+	attendees := []Attendee{}
+	if err := json.Unmarshal(jsonBytes, &attendees); err != nil {
+		return nil, fmt.Errorf("parse attendees: %w", err)
+	}
+	for i, a := range attendees {
+		if strings.TrimSpace(a.Email) == "" {
+			return nil, fmt.Errorf("attendees[%d]: email is required", i)
+		}
+	}
+	return attendees, nil
+}
+
+// clampMaxResults limits maxResults to ceiling when ceiling is positive.
+// It reports whether the value was reduced.
+func clampMaxResults(maxResults, ceiling int64) (int64, bool) {
+	if ceiling > 0 && maxResults > ceiling {
+		return ceiling, true
+	}
+	return maxResults, false
+}
+
+// cappedResult is a list result whose requested max_results exceeded the
+// server ceiling. Its items are the tool output, in the same shape as an
+// uncapped result; the cap is reported in the result's _meta.
+type cappedResult struct {
+	items      any
+	maxResults int64
+}
+
+// withCapIndicator returns items, marked as capped if the requested
+// max_results exceeded the server ceiling.
+func withCapIndicator(items any, requested, ceiling int64) any {
+	if _, capped := clampMaxResults(requested, ceiling); !capped {
+		return items
+	}
+	return cappedResult{items: items, maxResults: ceiling}
+}
+
+// splitCapIndicator returns the tool output of result and, if it was capped,
+// the _meta entries reporting the cap.
+func splitCapIndicator(result any) (any, map[string]interface{}) {
+	capped, ok := result.(cappedResult)
+	if !ok {
+		return result, nil
+	}
+	return capped.items, map[string]interface{}{"capped": true, "max_results": capped.maxResults}
+}
+
+// mergeMeta adds extra's entries to meta, allocating it if needed.
+func mergeMeta(meta, extra map[string]interface{}) map[string]interface{} {
+	if len(extra) == 0 {
+		return meta
+	}
+	if meta == nil {
+		meta = make(map[string]interface{}, len(extra))
+	}
+	for k, v := range extra {
+		meta[k] = v
+	}
+	return meta
+}
+
 // dispatchCalendarTool routes a calendar tool call to the appropriate CalendarService method.
 // This is shared between stdio and HTTP mode.
-func dispatchCalendarTool(svc *CalendarService, name string, args map[string]interface{}) (any, error) {
+func dispatchCalendarTool(ctx context.Context, svc *CalendarService, name string, args map[string]interface{}) (any, error) {
 	switch name {
 	case "list-calendars":
-		return svc.ListCalendars()
+		return svc.ListCalendars(ctx, argBool(args, "show_hidden", false))
+
+	case "get-calendar":
+		return svc.GetCalendar(ctx, argString(args, "calendar_id"))
+
+	case "get-calendar-settings":
+		return svc.GetSettings(ctx)
+
+	case "create-calendar":
+		return svc.CreateCalendar(
+			ctx,
+			argString(args, "summary"),
+			argString(args, "description"),
+			argString(args, "timezone"),
+		)
+
+	case "delete-calendar":
+		if err := svc.DeleteCalendar(ctx, argString(args, "calendar_id")); err != nil {
+			return nil, err
+		}
+		return map[string]string{"status": "deleted", "calendar_id": argString(args, "calendar_id")}, nil
 
 	case "list-events", "show-calendar", "gcal-list-events-app":
-		return svc.ListEvents(
+		maxResults := int64(argFloat(args, "max_results"))
+		page, err := svc.ListEvents(
+			ctx,
 			argString(args, "calendar_id"),
 			argString(args, "time_min"),
 			argString(args, "time_max"),
-			int64(argFloat(args, "max_results")),
+			maxResults,
 			argBool(args, "single_events", true),
 			argString(args, "order_by"),
+			argString(args, "page_token"),
 		)
+		if err != nil {
+			return nil, err
+		}
+		page.Events = filterEvents(page.Events, argBool(args, "my_events_only", false), argBool(args, "exclude_declined", false))
+		if name != "list-events" {
+			// The calendar UI reads a bare array of events.
+			return withCapIndicator(page.Events, maxResults, svc.opts.MaxResultsCeiling), nil
+		}
+		return withCapIndicator(page, maxResults, svc.opts.MaxResultsCeiling), nil
 
 	case "get-event", "gcal-get-event-app":
 		return svc.GetEvent(
+			ctx,
 			argString(args, "calendar_id"),
 			argString(args, "event_id"),
+			argBool(args, "include_availability", false),
+			argBool(args, "include_metadata", false),
 		)
 
+	case "get-events":
+		var ids []string
+		for _, id := range strings.Split(argString(args, "event_ids"), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		return svc.GetEvents(ctx, argString(args, "calendar_id"), ids)
+
+	case "get-freebusy":
+		var ids []string
+		for _, id := range strings.Split(argString(args, "calendar_ids"), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		return svc.FreeBusy(ctx, argString(args, "time_min"), argString(args, "time_max"), ids)
+
+	case "list-colors":
+		return svc.ListColors(ctx)
+
 	case "search-events":
-		return svc.SearchEvents(
+		maxResults := int64(argFloat(args, "max_results"))
+		events, err := svc.SearchEvents(
+			ctx,
 			argString(args, "calendar_id"),
 			argString(args, "query"),
 			argString(args, "time_min"),
 			argString(args, "time_max"),
-			int64(argFloat(args, "max_results")),
+			maxResults,
+			argString(args, "order_by"),
 		)
+		if err != nil {
+			return nil, err
+		}
+		return withCapIndicator(events, maxResults, svc.opts.MaxResultsCeiling), nil
+
+	case "whats-changed":
+		return svc.WhatsChanged(ctx, argString(args, "since"), argString(args, "token"))
 
 	case "create-event", "gcal-create-event-app":
-		return svc.CreateEvent(
+		attendees, err := argAttendees(args, "attendees")
+		if err != nil {
+			return nil, err
+		}
+		var metadata map[string]any
+		if tmpl := argString(args, "description_template"); tmpl != "" {
+			if err := json.Unmarshal([]byte(tmpl), &metadata); err != nil {
+				return nil, fmt.Errorf("description_template must be a JSON object: %w", err)
+			}
+		}
+		var conflicts []eventJSON
+		if argBool(args, "check_conflicts", false) || argBool(args, "fail_on_conflict", false) {
+			conflicts, err = svc.FindConflicts(
+				ctx,
+				argString(args, "calendar_id"),
+				argString(args, "start"),
+				argString(args, "end"),
+				argString(args, "timezone"),
+			)
+			if err != nil {
+				return nil, err
+			}
+			if len(conflicts) > 0 && argBool(args, "fail_on_conflict", false) {
+				return nil, conflictError(conflicts)
+			}
+		}
+		ev, err := svc.CreateEvent(
+			ctx,
 			argString(args, "calendar_id"),
 			argString(args, "summary"),
 			argString(args, "description"),
@@ -433,22 +1302,56 @@ func dispatchCalendarTool(svc *CalendarService, name string, args map[string]int
 			argString(args, "start"),
 			argString(args, "end"),
 			argString(args, "timezone"),
-			argString(args, "attendees"),
+			attendees,
+			createEventOptions{
+				ICalUID: argString(args, "ical_uid"),
+				Import:  argBool(args, "import", false),
+
+				AnyoneCanAddSelf: argBool(args, "anyone_can_add_self", false),
+				Visibility:       argString(args, "visibility"),
+				EventType:        argString(args, "event_type"),
+				DeclineMessage:   argString(args, "decline_message"),
+				Metadata:         metadata,
+				Recurrence:       argString(args, "recurrence"),
+				Reminders:        argString(args, "reminders"),
+				ColorID:          argString(args, "color_id"),
+				SendUpdates:      argString(args, "send_updates"),
+				AddConference:    argBool(args, "add_conference", false),
+			},
 		)
+		if err != nil || conflicts == nil {
+			return ev, err
+		}
+		return createdEventWithConflicts{eventJSON: ev, Conflicts: conflicts}, nil
+
+	case "quick-add-event":
+		return svc.QuickAddEvent(ctx, argString(args, "calendar_id"), argString(args, "text"))
 
 	case "update-event":
 		calID := argString(args, "calendar_id")
 		eventID := argString(args, "event_id")
 		updates := make(map[string]string)
-		for _, key := range []string{"summary", "description", "location", "start", "end", "attendees"} {
+		for _, key := range []string{"summary", "description", "location", "start", "end", "visibility", "color_id", "recurrence"} {
 			if v, ok := argOptionalString(args, key); ok {
 				updates[key] = v
 			}
 		}
-		return svc.UpdateEvent(calID, eventID, updates)
+		anyoneCanAddSelf, err := argOptionalBool(args, "anyone_can_add_self")
+		if err != nil {
+			return nil, err
+		}
+		if anyoneCanAddSelf != nil {
+			updates["anyone_can_add_self"] = strconv.FormatBool(*anyoneCanAddSelf)
+		}
+		attendees, err := argAttendees(args, "attendees")
+		if err != nil {
+			return nil, err
+		}
+		return svc.UpdateEvent(ctx, calID, eventID, updates, attendees, argString(args, "send_updates"))
 
 	case "delete-event", "gcal-delete-event-app":
 		err := svc.DeleteEvent(
+			ctx,
 			argString(args, "calendar_id"),
 			argString(args, "event_id"),
 		)
@@ -457,11 +1360,23 @@ func dispatchCalendarTool(svc *CalendarService, name string, args map[string]int
 		}
 		return map[string]string{"status": "deleted", "event_id": argString(args, "event_id")}, nil
 
+	case "move-event":
+		return svc.MoveEvent(
+			ctx,
+			argString(args, "calendar_id"),
+			argString(args, "event_id"),
+			argString(args, "destination_calendar_id"),
+		)
+
 	case "respond-to-event":
 		return svc.RespondToEvent(
+			ctx,
 			argString(args, "calendar_id"),
 			argString(args, "event_id"),
 			argString(args, "response"),
+			argString(args, "proposed_start"),
+			argString(args, "proposed_end"),
+			argString(args, "comment"),
 		)
 
 	default:
@@ -469,27 +1384,140 @@ func dispatchCalendarTool(svc *CalendarService, name string, args map[string]int
 	}
 }
 
+// isTasksTool returns true if the tool name is a Google Tasks tool.
+func isTasksTool(name string) bool {
+	switch name {
+	case "list-tasks", "create-task":
+		return true
+	}
+	return false
+}
+
+// dispatchTasksTool routes a Tasks tool call to the appropriate TasksService method.
+func dispatchTasksTool(ctx context.Context, svc *TasksService, name string, args map[string]interface{}) (any, error) {
+	switch name {
+	case "list-tasks":
+		maxResults := int64(argFloat(args, "max_results"))
+		tasks, err := svc.ListTasks(
+			ctx,
+			argString(args, "task_list_id"),
+			argBool(args, "show_completed", false),
+			maxResults,
+		)
+		if err != nil {
+			return nil, tasksScopeHint(err)
+		}
+		return withCapIndicator(tasks, maxResults, svc.opts.MaxResultsCeiling), nil
+
+	case "create-task":
+		task, err := svc.CreateTask(
+			ctx,
+			argString(args, "task_list_id"),
+			argString(args, "title"),
+			argString(args, "notes"),
+			argString(args, "due"),
+		)
+		if err != nil {
+			return nil, tasksScopeHint(err)
+		}
+		return task, nil
+
+	default:
+		return nil, fmt.Errorf("unknown tasks tool: %s", name)
+	}
+}
+
+// createdEventWithConflicts is create-event's result when check_conflicts is set.
+type createdEventWithConflicts struct {
+	*eventJSON
+	Conflicts []eventJSON `json:"conflicts"`
+}
+
+// conflictError explains why fail_on_conflict refused to create an event.
+func conflictError(conflicts []eventJSON) error {
+	descs := make([]string, 0, len(conflicts))
+	for _, c := range conflicts {
+		when := ""
+		if c.Start != nil {
+			when = c.Start.DateTime + c.Start.Date
+		}
+		descs = append(descs, fmt.Sprintf("%q (id %s, start %s)", c.Summary, c.ID, when))
+	}
+	return fmt.Errorf("event not created: it conflicts with %d existing event(s): %s", len(conflicts), strings.Join(descs, ", "))
+}
+
 // isGmailTool returns true if the tool name is a Gmail tool.
 func isGmailTool(name string) bool {
 	switch name {
-	case "search-emails", "read-email", "send-email", "draft-email",
-		"modify-email", "delete-email", "list-email-labels":
+	case "search-emails", "read-email", "read-thread", "get-attachment", "send-email", "draft-email",
+		"list-drafts", "send-draft", "reply-email", "forward-email", "modify-email", "delete-email", "permanently-delete-email",
+		"archive-email", "mark-read", "mark-unread", "trash-email", "untrash-email", "batch-delete-emails", "batch-modify-emails", "list-email-labels",
+		"create-label", "update-label", "delete-label",
+		"snooze-email", "schedule-email", "list-scheduled-emails", "cancel-scheduled-email":
+		return true
+	}
+	return false
+}
+
+// isLocalTool reports whether a tool is answered by the server itself, without
+// a Google service, so it works before authentication.
+func isLocalTool(name string) bool {
+	switch name {
+	case "get-current-time", "list-timezones", "parse-event-metadata":
 		return true
 	}
 	return false
 }
 
+// dispatchLocalTool runs a tool for which isLocalTool holds.
+func dispatchLocalTool(opts Options, name string, args map[string]interface{}) (any, error) {
+	switch name {
+	case "get-current-time":
+		tz := argString(args, "timezone")
+		if tz == "" {
+			tz = opts.Timezone
+		}
+		return currentTime(time.Now(), tz)
+
+	case "list-timezones":
+		zones := knownTimezones()
+		if len(zones) == 0 {
+			return nil, fmt.Errorf("no timezone database found on this server")
+		}
+		return filterTimezones(zones, argString(args, "query")), nil
+
+	case "parse-event-metadata":
+		description, metadata := splitMetadataFooter(argString(args, "description"))
+		return map[string]any{"description": description, "metadata": metadata}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown local tool: %s", name)
+	}
+}
+
 // dispatchGmailTool routes a Gmail tool call to the appropriate GmailService method.
-func dispatchGmailTool(svc *GmailService, name string, args map[string]interface{}) (any, error) {
+func dispatchGmailTool(ctx context.Context, svc *GmailService, name string, args map[string]interface{}) (any, error) {
 	switch name {
 	case "search-emails":
-		return svc.SearchEmails(
+		maxResults := int64(argFloat(args, "max_results"))
+		emails, err := svc.SearchEmails(
+			ctx,
 			argString(args, "query"),
-			int64(argFloat(args, "max_results")),
+			maxResults,
 		)
+		if err != nil {
+			return nil, err
+		}
+		return withCapIndicator(emails, maxResults, svc.opts.MaxResultsCeiling), nil
 
 	case "read-email":
-		return svc.ReadEmail(argString(args, "message_id"))
+		return svc.ReadEmail(ctx, argString(args, "message_id"), argString(args, "format"), argBool(args, "mark_read", false))
+
+	case "read-thread":
+		return svc.ReadThread(ctx, argString(args, "thread_id"), argBool(args, "mark_read", false))
+
+	case "get-attachment":
+		return svc.GetAttachment(ctx, argString(args, "message_id"), argString(args, "attachment_id"))
 
 	case "send-email":
 		atts, err := argAttachments(args, "attachments")
@@ -497,11 +1525,13 @@ func dispatchGmailTool(svc *GmailService, name string, args map[string]interface
 			return nil, err
 		}
 		return svc.SendEmail(
+			ctx,
 			argString(args, "to"),
 			argString(args, "subject"),
 			argString(args, "body"),
 			argString(args, "cc"),
 			argString(args, "bcc"),
+			argString(args, "reply_to"),
 			argString(args, "thread_id"),
 			argString(args, "in_reply_to"),
 			atts,
@@ -513,95 +1543,542 @@ func dispatchGmailTool(svc *GmailService, name string, args map[string]interface
 			return nil, err
 		}
 		return svc.DraftEmail(
+			ctx,
 			argString(args, "to"),
 			argString(args, "subject"),
 			argString(args, "body"),
 			argString(args, "cc"),
 			argString(args, "bcc"),
+			argString(args, "reply_to"),
 			atts,
 		)
 
+	case "list-drafts":
+		maxResults := int64(argFloat(args, "max_results"))
+		drafts, err := svc.ListDrafts(ctx, argString(args, "query"), maxResults)
+		if err != nil {
+			return nil, err
+		}
+		return withCapIndicator(drafts, maxResults, svc.opts.MaxResultsCeiling), nil
+
+	case "send-draft":
+		return svc.SendDraft(ctx, argString(args, "draft_id"))
+
+	case "reply-email":
+		return svc.ReplyEmail(ctx, argString(args, "message_id"), argString(args, "body"), argBool(args, "reply_all", false))
+
+	case "forward-email":
+		return svc.ForwardEmail(ctx, argString(args, "message_id"), argString(args, "to"), argString(args, "body"))
+
 	case "modify-email":
 		return svc.ModifyEmail(
+			ctx,
 			argString(args, "message_id"),
 			argString(args, "add_labels"),
 			argString(args, "remove_labels"),
 		)
 
 	case "delete-email":
-		err := svc.DeleteEmail(argString(args, "message_id"))
+		err := svc.DeleteEmail(ctx, argString(args, "message_id"))
 		if err != nil {
 			return nil, err
 		}
 		return map[string]string{"status": "trashed", "message_id": argString(args, "message_id")}, nil
 
+	case "permanently-delete-email":
+		if !argBool(args, "confirm", false) {
+			return nil, fmt.Errorf("permanently-delete-email requires confirm=true; the email cannot be recovered")
+		}
+		if err := svc.PermanentlyDelete(ctx, argString(args, "message_id")); err != nil {
+			return nil, err
+		}
+		return map[string]string{"status": "deleted", "message_id": argString(args, "message_id")}, nil
+
+	case "archive-email":
+		return svc.ArchiveEmail(ctx, argString(args, "message_id"))
+
+	case "mark-read", "mark-unread":
+		return svc.MarkRead(ctx, argString(args, "message_id"), name == "mark-read")
+
+	case "trash-email":
+		return svc.TrashEmail(ctx, argString(args, "message_id"))
+
+	case "untrash-email":
+		return svc.UntrashEmail(ctx, argString(args, "message_id"))
+
+	case "batch-delete-emails":
+		if !argBool(args, "confirm", false) {
+			return nil, fmt.Errorf("batch-delete-emails requires confirm=true")
+		}
+		return svc.BatchTrashEmails(
+			ctx,
+			argString(args, "query"),
+			int64(argFloat(args, "max_messages")),
+		)
+
+	case "batch-modify-emails":
+		return svc.BatchModify(
+			ctx,
+			argString(args, "query"),
+			argString(args, "add_labels"),
+			argString(args, "remove_labels"),
+			argBool(args, "confirm", false),
+		)
+
 	case "list-email-labels":
-		return svc.ListLabels()
+		return svc.ListLabels(ctx)
+
+	case "create-label":
+		return svc.CreateLabel(
+			ctx,
+			argString(args, "name"),
+			argString(args, "label_list_visibility"),
+			argString(args, "message_list_visibility"),
+		)
+
+	case "update-label":
+		return svc.UpdateLabel(
+			ctx,
+			argString(args, "label_id"),
+			argString(args, "name"),
+			argString(args, "label_list_visibility"),
+			argString(args, "message_list_visibility"),
+		)
+
+	case "delete-label":
+		if err := svc.DeleteLabel(ctx, argString(args, "label_id")); err != nil {
+			return nil, err
+		}
+		return map[string]string{"status": "deleted", "label_id": argString(args, "label_id")}, nil
 
 	default:
 		return nil, fmt.Errorf("unknown gmail tool: %s", name)
 	}
 }
 
+// recentCalendarTools maps the mutating calendar tools to the argument
+// naming the calendar recorded for list-recent-calendars. move-event records
+// its destination, where the event now lives.
+var recentCalendarTools = map[string]string{
+	"create-event":          "calendar_id",
+	"quick-add-event":       "calendar_id",
+	"update-event":          "calendar_id",
+	"delete-event":          "calendar_id",
+	"move-event":            "destination_calendar_id",
+	"gcal-create-event-app": "calendar_id",
+	"gcal-delete-event-app": "calendar_id",
+}
+
+// recordRecentCalendar notes the calendar used by a successful tool call.
+// The list is only a hint, so failures are logged rather than returned.
+func recordRecentCalendar(database *DB, userEmail, name string, args map[string]interface{}) {
+	key, ok := recentCalendarTools[name]
+	if !ok {
+		return
+	}
+	calendarID := argString(args, key)
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+	if err := database.TouchRecentCalendar(userEmail, calendarID); err != nil {
+		slog.Warn("record recent calendar failed", "user", userEmail, "calendar_id", calendarID, "error", err)
+	}
+}
+
+// forgetDeletedCalendar drops a calendar deleted by delete-calendar from the
+// user's default and recent calendars. Like recordRecentCalendar, failures
+// are only logged.
+func forgetDeletedCalendar(database *DB, userEmail, name string, args map[string]interface{}) {
+	if name != "delete-calendar" || database == nil {
+		return
+	}
+	calendarID := argString(args, "calendar_id")
+	if err := database.ForgetCalendar(userEmail, calendarID); err != nil {
+		slog.Warn("forget deleted calendar failed", "user", userEmail, "calendar_id", calendarID, "error", err)
+	}
+}
+
+// isStatefulTool returns true if the tool keeps server-side state in the database.
+func isStatefulTool(name string) bool {
+	switch name {
+	case "list-recent-calendars", "set-default-calendar", "get-default-calendar",
+		"watch-calendar", "list-watches", "stop-watch", "snooze-email",
+		"schedule-email", "list-scheduled-emails", "cancel-scheduled-email":
+		return true
+	}
+	return false
+}
+
+// statefulServices lazily provides Google API services to stateful tools,
+// which only build the ones they need.
+type statefulServices struct {
+	gmail    func() (*GmailService, error)
+	calendar func() (*CalendarService, error)
+}
+
+// dispatchStatefulTool routes a tool call that reads or writes userEmail's
+// state in the database.
+func dispatchStatefulTool(ctx context.Context, database *DB, opts Options, userEmail, name string, args map[string]interface{}, services statefulServices) (any, error) {
+	switch name {
+	case "list-recent-calendars":
+		return database.ListRecentCalendars(userEmail)
+
+	case "set-default-calendar":
+		calendarID := argString(args, "calendar_id")
+		if calendarID == "" {
+			return nil, fmt.Errorf("calendar_id is required")
+		}
+		if err := database.SetDefaultCalendar(userEmail, calendarID); err != nil {
+			return nil, err
+		}
+		return map[string]string{"status": "updated", "calendar_id": calendarID}, nil
+
+	case "get-default-calendar":
+		calendarID, err := database.GetDefaultCalendar(userEmail)
+		if err != nil {
+			return nil, err
+		}
+		if calendarID == "" {
+			calendarID = "primary"
+		}
+		return map[string]string{"calendar_id": calendarID}, nil
+
+	case "watch-calendar":
+		svc, err := services.calendar()
+		if err != nil {
+			return nil, err
+		}
+		ttl := time.Duration(argFloat(args, "ttl_hours") * float64(time.Hour))
+		wc, err := svc.WatchEvents(ctx, argString(args, "calendar_id"), argString(args, "address"), ttl)
+		if err != nil {
+			return nil, err
+		}
+		if err := database.SaveWatchChannel(userEmail, *wc); err != nil {
+			// Without the record the channel could never be stopped.
+			if stopErr := svc.StopChannel(ctx, wc.ChannelID, wc.ResourceID); stopErr != nil {
+				slog.Warn("stop unrecorded watch channel failed", "user", userEmail, "channel_id", wc.ChannelID, "error", stopErr)
+			}
+			return nil, err
+		}
+		return wc, nil
+
+	case "list-watches":
+		return database.ListWatchChannels(userEmail)
+
+	case "stop-watch":
+		wc, err := database.GetWatchChannel(userEmail, argString(args, "channel_id"))
+		if err != nil {
+			return nil, err
+		}
+		// Google has already closed an expired channel.
+		if !wc.Expired {
+			svc, err := services.calendar()
+			if err != nil {
+				return nil, err
+			}
+			if err := svc.StopChannel(ctx, wc.ChannelID, wc.ResourceID); err != nil && !isNotFound(err) {
+				return nil, err
+			}
+		}
+		if err := database.DeleteWatchChannel(userEmail, wc.ChannelID); err != nil {
+			return nil, err
+		}
+		return map[string]string{"status": "stopped", "channel_id": wc.ChannelID}, nil
+
+	case "snooze-email":
+		messageID := argString(args, "message_id")
+		if messageID == "" {
+			return nil, fmt.Errorf("message_id is required")
+		}
+		until, err := time.Parse(time.RFC3339, argString(args, "until"))
+		if err != nil {
+			return nil, fmt.Errorf("until must be an RFC3339 timestamp: %w", err)
+		}
+		if !until.After(time.Now()) {
+			return nil, fmt.Errorf("until must be in the future")
+		}
+		svc, err := services.gmail()
+		if err != nil {
+			return nil, err
+		}
+		labelID, err := svc.SnoozeEmail(ctx, messageID)
+		if err != nil {
+			return nil, err
+		}
+		if err := database.SnoozeEmail(userEmail, messageID, labelID, until); err != nil {
+			// Without a record the email would never wake, so put it back.
+			if undoErr := svc.UnsnoozeEmail(ctx, messageID, labelID); undoErr != nil {
+				slog.Warn("unsnooze email failed", "user", userEmail, "message_id", messageID, "error", undoErr)
+			}
+			return nil, err
+		}
+		return map[string]string{"status": "snoozed", "message_id": messageID, "until": until.Format(time.RFC3339)}, nil
+
+	case "schedule-email":
+		sendAt, err := time.Parse(time.RFC3339, argString(args, "send_at"))
+		if err != nil {
+			return nil, fmt.Errorf("send_at must be an RFC3339 timestamp: %w", err)
+		}
+		if !sendAt.After(time.Now()) {
+			return nil, fmt.Errorf("send_at must be in the future")
+		}
+		replyTo := argString(args, "reply_to")
+		to, cc, bcc, warnings, err := prepareRecipients(argString(args, "to"), argString(args, "cc"), argString(args, "bcc"), replyTo)
+		if err != nil {
+			return nil, err
+		}
+		subject := argString(args, "subject")
+		atts, err := argAttachments(args, "attachments")
+		if err != nil {
+			return nil, err
+		}
+		if err := validateAttachmentLimits(atts, opts.MaxAttachments); err != nil {
+			return nil, err
+		}
+		raw := buildMIMEMessage(
+			to,
+			subject,
+			argString(args, "body"),
+			cc,
+			bcc,
+			replyTo,
+			argString(args, "in_reply_to"),
+			atts,
+		)
+		id, err := database.ScheduleEmail(ScheduledEmail{
+			UserEmail: userEmail,
+			To:        to,
+			Subject:   subject,
+			Raw:       raw,
+			ThreadID:  argString(args, "thread_id"),
+		}, sendAt)
+		if err != nil {
+			return nil, err
+		}
+		result := map[string]any{"status": "scheduled", "id": id, "send_at": sendAt.Format(time.RFC3339)}
+		if len(warnings) > 0 {
+			result["warnings"] = warnings
+		}
+		return result, nil
+
+	case "list-scheduled-emails":
+		return database.ListScheduledEmails(userEmail)
+
+	case "cancel-scheduled-email":
+		id := int64(argFloat(args, "id"))
+		if id <= 0 {
+			return nil, fmt.Errorf("id is required")
+		}
+		if err := database.CancelScheduledEmail(userEmail, id); err != nil {
+			return nil, err
+		}
+		return map[string]any{"status": "cancelled", "id": id}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown tool: %s", name)
+	}
+}
+
+// withDefaultCalendar returns args with calendar_id set to userEmail's default
+// calendar when the call does not name one. args itself is not modified.
+// delete-calendar must always name its calendar, so it never gets the default.
+func withDefaultCalendar(database *DB, userEmail, name string, args map[string]interface{}) map[string]interface{} {
+	if database == nil || name == "delete-calendar" || argString(args, "calendar_id") != "" {
+		return args
+	}
+	calendarID, err := database.GetDefaultCalendar(userEmail)
+	if err != nil {
+		slog.Warn("get default calendar failed", "user", userEmail, "error", err)
+		return args
+	}
+	if calendarID == "" {
+		return args
+	}
+	withDefault := make(map[string]interface{}, len(args)+1)
+	for k, v := range args {
+		withDefault[k] = v
+	}
+	withDefault["calendar_id"] = calendarID
+	return withDefault
+}
+
 // dispatchHTTPTool routes a tool call for the HTTP server (multi-user).
-// It creates the appropriate service from the token source.
-func dispatchHTTPTool(ctx context.Context, ts oauth2.TokenSource, name string, args map[string]interface{}) (any, error) {
+// It creates the appropriate service from the token source; calendar
+// services share caches, keyed by userEmail.
+func dispatchHTTPTool(ctx context.Context, ts oauth2.TokenSource, opts Options, caches calendarCaches, userEmail, name string, args map[string]interface{}) (any, error) {
 	if isGmailTool(name) {
-		svc, err := NewGmailService(ctx, ts)
+		svc, err := NewGmailService(ctx, ts, opts)
 		if err != nil {
 			return nil, fmt.Errorf("gmail service error: %w", err)
 		}
-		return dispatchGmailTool(svc, name, args)
+		return dispatchGmailTool(ctx, svc, name, args)
+	}
+	if isTasksTool(name) {
+		svc, err := NewTasksService(ctx, ts, opts)
+		if err != nil {
+			return nil, fmt.Errorf("tasks service error: %w", err)
+		}
+		return dispatchTasksTool(ctx, svc, name, args)
 	}
-	svc, err := NewCalendarService(ctx, ts)
+	svc, err := NewCalendarService(ctx, ts, opts)
 	if err != nil {
 		return nil, fmt.Errorf("calendar service error: %w", err)
 	}
-	return dispatchCalendarTool(svc, name, args)
+	svc.caches, svc.cacheKey = caches, userEmail
+	return dispatchCalendarTool(ctx, svc, name, args)
 }
 
-// dispatchTool routes a tool call for the stdio server (single-user).
+// dispatchTool routes a tool call for the stdio server (single-user). The
+// optional account argument picks which stored Google account serves it.
 func (s *Server) dispatchTool(ctx context.Context, name string, args map[string]interface{}) (any, error) {
+	account, err := s.callAccount(args)
+	if err != nil {
+		return nil, err
+	}
+	stateKey := accountStateKey(account)
+
 	// authenticate is special - doesn't need an existing service
 	if name == "authenticate" {
-		return s.handleAuthenticate(ctx)
+		return s.handleAuthenticate(ctx, account, argBool(args, "force", false))
+	}
+	if isLocalTool(name) {
+		return dispatchLocalTool(s.opts, name, args)
+	}
+	if isStatefulTool(name) {
+		return dispatchStatefulTool(ctx, s.database, s.opts, stateKey, name, args, statefulServices{
+			gmail: func() (*GmailService, error) {
+				svc, err := s.ensureGmailService(ctx, account)
+				if err != nil {
+					return nil, serviceUnavailableError("gmail", err)
+				}
+				return svc, nil
+			},
+			calendar: func() (*CalendarService, error) {
+				svc, err := s.ensureCalendarService(ctx, account)
+				if err != nil {
+					return nil, serviceUnavailableError("calendar", err)
+				}
+				return svc, nil
+			},
+		})
 	}
 
 	if isGmailTool(name) {
-		svc, err := s.ensureGmailService(ctx)
+		svc, err := s.ensureGmailService(ctx, account)
 		if err != nil {
-			return nil, fmt.Errorf("gmail service unavailable: %w\nUse the 'authenticate' tool first.", err)
+			return nil, serviceUnavailableError("gmail", err)
 		}
-		return dispatchGmailTool(svc, name, args)
+		return dispatchGmailTool(ctx, svc, name, args)
 	}
 
-	svc, err := s.ensureCalendarService(ctx)
+	if isTasksTool(name) {
+		svc, err := s.ensureTasksService(ctx, account)
+		if err != nil {
+			return nil, serviceUnavailableError("tasks", err)
+		}
+		return dispatchTasksTool(ctx, svc, name, args)
+	}
+
+	svc, err := s.ensureCalendarService(ctx, account)
 	if err != nil {
-		return nil, fmt.Errorf("calendar service unavailable: %w\nUse the 'authenticate' tool first.", err)
+		return nil, serviceUnavailableError("calendar", err)
+	}
+	args = withDefaultCalendar(s.database, stateKey, name, args)
+
+	result, err := dispatchCalendarTool(ctx, svc, name, args)
+	if err == nil {
+		recordRecentCalendar(s.database, stateKey, name, args)
+		forgetDeletedCalendar(s.database, stateKey, name, args)
+	}
+	return result, err
+}
+
+// callAccount returns the account a stdio tool call runs as: its account
+// argument, else the server's --account, else defaultAccount.
+func (s *Server) callAccount(args map[string]interface{}) (string, error) {
+	account := argString(args, "account")
+	if account == "" {
+		account = s.opts.Account
+	}
+	if account == "" {
+		return defaultAccount, nil
+	}
+	if err := validateAccountName(account); err != nil {
+		return "", err
+	}
+	return account, nil
+}
+
+// withAccountProperty adds the stdio mode account argument to a tool's schema.
+func withAccountProperty(t mcpTool) mcpTool {
+	props := make(map[string]property, len(t.InputSchema.Properties)+1)
+	for k, v := range t.InputSchema.Properties {
+		props[k] = v
 	}
+	props["account"] = property{
+		Type:        "string",
+		Description: "Named Google account to use, as signed in with 'mcp-gcal auth --account' (default: the server's --account, else \"default\")",
+	}
+	t.InputSchema.Properties = props
+	return t
+}
 
-	return dispatchCalendarTool(svc, name, args)
+// serviceUnavailableError explains why a service could not be initialized.
+// A missing credentials file is reported on its own, since running the
+// authenticate tool would fail for the same reason.
+func serviceUnavailableError(service string, err error) error {
+	var notFound *credentialsNotFoundError
+	if errors.As(err, &notFound) {
+		return fmt.Errorf("%s service unavailable: %w", service, err)
+	}
+	return fmt.Errorf("%s service unavailable: %w\nUse the 'authenticate' tool first.", service, err)
 }
 
-// handleAuthenticate performs the OAuth flow and stores the token (stdio mode).
-func (s *Server) handleAuthenticate(ctx context.Context) (any, error) {
-	config, err := loadOAuthConfig(s.oauthConfig.credentialsFile, oauthScopes)
+// handleAuthenticate performs the OAuth flow and stores the token of account
+// (stdio mode). If a valid token is already stored and force is false, no
+// browser is opened.
+func (s *Server) handleAuthenticate(ctx context.Context, account string, force bool) (any, error) {
+	config, fallback, err := s.loadOAuthConfigs()
 	if err != nil {
 		return nil, err
 	}
+	oauthFlow, accountEmail := s.oauthFlow, s.accountEmail
+	if oauthFlow == nil {
+		oauthFlow = runOAuthFlow
+	}
+	if accountEmail == nil {
+		accountEmail = primaryCalendarEmail
+	}
+
+	if !force {
+		if ts, err := getTokenSource(config, fallback, s.database, account); err == nil {
+			result := map[string]string{"status": "already_authenticated", "account": account}
+			if email, err := accountEmail(ctx, ts, s.opts.RequestTimeout); err == nil {
+				result["email"] = email
+			}
+			return result, nil
+		}
+	}
 
-	tok, err := runOAuthFlow(config)
+	tok, err := oauthFlow(config)
 	if err != nil {
 		return nil, fmt.Errorf("OAuth flow failed: %w", err)
 	}
 
-	if err := s.database.SaveToken(tok); err != nil {
+	if err := s.database.SaveToken(account, tok); err != nil {
 		return nil, fmt.Errorf("save token: %w", err)
 	}
 
 	// Reset cached services so next call uses new token
-	s.calendarService = nil
-	s.gmailService = nil
+	s.resetServices(account)
 
-	return map[string]string{"status": "authenticated"}, nil
+	// The single-user scopes omit userinfo.email, so the account is identified
+	// through the primary calendar. Failing to do so does not undo the login.
+	result := map[string]string{"status": "authenticated", "account": account}
+	if email, err := accountEmail(ctx, config.TokenSource(ctx, tok), s.opts.RequestTimeout); err == nil {
+		result["email"] = email
+	} else {
+		slog.Warn("could not determine authenticated account", "error", err)
+	}
+	return result, nil
 }
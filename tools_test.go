@@ -1,16 +1,28 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/gmail/v1"
 )
 
 func TestIsGmailTool(t *testing.T) {
 	t.Parallel()
 
 	gmailTools := []string{
-		"search-emails", "read-email", "send-email", "draft-email",
-		"modify-email", "delete-email", "list-email-labels",
+		"search-emails", "read-email", "read-thread", "get-attachment", "send-email", "draft-email",
+		"list-drafts", "send-draft", "reply-email", "forward-email", "modify-email", "delete-email", "permanently-delete-email",
+		"archive-email", "mark-read", "mark-unread", "trash-email", "untrash-email", "batch-delete-emails", "batch-modify-emails", "list-email-labels",
+		"create-label", "update-label", "delete-label",
+		"snooze-email", "schedule-email", "list-scheduled-emails", "cancel-scheduled-email",
 	}
 	for _, name := range gmailTools {
 		if !isGmailTool(name) {
@@ -85,11 +97,15 @@ func TestAllTools_ContainsExpectedTools(t *testing.T) {
 	}
 
 	expected := []string{
-		"authenticate", "list-calendars", "list-events", "get-event",
-		"search-events", "create-event", "update-event", "delete-event",
+		"authenticate", "list-calendars", "get-calendar", "get-calendar-settings", "create-calendar", "delete-calendar", "list-recent-calendars", "set-default-calendar", "get-default-calendar", "watch-calendar", "list-watches", "stop-watch", "list-events", "get-event", "get-events", "get-freebusy", "list-timezones", "list-colors", "get-current-time", "parse-event-metadata",
+		"search-events", "whats-changed", "create-event", "quick-add-event", "update-event", "delete-event", "move-event",
 		"respond-to-event", "show-calendar",
-		"search-emails", "read-email", "send-email", "draft-email",
-		"modify-email", "delete-email", "list-email-labels",
+		"search-emails", "read-email", "read-thread", "get-attachment", "send-email", "draft-email",
+		"list-drafts", "send-draft", "reply-email", "forward-email", "modify-email", "delete-email", "permanently-delete-email",
+		"archive-email", "mark-read", "mark-unread", "trash-email", "untrash-email", "batch-delete-emails", "batch-modify-emails", "list-email-labels",
+		"create-label", "update-label", "delete-label",
+		"snooze-email", "schedule-email", "list-scheduled-emails", "cancel-scheduled-email",
+		"list-tasks", "create-task",
 		"gcal-list-events-app", "gcal-create-event-app",
 		"gcal-delete-event-app", "gcal-get-event-app",
 	}
@@ -280,3 +296,953 @@ func TestArgAttachments_InvalidJSON(t *testing.T) {
 		t.Fatal("expected error for invalid JSON")
 	}
 }
+
+func TestDispatchGmailTool_BatchDeleteRequiresConfirm(t *testing.T) {
+	t.Parallel()
+
+	for _, args := range []map[string]interface{}{
+		{"query": "from:newsletter@example.com"},
+		{"query": "from:newsletter@example.com", "confirm": false},
+		{"query": "from:newsletter@example.com", "confirm": "true"},
+	} {
+		// The confirm check must happen before any Gmail API call, so a nil service is safe here.
+		if _, err := dispatchGmailTool(context.Background(), nil, "batch-delete-emails", args); err == nil {
+			t.Errorf("dispatchGmailTool(batch-delete-emails, %v) expected error", args)
+		}
+	}
+}
+
+func TestInputSchema_AdditionalPropertiesFalse(t *testing.T) {
+	t.Parallel()
+
+	for _, tool := range allTools() {
+		b, err := json.Marshal(tool.InputSchema)
+		if err != nil {
+			t.Fatalf("marshal %q schema: %v", tool.Name, err)
+		}
+		if !strings.Contains(string(b), `"additionalProperties":false`) {
+			t.Errorf("tool %q schema = %s, want additionalProperties false", tool.Name, b)
+		}
+	}
+
+	b, err := json.Marshal(inputSchema{Type: "object"})
+	if err != nil {
+		t.Fatalf("marshal schema: %v", err)
+	}
+	if strings.Contains(string(b), "additionalProperties") {
+		t.Errorf("unset AdditionalProperties should be omitted, got %s", b)
+	}
+}
+
+func TestClampMaxResults(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		maxResults int64
+		ceiling    int64
+		want       int64
+		wantCapped bool
+	}{
+		{"below ceiling", 50, 250, 50, false},
+		{"at ceiling", 250, 250, 250, false},
+		{"above ceiling", 2500, 250, 250, true},
+		{"no ceiling", 2500, 0, 2500, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, capped := clampMaxResults(tt.maxResults, tt.ceiling)
+			if got != tt.want || capped != tt.wantCapped {
+				t.Fatalf("clampMaxResults(%d, %d) = (%d, %v), want (%d, %v)",
+					tt.maxResults, tt.ceiling, got, capped, tt.want, tt.wantCapped)
+			}
+		})
+	}
+}
+
+func TestWithCapIndicator(t *testing.T) {
+	t.Parallel()
+
+	items := []string{"a", "b"}
+	got, meta := splitCapIndicator(withCapIndicator(items, 10, 250))
+	if !reflect.DeepEqual(got, items) || meta != nil {
+		t.Fatalf("uncapped result = %v, %v, want items unchanged and no _meta", got, meta)
+	}
+
+	// A capped result keeps its shape; the cap is only reported in _meta.
+	got, meta = splitCapIndicator(withCapIndicator(items, 1000, 250))
+	if !reflect.DeepEqual(got, items) {
+		t.Fatalf("capped result = %v, want items unchanged", got)
+	}
+	if meta["capped"] != true || meta["max_results"] != int64(250) {
+		t.Fatalf("capped result _meta = %v", meta)
+	}
+}
+
+func TestArgAttendees_CommaSeparated(t *testing.T) {
+	t.Parallel()
+
+	args := map[string]interface{}{
+		"attendees": "alice@example.com, bob@example.com,",
+	}
+
+	atts, err := argAttendees(args, "attendees")
+	if err != nil {
+		t.Fatalf("argAttendees error: %v", err)
+	}
+	want := []Attendee{{Email: "alice@example.com"}, {Email: "bob@example.com"}}
+	if !reflect.DeepEqual(atts, want) {
+		t.Fatalf("argAttendees = %+v, want %+v", atts, want)
+	}
+}
+
+func TestArgAttendees_OptionalSuffix(t *testing.T) {
+	t.Parallel()
+
+	args := map[string]interface{}{
+		"attendees": "alice@example.com, bob@example.com:optional",
+	}
+
+	atts, err := argAttendees(args, "attendees")
+	if err != nil {
+		t.Fatalf("argAttendees error: %v", err)
+	}
+	want := []Attendee{{Email: "alice@example.com"}, {Email: "bob@example.com", Optional: true}}
+	if !reflect.DeepEqual(atts, want) {
+		t.Fatalf("argAttendees = %+v, want %+v", atts, want)
+	}
+}
+
+func TestArgAttendees_JSONString(t *testing.T) {
+	t.Parallel()
+
+	args := map[string]interface{}{
+		"attendees": `[{"email":"alice@example.com","optional":true,"displayName":"Alice"}]`,
+	}
+
+	atts, err := argAttendees(args, "attendees")
+	if err != nil {
+		t.Fatalf("argAttendees error: %v", err)
+	}
+	if len(atts) != 1 {
+		t.Fatalf("got %d attendees, want 1", len(atts))
+	}
+	if atts[0].Email != "alice@example.com" {
+		t.Errorf("email = %q, want %q", atts[0].Email, "alice@example.com")
+	}
+	if !atts[0].Optional {
+		t.Error("optional = false, want true")
+	}
+	if atts[0].DisplayName != "Alice" {
+		t.Errorf("displayName = %q, want %q", atts[0].DisplayName, "Alice")
+	}
+}
+
+func TestArgAttendees_JSONArray(t *testing.T) {
+	t.Parallel()
+
+	// Simulate what JSON unmarshalling produces for a JSON array
+	var raw interface{}
+	_ = json.Unmarshal([]byte(`[{"email":"bob@example.com"},{"email":"carol@example.com","optional":true}]`), &raw)
+	args := map[string]interface{}{
+		"attendees": raw,
+	}
+
+	atts, err := argAttendees(args, "attendees")
+	if err != nil {
+		t.Fatalf("argAttendees error: %v", err)
+	}
+	if len(atts) != 2 {
+		t.Fatalf("got %d attendees, want 2", len(atts))
+	}
+	if atts[0].Email != "bob@example.com" || atts[0].Optional {
+		t.Errorf("attendees[0] = %+v", atts[0])
+	}
+	if atts[1].Email != "carol@example.com" || !atts[1].Optional {
+		t.Errorf("attendees[1] = %+v", atts[1])
+	}
+}
+
+func TestArgAttendees_MissingAndEmpty(t *testing.T) {
+	t.Parallel()
+
+	atts, err := argAttendees(map[string]interface{}{}, "attendees")
+	if err != nil || atts != nil {
+		t.Fatalf("argAttendees(missing) = (%v, %v), want (nil, nil)", atts, err)
+	}
+
+	atts, err = argAttendees(map[string]interface{}{"attendees": ""}, "attendees")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if atts == nil || len(atts) != 0 {
+		t.Fatalf("argAttendees(empty) = %v, want empty non-nil slice", atts)
+	}
+}
+
+func TestArgAttendees_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{`[{"email":""}]`, `[not json`} {
+		if _, err := argAttendees(map[string]interface{}{"attendees": v}, "attendees"); err == nil {
+			t.Errorf("argAttendees(%q) expected error", v)
+		}
+	}
+}
+
+func TestArgOptionalBool(t *testing.T) {
+	t.Parallel()
+
+	args := map[string]interface{}{"yes": true, "no": false, "bad": "true"}
+
+	if got, err := argOptionalBool(args, "missing"); got != nil || err != nil {
+		t.Fatalf("argOptionalBool(missing) = %v, %v, want nil, nil", got, err)
+	}
+	if got, err := argOptionalBool(args, "yes"); err != nil || got == nil || !*got {
+		t.Fatalf("argOptionalBool(yes) = %v, %v, want true", got, err)
+	}
+	if got, err := argOptionalBool(args, "no"); err != nil || got == nil || *got {
+		t.Fatalf("argOptionalBool(no) = %v, %v, want false", got, err)
+	}
+	if _, err := argOptionalBool(args, "bad"); err == nil {
+		t.Fatal("argOptionalBool(bad) error = nil, want type error")
+	}
+}
+
+func TestMarshalToolResult_Fields(t *testing.T) {
+	t.Parallel()
+
+	result := map[string]any{
+		"events": []eventJSON{
+			{ID: "e1", Summary: "Standup", Location: "Room 1", Status: "confirmed"},
+			{ID: "e2", Summary: "Lunch"},
+		},
+		"nextPageToken": "p2",
+	}
+	got, err := marshalToolResult("list-events", map[string]interface{}{"fields": "id, summary"}, result, false)
+	if err != nil {
+		t.Fatalf("marshalToolResult() error = %v", err)
+	}
+	want := `{"events":[{"id":"e1","summary":"Standup"},{"id":"e2","summary":"Lunch"}],"nextPageToken":"p2"}`
+	if string(got) != want {
+		t.Fatalf("marshalToolResult() = %s, want %s", got, want)
+	}
+}
+
+func TestMarshalToolResult_FieldsMixedList(t *testing.T) {
+	t.Parallel()
+
+	// An item without any requested field must not fall back to the full item.
+	result := eventPageJSON{Events: []eventJSON{
+		{ID: "e1", Summary: "Standup", Location: "Room 1"},
+		{ID: "e2", Summary: "Lunch", Description: "secret long text"},
+	}, NextPageToken: "next"}
+	got, err := marshalToolResult("list-events", map[string]interface{}{"fields": "location"}, result, false)
+	if err != nil {
+		t.Fatalf("marshalToolResult() error = %v", err)
+	}
+	want := `{"events":[{"location":"Room 1"},{}],"nextPageToken":"next"}`
+	if string(got) != want {
+		t.Fatalf("marshalToolResult() = %s, want %s", got, want)
+	}
+
+	got, err = marshalToolResult("search-emails", map[string]interface{}{"fields": "subject"}, []map[string]any{{"id": "m1", "subject": "Hi"}, {"id": "m2", "snippet": "private"}}, false)
+	if err != nil {
+		t.Fatalf("marshalToolResult() error = %v", err)
+	}
+	if want := `[{"subject":"Hi"},{}]`; string(got) != want {
+		t.Fatalf("marshalToolResult() = %s, want %s", got, want)
+	}
+}
+
+func TestMarshalToolResult_Compact(t *testing.T) {
+	t.Parallel()
+
+	result := []emailJSON{{ID: "m1", Subject: "Hi", From: "a@example.com"}}
+	got, err := marshalToolResult("search-emails", nil, result, true)
+	if err != nil {
+		t.Fatalf("marshalToolResult() error = %v", err)
+	}
+	want := `[{"from":"a@example.com","id":"m1","subject":"Hi"}]`
+	if string(got) != want {
+		t.Fatalf("marshalToolResult() = %s, want %s", got, want)
+	}
+}
+
+func TestMarshalToolResult_UIToolsUnchanged(t *testing.T) {
+	t.Parallel()
+
+	result := []eventJSON{{ID: "e1", Summary: "Standup"}}
+	full, _ := json.Marshal(result)
+	for _, name := range []string{"show-calendar", "gcal-list-events-app"} {
+		got, err := marshalToolResult(name, map[string]interface{}{"fields": "id"}, result, true)
+		if err != nil {
+			t.Fatalf("marshalToolResult(%s) error = %v", name, err)
+		}
+		if string(got) != string(full) {
+			t.Fatalf("marshalToolResult(%s) = %s, want unchanged %s", name, got, full)
+		}
+	}
+}
+
+func TestDispatchCalendarTool_ListEvents(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/calendars/team@example.com/events", &calendar.Events{Items: []*calendar.Event{
+		{Id: "e1", Summary: "Standup", Start: &calendar.EventDateTime{DateTime: "2025-03-10T10:00:00Z"}},
+		{Id: "e2", Summary: "Retro"},
+	}, NextPageToken: "page3"})
+
+	result, err := dispatchCalendarTool(context.Background(), fake.calendarService(), "list-events", map[string]interface{}{
+		"calendar_id": "team@example.com",
+		"time_min":    "2025-03-10T00:00:00Z",
+		"time_max":    "2025-03-17T00:00:00Z",
+		"order_by":    "startTime",
+		"page_token":  "page2",
+	})
+	if err != nil {
+		t.Fatalf("dispatchCalendarTool(list-events) error = %v", err)
+	}
+	page, ok := result.(*eventPageJSON)
+	if !ok || len(page.Events) != 2 || page.Events[0].Summary != "Standup" || page.Events[0].Start.DateTime != "2025-03-10T10:00:00Z" {
+		t.Fatalf("list-events result = %#v", result)
+	}
+	if page.NextPageToken != "page3" {
+		t.Fatalf("nextPageToken = %q, want page3", page.NextPageToken)
+	}
+
+	req, _ := fake.request("GET", "/calendars/team@example.com/events")
+	if req.Query.Get("timeMin") != "2025-03-10T00:00:00Z" || req.Query.Get("singleEvents") != "true" ||
+		req.Query.Get("orderBy") != "startTime" || req.Query.Get("pageToken") != "page2" {
+		t.Fatalf("list-events query = %v", req.Query)
+	}
+
+	// The calendar UI still gets a bare array.
+	result, err = dispatchCalendarTool(context.Background(), fake.calendarService(), "gcal-list-events-app", map[string]interface{}{
+		"calendar_id": "team@example.com",
+	})
+	if err != nil {
+		t.Fatalf("dispatchCalendarTool(gcal-list-events-app) error = %v", err)
+	}
+	if events, ok := result.([]eventJSON); !ok || len(events) != 2 {
+		t.Fatalf("gcal-list-events-app result = %#v", result)
+	}
+}
+
+func TestDispatchCalendarTool_CreateEvent(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.handle("POST", "/calendars/primary/events", func(r *http.Request, body []byte) any {
+		var ev calendar.Event
+		_ = json.Unmarshal(body, &ev)
+		ev.Id = "new1"
+		return &ev
+	})
+
+	result, err := dispatchCalendarTool(context.Background(), fake.calendarService(), "create-event", map[string]interface{}{
+		"summary":   "Planning",
+		"start":     "2025-03-10T10:00:00Z",
+		"end":       "2025-03-10T11:00:00Z",
+		"attendees": "a@example.com, b@example.com",
+		"location":  "Room 2",
+	})
+	if err != nil {
+		t.Fatalf("dispatchCalendarTool(create-event) error = %v", err)
+	}
+	ev, ok := result.(*eventJSON)
+	if !ok || ev.ID != "new1" || ev.Summary != "Planning" {
+		t.Fatalf("create-event result = %#v", result)
+	}
+
+	req, _ := fake.request("POST", "/calendars/primary/events")
+	var sent calendar.Event
+	fake.decodeBody(req, &sent)
+	if sent.Location != "Room 2" || len(sent.Attendees) != 2 || sent.Attendees[1].Email != "b@example.com" {
+		t.Fatalf("create-event request = %+v", sent)
+	}
+	if sent.Start == nil || sent.Start.DateTime != "2025-03-10T10:00:00Z" {
+		t.Fatalf("create-event start = %+v", sent.Start)
+	}
+}
+
+func TestDispatchCalendarTool_UpdateEvent(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/calendars/primary/events/e1", &calendar.Event{
+		Id:          "e1",
+		Summary:     "Old title",
+		Description: "Keep me",
+		Start:       &calendar.EventDateTime{DateTime: "2025-03-10T10:00:00Z", TimeZone: "Asia/Tokyo"},
+		End:         &calendar.EventDateTime{DateTime: "2025-03-10T11:00:00Z", TimeZone: "Asia/Tokyo"},
+	})
+	fake.handle("PUT", "/calendars/primary/events/e1", func(r *http.Request, body []byte) any {
+		var ev calendar.Event
+		_ = json.Unmarshal(body, &ev)
+		return &ev
+	})
+
+	result, err := dispatchCalendarTool(context.Background(), fake.calendarService(), "update-event", map[string]interface{}{
+		"event_id": "e1",
+		"summary":  "New title",
+		"end":      "2025-03-10T12:00:00+09:00",
+	})
+	if err != nil {
+		t.Fatalf("dispatchCalendarTool(update-event) error = %v", err)
+	}
+	ev, ok := result.(*eventJSON)
+	if !ok || ev.Summary != "New title" || ev.Description != "Keep me" {
+		t.Fatalf("update-event result = %#v", result)
+	}
+	if ev.End == nil || ev.End.DateTime != "2025-03-10T12:00:00+09:00" || ev.End.TimeZone != "Asia/Tokyo" {
+		t.Fatalf("update-event end = %+v, want new time with original timezone", ev.End)
+	}
+}
+
+func TestDispatchCalendarTool_DeleteEvent(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("DELETE", "/calendars/primary/events/e1", nil)
+
+	result, err := dispatchCalendarTool(context.Background(), fake.calendarService(), "delete-event", map[string]interface{}{
+		"event_id": "e1",
+	})
+	if err != nil {
+		t.Fatalf("dispatchCalendarTool(delete-event) error = %v", err)
+	}
+	want := map[string]string{"status": "deleted", "event_id": "e1"}
+	if !reflect.DeepEqual(result, want) {
+		t.Fatalf("delete-event result = %#v, want %#v", result, want)
+	}
+	if _, ok := fake.request("DELETE", "/calendars/primary/events/e1"); !ok {
+		t.Fatal("delete-event did not call the Events.Delete endpoint")
+	}
+}
+
+func TestDispatchCalendarTool_APIError(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.fail("GET", "/calendars/primary/events/missing", http.StatusNotFound, "Not Found")
+
+	_, err := dispatchCalendarTool(context.Background(), fake.calendarService(), "get-event", map[string]interface{}{"event_id": "missing"})
+	if err == nil || !strings.Contains(err.Error(), "get event") || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("dispatchCalendarTool(get-event) error = %v, want wrapped 404", err)
+	}
+}
+
+func TestDispatchGmailTool_SearchAndModify(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/gmail/v1/users/me/messages", &gmail.ListMessagesResponse{
+		Messages: []*gmail.Message{{Id: "m1"}},
+	})
+	fake.respond("GET", "/gmail/v1/users/me/messages/m1", &gmail.Message{
+		Id:       "m1",
+		ThreadId: "t1",
+		Snippet:  "Lunch?",
+		Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+			{Name: "Subject", Value: "Lunch"},
+			{Name: "From", Value: "alice@example.com"},
+		}},
+	})
+	fake.handle("POST", "/gmail/v1/users/me/messages/m1/modify", func(r *http.Request, body []byte) any {
+		return &gmail.Message{Id: "m1", ThreadId: "t1", LabelIds: []string{"STARRED"}}
+	})
+	gs := fake.gmailService()
+
+	result, err := dispatchGmailTool(context.Background(), gs, "search-emails", map[string]interface{}{"query": "from:alice"})
+	if err != nil {
+		t.Fatalf("dispatchGmailTool(search-emails) error = %v", err)
+	}
+	emails, ok := result.([]emailJSON)
+	if !ok || len(emails) != 1 || emails[0].Subject != "Lunch" || emails[0].From != "alice@example.com" {
+		t.Fatalf("search-emails result = %#v", result)
+	}
+	if req, _ := fake.request("GET", "/gmail/v1/users/me/messages"); req.Query.Get("q") != "from:alice" {
+		t.Fatalf("search-emails query = %v", req.Query)
+	}
+
+	if _, err := dispatchGmailTool(context.Background(), gs, "modify-email", map[string]interface{}{
+		"message_id":    "m1",
+		"add_labels":    "STARRED",
+		"remove_labels": "UNREAD, INBOX",
+	}); err != nil {
+		t.Fatalf("dispatchGmailTool(modify-email) error = %v", err)
+	}
+	req, _ := fake.request("POST", "/gmail/v1/users/me/messages/m1/modify")
+	var modify gmail.ModifyMessageRequest
+	fake.decodeBody(req, &modify)
+	if !reflect.DeepEqual(modify.AddLabelIds, []string{"STARRED"}) || !reflect.DeepEqual(modify.RemoveLabelIds, []string{"UNREAD", "INBOX"}) {
+		t.Fatalf("modify-email request = %+v", modify)
+	}
+}
+
+func TestDispatchGmailTool_SendEmailAttachments(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("POST", "/gmail/v1/users/me/messages/send", &gmail.Message{Id: "m1", ThreadId: "t1"})
+	fake.respond("GET", "/gmail/v1/users/me/messages/m1", &gmail.Message{Id: "m1", ThreadId: "t1"})
+	gs := fake.gmailService()
+
+	if _, err := dispatchGmailTool(context.Background(), gs, "send-email", map[string]interface{}{
+		"to":          "bob@example.com",
+		"subject":     "Report",
+		"body":        "Attached.",
+		"attachments": `[{"filename":"report.csv","mime_type":"text/csv","data":"aWQsbmFtZQ=="}]`,
+	}); err != nil {
+		t.Fatalf("dispatchGmailTool(send-email) error = %v", err)
+	}
+	req, _ := fake.request("POST", "/gmail/v1/users/me/messages/send")
+	var msg gmail.Message
+	fake.decodeBody(req, &msg)
+	raw, _ := base64.RawURLEncoding.DecodeString(msg.Raw)
+	if !strings.Contains(string(raw), `filename="report.csv"`) || !strings.Contains(string(raw), "aWQsbmFtZQ==") {
+		t.Fatalf("raw message has no attachment:\n%s", raw)
+	}
+
+	// Invalid attachments are rejected before anything is sent.
+	_, err := dispatchGmailTool(context.Background(), newFakeGoogleAPI(t).gmailService(), "send-email", map[string]interface{}{
+		"to":          "bob@example.com",
+		"attachments": `[{"filename":"report.csv","data":"aWQsbmFtZQ=="}]`,
+	})
+	if err == nil || !strings.Contains(err.Error(), "mime_type is required") {
+		t.Fatalf("dispatchGmailTool(send-email, no mime_type) error = %v", err)
+	}
+}
+
+func TestDispatchStatefulTool_SnoozeEmail(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/gmail/v1/users/me/labels", &gmail.ListLabelsResponse{
+		Labels: []*gmail.Label{{Id: "INBOX", Name: "INBOX"}},
+	})
+	fake.respond("POST", "/gmail/v1/users/me/labels", &gmail.Label{Id: "Label_9", Name: snoozeLabelName})
+	fake.respond("POST", "/gmail/v1/users/me/messages/m1/modify", &gmail.Message{Id: "m1"})
+	services := statefulServices{gmail: func() (*GmailService, error) { return fake.gmailService(), nil }}
+
+	for _, args := range []map[string]interface{}{
+		{"message_id": "m1", "until": "tomorrow"},
+		{"message_id": "m1", "until": "2000-01-01T00:00:00Z"},
+		{"until": "2999-01-01T00:00:00Z"},
+	} {
+		if _, err := dispatchStatefulTool(context.Background(), d, Options{}, "a@example.com", "snooze-email", args, services); err == nil {
+			t.Errorf("dispatchStatefulTool(snooze-email, %v) expected error", args)
+		}
+	}
+
+	_, err = dispatchStatefulTool(context.Background(), d, Options{}, "a@example.com", "snooze-email", map[string]interface{}{
+		"message_id": "m1",
+		"until":      "2999-01-01T00:00:00Z",
+	}, services)
+	if err != nil {
+		t.Fatalf("dispatchStatefulTool(snooze-email) error = %v", err)
+	}
+
+	req, ok := fake.request("POST", "/gmail/v1/users/me/labels")
+	if !ok {
+		t.Fatalf("snooze label was not created")
+	}
+	var label gmail.Label
+	fake.decodeBody(req, &label)
+	if label.Name != snoozeLabelName {
+		t.Fatalf("created label = %q, want %q", label.Name, snoozeLabelName)
+	}
+
+	req, _ = fake.request("POST", "/gmail/v1/users/me/messages/m1/modify")
+	var modify gmail.ModifyMessageRequest
+	fake.decodeBody(req, &modify)
+	if !reflect.DeepEqual(modify.AddLabelIds, []string{"Label_9"}) || !reflect.DeepEqual(modify.RemoveLabelIds, []string{"INBOX"}) {
+		t.Fatalf("snooze modify request = %+v", modify)
+	}
+
+	due, err := d.DueSnoozedEmails(time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC), httpStateKeys)
+	if err != nil {
+		t.Fatalf("DueSnoozedEmails() error = %v", err)
+	}
+	if len(due) != 1 || due[0].UserEmail != "a@example.com" || due[0].MessageID != "m1" || due[0].LabelID != "Label_9" {
+		t.Fatalf("snoozed emails = %+v", due)
+	}
+}
+
+func TestDispatchStatefulTool_ScheduleAndCancelEmail(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	noGmail := statefulServices{gmail: func() (*GmailService, error) {
+		t.Fatalf("scheduling must not call Gmail")
+		return nil, nil
+	}}
+	dispatch := func(name string, args map[string]interface{}) (any, error) {
+		return dispatchStatefulTool(context.Background(), d, Options{}, "a@example.com", name, args, noGmail)
+	}
+
+	if _, err := dispatch("schedule-email", map[string]interface{}{
+		"to": "bob@example.com", "subject": "Hi", "body": "hello", "send_at": "2000-01-01T00:00:00Z",
+	}); err == nil {
+		t.Fatalf("schedule-email with past send_at expected error")
+	}
+
+	result, err := dispatch("schedule-email", map[string]interface{}{
+		"to": "bob@example.com", "cc": "Bob@example.com", "subject": "Hi", "body": "hello", "send_at": "2999-01-01T09:00:00+09:00",
+	})
+	if err != nil {
+		t.Fatalf("dispatchStatefulTool(schedule-email) error = %v", err)
+	}
+	id := result.(map[string]any)["id"].(int64)
+	if warnings, _ := result.(map[string]any)["warnings"].([]string); len(warnings) != 1 {
+		t.Fatalf("schedule-email warnings = %v, want one for the duplicate cc", warnings)
+	}
+	stored, err := d.DueScheduledEmails(time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC), httpStateKeys)
+	if err != nil || len(stored) != 1 || contains(string(stored[0].Raw), "Cc:") {
+		t.Fatalf("stored scheduled emails = %+v (err %v), want the duplicate cc dropped", stored, err)
+	}
+
+	result, err = dispatch("list-scheduled-emails", nil)
+	if err != nil {
+		t.Fatalf("dispatchStatefulTool(list-scheduled-emails) error = %v", err)
+	}
+	list := result.([]ScheduledEmail)
+	if len(list) != 1 || list[0].ID != id || list[0].To != "bob@example.com" || list[0].SendAt != "2999-01-01T00:00:00Z" || list[0].Status != scheduledPending {
+		t.Fatalf("scheduled emails = %+v", list)
+	}
+
+	if _, err := dispatch("cancel-scheduled-email", map[string]interface{}{"id": float64(id)}); err != nil {
+		t.Fatalf("dispatchStatefulTool(cancel-scheduled-email) error = %v", err)
+	}
+	if _, err := dispatch("cancel-scheduled-email", map[string]interface{}{"id": float64(id)}); err == nil {
+		t.Fatalf("cancelling an already cancelled email expected error")
+	}
+	if list, _ := d.ListScheduledEmails("a@example.com"); len(list) != 0 {
+		t.Fatalf("scheduled emails after cancel = %+v, want none", list)
+	}
+}
+
+func TestDispatchCalendarTool_CreateEventConflicts(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/calendars/primary/events", &calendar.Events{Items: []*calendar.Event{{
+		Id:      "busy",
+		Summary: "Standup",
+		Start:   &calendar.EventDateTime{DateTime: "2025-03-01T10:00:00Z"},
+		End:     &calendar.EventDateTime{DateTime: "2025-03-01T10:30:00Z"},
+	}}})
+	fake.respond("POST", "/calendars/primary/events", &calendar.Event{Id: "new", Summary: "1:1"})
+	cs := fake.calendarService()
+
+	args := map[string]interface{}{
+		"summary":          "1:1",
+		"start":            "2025-03-01T10:15:00Z",
+		"end":              "2025-03-01T11:00:00Z",
+		"fail_on_conflict": true,
+	}
+	if _, err := dispatchCalendarTool(context.Background(), cs, "create-event", args); err == nil || !strings.Contains(err.Error(), "Standup") {
+		t.Fatalf("create-event with fail_on_conflict error = %v, want conflict naming Standup", err)
+	}
+	if _, ok := fake.request("POST", "/calendars/primary/events"); ok {
+		t.Fatalf("event was created despite fail_on_conflict")
+	}
+	req, _ := fake.request("GET", "/calendars/primary/events")
+	if req.Query.Get("timeMin") != "2025-03-01T10:15:00Z" || req.Query.Get("timeMax") != "2025-03-01T11:00:00Z" {
+		t.Fatalf("conflict query = %v, want the new event's window", req.Query)
+	}
+
+	delete(args, "fail_on_conflict")
+	args["check_conflicts"] = true
+	result, err := dispatchCalendarTool(context.Background(), cs, "create-event", args)
+	if err != nil {
+		t.Fatalf("create-event with check_conflicts error = %v", err)
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("marshal result: %v", err)
+	}
+	var got struct {
+		ID        string      `json:"id"`
+		Conflicts []eventJSON `json:"conflicts"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unmarshal result: %v", err)
+	}
+	if got.ID != "new" || len(got.Conflicts) != 1 || got.Conflicts[0].ID != "busy" {
+		t.Fatalf("create-event result = %s, want created event with one conflict", b)
+	}
+}
+
+func TestRecordRecentCalendar(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	recordRecentCalendar(d, "a@example.com", "list-events", map[string]interface{}{"calendar_id": "read-only"})
+	recordRecentCalendar(d, "a@example.com", "create-event", map[string]interface{}{})
+	recordRecentCalendar(d, "a@example.com", "move-event", map[string]interface{}{
+		"calendar_id": "primary", "destination_calendar_id": "team@group.calendar.google.com",
+	})
+
+	recent, err := d.ListRecentCalendars("a@example.com")
+	if err != nil {
+		t.Fatalf("ListRecentCalendars() error = %v", err)
+	}
+	if len(recent) != 2 || recent[0].CalendarID != "team@group.calendar.google.com" || recent[1].CalendarID != "primary" {
+		t.Fatalf("recent calendars = %+v, want move-event's destination then primary", recent)
+	}
+}
+
+func TestForgetDeletedCalendar(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	const team = "team@group.calendar.google.com"
+	if err := d.SetDefaultCalendar("a@example.com", team); err != nil {
+		t.Fatalf("SetDefaultCalendar() error = %v", err)
+	}
+	if err := d.SetDefaultCalendar("b@example.com", team); err != nil {
+		t.Fatalf("SetDefaultCalendar() error = %v", err)
+	}
+	recordRecentCalendar(d, "a@example.com", "create-event", map[string]interface{}{"calendar_id": team})
+	recordRecentCalendar(d, "a@example.com", "create-event", map[string]interface{}{})
+
+	forgetDeletedCalendar(d, "a@example.com", "delete-calendar", map[string]interface{}{"calendar_id": team})
+
+	if id, err := d.GetDefaultCalendar("a@example.com"); err != nil || id != "" {
+		t.Fatalf("GetDefaultCalendar() = %q, %v, want the deleted default cleared", id, err)
+	}
+	if id, _ := d.GetDefaultCalendar("b@example.com"); id != team {
+		t.Fatalf("other user's default = %q, want it kept", id)
+	}
+	recent, err := d.ListRecentCalendars("a@example.com")
+	if err != nil || len(recent) != 1 || recent[0].CalendarID != "primary" {
+		t.Fatalf("recent calendars = %+v, %v, want only primary", recent, err)
+	}
+}
+
+func TestWithDefaultCalendar(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	args := map[string]interface{}{"summary": "Sync"}
+	if got := withDefaultCalendar(d, "a@example.com", "list-events", args); argString(got, "calendar_id") != "" {
+		t.Fatalf("calendar_id without a default = %v, want unset", got["calendar_id"])
+	}
+
+	set, err := dispatchStatefulTool(context.Background(), d, Options{}, "a@example.com", "set-default-calendar",
+		map[string]interface{}{"calendar_id": "team@group.calendar.google.com"}, statefulServices{})
+	if err != nil {
+		t.Fatalf("dispatchStatefulTool(set-default-calendar) error = %v", err)
+	}
+	if set.(map[string]string)["calendar_id"] != "team@group.calendar.google.com" {
+		t.Fatalf("set-default-calendar = %v, want the calendar_id", set)
+	}
+	got := withDefaultCalendar(d, "a@example.com", "list-events", args)
+	if got["calendar_id"] != "team@group.calendar.google.com" || got["summary"] != "Sync" {
+		t.Fatalf("args with default = %v", got)
+	}
+	if _, ok := args["calendar_id"]; ok {
+		t.Fatalf("withDefaultCalendar modified the caller's args")
+	}
+	if got := withDefaultCalendar(d, "a@example.com", "delete-calendar", args); argString(got, "calendar_id") != "" {
+		t.Fatalf("delete-calendar calendar_id = %v, want unset", got["calendar_id"])
+	}
+
+	explicit := map[string]interface{}{"calendar_id": "primary"}
+	if got := withDefaultCalendar(d, "a@example.com", "list-events", explicit); got["calendar_id"] != "primary" {
+		t.Fatalf("explicit calendar_id = %v, want primary kept", got["calendar_id"])
+	}
+	if got := withDefaultCalendar(d, "b@example.com", "list-events", args); argString(got, "calendar_id") != "" {
+		t.Fatalf("other user's calendar_id = %v, want unset", got["calendar_id"])
+	}
+
+	result, err := dispatchStatefulTool(context.Background(), d, Options{}, "b@example.com", "get-default-calendar", nil, statefulServices{})
+	if err != nil {
+		t.Fatalf("dispatchStatefulTool(get-default-calendar) error = %v", err)
+	}
+	if result.(map[string]string)["calendar_id"] != "primary" {
+		t.Fatalf("get-default-calendar without a default = %v, want primary", result)
+	}
+}
+
+func TestDispatchStatefulTool_Watches(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	for _, wc := range []WatchChannel{
+		{ChannelID: "active", ResourceID: "r1", CalendarID: "primary", Expiration: time.Now().Add(time.Hour)},
+		{ChannelID: "expired", ResourceID: "r2", CalendarID: "team", Expiration: time.Now().Add(-time.Hour)},
+	} {
+		if err := d.SaveWatchChannel("a@example.com", wc); err != nil {
+			t.Fatalf("SaveWatchChannel() error = %v", err)
+		}
+	}
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("POST", "/channels/stop", nil)
+	services := statefulServices{calendar: func() (*CalendarService, error) { return fake.calendarService(), nil }}
+	dispatch := func(name string, args map[string]interface{}) (any, error) {
+		return dispatchStatefulTool(context.Background(), d, Options{}, "a@example.com", name, args, services)
+	}
+
+	result, err := dispatch("list-watches", nil)
+	if err != nil {
+		t.Fatalf("dispatchStatefulTool(list-watches) error = %v", err)
+	}
+	watches := result.([]WatchChannel)
+	if len(watches) != 2 || watches[0].ChannelID != "expired" || !watches[0].Expired || watches[1].Expired {
+		t.Fatalf("watches = %+v, want expired flagged first", watches)
+	}
+
+	result, err = dispatch("stop-watch", map[string]interface{}{"channel_id": "active"})
+	if err != nil {
+		t.Fatalf("dispatchStatefulTool(stop-watch) error = %v", err)
+	}
+	if result.(map[string]string)["channel_id"] != "active" {
+		t.Fatalf("stop-watch = %v, want the channel_id", result)
+	}
+	req, ok := fake.request("POST", "/channels/stop")
+	if !ok {
+		t.Fatalf("Channels.Stop was not called")
+	}
+	var ch calendar.Channel
+	fake.decodeBody(req, &ch)
+	if ch.Id != "active" || ch.ResourceId != "r1" {
+		t.Fatalf("stop request = %+v", ch)
+	}
+
+	// An expired channel is only forgotten; Google has already closed it.
+	if _, err := dispatch("stop-watch", map[string]interface{}{"channel_id": "expired"}); err != nil {
+		t.Fatalf("dispatchStatefulTool(stop-watch expired) error = %v", err)
+	}
+	if _, err := dispatch("stop-watch", map[string]interface{}{"channel_id": "unknown"}); err == nil {
+		t.Fatalf("stop-watch on an unknown channel expected error")
+	}
+	if watches, _ := d.ListWatchChannels("a@example.com"); len(watches) != 0 {
+		t.Fatalf("watches after stop = %+v, want none", watches)
+	}
+
+	// watch-calendar opens a channel and records it for list-watches.
+	expiration := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	fake.handle("POST", "/calendars/team/events/watch", func(_ *http.Request, body []byte) any {
+		var ch calendar.Channel
+		_ = json.Unmarshal(body, &ch)
+		ch.ResourceId = "r3"
+		ch.Expiration = expiration.UnixMilli()
+		return &ch
+	})
+	if _, err := dispatch("watch-calendar", map[string]interface{}{"calendar_id": "team", "address": "http://example.com/hook"}); err == nil {
+		t.Fatalf("watch-calendar with a non-https address expected error")
+	}
+	result, err = dispatch("watch-calendar", map[string]interface{}{"calendar_id": "team", "address": "https://example.com/hook", "ttl_hours": float64(24)})
+	if err != nil {
+		t.Fatalf("dispatchStatefulTool(watch-calendar) error = %v", err)
+	}
+	req, _ = fake.request("POST", "/calendars/team/events/watch")
+	var opened calendar.Channel
+	fake.decodeBody(req, &opened)
+	if opened.Type != "web_hook" || opened.Address != "https://example.com/hook" || opened.Id == "" || opened.Params["ttl"] != "86400" {
+		t.Fatalf("watch request = %+v", opened)
+	}
+	watches, _ = d.ListWatchChannels("a@example.com")
+	if len(watches) != 1 || watches[0].ChannelID != opened.Id || watches[0].ResourceID != "r3" ||
+		watches[0].CalendarID != "team" || !watches[0].Expiration.Equal(expiration) || result.(*WatchChannel).ChannelID != opened.Id {
+		t.Fatalf("watches after watch-calendar = %+v, want the new channel", watches)
+	}
+}
+
+func TestHandleToolsCall_CappedListEventsReportsMeta(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/calendars/primary/events", &calendar.Events{Items: []*calendar.Event{{Id: "e1", Summary: "Standup"}}, NextPageToken: "p2"})
+	cs := fake.calendarService()
+	cs.opts.MaxResultsCeiling = 10
+	s := &Server{services: map[string]*accountServices{defaultAccount: {calendar: cs}}}
+
+	resp := s.handleMessage(context.Background(),
+		[]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"list-events","arguments":{"max_results":50}}}`))
+	result, ok := resp.Result.(*callToolResult)
+	if !ok || result.IsError {
+		t.Fatalf("list-events result = %+v", resp.Result)
+	}
+	want := `{"events":[{"id":"e1","summary":"Standup"}],"nextPageToken":"p2"}`
+	if result.Content[0].Text != want {
+		t.Fatalf("list-events output = %s, want %s", result.Content[0].Text, want)
+	}
+	if result.Meta["capped"] != true || result.Meta["max_results"] != int64(10) {
+		t.Fatalf("list-events _meta = %v, want the cap", result.Meta)
+	}
+	if req, ok := fake.request("GET", "/calendars/primary/events"); !ok || req.Query.Get("maxResults") != "10" {
+		t.Fatalf("list request = %+v, want maxResults clamped to 10", req)
+	}
+}
+
+func TestHandleToolsCall_CappedUIResultKeepsShape(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeGoogleAPI(t)
+	fake.respond("GET", "/calendars/primary/events", &calendar.Events{Items: []*calendar.Event{{Id: "e1", Summary: "Standup"}}})
+	cs := fake.calendarService()
+	cs.opts.MaxResultsCeiling = 10
+	s := &Server{services: map[string]*accountServices{defaultAccount: {calendar: cs}}}
+
+	resp := s.handleMessage(context.Background(),
+		[]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"show-calendar","arguments":{"max_results":50}}}`))
+	result, ok := resp.Result.(*callToolResult)
+	if !ok || result.IsError {
+		t.Fatalf("show-calendar result = %+v", resp.Result)
+	}
+	var events []eventJSON
+	if err := json.Unmarshal([]byte(result.Content[0].Text), &events); err != nil || len(events) != 1 {
+		t.Fatalf("show-calendar output = %s, want a bare array of events (%v)", result.Content[0].Text, err)
+	}
+	if result.Meta["capped"] != true || result.Meta["max_results"] != int64(10) || result.Meta["ui"] == nil {
+		t.Fatalf("show-calendar _meta = %v, want the cap and the UI resource", result.Meta)
+	}
+}
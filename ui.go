@@ -8,7 +8,10 @@ import (
 	"html"
 	"html/template"
 	"net/url"
+	"os"
 	"strings"
+
+	"golang.org/x/text/language"
 )
 
 //go:embed templates/calendar.html
@@ -36,18 +39,64 @@ func buildToolMeta(tool mcpTool) map[string]interface{} {
 }
 
 // buildResultMeta creates _meta object for a tool call result with output data (used in tools/call).
-func buildResultMeta(tool mcpTool, output string) map[string]interface{} {
+// A non-empty locale is carried in the resource URI for the UI to format dates with.
+func buildResultMeta(tool mcpTool, output, locale string) map[string]interface{} {
 	if !tool.hasUI() {
 		return nil
 	}
 	encodedOutput := base64.URLEncoding.EncodeToString([]byte(output))
+	uri := fmt.Sprintf("%s?data=%s", uiResourceURI(tool.Name), encodedOutput)
+	if locale != "" {
+		uri += "&locale=" + url.QueryEscape(locale)
+	}
 	return map[string]interface{}{
 		"ui": map[string]interface{}{
-			"resourceUri": fmt.Sprintf("%s?data=%s", uiResourceURI(tool.Name), encodedOutput),
+			"resourceUri": uri,
 		},
 	}
 }
 
+// normalizeLocale validates a BCP 47 language tag (e.g. "ja-JP") and returns
+// its canonical form. POSIX-style names such as "en_GB.UTF-8" are accepted.
+func normalizeLocale(locale string) (string, error) {
+	locale, _, _ = strings.Cut(locale, ".")
+	locale, _, _ = strings.Cut(locale, "@")
+	tag, err := language.Parse(strings.ReplaceAll(locale, "_", "-"))
+	if err != nil {
+		return "", fmt.Errorf("invalid locale %q: %w", locale, err)
+	}
+	return tag.String(), nil
+}
+
+// systemLocale returns the locale from the LC_ALL, LC_TIME or LANG
+// environment variables, or "" if unset or C/POSIX.
+func systemLocale() string {
+	for _, key := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		v := os.Getenv(key)
+		if v == "" {
+			continue
+		}
+		if v == "C" || v == "POSIX" || strings.HasPrefix(v, "C.") {
+			return ""
+		}
+		locale, err := normalizeLocale(v)
+		if err != nil {
+			return ""
+		}
+		return locale
+	}
+	return ""
+}
+
+// callLocale returns the locale for a tool call: a valid per-call locale
+// argument, otherwise the server default.
+func callLocale(args map[string]interface{}, fallback string) string {
+	if locale, err := normalizeLocale(argString(args, "locale")); err == nil {
+		return locale
+	}
+	return fallback
+}
+
 // templateData is passed to UI templates.
 type templateData struct {
 	Output     string
@@ -56,10 +105,12 @@ type templateData struct {
 	JSONPretty string
 	IsJSON     bool
 	SessionID  string
+	// Locale is the BCP 47 tag used to format dates; "" means the browser's.
+	Locale string
 }
 
 // generateUIHTML generates HTML for a tool's UI from its embedded template and encoded output data.
-func generateUIHTML(tool mcpTool, encodedData string, sessionID string) (string, error) {
+func generateUIHTML(tool mcpTool, encodedData, sessionID, locale string) (string, error) {
 	data, err := base64.URLEncoding.DecodeString(encodedData)
 	if err != nil {
 		return "", fmt.Errorf("failed to decode data: %w", err)
@@ -110,6 +161,7 @@ func generateUIHTML(tool mcpTool, encodedData string, sessionID string) (string,
 		Output:    output,
 		Lines:     strings.Split(output, "\n"),
 		SessionID: sessionID,
+		Locale:    locale,
 	}
 
 	var jsonData interface{}
@@ -156,3 +208,17 @@ func parseUIResourceURI(uri string) (*mcpTool, string, error) {
 	data := u.Query().Get("data")
 	return tool, data, nil
 }
+
+// uiResourceLocale returns the locale carried in a ui:// resource URI by
+// buildResultMeta, or fallback if there is none.
+func uiResourceLocale(uri, fallback string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fallback
+	}
+	locale, err := normalizeLocale(u.Query().Get("locale"))
+	if err != nil {
+		return fallback
+	}
+	return locale
+}
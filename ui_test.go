@@ -63,7 +63,7 @@ func TestBuildResultMeta_WithUI(t *testing.T) {
 		uiTemplate: "templates/calendar.html",
 	}
 	output := `[{"id":"1","summary":"Meeting"}]`
-	meta := buildResultMeta(tool, output)
+	meta := buildResultMeta(tool, output, "")
 	if meta == nil {
 		t.Fatal("buildResultMeta() returned nil")
 	}
@@ -96,7 +96,7 @@ func TestBuildResultMeta_WithoutUI(t *testing.T) {
 	t.Parallel()
 
 	tool := mcpTool{Name: "list-events"}
-	meta := buildResultMeta(tool, `{"result":"ok"}`)
+	meta := buildResultMeta(tool, `{"result":"ok"}`, "")
 	if meta != nil {
 		t.Fatalf("buildResultMeta() = %v, want nil for non-UI tool", meta)
 	}
@@ -197,7 +197,7 @@ func TestGenerateUIHTML(t *testing.T) {
 	jsonData := `[{"id":"1","summary":"Test Event","start":{"dateTime":"2024-01-01T10:00:00Z"}}]`
 	encodedData := base64.URLEncoding.EncodeToString([]byte(jsonData))
 
-	html, err := generateUIHTML(tool, encodedData, "session-123")
+	html, err := generateUIHTML(tool, encodedData, "session-123", "")
 	if err != nil {
 		t.Fatalf("generateUIHTML() error = %v", err)
 	}
@@ -218,8 +218,61 @@ func TestGenerateUIHTML_UnknownTemplate(t *testing.T) {
 	}
 	encodedData := base64.URLEncoding.EncodeToString([]byte("{}"))
 
-	_, err := generateUIHTML(tool, encodedData, "")
+	_, err := generateUIHTML(tool, encodedData, "", "")
 	if err == nil {
 		t.Fatal("expected error for unknown template")
 	}
 }
+
+func TestNormalizeLocale(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"en-GB", "en-GB", false},
+		{"ja_JP.UTF-8", "ja-JP", false},
+		{"de_DE@euro", "de-DE", false},
+		{"", "", true},
+		{"not a locale", "", true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeLocale(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("normalizeLocale(%q) = %q, %v, want %q (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestUILocale_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tool := mcpTool{Name: "show-calendar", uiTemplate: "templates/calendar.html"}
+	locale := callLocale(map[string]interface{}{"locale": "en_GB"}, "ja-JP")
+	meta := buildResultMeta(tool, `[]`, locale)
+	uri := meta["ui"].(map[string]interface{})["resourceUri"].(string)
+
+	if got := uiResourceLocale(uri, "ja-JP"); got != "en-GB" {
+		t.Fatalf("uiResourceLocale() = %q, want en-GB", got)
+	}
+	if got := callLocale(map[string]interface{}{"locale": "???"}, "ja-JP"); got != "ja-JP" {
+		t.Fatalf("callLocale() with invalid locale = %q, want server default", got)
+	}
+	if got := uiResourceLocale("ui://show-calendar/result?data=W10=", "ja-JP"); got != "ja-JP" {
+		t.Fatalf("uiResourceLocale() without locale = %q, want server default", got)
+	}
+
+	_, data, err := parseUIResourceURI(uri)
+	if err != nil {
+		t.Fatalf("parseUIResourceURI() error = %v", err)
+	}
+	html, err := generateUIHTML(tool, data, "", "en-GB")
+	if err != nil {
+		t.Fatalf("generateUIHTML() error = %v", err)
+	}
+	if !strings.Contains(html, `const locale = "en-GB" || [];`) {
+		t.Fatalf("generated HTML does not pass the locale to the script")
+	}
+}